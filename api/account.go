@@ -0,0 +1,67 @@
+// api/account.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"nft-auction-backend/internal/service"
+)
+
+type AccountHandler struct {
+	portfolio *service.PortfolioService
+	tokens    *service.TokenService
+}
+
+func NewAccountHandler(portfolioService *service.PortfolioService, tokenService *service.TokenService) *AccountHandler {
+	return &AccountHandler{
+		portfolio: portfolioService,
+		tokens:    tokenService,
+	}
+}
+
+// GetPortfolio 钱包资产：持有的NFT、托管在拍卖中的NFT、进行中的出价、赢得的拍卖
+// ?owned_limit= 持有NFT最多返回的数量（默认100，最大500）
+func (h *AccountHandler) GetPortfolio(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的地址",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("owned_limit", "100"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	portfolio, err := h.portfolio.GetPortfolio(ctx, common.HexToAddress(address).Hex(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取资产失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 金额按支付币种精度展示
+	h.tokens.DecorateAuctions(ctx, portfolio.Won)
+	for i := range portfolio.Escrowed {
+		h.tokens.DecorateAuction(ctx, &portfolio.Escrowed[i].Auction)
+	}
+	for i := range portfolio.ActiveBids {
+		h.tokens.DecorateAuction(ctx, &portfolio.ActiveBids[i].Auction)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    portfolio,
+	})
+}
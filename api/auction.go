@@ -4,8 +4,10 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 
 	"nft-auction-backend/internal/model"
@@ -14,11 +16,13 @@ import (
 
 type AuctionHandler struct {
 	service *service.AuctionService
+	tokens  *service.TokenService
 }
 
-func NewAuctionHandler(auctionService *service.AuctionService) *AuctionHandler {
+func NewAuctionHandler(auctionService *service.AuctionService, tokenService *service.TokenService) *AuctionHandler {
 	return &AuctionHandler{
 		service: auctionService,
+		tokens:  tokenService,
 	}
 }
 
@@ -29,8 +33,12 @@ func (h *AuctionHandler) GetAuctions(c *gin.Context) {
 	// 获取分页参数
 	pageStr := c.DefaultQuery("page", "1")
 	pageSizeStr := c.DefaultQuery("page_size", "20")
-	status := c.Query("status") // 按状态过滤：active, ended, all
-	seller := c.Query("seller") // 按卖家过滤
+	status := c.Query("status")                // 按状态过滤：active, ended, all
+	seller := c.Query("seller")                // 按卖家过滤
+	currency := c.Query("currency")            // 按支付币种过滤：eth, erc20
+	paymentToken := c.Query("payment_token")   // 按ERC20代币地址过滤
+	nftContract := c.Query("contract")         // 按NFT合约过滤
+	sortBy := c.DefaultQuery("sort", "recent") // recent: 最新创建, rarity: NFT稀有度排名
 
 	page, err := strconv.Atoi(pageStr)
 	if err != nil || page < 1 {
@@ -46,7 +54,8 @@ func (h *AuctionHandler) GetAuctions(c *gin.Context) {
 	var auctions []model.Auction
 	var total int64
 
-	query := h.service.DB.Model(&model.Auction{})
+	// 默认不返回被链重组移除的拍卖
+	query := h.service.DB.Model(&model.Auction{}).Where("chain_state <> ?", model.ChainStateOrphaned)
 
 	// 状态过滤
 	if status != "" && status != "all" {
@@ -64,10 +73,49 @@ func (h *AuctionHandler) GetAuctions(c *gin.Context) {
 		query = query.Where("seller = ?", seller)
 	}
 
+	// 支付币种过滤
+	switch currency {
+	case "eth":
+		query = query.Where("use_erc20 = ?", false)
+	case "erc20":
+		query = query.Where("use_erc20 = ?", true)
+	}
+	if paymentToken != "" {
+		query = query.Where("LOWER(payment_token) = ?", strings.ToLower(paymentToken))
+	}
+
+	// NFT合约与属性过滤（?trait[Background]=Blue）
+	if nftContract != "" {
+		if !common.IsHexAddress(nftContract) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的合约地址",
+			})
+			return
+		}
+		query = query.Where("nft_contract = ?", common.HexToAddress(nftContract).Hex())
+	}
+	query = service.ApplyTraitFilter(query, "auctions.nft_contract", "auctions.token_id", traitQuery(c))
+
+	order := "created_at DESC"
+	switch sortBy {
+	case "recent":
+	case "rarity":
+		// 排名只在同一合集内有意义，建议与 ?contract= 一起使用；未计算稀有度的排在最后
+		order = "COALESCE(NULLIF((SELECT m.rarity_rank FROM token_metadata m WHERE m.contract_address = auctions.nft_contract AND m.token_id = auctions.token_id), 0), 2147483647) ASC, created_at DESC"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的排序方式: " + sortBy,
+		})
+		return
+	}
+
 	query.Count(&total)
 
 	offset := (page - 1) * pageSize
-	query.Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&auctions)
+	query.Offset(offset).Limit(pageSize).Order(order).Find(&auctions)
+	h.tokens.DecorateAuctions(c.Request.Context(), auctions)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -95,6 +143,30 @@ func (h *AuctionHandler) GetActiveAuctions(c *gin.Context) {
 		})
 		return
 	}
+	h.tokens.DecorateAuctions(ctx, auctions)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"auctions": auctions,
+			"count":    len(auctions),
+		},
+	})
+}
+
+// GetExpiredAuctions 获取已到期、等待结算的拍卖
+func (h *AuctionHandler) GetExpiredAuctions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	auctions, err := h.service.GetExpiredAuctions(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取待结算拍卖失败: " + err.Error(),
+		})
+		return
+	}
+	h.tokens.DecorateAuctions(ctx, auctions)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -133,6 +205,8 @@ func (h *AuctionHandler) GetAuction(c *gin.Context) {
 			}
 		}
 
+		h.tokens.DecorateAuction(ctx, auction)
+
 		// 获取出价历史
 		bids, _, err := h.service.GetAuctionBids(ctx, auction.AuctionID, 1, 20)
 		if err != nil {
@@ -146,6 +220,7 @@ func (h *AuctionHandler) GetAuction(c *gin.Context) {
 			})
 			return
 		}
+		h.tokens.DecorateBids(ctx, auction.PaymentToken, bids)
 
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -192,6 +267,8 @@ func (h *AuctionHandler) CheckAuctionStatus(c *gin.Context) {
 		return
 	}
 
+	h.tokens.DecorateAuction(ctx, auction)
+
 	// 计算剩余时间
 	var timeRemaining uint64
 	if auction.EndTime > uint64(time.Now().Unix()) {
@@ -214,6 +291,13 @@ func (h *AuctionHandler) CheckAuctionStatus(c *gin.Context) {
 			"time_remaining": timeRemaining,
 			"nft_contract":   auction.NFTContract,
 			"token_id":       auction.TokenID,
+			"use_erc20":      auction.UseERC20,
+			"payment_token":  auction.PaymentToken,
+			"payment_symbol": auction.PaymentSymbol,
+			"decimals":       auction.PaymentDecimals,
+
+			"starting_price_formatted": auction.StartingPriceFormatted,
+			"highest_bid_formatted":    auction.HighestBidFormatted,
 		},
 	}
 
@@ -257,10 +341,29 @@ func (h *AuctionHandler) GetAuctionBids(c *gin.Context) {
 		return
 	}
 
+	// 被顶替出价的退款（合约不发事件，由出价链推导）
+	refunds, err := h.service.GetBidRefunds(ctx, auctionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取退款记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 出价金额按拍卖的支付币种换算
+	paymentToken := ""
+	if auction, err := h.service.GetAuctionByAuctionID(ctx, auctionID); err == nil {
+		paymentToken = auction.PaymentToken
+	}
+	h.tokens.DecorateBids(ctx, paymentToken, bids)
+	h.tokens.DecorateRefunds(ctx, paymentToken, refunds)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
-			"bids": bids,
+			"bids":    bids,
+			"refunds": refunds,
 			"pagination": gin.H{
 				"page":       page,
 				"page_size":  pageSize,
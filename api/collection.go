@@ -0,0 +1,188 @@
+// api/collection.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"nft-auction-backend/internal/model"
+	"nft-auction-backend/internal/service"
+)
+
+type CollectionHandler struct {
+	service *service.CollectionService
+}
+
+func NewCollectionHandler(collectionService *service.CollectionService) *CollectionHandler {
+	return &CollectionHandler{
+		service: collectionService,
+	}
+}
+
+// GetCollection 获取当前NFT合约的状态与持有者统计
+func (h *CollectionHandler) GetCollection(c *gin.Context) {
+	h.respondStats(c, h.service.GetContractAddress())
+}
+
+// GetCollectionByAddress 按合约地址获取状态与持有者统计（主合约或已注册的第三方合集）
+// 供应量、剩余供应量、铸造价格、铸造开关、持有者数量、持有分布
+func (h *CollectionHandler) GetCollectionByAddress(c *gin.Context) {
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的合约地址",
+		})
+		return
+	}
+	h.respondStats(c, common.HexToAddress(address))
+}
+
+func (h *CollectionHandler) respondStats(c *gin.Context, contractAddr common.Address) {
+	top, _ := strconv.Atoi(c.DefaultQuery("top", "10"))
+	if top < 1 || top > 100 {
+		top = 10
+	}
+
+	stats, err := h.service.GetCollectionStats(c.Request.Context(), contractAddr, top)
+	if errors.Is(err, service.ErrCollectionNotRegistered) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "未索引的合约: " + contractAddr.Hex(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取合约状态失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    stats,
+	})
+}
+
+// ListCollections 列出合集注册表
+func (h *CollectionHandler) ListCollections(c *gin.Context) {
+	collections, err := h.service.ListCollections(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取合集列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"collections": collections,
+			"count":       len(collections),
+		},
+	})
+}
+
+// RegisterCollection 管理员注册第三方合集（ERC-165 检查通过后开始索引）
+func (h *CollectionHandler) RegisterCollection(c *gin.Context) {
+	var req struct {
+		Address   string `json:"address" binding:"required"`
+		FromBlock uint64 `json:"from_block"` // 开始索引的区块，0 表示从最新区块开始
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !common.IsHexAddress(req.Address) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的合约地址",
+		})
+		return
+	}
+
+	collection, err := h.service.Register(c.Request.Context(),
+		common.HexToAddress(req.Address), model.CollectionSourceAdmin, req.FromBlock)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if collection != nil && !collection.Supported {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "注册合集失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    collection,
+	})
+}
+
+// GetWithdrawals 分页查询铸造收入提取记录
+func (h *CollectionHandler) GetWithdrawals(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	withdrawals, total, err := h.service.GetWithdrawals(c.Request.Context(), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取提取记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"withdrawals": withdrawals,
+			"pagination": gin.H{
+				"page":       page,
+				"page_size":  pageSize,
+				"total":      total,
+				"total_page": (total + int64(pageSize) - 1) / int64(pageSize),
+			},
+		},
+	})
+}
+
+// GetOperatorApprovals 查询当前有效的全量授权（可按 owner 过滤）
+func (h *CollectionHandler) GetOperatorApprovals(c *gin.Context) {
+	owner := c.Query("owner")
+	if owner != "" && !common.IsHexAddress(owner) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的地址",
+		})
+		return
+	}
+
+	approvals, err := h.service.GetOperatorApprovals(c.Request.Context(), owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取全量授权失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"approvals": approvals,
+			"count":     len(approvals),
+		},
+	})
+}
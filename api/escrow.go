@@ -0,0 +1,180 @@
+// api/escrow.go
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+
+	"nft-auction-backend/internal/model"
+	"nft-auction-backend/internal/service"
+)
+
+type EscrowHandler struct {
+	service *service.EscrowService
+}
+
+func NewEscrowHandler(escrowService *service.EscrowService) *EscrowHandler {
+	return &EscrowHandler{service: escrowService}
+}
+
+// GetBalances 账户按币种的流水余额（:account 为地址或 escrow）
+func (h *EscrowHandler) GetBalances(c *gin.Context) {
+	account := c.Param("account")
+	if account != model.LedgerEscrowAccount {
+		if !common.IsHexAddress(account) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的账户: 只能是地址或 escrow",
+			})
+			return
+		}
+		account = common.HexToAddress(account).Hex()
+	}
+
+	balances, err := h.service.GetBalances(c.Request.Context(), account)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"account":  account,
+			"balances": balances,
+		},
+	})
+}
+
+// GetLedger 资金流水（?account= ?auction_id= ?kind= 分页）
+func (h *EscrowHandler) GetLedger(c *gin.Context) {
+	query := service.LedgerQuery{
+		Account: c.Query("account"),
+		Kind:    c.Query("kind"),
+	}
+	auctionID, err := uintQuery(c, "auction_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	query.AuctionID = auctionID
+	query.Page, query.PageSize = pageQuery(c)
+
+	entries, total, err := h.service.GetLedger(c.Request.Context(), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"entries": entries,
+			"pagination": gin.H{
+				"page":       query.Page,
+				"page_size":  query.PageSize,
+				"total":      total,
+				"total_page": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+			},
+		},
+	})
+}
+
+// reconciliationNote 对账结果说明（随结果返回）
+const reconciliationNote = "管理员提取（withdrawETH / withdrawERC20）不发事件，只有通过 POST /api/escrow/withdrawals 登记后才计入流水；" +
+	"未登记的提取会让对应币种显示为 mismatch（实际余额少于应有余额）。" +
+	"unavailable 表示节点没有对账区块的状态（非归档节点），不算不一致"
+
+// GetReconciliations 最近的托管对账结果（?limit= 默认50，最大500）
+func (h *EscrowHandler) GetReconciliations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	results, err := h.service.GetReconciliations(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    results,
+		"note":    reconciliationNote,
+	})
+}
+
+// Reconcile 立即执行一次托管对账
+func (h *EscrowHandler) Reconcile(c *gin.Context) {
+	results, err := h.service.Reconcile(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "对账失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    results,
+		"note":    reconciliationNote,
+	})
+}
+
+// RecordWithdrawal 按交易哈希登记管理员提取 body: {"tx_hash": "0x..."}
+func (h *EscrowHandler) RecordWithdrawal(c *gin.Context) {
+	var req struct {
+		TxHash string `json:"tx_hash" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	if len(common.FromHex(req.TxHash)) != common.HashLength {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的交易哈希",
+		})
+		return
+	}
+
+	entry, err := h.service.RecordWithdrawal(c.Request.Context(), common.HexToHash(req.TxHash))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrNotWithdrawal) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "登记提取失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    entry,
+	})
+}
@@ -0,0 +1,83 @@
+// api/image.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/service"
+)
+
+type ImageHandler struct {
+	service    *service.ImageService
+	nftService *service.NFTService
+}
+
+func NewImageHandler(imageService *service.ImageService, nftService *service.NFTService) *ImageHandler {
+	return &ImageHandler{
+		service:    imageService,
+		nftService: nftService,
+	}
+}
+
+// GetImage 返回本地缓存的NFT图片（?size=128 缩略图，?size=original 或不传为原图）
+func (h *ImageHandler) GetImage(c *gin.Context) {
+	contractAddr, ok := contractQuery(c, h.nftService.GetContractAddress())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的合约地址",
+		})
+		return
+	}
+
+	size := 0
+	if sizeStr := c.DefaultQuery("size", "original"); sizeStr != "original" {
+		var err error
+		if size, err = strconv.Atoi(sizeStr); err != nil || size <= 0 {
+			size = -1
+		}
+	}
+
+	path, img, err := h.service.ImageFile(c.Request.Context(), contractAddr, c.Param("id"), size)
+	switch {
+	case errors.Is(err, service.ErrInvalidImageSize):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的图片尺寸",
+			"sizes":   h.service.Sizes(),
+		})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "图片不存在",
+		})
+		return
+	case errors.Is(err, service.ErrImageNotCached):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":    false,
+			"error":      "图片尚未缓存",
+			"status":     img.Status,
+			"last_error": img.LastError,
+			"source_uri": img.SourceURI,
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取图片失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 文件内容由哈希和尺寸决定；NFT的图片可能更换，所以不设置 immutable
+	c.Header("ETag", fmt.Sprintf(`"%s-%d"`, img.ContentHash, size))
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.File(path)
+}
@@ -0,0 +1,77 @@
+// api/keeper.go
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"nft-auction-backend/internal/service"
+)
+
+type KeeperHandler struct {
+	service *service.KeeperService
+}
+
+func NewKeeperHandler(keeperService *service.KeeperService) *KeeperHandler {
+	return &KeeperHandler{
+		service: keeperService,
+	}
+}
+
+// GetAttempts 查询自动结算尝试记录（可按 auction_id 过滤）
+func (h *KeeperHandler) GetAttempts(c *gin.Context) {
+	if h.service == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "自动结算未启用",
+		})
+		return
+	}
+
+	var auctionID *uint64
+	if idStr := c.Query("auction_id"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的拍卖ID",
+			})
+			return
+		}
+		auctionID = &id
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	attempts, total, err := h.service.GetAttempts(c.Request.Context(), auctionID, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取结算记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"keeper":   h.service.Address().Hex(),
+			"attempts": attempts,
+			"pagination": gin.H{
+				"page":       page,
+				"page_size":  pageSize,
+				"total":      total,
+				"total_page": (total + int64(pageSize) - 1) / int64(pageSize),
+			},
+		},
+	})
+}
@@ -0,0 +1,121 @@
+// api/metadata.go
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/service"
+)
+
+type MetadataHandler struct {
+	service    *service.MetadataService
+	nftService *service.NFTService
+}
+
+func NewMetadataHandler(metadataService *service.MetadataService, nftService *service.NFTService) *MetadataHandler {
+	return &MetadataHandler{
+		service:    metadataService,
+		nftService: nftService,
+	}
+}
+
+// GetMetadata 获取NFT元数据（名称、描述、图片、属性）与抓取状态
+func (h *MetadataHandler) GetMetadata(c *gin.Context) {
+	contractAddr, ok := contractQuery(c, h.nftService.GetContractAddress())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的合约地址",
+		})
+		return
+	}
+
+	metadata, err := h.service.GetMetadata(c.Request.Context(), contractAddr, c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "元数据不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取元数据失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    metadata,
+	})
+}
+
+// RetryMetadata 手动重新抓取元数据（包括已超过重试次数的）
+func (h *MetadataHandler) RetryMetadata(c *gin.Context) {
+	contractAddr, ok := contractQuery(c, h.nftService.GetContractAddress())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的合约地址",
+		})
+		return
+	}
+
+	err := h.service.Retry(c.Request.Context(), contractAddr, c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "元数据不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "重新抓取失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已加入抓取队列",
+	})
+}
+
+// GetTraits 合集的属性统计（各属性值的数量与占比）
+func (h *MetadataHandler) GetTraits(c *gin.Context) {
+	address := c.Param("address")
+	if !common.IsHexAddress(address) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的合约地址",
+		})
+		return
+	}
+
+	traits, total, err := h.service.TraitCounts(c.Request.Context(), common.HexToAddress(address).Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取属性统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"contract_address": common.HexToAddress(address).Hex(),
+			"token_count":      total,
+			"traits":           traits,
+		},
+	})
+}
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"nft-auction-backend/internal/service"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gin-gonic/gin"
 )
 
@@ -29,8 +32,14 @@ func (h *NFTHandler) GetNFTInfo(c *gin.Context) {
 		return
 	}
 
-	// 调用服务层获取NFT信息
-	contractAddr := h.service.GetContractAddress().Hex()
+	// 调用服务层获取NFT信息（?contract= 查询第三方合集，默认主合约）
+	contractAddr, ok := contractQuery(c, h.service.GetContractAddress())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid contract address",
+		})
+		return
+	}
 
 	info, err := h.service.GetNFT(contractAddr, tokenID)
 	if err != nil {
@@ -58,6 +67,99 @@ func (h *NFTHandler) GetNFTInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, info)
 }
 
+// ListNFTs NFT列表（分页、排序、过滤）
+//
+//	?contract=      合约地址，默认主合约，all 表示所有已索引合约
+//	?owner=         实际所有者地址（包括托管在拍卖中的NFT）
+//	?holder=        链上持有者地址
+//	?minted_from= / ?minted_to=  铸造区块范围
+//	?in_auction=true|false       是否在进行中的拍卖里
+//	?trait[Background]=Blue      属性过滤
+//	?sort=token_id|rarity|recent|minted  ?order=asc|desc
+func (h *NFTHandler) ListNFTs(c *gin.Context) {
+	query := service.NFTQuery{
+		Traits: traitQuery(c),
+		Sort:   c.Query("sort"),
+	}
+
+	if c.Query("contract") != "all" {
+		contractAddr, ok := contractQuery(c, h.service.GetContractAddress())
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的合约地址",
+			})
+			return
+		}
+		query.ContractAddress = contractAddr
+	}
+
+	for key, target := range map[string]*string{"owner": &query.Owner, "holder": &query.Holder} {
+		if address := c.Query(key); address != "" {
+			if !common.IsHexAddress(address) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success": false,
+					"error":   "无效的地址: " + key,
+				})
+				return
+			}
+			*target = address
+		}
+	}
+
+	var err error
+	if query.MintedFrom, err = uintQuery(c, "minted_from"); err == nil {
+		query.MintedTo, err = uintQuery(c, "minted_to")
+	}
+	if err == nil {
+		query.InAuction, err = boolQuery(c, "in_auction")
+	}
+	if err == nil {
+		switch c.Query("order") {
+		case "":
+		case "asc", "desc":
+			desc := c.Query("order") == "desc"
+			query.Desc = &desc
+		default:
+			err = fmt.Errorf("order 只能是 asc 或 desc")
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	query.Page, query.PageSize = pageQuery(c)
+	nfts, total, err := h.service.ListNFTs(c.Request.Context(), query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "invalid") {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "获取NFT列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"nfts": nfts,
+			"pagination": gin.H{
+				"page":       query.Page,
+				"page_size":  query.PageSize,
+				"total":      total,
+				"total_page": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+			},
+		},
+	})
+}
+
 // GetNFTOwner 获取NFT所有者
 func (h *NFTHandler) GetNFTOwner(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -70,7 +172,7 @@ func (h *NFTHandler) GetNFTOwner(c *gin.Context) {
 		return
 	}
 
-	owner, err := h.service.GetOwner(ctx, tokenID)
+	custody, err := h.service.GetCustody(ctx, tokenID)
 	if err != nil {
 		if strings.Contains(err.Error(), "does not exist") ||
 			strings.Contains(err.Error(), "not found") {
@@ -92,9 +194,13 @@ func (h *NFTHandler) GetNFTOwner(c *gin.Context) {
 		return
 	}
 
+	// owner 为实际所有者（托管在拍卖中时是卖家），holder 为链上持有者
 	c.JSON(http.StatusOK, gin.H{
-		"token_id": tokenID,
-		"owner":    owner,
+		"token_id":            tokenID,
+		"owner":               custody.BeneficialOwner,
+		"holder":              custody.Holder,
+		"beneficial_owner":    custody.BeneficialOwner,
+		"escrowed_in_auction": custody.EscrowedInAuction,
 	})
 }
 
@@ -115,7 +221,9 @@ func (h *NFTHandler) ValidateOwnership(c *gin.Context) {
 	// 清理地址格式
 	address = strings.ToLower(strings.TrimSpace(address))
 
-	isOwner, err := h.service.ValidateOwnership(ctx, tokenID, address)
+	// ?mode=holder（默认，链上持有者）| beneficial（托管在拍卖中的NFT算作卖家的）
+	mode := c.DefaultQuery("mode", service.OwnershipModeHolder)
+	isOwner, err := h.service.ValidateOwnership(ctx, tokenID, address, mode)
 	if err != nil {
 		if strings.Contains(err.Error(), "does not exist") ||
 			strings.Contains(err.Error(), "not found") {
@@ -140,6 +248,7 @@ func (h *NFTHandler) ValidateOwnership(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"token_id": tokenID,
 		"address":  address,
+		"mode":     mode,
 		"is_owner": isOwner,
 	})
 }
@@ -162,3 +271,71 @@ func (h *NFTHandler) SyncNFTInfo(c *gin.Context) {
 		"timestamp": time.Now().Unix(),
 	})
 }
+
+// contractQuery 读取 ?contract= 参数（校验后转为规范地址），未传时使用默认合约
+func contractQuery(c *gin.Context, defaultAddr common.Address) (string, bool) {
+	contract := c.Query("contract")
+	if contract == "" {
+		return defaultAddr.Hex(), true
+	}
+	if !common.IsHexAddress(contract) {
+		return "", false
+	}
+	return common.HexToAddress(contract).Hex(), true
+}
+
+// pageQuery 读取 ?page=&page_size= 分页参数（page_size 最大 100，默认 20）
+func pageQuery(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+// traitQuery 读取 ?trait[Background]=Blue 形式的属性过滤；同一属性可重复传多个值
+func traitQuery(c *gin.Context) service.TraitFilter {
+	filter := service.TraitFilter{}
+	for key, values := range c.Request.URL.Query() {
+		if !strings.HasPrefix(key, "trait[") || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		traitType := key[len("trait[") : len(key)-1]
+		for _, value := range values {
+			if value != "" {
+				filter[traitType] = append(filter[traitType], value)
+			}
+		}
+	}
+	return filter
+}
+
+// uintQuery 读取非负整数参数，未传时为 0
+func uintQuery(c *gin.Context, key string) (uint64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s 必须是非负整数", key)
+	}
+	return n, nil
+}
+
+// boolQuery 读取 true/false 参数，未传时为 nil
+func boolQuery(c *gin.Context, key string) (*bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s 只能是 true 或 false", key)
+	}
+	return &b, nil
+}
@@ -0,0 +1,66 @@
+// api/provenance.go
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nft-auction-backend/internal/service"
+)
+
+type ProvenanceHandler struct {
+	nftService *service.NFTService
+	tokens     *service.TokenService
+}
+
+func NewProvenanceHandler(nftService *service.NFTService, tokenService *service.TokenService) *ProvenanceHandler {
+	return &ProvenanceHandler{
+		nftService: nftService,
+		tokens:     tokenService,
+	}
+}
+
+// GetHistory NFT的所有权与来源记录（铸造、转移、拍卖托管与成交价、销毁），按时间顺序
+func (h *ProvenanceHandler) GetHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	contractAddr, ok := contractQuery(c, h.nftService.GetContractAddress())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的合约地址",
+		})
+		return
+	}
+
+	tokenID := c.Param("id")
+	entries, err := h.nftService.GetProvenance(ctx, contractAddr, tokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取来源记录失败: " + err.Error(),
+		})
+		return
+	}
+	if len(entries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "没有该NFT的转移记录",
+		})
+		return
+	}
+
+	// 成交价按支付币种精度展示
+	h.tokens.DecorateProvenance(ctx, entries)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"contract": contractAddr,
+			"token_id": tokenID,
+			"history":  entries,
+			"count":    len(entries),
+		},
+	})
+}
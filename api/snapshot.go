@@ -0,0 +1,144 @@
+// api/snapshot.go
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/service"
+)
+
+type SnapshotHandler struct {
+	service    *service.SnapshotService
+	nftService *service.NFTService
+}
+
+func NewSnapshotHandler(snapshotService *service.SnapshotService, nftService *service.NFTService) *SnapshotHandler {
+	return &SnapshotHandler{
+		service:    snapshotService,
+		nftService: nftService,
+	}
+}
+
+// CreateSnapshot 计算并保存持有者快照
+// body: {"name": "...", "contract": "0x..", "token_id": "", "block": N | "timestamp": T, "mode": "holder|beneficial"}
+func (h *SnapshotHandler) CreateSnapshot(c *gin.Context) {
+	var req service.SnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.ContractAddress == "" {
+		req.ContractAddress = h.nftService.GetContractAddress().Hex()
+	}
+
+	snapshot, err := h.service.CreateSnapshot(c.Request.Context(), req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrSnapshotExists):
+			status = http.StatusConflict
+		case errors.Is(err, service.ErrSnapshotNotIndexed):
+			status = http.StatusUnprocessableEntity
+		case strings.Contains(err.Error(), "invalid"):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "创建快照失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    snapshot,
+	})
+}
+
+// ListSnapshots 已保存的快照
+func (h *SnapshotHandler) ListSnapshots(c *gin.Context) {
+	snapshots, err := h.service.ListSnapshots(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    snapshots,
+	})
+}
+
+// ExportSnapshot 导出快照持有者（?format=json 默认 | csv）
+func (h *SnapshotHandler) ExportSnapshot(c *gin.Context) {
+	name := c.Param("name")
+	export, err := h.service.GetSnapshot(c.Request.Context(), name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "快照不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		if err := export.WriteCSV(c.Writer); err != nil {
+			c.Error(err)
+		}
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    export,
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "format 只能是 json 或 csv",
+		})
+	}
+}
+
+// DeleteSnapshot 删除快照
+func (h *SnapshotHandler) DeleteSnapshot(c *gin.Context) {
+	err := h.service.DeleteSnapshot(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "快照不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
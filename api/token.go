@@ -0,0 +1,65 @@
+// api/token.go
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"nft-auction-backend/internal/service"
+)
+
+type TokenHandler struct {
+	service *service.TokenService
+}
+
+func NewTokenHandler(tokenService *service.TokenService) *TokenHandler {
+	return &TokenHandler{
+		service: tokenService,
+	}
+}
+
+// ListTokens 已缓存的ERC20代币列表
+func (h *TokenHandler) ListTokens(c *gin.Context) {
+	tokens, err := h.service.ListTokens(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取代币列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"tokens": tokens,
+			"count":  len(tokens),
+		},
+	})
+}
+
+// GetToken 查询代币元数据（未缓存时从链上读取，只限已索引拍卖使用的支付币种）
+func (h *TokenHandler) GetToken(c *gin.Context) {
+	token, err := h.service.GetToken(c.Request.Context(), c.Param("address"))
+	if errors.Is(err, service.ErrTokenNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "代币不存在（未被任何拍卖使用）",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "获取代币信息失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    token,
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/config"   // 配置管理
+	"nft-auction-backend/internal/contract" // 区块链交互层
+	"nft-auction-backend/internal/service"  // 业务逻辑层
+	"nft-auction-backend/pkg/database"      // 数据库层
+	"nft-auction-backend/pkg/rpcpool"       // RPC连接池
+)
+
+// App 各子命令共享的依赖（配置、数据库、RPC连接池、服务层）
+// serve / backfill / replay / sync / verify 使用同一套初始化流程，migrate 只需要数据库
+type App struct {
+	Cfg *config.Config
+	DB  *gorm.DB
+
+	RPCPool        *rpcpool.Pool
+	UserService    *service.UserService
+	NFTService     *service.NFTService
+	AuctionService *service.AuctionService
+	TokenService   *service.TokenService
+	Collections    *service.CollectionService
+	Metadata       *service.MetadataService
+	Images         *service.ImageService
+	Portfolio      *service.PortfolioService
+	Snapshots      *service.SnapshotService
+	Escrow         *service.EscrowService
+	EventSync      *service.EventSyncService
+	Reorg          *service.ReorgService
+	Journal        *service.EventJournalService
+	Listener       *service.BlockchainListener
+
+	Ctx    context.Context
+	Cancel context.CancelFunc
+}
+
+// newApp 加载配置并初始化数据库（自动迁移表结构）
+func newApp() (*App, error) {
+	// ==================== 1. 配置加载阶段 ====================
+	cfg := config.LoadConfig()
+	log.SetPrefix("[NFT_BACK_END] ")
+
+	// ==================== 2. 数据库初始化阶段 ====================
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("数据库初始化失败: %v", err)
+	}
+
+	// 启动监听器（使用后台context）
+	// context.WithCancel 是 Go 语言中用于创建 可取消的上下文（Context） 的函数
+	//         case <-ctx.Done():  // 在监听器中 监听取消信号
+
+	// 它在函数调用之间显式传递
+	// 它携带本次调用的相关信息（取消信号、超时、请求ID等）
+	// 每个请求/任务有自己独立的Context链条
+	// 它让函数知道自己为什么运行、何时应该停止
+	// 在你的区块链监听器中，ctx 让监听器知道："当主程序要退出时，请优雅地停止监听，清理资源"。
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &App{
+		Cfg:         cfg,
+		DB:          db,
+		UserService: service.NewUserService(db),
+		Snapshots:   service.NewSnapshotService(db, service.NewEventSyncService(db)), // 只读数据库，CLI 不需要连接区块链
+		Ctx:         ctx,
+		Cancel:      cancel,
+	}, nil
+}
+
+// connectChain 连接区块链并初始化依赖链上数据的服务与监听器（监听器不启动）
+func (a *App) connectChain() error {
+	cfg := a.Cfg
+
+	// ==================== 3. NFT客户端初始化 ====================
+	// ┌─────────────┐    调用    ┌─────────────┐    调用    ┌─────────────┐
+	// │   API层     │───────────▶│ Service层  │───────────▶│ Contract层  │
+	// │  Handlers   │            │  Services  │            │    Client   │
+	// └─────────────┘            └─────────────┘            └─────────────┘
+	//        │                         │                            │
+	//        │ 返回JSON                │ 业务逻辑                   │ 区块链交互
+	//        ▼                         ▼                            ▼
+	//    前端/客户端               数据库操作                    以太坊网络
+
+	// 检查必要的配置
+	if cfg.Blockchain.RPCURL == "" && len(cfg.Blockchain.RPCEndpoints) == 0 {
+		return fmt.Errorf("请在 config.yaml 中配置 blockchain.rpc_url 或 blockchain.rpc_endpoints")
+	}
+
+	if cfg.Blockchain.NFTContractAddress == "" {
+		return fmt.Errorf("请在 config.yaml 中配置 blockchain.nft_contract_address")
+	}
+
+	// 初始化RPC连接池（两个合约客户端和监听器共享，自动健康探测与故障转移）
+	rpcPool, err := rpcpool.NewPool(cfg.Blockchain)
+	if err != nil {
+		return fmt.Errorf("RPC连接池初始化失败: %v", err)
+	}
+	a.RPCPool = rpcPool
+
+	// 初始化NFT客户端
+	nftClient, err := contract.NewNFTClient(rpcPool, cfg.Blockchain.NFTContractAddress)
+	if err != nil {
+		return fmt.Errorf("NFT客户端初始化失败: %v", err)
+	}
+
+	// 初始化拍卖客户端
+	auctionClient, err := contract.NewAuctionClient(rpcPool, cfg.Blockchain.AuctionContractAddress)
+	if err != nil {
+		return fmt.Errorf("拍卖客户端初始化失败: %v", err)
+	}
+
+	chainID, err := rpcPool.ChainID(a.Ctx)
+	if err != nil {
+		return fmt.Errorf("获取链ID失败: %v", err)
+	}
+	if cfg.Blockchain.ChainID != 0 && cfg.Blockchain.ChainID != chainID.Uint64() {
+		return fmt.Errorf("节点链ID %s 与配置的 blockchain.chain_id %d 不一致", chainID, cfg.Blockchain.ChainID)
+	}
+	return a.initServices(nftClient, auctionClient, chainID.Uint64())
+}
+
+// openOffline 不连接区块链初始化服务与监听器（事件日志回放）
+// 连接池没有节点，任何链上调用都返回错误；链ID取配置，未配置时取事件日志中记录的链ID
+func (a *App) openOffline() error {
+	cfg := a.Cfg
+	if cfg.Blockchain.NFTContractAddress == "" {
+		return fmt.Errorf("请在 config.yaml 中配置 blockchain.nft_contract_address")
+	}
+
+	chainID := cfg.Blockchain.ChainID
+	if chainID == 0 {
+		var err error
+		if chainID, err = service.StoredChainID(a.Ctx, a.DB); err != nil {
+			return err
+		}
+	}
+	log.Printf("📴 离线模式（不连接区块链），链ID: %d", chainID)
+
+	a.RPCPool = rpcpool.NewOfflinePool()
+	nftClient, err := contract.BindNFTClient(a.RPCPool, cfg.Blockchain.NFTContractAddress)
+	if err != nil {
+		return fmt.Errorf("NFT客户端初始化失败: %v", err)
+	}
+	auctionClient, err := contract.BindAuctionClient(a.RPCPool, cfg.Blockchain.AuctionContractAddress)
+	if err != nil {
+		return fmt.Errorf("拍卖客户端初始化失败: %v", err)
+	}
+	return a.initServices(nftClient, auctionClient, chainID)
+}
+
+// initServices 初始化依赖链上数据的服务与监听器（监听器不启动），使用 a.RPCPool
+func (a *App) initServices(nftClient *contract.NFTClient, auctionClient *contract.AuctionClient, chainID uint64) error {
+	cfg := a.Cfg
+	db := a.DB
+	rpcPool := a.RPCPool
+
+	// ==================== 4. 服务层初始化 ====================
+	// NFT 服务
+	a.NFTService = service.NewNFTService(db, nftClient)
+
+	// ERC20代币元数据 服务（拍卖金额按支付币种精度展示）
+	a.TokenService = service.NewTokenService(db, rpcPool)
+
+	// NFT合约级状态 服务（铸造价格、铸造开关、提取记录、全量授权）与合集注册表
+	a.Collections = service.NewCollectionService(db, nftClient, rpcPool)
+	if err := a.Collections.LoadRegistry(a.Ctx); err != nil {
+		return err
+	}
+	a.NFTService.SetRegistry(a.Collections)
+
+	// tokenURI 元数据抓取 服务（ipfs:// ar:// https:// data:）
+	a.Metadata = service.NewMetadataService(db, cfg.Metadata)
+	if cfg.Metadata.Enabled {
+		a.NFTService.SetMetadata(a.Metadata)
+	}
+
+	// NFT图片缓存 服务（原图与缩略图按内容哈希保存在本地）
+	a.Images = service.NewImageService(db, cfg.Images, a.Metadata.Resolver())
+	if cfg.Images.Enabled {
+		a.Metadata.SetImages(a.Images)
+	}
+
+	// NFT拍卖 服务（传入两个客户端）
+	a.AuctionService = service.NewAuctionService(db, auctionClient)
+	a.NFTService.SetEscrowContract(a.AuctionService.GetContractAddress())
+
+	// 钱包资产 服务（持有、托管、出价、赢得的拍卖，全部来自数据库）
+	a.Portfolio = service.NewPortfolioService(db, a.NFTService)
+
+	// ==================== 5.区块链监听器初始化 ====================
+	a.EventSync = service.NewEventSyncService(db)
+	a.Reorg = service.NewReorgService(db, cfg.Blockchain.Confirmations)
+	a.Journal = service.NewEventJournalService(db, chainID)
+
+	// 托管资金流水与对账（合约 ETH 余额、ERC20 balanceOf）
+	a.Snapshots.SetHeaderSource(rpcPool) // 按时间戳取快照时查询区块头
+	a.Escrow = service.NewEscrowService(db, rpcPool, a.AuctionService.GetContractAddress(), a.EventSync, cfg.Escrow)
+	a.Listener = service.NewBlockchainListener(
+		a.NFTService,     // NFT Service
+		a.AuctionService, // Auction Service
+		a.Collections,    // 合约级状态
+		a.EventSync,      // 区块检查点
+		a.Reorg,          // 确认深度与重组检测
+		a.Journal,        // 原始事件日志
+		rpcPool,          // RPC连接池
+		cfg.Blockchain,   // 区块链配置（RPC URL、起始区块、回填批大小）
+		a.Ctx,
+		a.Cancel,
+	)
+	return nil
+}
+
+// Close 释放资源
+func (a *App) Close() {
+	a.Cancel()
+	if a.RPCPool != nil {
+		a.RPCPool.Close()
+	}
+	if sqlDB, _ := a.DB.DB(); sqlDB != nil {
+		sqlDB.Close()
+	}
+}
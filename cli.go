@@ -0,0 +1,345 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"nft-auction-backend/internal/model"   // 数据模型
+	"nft-auction-backend/internal/service" // 业务逻辑层
+)
+
+const cliUsage = `用法: nft-auction-backend <命令> [参数]
+
+命令:
+  serve                          启动 API 服务与区块链监听（默认）
+  migrate                        只执行数据库迁移
+  backfill --from N [--to M]     通过 eth_getLogs 重新拉取并应用区块区间内的事件（--to 默认为最新区块）
+  replay [--from N] [--to M] [--reset]
+                                 从原始事件日志离线回放，重建业务表（--reset 需要从0开始；不连接区块链，
+                                 链ID取 blockchain.chain_id，未配置时取事件日志中记录的链ID）
+  sync auctions|nfts             从链上全量同步拍卖或NFT
+  verify [auctions|nfts]         比对数据库与链上状态，不一致时以非0状态退出
+  snapshot create --name X [--contract A] [--token ID] [--block N | --time T] [--mode holder|beneficial]
+                                 按区块或时间戳计算持有者快照并保存（默认主合约、已索引的最新区块）
+  snapshot list                  列出已保存的快照
+  snapshot export --name X [--format csv|json] [--out 文件]
+                                 导出快照持有者（默认 csv 输出到标准输出）
+  escrow reconcile               比较流水推导的托管余额与拍卖合约实际余额，不一致时以非0状态退出（节点没有历史状态不算不一致）
+  escrow withdrawal --tx HASH    登记管理员提取（withdrawETH / withdrawERC20 不发事件）
+`
+
+// runCLI 解析子命令并执行；没有子命令时启动服务（兼容原有的直接运行方式）
+func runCLI(args []string) error {
+	cmd := "serve"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "serve":
+		return withChain(func(app *App) error { return runServe(app) })
+	case "migrate":
+		return runMigrate()
+	case "backfill":
+		return runBackfill(args)
+	case "replay":
+		return runReplay(args)
+	case "sync":
+		return runSync(args)
+	case "verify":
+		return runVerify(args)
+	case "snapshot":
+		return runSnapshot(args)
+	case "escrow":
+		return runEscrow(args)
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return fmt.Errorf("未知命令: %s", cmd)
+	}
+}
+
+// withChain 初始化数据库与链上依赖后执行 fn，结束时释放资源
+func withChain(fn func(app *App) error) error {
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	if err := app.connectChain(); err != nil {
+		return err
+	}
+	return fn(app)
+}
+
+// runMigrate 数据库初始化时自动迁移，完成后直接退出
+func runMigrate() error {
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	log.Println("✅ 数据库迁移完成")
+	return nil
+}
+
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	from := fs.Uint64("from", 0, "起始区块")
+	to := fs.Uint64("to", 0, "结束区块（0表示最新区块）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !isFlagSet(fs, "from") {
+		return fmt.Errorf("backfill 需要 --from 参数")
+	}
+
+	return withChain(func(app *App) error {
+		return app.Listener.Reindex(*from, *to)
+	})
+}
+
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	from := fs.Uint64("from", 0, "起始区块")
+	to := fs.Uint64("to", 0, "结束区块（0表示日志末尾）")
+	reset := fs.Bool("reset", false, "回放前清空由事件派生的业务表（拍卖、NFT、出价、退款、提取、授权、转移与资金流水，登记的管理员提取保留）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// 回放只读事件日志，不连接区块链
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	if err := app.openOffline(); err != nil {
+		return err
+	}
+	_, err = app.Listener.Replay(app.Ctx, *from, *to, *reset)
+	return err
+}
+
+func runSync(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: sync auctions|nfts")
+	}
+
+	return withChain(func(app *App) error {
+		switch args[0] {
+		case "auctions":
+			return app.AuctionService.SyncAllAuctions(app.Ctx)
+		case "nfts":
+			return app.NFTService.SyncAllNFTs(app.Ctx)
+		default:
+			return fmt.Errorf("未知同步目标: %s（可选 auctions / nfts）", args[0])
+		}
+	})
+}
+
+func runVerify(args []string) error {
+	target := "all"
+	if len(args) > 0 {
+		target = args[0]
+	}
+	if target != "all" && target != "auctions" && target != "nfts" {
+		return fmt.Errorf("未知校验目标: %s（可选 auctions / nfts）", target)
+	}
+
+	return withChain(func(app *App) error {
+		var mismatches []service.Mismatch
+		if target == "all" || target == "auctions" {
+			found, err := app.AuctionService.VerifyAuctions(app.Ctx)
+			if err != nil {
+				return err
+			}
+			mismatches = append(mismatches, found...)
+		}
+		if target == "all" || target == "nfts" {
+			found, err := app.NFTService.VerifyNFTs(app.Ctx)
+			if err != nil {
+				return err
+			}
+			mismatches = append(mismatches, found...)
+		}
+
+		for _, m := range mismatches {
+			log.Printf("⚠️ %s", m)
+		}
+		if len(mismatches) > 0 {
+			return fmt.Errorf("发现 %d 处数据库与链上不一致", len(mismatches))
+		}
+		log.Println("✅ 数据库与链上状态一致")
+		return nil
+	})
+}
+
+func runSnapshot(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: snapshot create|list|export")
+	}
+	sub, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("snapshot "+sub, flag.ContinueOnError)
+	name := fs.String("name", "", "快照名称")
+	contractAddr := fs.String("contract", "", "合约地址（默认主合约）")
+	tokenID := fs.String("token", "", "只快照单个 token")
+	block := fs.Uint64("block", 0, "快照区块（0表示已索引的最新区块）")
+	timestamp := fs.Uint64("time", 0, "按区块时间戳（秒）取快照")
+	mode := fs.String("mode", service.OwnershipModeHolder, "holder | beneficial（托管在拍卖中的算作卖家）")
+	format := fs.String("format", "csv", "导出格式 csv | json")
+	out := fs.String("out", "", "导出文件（默认标准输出）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if sub != "list" && *name == "" {
+		return fmt.Errorf("snapshot %s 需要 --name 参数", sub)
+	}
+
+	// 快照只读写数据库；只有按时间戳取快照时才连接区块链（查询区块头）
+	app, err := newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if sub == "create" && *timestamp != 0 {
+		if err := app.connectChain(); err != nil {
+			return err
+		}
+	}
+
+	switch sub {
+	case "create":
+		if *contractAddr == "" {
+			*contractAddr = app.Cfg.Blockchain.NFTContractAddress
+		}
+		snapshot, err := app.Snapshots.CreateSnapshot(app.Ctx, service.SnapshotRequest{
+			Name:            *name,
+			ContractAddress: *contractAddr,
+			TokenID:         *tokenID,
+			Block:           *block,
+			Timestamp:       *timestamp,
+			Mode:            *mode,
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("✅ 快照 %s 已保存: 区块 %d，%d 个持有者，%d 个token",
+			snapshot.Name, snapshot.BlockNumber, snapshot.HolderCount, snapshot.TokenCount)
+		return nil
+
+	case "list":
+		snapshots, err := app.Snapshots.ListSnapshots(app.Ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s\t%s\t区块 %d\t%s\t%d 个持有者\t%d 个token\n",
+				s.Name, s.ContractAddress, s.BlockNumber, s.Mode, s.HolderCount, s.TokenCount)
+		}
+		return nil
+
+	case "export":
+		export, err := app.Snapshots.GetSnapshot(app.Ctx, *name)
+		if err != nil {
+			return fmt.Errorf("读取快照 %s 失败: %v", *name, err)
+		}
+		w := os.Stdout
+		if *out != "" {
+			if w, err = os.Create(*out); err != nil {
+				return err
+			}
+			defer w.Close()
+		}
+		switch *format {
+		case "csv":
+			return export.WriteCSV(w)
+		case "json":
+			return export.WriteJSON(w)
+		default:
+			return fmt.Errorf("未知导出格式: %s（可选 csv / json）", *format)
+		}
+
+	default:
+		return fmt.Errorf("未知快照命令: %s（可选 create / list / export）", sub)
+	}
+}
+
+func runEscrow(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: escrow reconcile|withdrawal")
+	}
+	sub, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("escrow "+sub, flag.ContinueOnError)
+	txHash := fs.String("tx", "", "提取交易哈希")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return withChain(func(app *App) error {
+		switch sub {
+		case "reconcile":
+			results, err := app.Escrow.Reconcile(app.Ctx)
+			if err != nil {
+				return err
+			}
+			mismatches, unavailable := 0, 0
+			for _, r := range results {
+				currency := r.Currency
+				if currency == "" {
+					currency = "ETH"
+				}
+				log.Printf("%s 区块 %d: 应有 %s 实际 %s 差额 %s [%s] %s",
+					currency, r.BlockNumber, r.Expected, r.Actual, r.Difference, r.Status, r.Error)
+				switch r.Status {
+				case model.ReconciliationOK:
+				case model.ReconciliationUnavailable:
+					unavailable++
+				default:
+					mismatches++
+				}
+			}
+			if mismatches > 0 {
+				return fmt.Errorf("%d 个币种托管余额不一致或无法读取", mismatches)
+			}
+			if unavailable > 0 {
+				log.Printf("⚠️ %d 个币种无法对账：节点没有检查点区块的状态（需要归档节点，或等检查点追上最新区块后重试）", unavailable)
+				return nil
+			}
+			log.Println("✅ 托管余额与链上一致")
+			return nil
+
+		case "withdrawal":
+			if len(common.FromHex(*txHash)) != common.HashLength {
+				return fmt.Errorf("escrow withdrawal 需要有效的 --tx 参数")
+			}
+			_, err := app.Escrow.RecordWithdrawal(app.Ctx, common.HexToHash(*txHash))
+			return err
+
+		default:
+			return fmt.Errorf("未知托管命令: %s（可选 reconcile / withdrawal）", sub)
+		}
+	})
+}
+
+// isFlagSet 判断参数是否在命令行中显式给出
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
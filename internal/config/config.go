@@ -3,6 +3,7 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -19,6 +20,10 @@ type Config struct {
 	Server     ServerConfig     `mapstructure:"server"`     // 服务器配置
 	Database   DatabaseConfig   `mapstructure:"database"`   // 数据库配置
 	Blockchain BlockchainConfig `mapstructure:"blockchain"` // 区块链配置
+	Keeper     KeeperConfig     `mapstructure:"keeper"`     // 自动结算配置
+	Metadata   MetadataConfig   `mapstructure:"metadata"`   // NFT元数据抓取配置
+	Images     ImageConfig      `mapstructure:"images"`     // NFT图片缓存配置
+	Escrow     EscrowConfig     `mapstructure:"escrow"`     // 托管对账配置
 }
 
 // ServerConfig 服务器配置
@@ -31,11 +36,84 @@ type DatabaseConfig struct {
 	Path string `mapstructure:"path"` // SQLite数据库文件路径
 }
 
+// RPCEndpointConfig 单个RPC节点配置
+type RPCEndpointConfig struct {
+	Name      string  `mapstructure:"name"`       // 节点名称（日志展示用）
+	URL       string  `mapstructure:"url"`        // wss:// 或 https:// 地址
+	RateLimit float64 `mapstructure:"rate_limit"` // 每秒最多请求数（0表示不限流）
+	Burst     int     `mapstructure:"burst"`      // 突发请求数
+}
+
 // BlockchainConfig 区块链配置
 type BlockchainConfig struct {
-	RPCURL                 string `mapstructure:"rpc_url"`                  // 以太坊节点RPC URL
-	NFTContractAddress     string `mapstructure:"nft_contract_address"`     // NFT合约地址
-	AuctionContractAddress string `mapstructure:"auction_contract_address"` // 拍卖合约地址
+	RPCURL                 string              `mapstructure:"rpc_url"`                  // 以太坊节点RPC URL（未配置 rpc_endpoints 时使用）
+	RPCEndpoints           []RPCEndpointConfig `mapstructure:"rpc_endpoints"`            // 多节点RPC池
+	HealthCheckInterval    time.Duration       `mapstructure:"health_check_interval"`    // 节点健康探测间隔
+	MaxBlockLag            uint64              `mapstructure:"max_block_lag"`            // 落后最高节点超过多少区块视为不健康
+	MaxErrorRate           float64             `mapstructure:"max_error_rate"`           // 错误率超过多少视为不健康
+	MaxLatency             time.Duration       `mapstructure:"max_latency"`              // 探测延迟超过多少视为不健康（0表示不限制）
+	NFTContractAddress     string              `mapstructure:"nft_contract_address"`     // NFT合约地址
+	AuctionContractAddress string              `mapstructure:"auction_contract_address"` // 拍卖合约地址
+	StartBlock             uint64              `mapstructure:"start_block"`              // 无检查点时开始回填的区块（0表示全量同步后从最新区块开始）
+	BackfillBatchSize      uint64              `mapstructure:"backfill_batch_size"`      // 回填时每次 eth_getLogs 查询的区块数
+	Confirmations          uint64              `mapstructure:"confirmations"`            // 确认深度：区块之上再出多少块才视为确认
+	ListenerMode           string              `mapstructure:"listener_mode"`            // 监听模式: auto, subscribe, poll
+	PollInterval           time.Duration       `mapstructure:"poll_interval"`            // 轮询模式下 eth_getLogs 的间隔
+	MaxSubscribeFailures   int                 `mapstructure:"max_subscribe_failures"`   // auto 模式下订阅连续失败多少次后切换到轮询
+	ReconcileInterval      time.Duration       `mapstructure:"reconcile_interval"`       // 过期拍卖与链上状态对账的间隔
+	ChainID                uint64              `mapstructure:"chain_id"`                 // 链ID（0表示从节点获取；离线回放时从事件日志获取）
+}
+
+// KeeperConfig 自动结算（调用 endAuction）配置
+// 私钥只从本地 keystore 文件或环境变量读取，不写入配置文件
+type KeeperConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`          // 是否启用自动结算
+	KeystorePath    string        `mapstructure:"keystore_path"`    // keystore JSON 文件路径（优先于环境变量私钥）
+	PasswordEnv     string        `mapstructure:"password_env"`     // keystore 密码所在的环境变量名
+	PrivateKeyEnv   string        `mapstructure:"private_key_env"`  // 十六进制私钥所在的环境变量名
+	Interval        time.Duration `mapstructure:"interval"`         // 扫描待结算拍卖的间隔
+	MaxAttempts     int           `mapstructure:"max_attempts"`     // 每个拍卖最多失败次数，超过后放弃
+	ReceiptTimeout  time.Duration `mapstructure:"receipt_timeout"`  // 等待交易上链的超时，超时后提高gas重发
+	MaxGasBumps     int           `mapstructure:"max_gas_bumps"`    // 同一nonce最多提高gas次数
+	GasBumpPercent  int           `mapstructure:"gas_bump_percent"` // 每次提高的百分比（节点要求至少10%）
+	MaxGasPriceGwei uint64        `mapstructure:"max_gas_price"`    // gas费上限（Gwei，0表示不限制）
+}
+
+// MetadataConfig tokenURI 元数据抓取配置
+// ipfs:// 与 ar:// 通过网关解析，网关按顺序尝试
+type MetadataConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`         // 是否启用元数据抓取
+	IPFSGateways   []string      `mapstructure:"ipfs_gateways"`   // IPFS 网关（如 https://ipfs.io/ipfs/）
+	ArweaveGateway string        `mapstructure:"arweave_gateway"` // Arweave 网关（如 https://arweave.net/）
+	Interval       time.Duration `mapstructure:"interval"`        // 扫描待抓取元数据的间隔
+	BatchSize      int           `mapstructure:"batch_size"`      // 每次扫描最多抓取的数量
+	Timeout        time.Duration `mapstructure:"timeout"`         // 单次HTTP请求超时
+	MaxBodyBytes   int64         `mapstructure:"max_body_bytes"`  // 元数据JSON最大字节数
+	MaxAttempts    int           `mapstructure:"max_attempts"`    // 最多失败次数，超过后标记为 failed
+	BackoffBase    time.Duration `mapstructure:"backoff_base"`    // 首次重试等待时间（之后每次翻倍）
+	BackoffMax     time.Duration `mapstructure:"backoff_max"`     // 重试等待时间上限
+	AllowPrivate   bool          `mapstructure:"allow_private"`   // 允许请求内网/回环地址（仅本地IPFS节点等开发环境使用）
+}
+
+// ImageConfig NFT图片缓存与缩略图配置
+// 图片按内容 SHA-256 保存在本地目录，相同图片只保存一份
+type ImageConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`      // 是否启用图片缓存
+	Dir         string        `mapstructure:"dir"`          // 本地存储目录
+	Sizes       []int         `mapstructure:"sizes"`        // 缩略图尺寸（最长边像素）
+	MaxBytes    int64         `mapstructure:"max_bytes"`    // 原图最大字节数
+	MaxPixels   int64         `mapstructure:"max_pixels"`   // 原图最大像素数（宽×高，防止解码炸弹）
+	Interval    time.Duration `mapstructure:"interval"`     // 扫描待下载图片的间隔
+	BatchSize   int           `mapstructure:"batch_size"`   // 每次扫描最多下载的数量
+	MaxAttempts int           `mapstructure:"max_attempts"` // 最多失败次数，超过后标记为 failed
+	BackoffBase time.Duration `mapstructure:"backoff_base"` // 首次重试等待时间（之后每次翻倍）
+	BackoffMax  time.Duration `mapstructure:"backoff_max"`  // 重试等待时间上限
+}
+
+// EscrowConfig 托管对账配置（流水推导的余额与拍卖合约实际余额比较）
+type EscrowConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`  // 是否定期对账
+	Interval time.Duration `mapstructure:"interval"` // 对账间隔
 }
 
 // LoadConfig 加载配置文件
@@ -54,6 +132,58 @@ func LoadConfig() *Config {
 	viper.SetDefault("blockchain.rpc_url", "")                  // 默认空RPC URL（演示模式）
 	viper.SetDefault("blockchain.nft_contract_address", "")     // 默认空NFT合约地址
 	viper.SetDefault("blockchain.auction_contract_address", "") // 默认空拍卖合约地址
+	viper.SetDefault("blockchain.start_block", 0)               // 默认不指定起始区块
+	viper.SetDefault("blockchain.backfill_batch_size", 2000)    // 默认每批回填2000个区块
+	viper.SetDefault("blockchain.confirmations", 6)             // 默认6个区块确认
+	viper.SetDefault("blockchain.listener_mode", "auto")        // 默认根据RPC URL自动选择
+	viper.SetDefault("blockchain.poll_interval", "5s")          // 默认每5秒轮询一次
+	viper.SetDefault("blockchain.max_subscribe_failures", 3)    // 默认订阅连续失败3次后切换到轮询
+	viper.SetDefault("blockchain.reconcile_interval", "1m")     // 默认每分钟对账一次过期拍卖
+	viper.SetDefault("blockchain.chain_id", 0)                  // 默认从节点获取链ID
+	viper.SetDefault("blockchain.health_check_interval", "15s") // 默认每15秒探测一次节点
+	viper.SetDefault("blockchain.max_block_lag", 5)             // 默认落后5个区块视为不健康
+	viper.SetDefault("blockchain.max_error_rate", 0.5)          // 默认错误率超过50%视为不健康
+	viper.SetDefault("blockchain.max_latency", "0s")            // 默认不限制延迟
+
+	// 自动结算默认值
+	viper.SetDefault("keeper.enabled", false)                           // 默认不启用自动结算
+	viper.SetDefault("keeper.password_env", "KEEPER_KEYSTORE_PASSWORD") // 默认密码环境变量
+	viper.SetDefault("keeper.private_key_env", "KEEPER_PRIVATE_KEY")    // 默认私钥环境变量
+	viper.SetDefault("keeper.interval", "30s")                          // 默认每30秒扫描一次
+	viper.SetDefault("keeper.max_attempts", 5)                          // 默认每个拍卖最多失败5次
+	viper.SetDefault("keeper.receipt_timeout", "2m")                    // 默认等待2分钟未上链则提高gas
+	viper.SetDefault("keeper.max_gas_bumps", 3)                         // 默认最多提高3次gas
+	viper.SetDefault("keeper.gas_bump_percent", 20)                     // 默认每次提高20%
+	viper.SetDefault("keeper.max_gas_price", 0)                         // 默认不限制gas费
+
+	// 元数据抓取默认值
+	viper.SetDefault("metadata.enabled", true)                                                               // 默认启用元数据抓取
+	viper.SetDefault("metadata.ipfs_gateways", []string{"https://ipfs.io/ipfs/", "https://dweb.link/ipfs/"}) // 默认公共IPFS网关
+	viper.SetDefault("metadata.arweave_gateway", "https://arweave.net/")                                     // 默认Arweave网关
+	viper.SetDefault("metadata.interval", "30s")                                                             // 默认每30秒扫描一次
+	viper.SetDefault("metadata.batch_size", 20)                                                              // 默认每次抓取20个
+	viper.SetDefault("metadata.timeout", "15s")                                                              // 默认请求超时15秒
+	viper.SetDefault("metadata.max_body_bytes", 1<<20)                                                       // 默认最大1MB
+	viper.SetDefault("metadata.max_attempts", 8)                                                             // 默认最多失败8次
+	viper.SetDefault("metadata.backoff_base", "1m")                                                          // 默认首次重试等待1分钟
+	viper.SetDefault("metadata.backoff_max", "6h")                                                           // 默认最长等待6小时
+	viper.SetDefault("metadata.allow_private", false)                                                        // 默认拒绝内网地址（tokenURI 由合约控制，防止 SSRF）
+
+	// 图片缓存默认值
+	viper.SetDefault("images.enabled", true)          // 默认启用图片缓存
+	viper.SetDefault("images.dir", "./data/images")   // 默认存储目录
+	viper.SetDefault("images.sizes", []int{128, 512}) // 默认生成128和512两种缩略图
+	viper.SetDefault("images.max_bytes", 20<<20)      // 默认原图最大20MB
+	viper.SetDefault("images.max_pixels", 50_000_000) // 默认最大5000万像素
+	viper.SetDefault("images.interval", "30s")        // 默认每30秒扫描一次
+	viper.SetDefault("images.batch_size", 10)         // 默认每次下载10张
+	viper.SetDefault("images.max_attempts", 6)        // 默认最多失败6次
+	viper.SetDefault("images.backoff_base", "2m")     // 默认首次重试等待2分钟
+	viper.SetDefault("images.backoff_max", "12h")     // 默认最长等待12小时
+
+	// 托管对账默认值
+	viper.SetDefault("escrow.enabled", true)   // 默认启用定期对账
+	viper.SetDefault("escrow.interval", "10m") // 默认每10分钟对账一次
 
 	var cfg Config
 
@@ -82,9 +212,19 @@ func LoadConfig() *Config {
 	log.Printf("服务器端口: %d", cfg.Server.Port)
 	log.Printf("数据库路径: %s", cfg.Database.Path)
 	log.Printf("RPC URL: %s", cfg.Blockchain.RPCURL)
+	for _, ep := range cfg.Blockchain.RPCEndpoints {
+		log.Printf("RPC节点: %s %s (限流 %.1f/s)", ep.Name, ep.URL, ep.RateLimit)
+	}
 	log.Printf("NFT合约地址: %s", cfg.Blockchain.NFTContractAddress)
 	log.Printf("拍卖合约地址: %s", cfg.Blockchain.AuctionContractAddress)
 	log.Printf("RPC URL是否为空: %v", cfg.Blockchain.RPCURL == "")
+	log.Printf("起始区块: %d, 回填批大小: %d", cfg.Blockchain.StartBlock, cfg.Blockchain.BackfillBatchSize)
+	log.Printf("确认深度: %d", cfg.Blockchain.Confirmations)
+	log.Printf("监听模式: %s, 轮询间隔: %s", cfg.Blockchain.ListenerMode, cfg.Blockchain.PollInterval)
+	log.Printf("拍卖对账间隔: %s", cfg.Blockchain.ReconcileInterval)
+	log.Printf("自动结算: %v, 扫描间隔: %s", cfg.Keeper.Enabled, cfg.Keeper.Interval)
+	log.Printf("元数据抓取: %v, IPFS网关: %v", cfg.Metadata.Enabled, cfg.Metadata.IPFSGateways)
+	log.Printf("图片缓存: %v, 目录: %s, 缩略图: %v", cfg.Images.Enabled, cfg.Images.Dir, cfg.Images.Sizes)
 
 	return &cfg
 }
@@ -0,0 +1,67 @@
+package contract
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 拍卖状态（根据链上数据计算）
+const (
+	AuctionStatusActive  = "active"                     // 进行中
+	AuctionStatusEnded   = "ended"                      // 已调用 endAuction
+	AuctionStatusExpired = "expired_pending_settlement" // 已过结束时间，等待 endAuction 结算
+
+	AuctionStatusEndedUnsold = "ended_unsold" // 无人出价结束，NFT已退回卖家（合约不发事件）
+)
+
+// AuctionInfo 链上拍卖信息（auctions(id) 的类型化结果）
+type AuctionInfo struct {
+	AuctionID     *big.Int
+	Seller        common.Address
+	Duration      *big.Int // 秒
+	StartPrice    *big.Int // 支付币种的最小单位
+	StartTime     *big.Int
+	EndTime       *big.Int // StartTime + Duration
+	Ended         bool
+	HighestBidder common.Address
+	HighestBid    *big.Int
+	NFTContract   common.Address
+	TokenID       *big.Int
+
+	// 支付币种
+	UseERC20     bool
+	PaymentToken common.Address // ERC20代币地址，ETH拍卖为零地址
+
+	// 计算字段
+	Status        string
+	TimeRemaining *big.Int // 秒，已结束或已过期为0
+}
+
+// Exists 拍卖是否存在（不存在的ID映射返回零值结构体）
+func (a *AuctionInfo) Exists() bool {
+	return a.Seller != (common.Address{})
+}
+
+// HasBids 是否有人出价
+func (a *AuctionInfo) HasBids() bool {
+	return a.HighestBidder != (common.Address{})
+}
+
+// computeStatus 根据当前时间计算状态和剩余时间
+func (a *AuctionInfo) computeStatus(now time.Time) {
+	a.TimeRemaining = big.NewInt(0)
+
+	switch {
+	case a.Ended && !a.HasBids():
+		a.Status = AuctionStatusEndedUnsold
+	case a.Ended:
+		a.Status = AuctionStatusEnded
+	case now.Unix() >= a.EndTime.Int64():
+		a.Status = AuctionStatusExpired
+	default:
+		a.Status = AuctionStatusActive
+		a.TimeRemaining = big.NewInt(a.EndTime.Int64() - now.Unix())
+	}
+}
@@ -0,0 +1,47 @@
+package contract
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// AuctionWithdrawal 管理员提取（withdrawETH / withdrawERC20 的调用参数，合约不发事件）
+type AuctionWithdrawal struct {
+	Token  common.Address // ERC20代币地址，提取ETH时为零地址
+	Amount *big.Int
+}
+
+// IsERC20 是否提取ERC20
+func (w *AuctionWithdrawal) IsERC20() bool {
+	return w.Token != (common.Address{})
+}
+
+// DecodeAuctionWithdrawal 解析发往拍卖合约的交易 input；不是提取调用时返回错误
+func DecodeAuctionWithdrawal(input []byte) (*AuctionWithdrawal, error) {
+	parsed, err := NftAuctionMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("解析拍卖合约ABI失败: %v", err)
+	}
+	if len(input) < 4 {
+		return nil, fmt.Errorf("交易没有调用数据")
+	}
+	method, err := parsed.MethodById(input[:4])
+	if err != nil {
+		return nil, fmt.Errorf("未知的方法: %v", err)
+	}
+
+	args, err := method.Inputs.Unpack(input[4:])
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 参数失败: %v", method.Name, err)
+	}
+	switch method.Name {
+	case "withdrawETH":
+		return &AuctionWithdrawal{Amount: args[0].(*big.Int)}, nil
+	case "withdrawERC20":
+		return &AuctionWithdrawal{Token: args[0].(common.Address), Amount: args[1].(*big.Int)}, nil
+	default:
+		return nil, fmt.Errorf("%s 不是提取方法", method.Name)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"math/big"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
@@ -17,19 +18,37 @@ import (
 // └── service/
 //     └── nft_service.
 
-// ==================== NFT合约接口（不变）====================
-type NFTContract interface {
-	// 基本信息
+// ==================== 链上访问接口 ====================
+// Backend 合约客户端依赖的链上访问能力（由 rpcpool.Pool 实现，多个客户端共享同一个连接池）
+type Backend interface {
+	bind.ContractBackend
+	NetworkID(ctx context.Context) (*big.Int, error)
+}
+
+// ==================== 通用ERC-721接口 ====================
+// ERC721Reader 任意 ERC-721 合约的只读访问（NFTClient 与 ERC721Client 都实现）
+type ERC721Reader interface {
 	GetName(ctx context.Context) (string, error)
 	GetSymbol(ctx context.Context) (string, error)
-	GetContractAddress() common.Address                   // 🔥 新增：获取合约地址方法
-	GetTotalSupply(ctx context.Context) (*big.Int, error) // 获取 NFT 总量
+	GetContractAddress() common.Address
 
-	// NFT 查询
 	GetOwner(ctx context.Context, tokenID *big.Int) (common.Address, error)
 	GetTokenURI(ctx context.Context, tokenID *big.Int) (string, error)
 	GetBalanceOf(ctx context.Context, address common.Address) (*big.Int, error)
 	CheckIfMinted(ctx context.Context, tokenID *big.Int) (bool, error)
+	IsApprovedForAll(ctx context.Context, owner, operator common.Address) (bool, error)
+}
+
+// ==================== NFT合约接口（KevinNFT）====================
+type NFTContract interface {
+	ERC721Reader
+
+	GetTotalSupply(ctx context.Context) (*big.Int, error) // 获取 NFT 总量
+
+	// 合约级状态
+	GetContractOwner(ctx context.Context) (common.Address, error)
+	GetStats(ctx context.Context) (*CollectionStats, error)
+	GetMaxSupply(ctx context.Context) (*big.Int, error)
 
 	// 验证
 	CheckOwner(ctx context.Context, tokenID *big.Int, address string) (bool, error)
@@ -41,21 +60,7 @@ type NFTContract interface {
 // ==================== 拍卖合约接口（新增）====================
 type AuctionContract interface {
 	// 查询方法
-	GetAuctionInfo(ctx context.Context, auctionID *big.Int) (
-		common.Address, // seller
-		*big.Int, // duration
-		*big.Int, // startPrice
-		*big.Int, // startTime
-		bool, // ended
-		common.Address, // highestBidder
-		*big.Int, // highestBid
-		common.Address, // nftContract
-		*big.Int, // tokenId
-		common.Address, // tokenAddress
-		*big.Int, // bidTokenAmount
-		*big.Int, // timeRemaining
-		error,
-	)
+	GetAuctionInfo(ctx context.Context, auctionID *big.Int) (*AuctionInfo, error)
 
 	GetAuctionCount(ctx context.Context) (*big.Int, error)
 	GetAdmin(ctx context.Context) (common.Address, error)
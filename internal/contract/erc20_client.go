@@ -0,0 +1,99 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// erc20ABI 只包含后端需要的只读方法（IERC20 + IERC20Metadata）
+const erc20ABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// ERC20Client ERC20代币客户端（只读）
+type ERC20Client struct {
+	contract *bind.BoundContract
+	address  common.Address
+}
+
+// NewERC20Client 创建ERC20客户端（共享RPC连接池）
+func NewERC20Client(client Backend, tokenAddress common.Address) (*ERC20Client, error) {
+	parsed, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		return nil, fmt.Errorf("解析ERC20 ABI失败: %v", err)
+	}
+
+	return &ERC20Client{
+		contract: bind.NewBoundContract(tokenAddress, parsed, client, client, client),
+		address:  tokenAddress,
+	}, nil
+}
+
+// Name 代币名称
+func (c *ERC20Client) Name(ctx context.Context) (string, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "name"); err != nil {
+		return "", err
+	}
+	return *abi.ConvertType(out[0], new(string)).(*string), nil
+}
+
+// Symbol 代币符号
+func (c *ERC20Client) Symbol(ctx context.Context) (string, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "symbol"); err != nil {
+		return "", err
+	}
+	return *abi.ConvertType(out[0], new(string)).(*string), nil
+}
+
+// Decimals 代币精度
+func (c *ERC20Client) Decimals(ctx context.Context) (uint8, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "decimals"); err != nil {
+		return 0, err
+	}
+	return *abi.ConvertType(out[0], new(uint8)).(*uint8), nil
+}
+
+// TotalSupply 代币总供应量
+func (c *ERC20Client) TotalSupply(ctx context.Context) (*big.Int, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "totalSupply"); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// BalanceOf 查询地址余额
+func (c *ERC20Client) BalanceOf(ctx context.Context, account common.Address) (*big.Int, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "balanceOf", account); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// BalanceOfAt 查询地址在指定区块的余额（blockNumber 为 nil 表示最新区块）
+func (c *ERC20Client) BalanceOfAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx, BlockNumber: blockNumber}, &out, "balanceOf", account); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// GetContractAddress 获取代币地址
+func (c *ERC20Client) GetContractAddress() common.Address {
+	return c.address
+}
@@ -0,0 +1,198 @@
+package contract
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ERC-165 接口ID
+var (
+	InterfaceIDERC721         = [4]byte{0x80, 0xac, 0x58, 0xcd}
+	InterfaceIDERC721Metadata = [4]byte{0x5b, 0x5e, 0x13, 0x9f}
+)
+
+// erc721ABI 通用 ERC-721 合约：IERC165 + IERC721 + IERC721Metadata 的只读方法和事件
+const erc721ABI = `[
+	{"type":"function","name":"supportsInterface","stateMutability":"view","inputs":[{"name":"interfaceId","type":"bytes4"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"tokenURI","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"isApprovedForAll","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"operator","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+	{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"approved","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+	{"type":"event","name":"ApprovalForAll","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool","indexed":false}]}
+]`
+
+// ERC721ABI 解析通用 ERC-721 ABI（事件注册表与事件日志解码使用）
+func ERC721ABI() (*abi.ABI, error) {
+	parsed, err := abi.JSON(strings.NewReader(erc721ABI))
+	if err != nil {
+		return nil, fmt.Errorf("解析ERC721 ABI失败: %v", err)
+	}
+	return &parsed, nil
+}
+
+// ERC721Transfer 通用 Transfer 事件
+type ERC721Transfer struct {
+	From    common.Address
+	To      common.Address
+	TokenId *big.Int
+	Raw     types.Log
+}
+
+// ERC721Approval 通用 Approval 事件
+type ERC721Approval struct {
+	Owner    common.Address
+	Approved common.Address
+	TokenId  *big.Int
+	Raw      types.Log
+}
+
+// ERC721ApprovalForAll 通用 ApprovalForAll 事件
+type ERC721ApprovalForAll struct {
+	Owner    common.Address
+	Operator common.Address
+	Approved bool
+	Raw      types.Log
+}
+
+// ERC721Client 任意 ERC-721 合约的客户端（只读，拍卖中出现的第三方合集使用）
+type ERC721Client struct {
+	contract *bind.BoundContract
+	address  common.Address
+}
+
+// NewERC721Client 创建通用 ERC-721 客户端（共享RPC连接池）
+func NewERC721Client(client Backend, contractAddress common.Address) (*ERC721Client, error) {
+	parsed, err := ERC721ABI()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ERC721Client{
+		contract: bind.NewBoundContract(contractAddress, *parsed, client, client, client),
+		address:  contractAddress,
+	}, nil
+}
+
+// GetContractAddress 获取合约地址
+func (c *ERC721Client) GetContractAddress() common.Address {
+	return c.address
+}
+
+// SupportsInterface ERC-165 接口检查；合约未实现 ERC-165 时返回 false
+func (c *ERC721Client) SupportsInterface(ctx context.Context, interfaceID [4]byte) (bool, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "supportsInterface", interfaceID); err != nil {
+		if strings.Contains(err.Error(), "execution reverted") || strings.Contains(err.Error(), "no contract code") {
+			return false, nil
+		}
+		return false, err
+	}
+	return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+}
+
+// SupportsERC721 是否声明支持 ERC-721
+func (c *ERC721Client) SupportsERC721(ctx context.Context) (bool, error) {
+	return c.SupportsInterface(ctx, InterfaceIDERC721)
+}
+
+// GetName 合约名称（未实现 IERC721Metadata 时返回错误）
+func (c *ERC721Client) GetName(ctx context.Context) (string, error) {
+	return c.callString(ctx, "name")
+}
+
+// GetSymbol 合约符号
+func (c *ERC721Client) GetSymbol(ctx context.Context) (string, error) {
+	return c.callString(ctx, "symbol")
+}
+
+// GetTokenURI 获取 token URI
+func (c *ERC721Client) GetTokenURI(ctx context.Context, tokenID *big.Int) (string, error) {
+	return c.callString(ctx, "tokenURI", tokenID)
+}
+
+// GetOwner 获取 NFT 所有者
+func (c *ERC721Client) GetOwner(ctx context.Context, tokenID *big.Int) (common.Address, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "ownerOf", tokenID); err != nil {
+		return common.Address{}, err
+	}
+	return *abi.ConvertType(out[0], new(common.Address)).(*common.Address), nil
+}
+
+// GetBalanceOf 获取地址拥有的 NFT 数量
+func (c *ERC721Client) GetBalanceOf(ctx context.Context, address common.Address) (*big.Int, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "balanceOf", address); err != nil {
+		return nil, err
+	}
+	return *abi.ConvertType(out[0], new(*big.Int)).(**big.Int), nil
+}
+
+// IsApprovedForAll 检查 operator 是否被 owner 授权管理其全部 NFT
+func (c *ERC721Client) IsApprovedForAll(ctx context.Context, owner, operator common.Address) (bool, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, "isApprovedForAll", owner, operator); err != nil {
+		return false, err
+	}
+	return *abi.ConvertType(out[0], new(bool)).(*bool), nil
+}
+
+// CheckIfMinted 检查 NFT 是否存在（ownerOf 对不存在的 token 会 revert）
+func (c *ERC721Client) CheckIfMinted(ctx context.Context, tokenID *big.Int) (bool, error) {
+	if _, err := c.GetOwner(ctx, tokenID); err != nil {
+		if strings.Contains(err.Error(), "execution reverted") {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ParseTransfer 解析 Transfer 日志
+func (c *ERC721Client) ParseTransfer(log types.Log) (*ERC721Transfer, error) {
+	event := new(ERC721Transfer)
+	if err := c.contract.UnpackLog(event, "Transfer", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseApproval 解析 Approval 日志
+func (c *ERC721Client) ParseApproval(log types.Log) (*ERC721Approval, error) {
+	event := new(ERC721Approval)
+	if err := c.contract.UnpackLog(event, "Approval", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+// ParseApprovalForAll 解析 ApprovalForAll 日志
+func (c *ERC721Client) ParseApprovalForAll(log types.Log) (*ERC721ApprovalForAll, error) {
+	event := new(ERC721ApprovalForAll)
+	if err := c.contract.UnpackLog(event, "ApprovalForAll", log); err != nil {
+		return nil, err
+	}
+	event.Raw = log
+	return event, nil
+}
+
+func (c *ERC721Client) callString(ctx context.Context, method string, args ...interface{}) (string, error) {
+	var out []interface{}
+	if err := c.contract.Call(&bind.CallOpts{Context: ctx}, &out, method, args...); err != nil {
+		return "", err
+	}
+	return *abi.ConvertType(out[0], new(string)).(*string), nil
+}
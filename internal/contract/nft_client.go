@@ -9,7 +9,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // 你的智能合约代码 (.sol)
@@ -46,24 +45,18 @@ import (
 
 // NFTClient 现在使用你的 KevinNFT 合约
 type NFTClient struct {
-	client   *ethclient.Client
+	client   Backend
 	contract *KevinNFT
 	address  common.Address
 }
 
-// NewNFTClient 创建新的 NFT 客户端
-func NewNFTClient(rpcURL string, contractAddress string) (*NFTClient, error) {
-	log.Printf("正在连接到以太坊节点（NFT合约）: %s", rpcURL)
+// NewNFTClient 创建新的 NFT 客户端（共享RPC连接池）
+func NewNFTClient(client Backend, contractAddress string) (*NFTClient, error) {
+	log.Printf("正在初始化NFT合约客户端: %s", contractAddress)
 
-	client, err := ethclient.Dial(rpcURL)
+	c, err := BindNFTClient(client, contractAddress)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum client: %v", err)
-	}
-
-	address := common.HexToAddress(contractAddress)
-	contract, err := NewKevinNFT(address, client)
-	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate contract: %v", err)
+		return nil, err
 	}
 
 	// 测试连接
@@ -73,8 +66,17 @@ func NewNFTClient(rpcURL string, contractAddress string) (*NFTClient, error) {
 	}
 
 	log.Printf("✅ NFT合约连接成功，网络ID: %v", networkID)
-	log.Printf("✅ NFT合约地址: %s", address.Hex())
+	log.Printf("✅ NFT合约地址: %s", c.address.Hex())
+	return c, nil
+}
 
+// BindNFTClient 只绑定合约、不测试连接（离线子命令使用，任何链上调用都会返回错误）
+func BindNFTClient(client Backend, contractAddress string) (*NFTClient, error) {
+	address := common.HexToAddress(contractAddress)
+	contract, err := NewKevinNFT(address, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate contract: %v", err)
+	}
 	return &NFTClient{
 		client:   client,
 		contract: contract,
@@ -128,6 +130,45 @@ func (c *NFTClient) GetBalanceOf(ctx context.Context, address common.Address) (*
 	return c.contract.BalanceOf(&bind.CallOpts{Context: ctx}, address)
 }
 
+// IsApprovedForAll 检查 operator 是否被 owner 授权管理其全部 NFT
+func (c *NFTClient) IsApprovedForAll(ctx context.Context, owner, operator common.Address) (bool, error) {
+	return c.contract.IsApprovedForAll(&bind.CallOpts{Context: ctx}, owner, operator)
+}
+
+// GetContractOwner 获取合约所有者（Ownable）
+func (c *NFTClient) GetContractOwner(ctx context.Context) (common.Address, error) {
+	return c.contract.Owner(&bind.CallOpts{Context: ctx})
+}
+
+// CollectionStats KevinNFT.getStats 的返回值
+type CollectionStats struct {
+	NextTokenID    *big.Int // 下一个铸造的 TokenID
+	TotalSupply    *big.Int // 已铸造数量
+	Remaining      *big.Int // 剩余可铸造数量
+	MintPrice      *big.Int // 铸造价格（wei）
+	MintingEnabled bool     // 是否开放铸造
+}
+
+// GetStats 一次调用读取供应量、铸造价格与铸造开关
+func (c *NFTClient) GetStats(ctx context.Context) (*CollectionStats, error) {
+	stats, err := c.contract.GetStats(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	return &CollectionStats{
+		NextTokenID:    stats.CurrentTokenId,
+		TotalSupply:    stats.CurrentSupply,
+		Remaining:      stats.Remaining,
+		MintPrice:      stats.Price,
+		MintingEnabled: stats.MintingEnabled,
+	}, nil
+}
+
+// GetMaxSupply 获取最大供应量
+func (c *NFTClient) GetMaxSupply(ctx context.Context) (*big.Int, error) {
+	return c.contract.MaxSupply(&bind.CallOpts{Context: ctx})
+}
+
 // CheckIfMinted 检查 NFT 是否已被铸造
 func (c *NFTClient) CheckIfMinted(ctx context.Context, tokenID *big.Int) (bool, error) {
 	_, err := c.contract.OwnerOf(&bind.CallOpts{Context: ctx}, tokenID)
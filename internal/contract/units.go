@@ -0,0 +1,46 @@
+package contract
+
+import (
+	"math/big"
+	"strings"
+)
+
+// FormatUnits 将最小单位的整数金额按精度转换为十进制字符串（不丢失精度）
+// 例如 FormatUnits(1500000, 6) = "1.5"
+func FormatUnits(amount *big.Int, decimals uint8) string {
+	if amount == nil {
+		return "0"
+	}
+	if decimals == 0 {
+		return amount.String()
+	}
+
+	negative := amount.Sign() < 0
+	digits := new(big.Int).Abs(amount).String()
+
+	// 左侧补零，保证至少有 decimals+1 位
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+
+	intPart := digits[:len(digits)-int(decimals)]
+	fracPart := strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+
+	result := intPart
+	if fracPart != "" {
+		result += "." + fracPart
+	}
+	if negative {
+		result = "-" + result
+	}
+	return result
+}
+
+// FormatUnitsString 同 FormatUnits，输入为十进制字符串（数据库中的金额字段）
+func FormatUnitsString(amount string, decimals uint8) string {
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return amount
+	}
+	return FormatUnits(value, decimals)
+}
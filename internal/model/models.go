@@ -26,9 +26,20 @@ type Auction struct {
 	StartTime     uint64
 	EndTime       uint64
 	Ended         bool
-	TxHash        string    `gorm:"size:66"`        // size:66: 字符串最大长度66个字符（以太坊交易哈希长度）
-	CreatedAt     time.Time `gorm:"autoCreateTime"` // autoCreateTime: 自动设置创建时间，记录插入时自动填充
-	UpdatedAt     time.Time `gorm:"autoUpdateTime"` // autoUpdateTime: 自动更新时间，记录修改时自动更新
+	UseERC20      bool      `gorm:"index"`                     // 是否使用ERC20代币支付
+	PaymentToken  string    `gorm:"size:42"`                   // ERC20代币地址，ETH拍卖为空
+	TxHash        string    `gorm:"size:66"`                   // size:66: 字符串最大长度66个字符（以太坊交易哈希长度）
+	BlockNumber   uint64    `gorm:"index"`                     // 创建拍卖的区块高度
+	BlockHash     string    `gorm:"size:66"`                   // 创建拍卖的区块哈希（用于重组检测）
+	ChainState    string    `gorm:"size:16;default:'pending'"` // 链上确认状态: pending, confirmed, orphaned
+	CreatedAt     time.Time `gorm:"autoCreateTime"`            // autoCreateTime: 自动设置创建时间，记录插入时自动填充
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`            // autoUpdateTime: 自动更新时间，记录修改时自动更新
+
+	// 展示字段（不入库，由 TokenService 按支付币种精度填充）
+	PaymentSymbol          string `gorm:"-"`
+	PaymentDecimals        uint8  `gorm:"-"`
+	StartingPriceFormatted string `gorm:"-"`
+	HighestBidFormatted    string `gorm:"-"`
 }
 
 // NFTInfo NFT合约信息表
@@ -38,22 +49,27 @@ type NFTInfo struct {
 	TokenID         string `gorm:"size:100;comment:Token ID"` // 新增TokenID字段
 	Name            string `gorm:"size:255;comment:NFT名称"`
 	Symbol          string `gorm:"size:50;comment:NFT符号"`
-	Uri             string `gorm:"size:50;comment:URI"`
-	TotalSupply     string `gorm:"type:varchar(100);comment:总供应量"`
-	Owner           string `gorm:"size:42;comment:合约所有者"`
+	Uri             string `gorm:"type:text;comment:URI"` // tokenURI 原文（data: URI 可能很长）
+	Owner           string `gorm:"size:42;comment:链上持有者"` // ownerOf：托管在拍卖中时为拍卖合约
+	// 托管：NFT在拍卖中时链上持有者是拍卖合约，实际所有者仍是卖家
+	BeneficialOwner   string `gorm:"size:42;index;comment:实际所有者" json:"beneficial_owner"`
+	EscrowedInAuction uint64 `gorm:"index;comment:托管所在拍卖ID" json:"escrowed_in_auction"` // 0 表示未托管
 	// 授权事件
 	// Approved string `gorm:"size:42;comment:合约授权地址"`
 	ApprovedAddress string    `gorm:"size:42;comment:被授权地址"`
 	ApprovedAt      time.Time `gorm:"comment:授权时间"`
 	ApprovalTxHash  string    `gorm:"size:66;comment:授权交易哈希"`
 
-	Blockchain     string    `gorm:"size:20;default:'sepolia';comment:区块链网络"`
-	LastSyncTime   time.Time `gorm:"comment:最后同步时间"`
-	ContractName   string    `gorm:"size:255;comment:合约名称"`       // 新增
-	ContractSymbol string    `gorm:"size:50;comment:合约符号"`        // 新增
-	IsMinted       bool      `gorm:"default:false;comment:是否已铸造"` // 新增
-	CreatedAt      time.Time `gorm:"autoCreateTime"`
-	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+	Blockchain   string    `gorm:"size:20;default:'sepolia';comment:区块链网络"`
+	LastSyncTime time.Time `gorm:"comment:最后同步时间"`
+	IsMinted     bool      `gorm:"default:false;comment:是否已铸造"` // 新增
+	MintedBlock  uint64    `gorm:"index;comment:铸造所在区块"`        // 0 表示未观察到铸造事件（全量同步或发现合集前已存在）
+	MintedAt     time.Time `gorm:"comment:铸造时间"`
+	BlockNumber  uint64    `gorm:"index;comment:最近一次变更所在区块"`
+	BlockHash    string    `gorm:"size:66;comment:最近一次变更所在区块哈希"`
+	ChainState   string    `gorm:"size:16;default:'pending';comment:链上确认状态"` // pending, confirmed, orphaned
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
 }
 
 // User 用户模型
@@ -71,44 +87,391 @@ func (Auction) TableName() string {
 	return "auctions" // 明确指定表名为"auctions"，而不是GORM默认的复数形式"auctions"（这里相同，但习惯性显式声明）
 }
 
-// EventSync 事件同步记录
+// EventSync 事件同步记录（每个合约一个检查点）
 type EventSync struct {
-	ID        uint   `gorm:"primarykey"`
-	EventType string `gorm:"size:50;uniqueIndex"` // auction_events, bid_events, nft_events
-	LastBlock uint64 // 最后处理的区块
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID              uint   `gorm:"primarykey"`
+	EventType       string `gorm:"size:100;uniqueIndex"` // 检查点键: nft_events:<合约地址>, auction_events:<合约地址>
+	ContractAddress string `gorm:"size:42;index"`        // 合约地址
+	LastBlock       uint64 // 最后完整处理的区块
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
-// Bid 当前出价记录（用于快速查询）
+// Bid 当前出价记录（用于快速查询）：每个拍卖一行，对应出价链中 IsHighest 的那次出价
+// 与 bid_histories 在同一事务中维护，拍卖没有未被重组移除的出价时不存在
 type Bid struct {
 	ID          uint      `gorm:"primarykey"`
-	AuctionID   uint64    `gorm:"index;not null"`             // 拍卖ID，加索引
+	AuctionID   uint64    `gorm:"uniqueIndex;not null"`       // 拍卖ID，每个拍卖一行
+	BidID       uint      `gorm:"index"`                      // 对应的出价记录 bid_histories.id
 	Bidder      string    `gorm:"size:42;not null;index"`     // 出价者地址
 	Amount      string    `gorm:"type:varchar(100);not null"` // 出价金额
-	TxHash      string    `gorm:"size:66;uniqueIndex"`        // 交易哈希
-	Status      string    `gorm:"size:20;default:'pending'"`  // 状态: pending, confirmed, failed
-	IsHighest   bool      `gorm:"default:false"`              // 是否是当前最高出价
-	BlockNumber uint64    `gorm:"index"`                      // 区块高度
+	TxHash      string    `gorm:"size:66;index"`              // 交易哈希
+	LogIndex    uint      // 日志在区块中的序号
+	Status      string    `gorm:"size:20;default:'pending'"` // 状态: pending, confirmed
+	IsHighest   bool      `gorm:"default:false"`             // 是否是当前最高出价
+	BlockNumber uint64    `gorm:"index"`                     // 区块高度
 	ConfirmedAt time.Time // 确认时间
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
 }
 
-// 更新 BidHistory，添加更多字段
+// BidHistory 出价记录（NewBid 日志，每条日志一行）
+// 合约要求每次出价高于当前最高价，所以按 (区块, 日志序号) 排列后每次出价都会成为最高价并顶替前一次
 type BidHistory struct {
 	ID            uint      `gorm:"primarykey"`
-	AuctionID     uint64    `gorm:"index"`                       // 拍卖ID
-	Bidder        string    `gorm:"size:42"`                     // 出价者地址
-	Amount        string    `gorm:"type:varchar(100)"`           // 出价金额
-	TxHash        string    `gorm:"size:66;uniqueIndex"`         // 交易哈希
-	Status        string    `gorm:"size:20;default:'submitted'"` // 状态: submitted, pending, confirmed, failed
-	BlockNumber   uint64    `gorm:"index"`                       // 区块高度
+	AuctionID     uint64    `gorm:"index"`                              // 拍卖ID
+	Bidder        string    `gorm:"size:42"`                            // 出价者地址
+	Amount        string    `gorm:"type:varchar(100)"`                  // 出价金额
+	TxHash        string    `gorm:"size:66;uniqueIndex:idx_bid_tx_log"` // 交易哈希
+	LogIndex      uint      `gorm:"uniqueIndex:idx_bid_tx_log"`         // 日志在区块中的序号（同一交易可有多条出价日志）
+	Status        string    `gorm:"size:20;default:'submitted'"`        // 状态: submitted, pending, confirmed, orphaned, failed
+	BlockNumber   uint64    `gorm:"index"`                              // 区块高度
+	BlockHash     string    `gorm:"size:66"`                            // 区块哈希（用于重组检测）
 	BlockTime     uint64    // 区块时间戳
 	GasPrice      string    `gorm:"type:varchar(50)"` // Gas价格
 	GasUsed       uint64    // Gas使用量
 	Confirmations uint      `gorm:"default:0"` // 确认数
 	ErrorMessage  string    `gorm:"type:text"` // 错误信息（如果有）
+	IsHighest     bool      `gorm:"index"`     // 是否为拍卖当前最高出价
+	PreviousBidID uint      `gorm:"index"`     // 被这次出价顶替的最高出价，第一次出价为0
 	CreatedAt     time.Time `gorm:"autoCreateTime"`
 	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+
+	// 展示字段（不入库）
+	Symbol          string `gorm:"-"`
+	AmountFormatted string `gorm:"-"`
+}
+
+// Token ERC20代币元数据缓存（symbol/decimals/name 不可变，查询一次后持久化）
+type Token struct {
+	ID        uint      `gorm:"primarykey"`
+	Address   string    `gorm:"size:42;uniqueIndex"` // 代币地址（小写）
+	Name      string    `gorm:"size:255"`
+	Symbol    string    `gorm:"size:50"`
+	Decimals  uint8     // 精度
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// 链上确认状态
+const (
+	ChainStatePending   = "pending"   // 已上链，确认数不足
+	ChainStateConfirmed = "confirmed" // 达到确认深度
+	ChainStateOrphaned  = "orphaned"  // 所在区块被重组移除
+)
+
+// ProcessedBlock 已处理区块的哈希（只保留未确认窗口附近的区块，用于重组检测）
+type ProcessedBlock struct {
+	ID        uint   `gorm:"primarykey"`
+	Number    uint64 `gorm:"uniqueIndex"` // 区块高度
+	Hash      string `gorm:"size:66"`     // 处理时的区块哈希
+	CreatedAt time.Time
+}
+
+// 结算尝试状态
+const (
+	SettlementSubmitted = "submitted" // 已广播，等待上链
+	SettlementMined     = "mined"     // 已上链且执行成功
+	SettlementReverted  = "reverted"  // 已上链但执行失败
+	SettlementReplaced  = "replaced"  // 超时未上链，已用更高gas替换
+	SettlementFailed    = "failed"    // 发送失败（估算gas、签名或广播出错）
+)
+
+// SettlementAttempt 自动结算（endAuction）尝试记录
+type SettlementAttempt struct {
+	ID           uint      `gorm:"primarykey"`
+	AuctionID    uint64    `gorm:"index"`         // 拍卖ID
+	Keeper       string    `gorm:"size:42"`       // 签名账户
+	TxHash       string    `gorm:"size:66;index"` // 交易哈希（发送失败时为空）
+	Nonce        uint64    // 交易nonce
+	GasTipCap    string    `gorm:"type:varchar(50)"` // 小费上限（wei）
+	GasFeeCap    string    `gorm:"type:varchar(50)"` // 总费用上限（wei）
+	Status       string    `gorm:"size:20;index"`    // submitted, mined, reverted, replaced, failed
+	BlockNumber  uint64    // 上链区块
+	GasUsed      uint64    // 实际gas消耗
+	ErrorMessage string    `gorm:"type:text"` // 错误信息
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+// RawEvent 原始事件日志（只追加）：每条链上日志一行，可据此回放重建业务表
+type RawEvent struct {
+	ID              uint64    `gorm:"primaryKey;autoIncrement"`
+	ChainID         uint64    `gorm:"uniqueIndex:idx_raw_event_key"`         // 链ID
+	BlockHash       string    `gorm:"size:66;uniqueIndex:idx_raw_event_key"` // 区块哈希（重组后的新区块是新的一行）
+	TxHash          string    `gorm:"size:66;uniqueIndex:idx_raw_event_key"` // 交易哈希
+	LogIndex        uint      `gorm:"uniqueIndex:idx_raw_event_key"`         // 日志在区块中的序号
+	BlockNumber     uint64    `gorm:"index"`                                 // 区块高度
+	BlockTime       uint64    // 区块时间戳
+	TxIndex         uint      // 交易在区块中的序号
+	ContractAddress string    `gorm:"size:42;index"` // 合约地址
+	EventName       string    `gorm:"size:64;index"` // 事件名（ABI解码）
+	Topics          string    `gorm:"type:text"`     // 主题（JSON数组）
+	Data            string    `gorm:"type:text"`     // 原始数据（十六进制）
+	Decoded         string    `gorm:"type:text"`     // 解码后的参数（JSON）
+	Enrichment      string    `gorm:"type:text"`     // 处理时从链上补充的数据（JSON），回放时代替RPC查询
+	Removed         bool      `gorm:"index"`         // 所在区块已被重组移除
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+// Collection NFT合约注册表与合约级状态
+// 主合约（KevinNFT）由 getStats / maxSupply 初始化，之后由铸造与 PriceUpdated / MintingToggled / OwnershipTransferred 事件维护
+// 拍卖中出现或管理员注册的第三方合集只保存名称、符号，通过 ERC-165 检查后索引 Transfer
+// 合约名称、符号、供应量只存在这里，不再冗余到每个NFT
+type Collection struct {
+	ID              uint64    `gorm:"primaryKey;autoIncrement"`
+	ContractAddress string    `gorm:"size:42;uniqueIndex"` // NFT合约地址
+	Name            string    `gorm:"size:255"`
+	Symbol          string    `gorm:"size:50"`
+	Source          string    `gorm:"size:16;index"` // 来源: config, auction, admin
+	Supported       bool      `gorm:"index"`         // 通过 ERC-165 检查，建立索引
+	DiscoveredBlock uint64    // 发现时的区块（从该区块开始索引）
+	Owner           string    `gorm:"size:42"`           // 合约所有者
+	MintPrice       string    `gorm:"type:varchar(100)"` // 当前铸造价格（wei）
+	MintingEnabled  bool      // 是否开放铸造
+	MaxSupply       uint64    // 最大供应量
+	TotalSupply     uint64    // 已铸造数量
+	RemainingSupply uint64    // 剩余可铸造数量
+	NextTokenID     uint64    // 下一个铸造的 TokenID
+	BlockNumber     uint64    // 最近一次状态变更所在区块
+	LastSyncTime    time.Time // 最近一次从链上同步的时间
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+// 合约来源
+const (
+	CollectionSourceConfig  = "config"  // 配置文件中的主合约（KevinNFT）
+	CollectionSourceAuction = "auction" // 从拍卖中发现
+	CollectionSourceAdmin   = "admin"   // 管理员手动注册
+)
+
+// Withdrawal 合约所有者提取铸造收入的记录（Withdrawn 事件）
+type Withdrawal struct {
+	ID              uint64    `gorm:"primaryKey;autoIncrement"`
+	ContractAddress string    `gorm:"size:42;index"`
+	Owner           string    `gorm:"size:42;index"`     // 提取者
+	Amount          string    `gorm:"type:varchar(100)"` // 提取金额（wei）
+	TxHash          string    `gorm:"size:66;uniqueIndex:idx_withdrawal_tx_log"`
+	LogIndex        uint      `gorm:"uniqueIndex:idx_withdrawal_tx_log"`
+	BlockNumber     uint64    `gorm:"index"`
+	BlockHash       string    `gorm:"size:66"`
+	BlockTime       uint64    // 区块时间戳
+	ChainState      string    `gorm:"size:16;default:'pending'"` // pending, confirmed, orphaned
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+// OperatorApproval 全量授权（ApprovalForAll 事件），每个 (合约, 持有者, 操作者) 一行
+type OperatorApproval struct {
+	ID              uint64    `gorm:"primaryKey;autoIncrement"`
+	ContractAddress string    `gorm:"size:42;uniqueIndex:idx_operator_approval"`
+	Owner           string    `gorm:"size:42;uniqueIndex:idx_operator_approval"` // NFT持有者
+	Operator        string    `gorm:"size:42;uniqueIndex:idx_operator_approval"` // 被授权的操作者
+	Approved        bool      `gorm:"index"`                                     // 当前是否授权
+	TxHash          string    `gorm:"size:66"`                                   // 最近一次变更的交易
+	BlockNumber     uint64    `gorm:"index"`
+	BlockHash       string    `gorm:"size:66"`
+	ChainState      string    `gorm:"size:16;default:'pending'"` // pending, confirmed, orphaned
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+// TokenMetadata tokenURI 解析出的 ERC-721 元数据，每个 (合约, TokenID) 一行
+// tokenURI 变化时重新进入 pending，由元数据抓取任务按退避时间重试
+type TokenMetadata struct {
+	ID              uint64    `gorm:"primaryKey;autoIncrement"`
+	ContractAddress string    `gorm:"size:42;uniqueIndex:idx_metadata_token"`
+	TokenID         string    `gorm:"size:100;uniqueIndex:idx_metadata_token"`
+	TokenURI        string    `gorm:"type:text"` // 抓取时使用的 tokenURI
+	Name            string    `gorm:"size:255"`
+	Description     string    `gorm:"type:text"`
+	Image           string    `gorm:"type:text"` // 图片地址（保留 ipfs:// 等原始形式）
+	AnimationURL    string    `gorm:"type:text"`
+	ExternalURL     string    `gorm:"type:text"`
+	Raw             string    `gorm:"type:text" json:"-"` // 原始JSON（外部内容，不通过接口返回）
+	Status          string    `gorm:"size:16;index"`      // pending, fetched, failed
+	Attempts        int       // 连续失败次数
+	LastError       string    `gorm:"type:text"`
+	NextAttemptAt   time.Time `gorm:"index"` // 下一次抓取时间（退避）
+	FetchedAt       time.Time
+
+	// 稀有度（同一合集内已抓取元数据的NFT之间比较，属性变化后重新计算）
+	RarityScore       float64 `gorm:"index"` // 属性稀有度得分：Σ 总数/该属性值数量，越高越稀有
+	StatisticalRarity float64 // 统计稀有度：Π 该属性值数量/总数，越低越稀有
+	RarityRank        int     `gorm:"index"` // 按 RarityScore 的排名（1 最稀有，0 表示未计算）
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// 元数据抓取状态
+const (
+	MetadataStatusPending = "pending" // 等待抓取（含退避中的重试）
+	MetadataStatusFetched = "fetched" // 已解析
+	MetadataStatusFailed  = "failed"  // 超过最大重试次数
+)
+
+// NFTImage 元数据 image 的本地缓存，每个 (合约, TokenID) 一行
+// 文件按内容 SHA-256 保存（原图与各尺寸缩略图），多个NFT使用同一图片时共享文件
+type NFTImage struct {
+	ID              uint64    `gorm:"primaryKey;autoIncrement"`
+	ContractAddress string    `gorm:"size:42;uniqueIndex:idx_image_token"`
+	TokenID         string    `gorm:"size:100;uniqueIndex:idx_image_token"`
+	SourceURI       string    `gorm:"type:text"`     // 元数据中的图片地址
+	ContentHash     string    `gorm:"size:64;index"` // 原图 SHA-256（十六进制）
+	ContentType     string    `gorm:"size:32"`       // image/png, image/jpeg, image/gif
+	Bytes           int64     // 原图字节数
+	Width           int       // 原图宽度
+	Height          int       // 原图高度
+	Status          string    `gorm:"size:16;index"` // pending, cached, failed
+	Attempts        int       // 连续失败次数
+	LastError       string    `gorm:"type:text"`
+	NextAttemptAt   time.Time `gorm:"index"`
+	CachedAt        time.Time
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
 }
+
+// 图片缓存状态
+const (
+	ImageStatusPending = "pending" // 等待下载（含退避中的重试）
+	ImageStatusCached  = "cached"  // 原图与缩略图已保存
+	ImageStatusFailed  = "failed"  // 超过最大重试次数或类型不支持
+)
+
+// TokenAttribute 元数据中的 attributes，每个属性一行（按 trait_type 统计与筛选）
+type TokenAttribute struct {
+	ID              uint64 `gorm:"primaryKey;autoIncrement"`
+	ContractAddress string `gorm:"size:42;index:idx_attribute_token"`
+	TokenID         string `gorm:"size:100;index:idx_attribute_token"`
+	TraitType       string `gorm:"size:255;index"`
+	Value           string `gorm:"type:text"`
+	DisplayType     string `gorm:"size:50"` // number, boost_percentage, date 等
+}
+
+// Transfer NFT转移记录（Transfer 日志，每条日志一行），用于所有权与来源追溯
+// NFTInfo.Owner 只保存当前持有者，历史持有者从这里查询
+type Transfer struct {
+	ID              uint64    `gorm:"primaryKey;autoIncrement"`
+	ContractAddress string    `gorm:"size:42;index:idx_transfer_token"`
+	TokenID         string    `gorm:"size:100;index:idx_transfer_token"`
+	From            string    `gorm:"size:42;index"`
+	To              string    `gorm:"size:42;index"`
+	Kind            string    `gorm:"size:16;index"` // mint, burn, transfer, escrow, settlement
+	TxHash          string    `gorm:"size:66;uniqueIndex:idx_transfer_tx_log"`
+	LogIndex        uint      `gorm:"uniqueIndex:idx_transfer_tx_log"`
+	BlockNumber     uint64    `gorm:"index"`
+	BlockHash       string    `gorm:"size:66"`
+	BlockTime       uint64    // 区块时间戳
+	ChainState      string    `gorm:"size:16;default:'pending'"` // pending, confirmed, orphaned
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+// 转移类型
+const (
+	TransferKindMint       = "mint"       // 从零地址转出
+	TransferKindBurn       = "burn"       // 转入零地址
+	TransferKindTransfer   = "transfer"   // 普通转移
+	TransferKindEscrow     = "escrow"     // 卖家转入拍卖合约（创建拍卖）
+	TransferKindSettlement = "settlement" // 拍卖合约转出（成交给赢家或无人出价退回卖家）
+)
+
+// Snapshot 按区块的持有者快照（空投、白名单），由 transfers 表计算后按名称保存
+type Snapshot struct {
+	ID              uint64 `gorm:"primaryKey;autoIncrement"`
+	Name            string `gorm:"size:100;uniqueIndex"`
+	ContractAddress string `gorm:"size:42;index"`
+	TokenID         string `gorm:"size:100"` // 为空表示整个合集
+	BlockNumber     uint64 // 快照区块（含该区块内的转移）
+	Timestamp       uint64 // 按时间戳创建时的请求时间，按区块创建时为0
+	Mode            string `gorm:"size:16"` // holder: 链上持有者, beneficial: 托管在拍卖中的算作卖家
+	HolderCount     int
+	TokenCount      int
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+// SnapshotHolder 快照中的持有者，每个 (快照, 地址) 一行
+type SnapshotHolder struct {
+	ID         uint64 `gorm:"primaryKey;autoIncrement"`
+	SnapshotID uint64 `gorm:"uniqueIndex:idx_snapshot_holder"`
+	Address    string `gorm:"size:42;uniqueIndex:idx_snapshot_holder"`
+	Quantity   int    // 持有数量
+	TokenIDs   string `gorm:"type:text"` // 逗号分隔，按数值排序
+}
+
+// BidRefund 被顶替出价的退款（合约 _refundPreviousBidder 不发事件，由出价记录推导）
+// 退款与顶替它的出价在同一笔交易中，每个被顶替的出价一行
+type BidRefund struct {
+	ID          uint      `gorm:"primarykey"`
+	AuctionID   uint64    `gorm:"index"`
+	BidID       uint      `gorm:"uniqueIndex"` // 被退款的出价
+	OutbidByID  uint      `gorm:"index"`       // 顶替它的出价
+	Bidder      string    `gorm:"size:42;index"`
+	Amount      string    `gorm:"type:varchar(100)"`
+	TxHash      string    `gorm:"size:66"` // 顶替出价的交易
+	LogIndex    uint      // 顶替出价的日志序号
+	BlockNumber uint64    `gorm:"index"`
+	BlockHash   string    `gorm:"size:66"`
+	BlockTime   uint64    // 区块时间戳
+	ChainState  string    `gorm:"size:16;default:'pending'"` // pending, confirmed, orphaned
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+
+	// 展示字段（不入库）
+	Symbol          string `gorm:"-"`
+	AmountFormatted string `gorm:"-"`
+}
+
+// LedgerEntry 拍卖合约资金流水（复式记账：资金从贷方账户流向借方账户）
+// 账户为地址或 escrow（拍卖合约托管）；escrow 的借贷差即合约应持有的资金
+type LedgerEntry struct {
+	ID            uint64    `gorm:"primaryKey;autoIncrement"`
+	Kind          string    `gorm:"size:32;uniqueIndex:idx_ledger_ref"` // bid_escrowed, refund_issued, seller_paid, admin_withdrawal
+	Ref           string    `gorm:"size:80;uniqueIndex:idx_ledger_ref"` // 来源记录：bid:<id>, refund:<id>, auction:<id>, tx:<hash>
+	AuctionID     uint64    `gorm:"index"`                              // 管理员提取为0
+	Currency      string    `gorm:"size:42;index"`                      // ERC20代币地址，ETH为空
+	DebitAccount  string    `gorm:"size:42;index"`                      // 收款方
+	CreditAccount string    `gorm:"size:42;index"`                      // 付款方
+	Amount        string    `gorm:"type:varchar(100)"`                  // 最小单位
+	TxHash        string    `gorm:"size:66;index"`
+	LogIndex      uint      // 来源日志序号（管理员提取为0）
+	BlockNumber   uint64    `gorm:"index"`
+	BlockTime     uint64    // 区块时间戳
+	ChainState    string    `gorm:"size:16;default:'pending'"` // pending, confirmed, orphaned
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+// 流水类型与托管账户
+const (
+	LedgerBidEscrowed   = "bid_escrowed"     // 出价：出价者 → escrow
+	LedgerRefundIssued  = "refund_issued"    // 被顶替退款：escrow → 出价者
+	LedgerSellerPaid    = "seller_paid"      // 成交：escrow → 卖家
+	LedgerAdminWithdraw = "admin_withdrawal" // 管理员提取：escrow → 管理员
+
+	LedgerEscrowAccount = "escrow"
+)
+
+// EscrowReconciliation 托管对账记录：流水推导的 escrow 余额与合约实际余额（ETH 余额 / ERC20 balanceOf）的比较
+type EscrowReconciliation struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement"`
+	Currency    string    `gorm:"size:42;index"` // ERC20代币地址，ETH为空
+	BlockNumber uint64    // 对账区块（拍卖合约事件已处理到的区块）
+	Expected    string    `gorm:"type:varchar(100)"` // 流水推导的余额
+	Actual      string    `gorm:"type:varchar(100)"` // 链上余额
+	Difference  string    `gorm:"type:varchar(100)"` // Actual - Expected
+	Status      string    `gorm:"size:16;index"`     // ok, mismatch, unavailable, error
+	Error       string    `gorm:"type:text"`
+	CheckedAt   time.Time `gorm:"autoCreateTime;index"`
+}
+
+// 对账结果
+const (
+	ReconciliationOK       = "ok"
+	ReconciliationMismatch = "mismatch"
+	ReconciliationError    = "error"
+
+	// ReconciliationUnavailable 节点没有对账区块的状态（非归档节点），无法比较，不算不一致
+	ReconciliationUnavailable = "unavailable"
+)
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"nft-auction-backend/internal/contract"
 	"nft-auction-backend/internal/model"
@@ -19,6 +21,8 @@ import (
 type AuctionService struct {
 	DB              *gorm.DB
 	AuctionContract contract.AuctionContract
+
+	expiry *ExpiryScheduler // 可选：拍卖保存时同步更新过期调度
 }
 
 // NewAuctionService 创建拍卖服务
@@ -29,6 +33,11 @@ func NewAuctionService(db *gorm.DB, auctionContract contract.AuctionContract) *A
 	}
 }
 
+// SetExpiryScheduler 注册过期调度器
+func (s *AuctionService) SetExpiryScheduler(scheduler *ExpiryScheduler) {
+	s.expiry = scheduler
+}
+
 // ==================== 数据库操作 ====================
 func (s *AuctionService) GetContractAddress() common.Address {
 	return s.AuctionContract.GetContractAddress()
@@ -53,6 +62,7 @@ func (s *AuctionService) SaveAuction(ctx context.Context, auction *model.Auction
 			return fmt.Errorf("创建拍卖失败: %v", err)
 		}
 		log.Printf("✅ 新增拍卖 #%d", auction.AuctionID)
+		s.trackExpiry(auction)
 	} else {
 		// 更新现有记录
 		existing.NFTContract = auction.NFTContract
@@ -65,91 +75,427 @@ func (s *AuctionService) SaveAuction(ctx context.Context, auction *model.Auction
 		existing.EndTime = auction.EndTime
 		existing.Ended = auction.Ended
 		existing.Status = auction.Status
+		existing.UseERC20 = auction.UseERC20
+		existing.PaymentToken = auction.PaymentToken
+		if auction.TxHash != "" {
+			existing.TxHash = auction.TxHash
+		}
+		if auction.BlockHash != "" {
+			// 只有来自事件的更新才携带区块信息
+			existing.BlockNumber = auction.BlockNumber
+			existing.BlockHash = auction.BlockHash
+			existing.ChainState = auction.ChainState
+		}
 		existing.UpdatedAt = now
 
 		if err := s.DB.WithContext(ctx).Save(&existing).Error; err != nil {
 			return fmt.Errorf("更新拍卖失败: %v", err)
 		}
 		log.Printf("🔄 更新拍卖 #%d", auction.AuctionID)
+		s.trackExpiry(&existing)
+	}
+
+	return nil
+}
+
+// trackExpiry 根据拍卖最新状态更新过期调度
+func (s *AuctionService) trackExpiry(auction *model.Auction) {
+	if s.expiry == nil {
+		return
+	}
+	if auction.Ended || auction.ChainState == model.ChainStateOrphaned {
+		s.expiry.Cancel(auction.AuctionID)
+		return
 	}
+	s.expiry.Schedule(auction.AuctionID, auction.EndTime)
+}
 
+// MarkAuctionEnded 按已知的结束状态标记拍卖结束（回放退回事件时使用，不查询链上）
+func (s *AuctionService) MarkAuctionEnded(ctx context.Context, auctionID uint64, status string) error {
+	var auction model.Auction
+	if err := s.DB.WithContext(ctx).Where("auction_id = ?", auctionID).First(&auction).Error; err != nil {
+		return fmt.Errorf("查询拍卖 #%d 失败: %v", auctionID, err)
+	}
+	auction.Ended = true
+	auction.Status = status
+	if err := s.DB.WithContext(ctx).Save(&auction).Error; err != nil {
+		return fmt.Errorf("更新拍卖 #%d 结束状态失败: %v", auctionID, err)
+	}
+	s.trackExpiry(&auction)
 	return nil
 }
 
-// SaveBidHistory 保存出价历史记录
+// MarkAuctionExpired 已过结束时间但未结算的拍卖切换为 expired_pending_settlement
+func (s *AuctionService) MarkAuctionExpired(ctx context.Context, auctionID uint64) error {
+	result := s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Where("auction_id = ? AND ended = ? AND end_time > 0 AND end_time <= ? AND chain_state <> ? AND status <> ?",
+			auctionID, false, uint64(time.Now().Unix()), model.ChainStateOrphaned, contract.AuctionStatusExpired).
+		Update("status", contract.AuctionStatusExpired)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("⏰ 拍卖 #%d 已到期，等待结算", auctionID)
+	}
+	return nil
+}
+
+// SaveBidHistory 保存出价历史记录，并在同一事务中更新该拍卖的出价链与退款记录
+// 新出价排在当前最高出价之后（实时处理的正常情况）时只追加：顶替前一最高出价、写入它的退款与两条流水；
+// 已存在的出价（重组后被重新包含）或乱序到达的出价重建整条出价链
 func (s *AuctionService) SaveBidHistory(ctx context.Context, bid *model.BidHistory) error {
 	if bid == nil {
 		return fmt.Errorf("出价记录为空")
 	}
 
-	// 检查是否已存在（根据交易哈希）
-	var existing model.BidHistory
-	if err := s.DB.WithContext(ctx).Where("tx_hash = ?", bid.TxHash).First(&existing).Error; err == nil {
-		// 已存在，更新
-		existing.Amount = bid.Amount
-		existing.Status = bid.Status
-		existing.BlockNumber = bid.BlockNumber
-		existing.BlockTime = bid.BlockTime
-		existing.UpdatedAt = time.Now()
+	created := false
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 检查是否已存在（根据交易哈希 + 日志序号）
+		var existing model.BidHistory
+		if err := tx.Where("tx_hash = ? AND log_index = ?", bid.TxHash, bid.LogIndex).
+			First(&existing).Error; err == nil {
+			// 已存在，更新
+			existing.Amount = bid.Amount
+			existing.Status = bid.Status
+			existing.BlockNumber = bid.BlockNumber
+			existing.BlockHash = bid.BlockHash
+			existing.BlockTime = bid.BlockTime
+			existing.UpdatedAt = time.Now()
+
+			if err := tx.Save(&existing).Error; err != nil {
+				return fmt.Errorf("更新出价记录失败: %v", err)
+			}
+			*bid = existing
+		} else {
+			// 新记录
+			now := time.Now()
+			bid.CreatedAt = now
+			bid.UpdatedAt = now
+
+			if err := tx.Create(bid).Error; err != nil {
+				return fmt.Errorf("创建出价记录失败: %v", err)
+			}
+			created = true
+		}
 
-		if err := s.DB.WithContext(ctx).Save(&existing).Error; err != nil {
-			return fmt.Errorf("更新出价记录失败: %v", err)
+		if created && bid.Status != model.ChainStateOrphaned {
+			appended, err := appendBid(tx, bid)
+			if err != nil || appended {
+				return err
+			}
+		}
+		return rebuildBidChain(tx, bid.AuctionID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if created {
+		log.Printf("✅ 保存出价记录: AuctionID=%d, Bidder=%s", bid.AuctionID, bid.Bidder)
+	}
+	return nil
+}
+
+// appendBid 新出价追加到出价链末尾：只更新前一最高出价、它的退款、当前出价与两条流水
+// 新出价不在当前最高出价之后时返回 false，由调用方重建整条出价链
+func appendBid(tx *gorm.DB, bid *model.BidHistory) (bool, error) {
+	var highest []model.BidHistory
+	if err := tx.Where("auction_id = ? AND is_highest = ? AND status <> ? AND id <> ?",
+		bid.AuctionID, true, model.ChainStateOrphaned, bid.ID).
+		Limit(1).Find(&highest).Error; err != nil {
+		return false, fmt.Errorf("查询最高出价失败: %v", err)
+	}
+
+	var previous *model.BidHistory
+	if len(highest) > 0 {
+		previous = &highest[0]
+		if bid.BlockNumber < previous.BlockNumber ||
+			(bid.BlockNumber == previous.BlockNumber && bid.LogIndex <= previous.LogIndex) {
+			return false, nil
+		}
+	} else {
+		// 没有最高出价但有其他出价：出价链不完整，重建
+		var count int64
+		if err := tx.Model(&model.BidHistory{}).
+			Where("auction_id = ? AND status <> ? AND id <> ?", bid.AuctionID, model.ChainStateOrphaned, bid.ID).
+			Count(&count).Error; err != nil {
+			return false, fmt.Errorf("查询出价失败: %v", err)
+		}
+		if count > 0 {
+			return false, nil
+		}
+	}
+
+	currency, err := auctionCurrency(tx, bid.AuctionID)
+	if err != nil {
+		return false, err
+	}
+	updates := map[string]interface{}{"is_highest": true}
+	if previous != nil {
+		updates["previous_bid_id"] = previous.ID
+		if err := tx.Model(&model.BidHistory{}).Where("id = ?", previous.ID).
+			Update("is_highest", false).Error; err != nil {
+			return false, fmt.Errorf("更新出价链失败: %v", err)
+		}
+		if err := saveBidRefund(tx, newBidRefund(*previous, *bid)); err != nil {
+			return false, err
+		}
+		if err := saveLedgerEntry(tx, refundLedgerEntry(currency, *previous, *bid)); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Model(&model.BidHistory{}).Where("id = ?", bid.ID).Updates(updates).Error; err != nil {
+		return false, fmt.Errorf("更新出价链失败: %v", err)
+	}
+	bid.IsHighest = true
+	if previous != nil {
+		bid.PreviousBidID = previous.ID
+	}
+	if err := saveLedgerEntry(tx, bidLedgerEntry(currency, *bid)); err != nil {
+		return false, err
+	}
+	return true, syncCurrentBid(tx, bid.AuctionID, []model.BidHistory{*bid})
+}
+
+// newBidRefund 被顶替出价的退款记录（与顶替出价同一交易、同一确认状态）
+func newBidRefund(previous, outbid model.BidHistory) *model.BidRefund {
+	return &model.BidRefund{
+		AuctionID:   previous.AuctionID,
+		BidID:       previous.ID,
+		OutbidByID:  outbid.ID,
+		Bidder:      previous.Bidder,
+		Amount:      previous.Amount,
+		TxHash:      outbid.TxHash,
+		LogIndex:    outbid.LogIndex,
+		BlockNumber: outbid.BlockNumber,
+		BlockHash:   outbid.BlockHash,
+		BlockTime:   outbid.BlockTime,
+		ChainState:  bidChainState(outbid.Status),
+	}
+}
+
+// saveBidRefund 按被顶替的出价写入退款记录，已存在时覆盖
+func saveBidRefund(tx *gorm.DB, refund *model.BidRefund) error {
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "bid_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"outbid_by_id", "bidder", "amount", "tx_hash", "log_index",
+			"block_number", "block_hash", "block_time", "chain_state", "updated_at",
+		}),
+	}).Create(refund).Error; err != nil {
+		return fmt.Errorf("保存退款记录失败: %v", err)
+	}
+	return nil
+}
+
+// rebuildBidChain 按 (区块, 日志序号) 重建拍卖的出价链：
+// 每次出价的 PreviousBidID 指向它顶替的出价，只有最后一次出价 IsHighest，
+// 每个被顶替的出价对应一条退款记录（与顶替出价同一交易、同一确认状态），并同步资金流水；
+// 被重组移除的出价不参与，链上已不存在的退款标记为 orphaned
+func rebuildBidChain(tx *gorm.DB, auctionID uint64) error {
+	var bids []model.BidHistory
+	if err := tx.Where("auction_id = ? AND status <> ?", auctionID, model.ChainStateOrphaned).
+		Order("block_number ASC, log_index ASC").
+		Find(&bids).Error; err != nil {
+		return fmt.Errorf("查询出价失败: %v", err)
+	}
+
+	if err := tx.Model(&model.BidHistory{}).
+		Where("auction_id = ? AND (is_highest = ? OR previous_bid_id <> 0)", auctionID, true).
+		Updates(map[string]interface{}{"is_highest": false, "previous_bid_id": 0}).Error; err != nil {
+		return fmt.Errorf("重置出价链失败: %v", err)
+	}
+	if err := tx.Model(&model.BidRefund{}).
+		Where("auction_id = ?", auctionID).
+		Update("chain_state", model.ChainStateOrphaned).Error; err != nil {
+		return fmt.Errorf("重置退款记录失败: %v", err)
+	}
+
+	for i, bid := range bids {
+		updates := map[string]interface{}{"is_highest": i == len(bids)-1}
+		if i > 0 {
+			previous := bids[i-1]
+			updates["previous_bid_id"] = previous.ID
+			if err := saveBidRefund(tx, newBidRefund(previous, bid)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.BidHistory{}).Where("id = ?", bid.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新出价链失败: %v", err)
+		}
+	}
+	if err := syncCurrentBid(tx, auctionID, bids); err != nil {
+		return err
+	}
+	return rebuildBidLedger(tx, auctionID, bids)
+}
+
+// syncCurrentBid 让 bids 表的当前出价与出价链最后一次出价一致；没有出价时删除
+func syncCurrentBid(tx *gorm.DB, auctionID uint64, bids []model.BidHistory) error {
+	if len(bids) == 0 {
+		if err := tx.Where("auction_id = ?", auctionID).Delete(&model.Bid{}).Error; err != nil {
+			return fmt.Errorf("删除当前出价失败: %v", err)
 		}
 		return nil
 	}
 
-	// 新记录
-	now := time.Now()
-	bid.CreatedAt = now
-	bid.UpdatedAt = now
+	highest := bids[len(bids)-1]
+	current := &model.Bid{
+		AuctionID:   auctionID,
+		BidID:       highest.ID,
+		Bidder:      highest.Bidder,
+		Amount:      highest.Amount,
+		TxHash:      highest.TxHash,
+		LogIndex:    highest.LogIndex,
+		Status:      bidChainState(highest.Status),
+		IsHighest:   true,
+		BlockNumber: highest.BlockNumber,
+	}
+	if current.Status == model.ChainStateConfirmed {
+		current.ConfirmedAt = time.Now()
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "auction_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"bid_id", "bidder", "amount", "tx_hash", "log_index",
+			"status", "is_highest", "block_number", "confirmed_at", "updated_at",
+		}),
+	}).Create(current).Error; err != nil {
+		return fmt.Errorf("保存当前出价失败: %v", err)
+	}
+	return nil
+}
+
+// bidChainState 出价派生记录（退款、资金流水）的确认状态跟随出价
+func bidChainState(bidStatus string) string {
+	if bidStatus == model.ChainStateConfirmed {
+		return model.ChainStateConfirmed
+	}
+	return model.ChainStatePending
+}
+
+// GetBidRefunds 拍卖中被顶替出价的退款记录（不含被重组移除的）
+func (s *AuctionService) GetBidRefunds(ctx context.Context, auctionID uint64) ([]model.BidRefund, error) {
+	var refunds []model.BidRefund
+	if err := s.DB.WithContext(ctx).
+		Where("auction_id = ? AND chain_state <> ?", auctionID, model.ChainStateOrphaned).
+		Order("block_number ASC, log_index ASC").
+		Find(&refunds).Error; err != nil {
+		return nil, fmt.Errorf("查询退款记录失败: %v", err)
+	}
+	return refunds, nil
+}
 
-	if err := s.DB.WithContext(ctx).Create(bid).Error; err != nil {
-		return fmt.Errorf("创建出价记录失败: %v", err)
+// OrphanBid 将被重组移除的出价标记为 orphaned
+func (s *AuctionService) OrphanBid(ctx context.Context, txHash string, logIndex uint) error {
+	err := s.DB.WithContext(ctx).Model(&model.BidHistory{}).
+		Where("tx_hash = ? AND log_index = ?", txHash, logIndex).
+		Update("status", model.ChainStateOrphaned).Error
+	if err != nil {
+		return fmt.Errorf("标记出价失败: %v", err)
 	}
+	return nil
+}
 
-	log.Printf("✅ 保存出价记录: AuctionID=%d, Bidder=%s", bid.AuctionID, bid.Bidder)
+// MarkAuctionOrphaned 将拍卖标记为 orphaned（创建交易被重组移除）
+func (s *AuctionService) MarkAuctionOrphaned(ctx context.Context, auctionID uint64) error {
+	err := s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Where("auction_id = ?", auctionID).
+		Update("chain_state", model.ChainStateOrphaned).Error
+	if err != nil {
+		return fmt.Errorf("标记拍卖失败: %v", err)
+	}
+	if s.expiry != nil {
+		s.expiry.Cancel(auctionID)
+	}
 	return nil
 }
 
-// GetAuctionFromChain 从区块链获取拍卖信息
-func (s *AuctionService) GetAuctionFromChain(ctx context.Context, auctionID uint64) (*model.Auction, error) {
-	// 使用 GetAuctionInfo 方法获取拍卖信息
-	seller, duration, startPrice, startTime, ended, highestBidder, highestBid,
-		nftContract, tokenId, _, _, _, err :=
-		s.AuctionContract.GetAuctionInfo(ctx, big.NewInt(int64(auctionID)))
+// RecalculateHighestBid 根据未被重组移除的出价重建出价链，并重新计算最高出价
+func (s *AuctionService) RecalculateHighestBid(ctx context.Context, auctionID uint64) error {
+	if err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return rebuildBidChain(tx, auctionID)
+	}); err != nil {
+		return err
+	}
 
+	auction, err := s.GetAuctionByAuctionID(ctx, auctionID)
 	if err != nil {
-		return nil, fmt.Errorf("从链上获取拍卖失败: %v", err)
+		return fmt.Errorf("获取拍卖 #%d 失败: %v", auctionID, err)
 	}
 
-	// 计算结束时间
-	endTime := big.NewInt(0)
-	if startTime != nil && duration != nil {
-		endTime = new(big.Int).Add(startTime, duration)
+	var bids []model.BidHistory
+	if err := s.DB.WithContext(ctx).
+		Where("auction_id = ? AND status <> ?", auctionID, model.ChainStateOrphaned).
+		Find(&bids).Error; err != nil {
+		return fmt.Errorf("查询出价失败: %v", err)
 	}
 
-	// 判断状态
-	status := "active"
-	if ended {
-		status = "ended"
-	} else if time.Now().Unix() > endTime.Int64() {
-		status = "expired"
-	} else if auctionID == 0 { // 特殊处理拍卖ID为0的情况
-		status = "active"
+	highest := big.NewInt(0)
+	highestBidder := common.Address{}.Hex()
+	for _, bid := range bids {
+		amount, ok := new(big.Int).SetString(bid.Amount, 10)
+		if ok && amount.Cmp(highest) > 0 {
+			highest = amount
+			highestBidder = bid.Bidder
+		}
+	}
+
+	auction.HighestBid = highest.String()
+	auction.HighestBidder = highestBidder
+	return s.SaveAuction(ctx, auction)
+}
+
+// RefreshAuctionFromChain 重组后从链上刷新拍卖；链上已不存在的拍卖标记为 orphaned
+func (s *AuctionService) RefreshAuctionFromChain(ctx context.Context, auctionID uint64) error {
+	count, err := s.AuctionContract.GetAuctionCount(ctx)
+	if err != nil {
+		return fmt.Errorf("获取拍卖数量失败: %v", err)
+	}
+	if auctionID >= count.Uint64() {
+		return s.MarkAuctionOrphaned(ctx, auctionID)
+	}
+	if err := s.UpdateAuctionFromChain(ctx, auctionID); err != nil {
+		return err
+	}
+	// 链上仍存在：重新进入确认流程
+	return s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Where("auction_id = ? AND chain_state = ?", auctionID, model.ChainStateOrphaned).
+		Update("chain_state", model.ChainStatePending).Error
+}
+
+// GetAuctionInfo 从区块链读取类型化的拍卖信息
+func (s *AuctionService) GetAuctionInfo(ctx context.Context, auctionID uint64) (*contract.AuctionInfo, error) {
+	info, err := s.AuctionContract.GetAuctionInfo(ctx, new(big.Int).SetUint64(auctionID))
+	if err != nil {
+		return nil, fmt.Errorf("从链上获取拍卖失败: %v", err)
+	}
+	return info, nil
+}
+
+// GetAuctionFromChain 从区块链获取拍卖信息
+func (s *AuctionService) GetAuctionFromChain(ctx context.Context, auctionID uint64) (*model.Auction, error) {
+	info, err := s.GetAuctionInfo(ctx, auctionID)
+	if err != nil {
+		return nil, err
 	}
 
 	auction := &model.Auction{
 		AuctionID:     auctionID,
-		NFTContract:   nftContract.Hex(),
-		TokenID:       tokenId.String(),
-		Seller:        seller.Hex(),
-		StartingPrice: startPrice.String(),
-		HighestBid:    highestBid.String(),
-		HighestBidder: highestBidder.Hex(),
-		StartTime:     uint64(startTime.Int64()),
-		EndTime:       uint64(endTime.Int64()),
-		Ended:         ended,
-		Status:        status,
+		NFTContract:   info.NFTContract.Hex(),
+		TokenID:       info.TokenID.String(),
+		Seller:        info.Seller.Hex(),
+		StartingPrice: info.StartPrice.String(),
+		HighestBid:    info.HighestBid.String(),
+		HighestBidder: info.HighestBidder.Hex(),
+		StartTime:     info.StartTime.Uint64(),
+		EndTime:       info.EndTime.Uint64(),
+		Ended:         info.Ended,
+		Status:        info.Status,
+		UseERC20:      info.UseERC20,
+	}
+	if info.UseERC20 {
+		auction.PaymentToken = info.PaymentToken.Hex()
 	}
 
 	return auction, nil
@@ -229,7 +575,8 @@ func (s *AuctionService) GetActiveAuctions(ctx context.Context) ([]model.Auction
 	currentTime := uint64(time.Now().Unix())
 	// log.Printf("✅ ----currentTime=%d ", currentTime)
 
-	result := s.DB.WithContext(ctx).Where("ended = ? AND end_time > ?", false, currentTime).
+	result := s.DB.WithContext(ctx).
+		Where("ended = ? AND end_time > ? AND chain_state <> ?", false, currentTime, model.ChainStateOrphaned).
 		Order("created_at DESC").
 		Find(&auctions)
 
@@ -239,17 +586,32 @@ func (s *AuctionService) GetActiveAuctions(ctx context.Context) ([]model.Auction
 	return auctions, nil
 }
 
+// GetExpiredAuctions 获取已到期、等待 endAuction 结算的拍卖
+func (s *AuctionService) GetExpiredAuctions(ctx context.Context) ([]model.Auction, error) {
+	var auctions []model.Auction
+	result := s.DB.WithContext(ctx).
+		Where("ended = ? AND status = ? AND chain_state <> ?", false, contract.AuctionStatusExpired, model.ChainStateOrphaned).
+		Order("end_time ASC").
+		Find(&auctions)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return auctions, nil
+}
+
 // GetAuctionBids 获取拍卖的出价历史
 func (s *AuctionService) GetAuctionBids(ctx context.Context, auctionID uint64, page, pageSize int) ([]model.BidHistory, int64, error) {
 	var bids []model.BidHistory
 	var total int64
 
-	query := s.DB.WithContext(ctx).Model(&model.BidHistory{}).Where("auction_id = ?", auctionID)
+	query := s.DB.WithContext(ctx).Model(&model.BidHistory{}).
+		Where("auction_id = ? AND status <> ?", auctionID, model.ChainStateOrphaned)
 	query.Count(&total)
 
 	offset := (page - 1) * pageSize
 	err := query.Offset(offset).Limit(pageSize).
-		Order("created_at DESC").
+		Order("block_number DESC, log_index DESC").
 		Find(&bids).Error
 
 	if err != nil {
@@ -269,11 +631,97 @@ func (s *AuctionService) UpdateAuctionFromChain(ctx context.Context, auctionID u
 	return s.SaveAuction(ctx, auction)
 }
 
+// FindEscrowedAuction 查找托管中（未结束）的拍卖：按NFT合约、TokenID 与卖家匹配
+func (s *AuctionService) FindEscrowedAuction(ctx context.Context, nftContract, tokenID, seller string) (*model.Auction, error) {
+	var auction model.Auction
+	err := s.DB.WithContext(ctx).
+		Where("LOWER(nft_contract) = ? AND token_id = ? AND LOWER(seller) = ? AND ended = ? AND chain_state <> ?",
+			strings.ToLower(nftContract), tokenID, strings.ToLower(seller), false, model.ChainStateOrphaned).
+		Order("auction_id DESC").
+		First(&auction).Error
+	if err != nil {
+		return nil, err
+	}
+	return &auction, nil
+}
+
+// ReconcileAuction 回读链上 auctions(id) 并在结束状态变化时更新数据库
+// 返回 true 表示拍卖已在链上结束
+func (s *AuctionService) ReconcileAuction(ctx context.Context, auctionID uint64) (bool, error) {
+	auction, err := s.GetAuctionFromChain(ctx, auctionID)
+	if err != nil {
+		return false, err
+	}
+	if !auction.Ended {
+		return false, nil
+	}
+
+	if err := s.SaveAuction(ctx, auction); err != nil {
+		return false, err
+	}
+	log.Printf("🔄 拍卖 #%d 已在链上结束，状态: %s", auctionID, auction.Status)
+	return true, nil
+}
+
+// ReconcileExpiredAuctions 对账所有已过结束时间但数据库仍未结束的拍卖
+// EndTime 为0（事件创建、尚未回读时长）的拍卖也一并回读
+func (s *AuctionService) ReconcileExpiredAuctions(ctx context.Context) (int, error) {
+	var auctions []model.Auction
+	err := s.DB.WithContext(ctx).
+		Where("ended = ? AND end_time <= ? AND chain_state <> ?",
+			false, uint64(time.Now().Unix()), model.ChainStateOrphaned).
+		Find(&auctions).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询过期拍卖失败: %v", err)
+	}
+
+	reconciled := 0
+	for _, auction := range auctions {
+		ended, err := s.ReconcileAuction(ctx, auction.AuctionID)
+		if err != nil {
+			log.Printf("❌ 对账拍卖 #%d 失败: %v", auction.AuctionID, err)
+			continue
+		}
+		if ended {
+			reconciled++
+		}
+	}
+	return reconciled, nil
+}
+
+// RepairNFTContracts 修正旧版本事件处理写入的 nft_contract（拍卖合约地址或空），从链上回读真实的NFT合约
+func (s *AuctionService) RepairNFTContracts(ctx context.Context) (int, error) {
+	var auctionIDs []uint64
+	err := s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Where("nft_contract = ? OR nft_contract = ''", s.GetContractAddress().Hex()).
+		Pluck("auction_id", &auctionIDs).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待修正拍卖失败: %v", err)
+	}
+
+	repaired := 0
+	for _, auctionID := range auctionIDs {
+		info, err := s.GetAuctionInfo(ctx, auctionID)
+		if err != nil {
+			log.Printf("❌ 回读拍卖 #%d 失败: %v", auctionID, err)
+			continue
+		}
+		if err := s.DB.WithContext(ctx).Model(&model.Auction{}).
+			Where("auction_id = ?", auctionID).
+			Update("nft_contract", info.NFTContract.Hex()).Error; err != nil {
+			return repaired, fmt.Errorf("修正拍卖 #%d 的NFT合约失败: %v", auctionID, err)
+		}
+		repaired++
+	}
+	if repaired > 0 {
+		log.Printf("✅ 已修正 %d 个拍卖的NFT合约地址", repaired)
+	}
+	return repaired, nil
+}
+
 // ValidateAuctionExists 验证拍卖是否存在（只读检查）
 func (s *AuctionService) ValidateAuctionExists(ctx context.Context, auctionID uint64) (bool, error) {
-	_, _, _, _, _, _, _, _, _, _, _, _, err :=
-		s.AuctionContract.GetAuctionInfo(ctx, big.NewInt(int64(auctionID)))
-
+	info, err := s.AuctionContract.GetAuctionInfo(ctx, new(big.Int).SetUint64(auctionID))
 	if err != nil {
 		// 检查是否是"拍卖不存在"的错误
 		if err.Error() == "execution reverted" ||
@@ -283,7 +731,8 @@ func (s *AuctionService) ValidateAuctionExists(ctx context.Context, auctionID ui
 		}
 		return false, err
 	}
-	return true, nil
+	// 不存在的ID返回零值结构体
+	return info.Exists(), nil
 }
 
 // GetAuctionCount 获取拍卖总数
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+func saveBid(t *testing.T, s *AuctionService, auctionID uint64, bidder, amount string, block uint64, status string) {
+	t.Helper()
+	err := s.SaveBidHistory(context.Background(), &model.BidHistory{
+		AuctionID:   auctionID,
+		Bidder:      bidder,
+		Amount:      amount,
+		TxHash:      fmt.Sprintf("0x%d%d", auctionID, block),
+		BlockNumber: block,
+		Status:      status,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func currentBid(t *testing.T, db *gorm.DB, auctionID uint64) *model.Bid {
+	t.Helper()
+	var bid model.Bid
+	err := db.Where("auction_id = ?", auctionID).First(&bid).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &bid
+}
+
+func TestSaveBidHistorySyncsCurrentBid(t *testing.T) {
+	db := newTestDB(t)
+	s := NewAuctionService(db, nil)
+
+	saveBid(t, s, 1, alice, "100", 10, model.ChainStateConfirmed)
+	bid := currentBid(t, db, 1)
+	if bid == nil || bid.Bidder != alice || bid.Amount != "100" || !bid.IsHighest {
+		t.Fatalf("after first bid: %+v", bid)
+	}
+	if bid.Status != model.ChainStateConfirmed || bid.ConfirmedAt.IsZero() {
+		t.Fatalf("confirmed bid: status=%s confirmed_at=%v", bid.Status, bid.ConfirmedAt)
+	}
+
+	saveBid(t, s, 1, bob, "200", 11, model.ChainStatePending)
+	saveBid(t, s, 2, carol, "50", 11, model.ChainStatePending)
+
+	var count int64
+	db.Model(&model.Bid{}).Where("auction_id = ?", 1).Count(&count)
+	if count != 1 {
+		t.Fatalf("auction 1 has %d current bids, want 1", count)
+	}
+	bid = currentBid(t, db, 1)
+	if bid.Bidder != bob || bid.Amount != "200" || bid.Status != model.ChainStatePending || bid.BlockNumber != 11 {
+		t.Fatalf("after outbid: %+v", bid)
+	}
+	var history model.BidHistory
+	if err := db.Where("is_highest = ? AND auction_id = ?", true, 1).First(&history).Error; err != nil {
+		t.Fatal(err)
+	}
+	if bid.BidID != history.ID {
+		t.Fatalf("current bid points at %d, highest history is %d", bid.BidID, history.ID)
+	}
+	if other := currentBid(t, db, 2); other == nil || other.Bidder != carol {
+		t.Fatalf("auction 2: %+v", other)
+	}
+}
+
+func TestRebuildBidChainAfterReorgSyncsCurrentBid(t *testing.T) {
+	db := newTestDB(t)
+	s := NewAuctionService(db, nil)
+	ctx := context.Background()
+
+	saveBid(t, s, 1, alice, "100", 10, model.ChainStatePending)
+	saveBid(t, s, 1, bob, "200", 11, model.ChainStatePending)
+
+	rebuild := func() {
+		t.Helper()
+		if err := db.Transaction(func(tx *gorm.DB) error { return rebuildBidChain(tx, 1) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 顶替出价被重组移除：当前出价回到前一次出价
+	if err := s.OrphanBid(ctx, "0x111", 0); err != nil {
+		t.Fatal(err)
+	}
+	rebuild()
+	if bid := currentBid(t, db, 1); bid == nil || bid.Bidder != alice || bid.Amount != "100" {
+		t.Fatalf("after orphaning outbid: %+v", bid)
+	}
+
+	// 全部出价被移除：不再有当前出价
+	if err := s.OrphanBid(ctx, "0x110", 0); err != nil {
+		t.Fatal(err)
+	}
+	rebuild()
+	if bid := currentBid(t, db, 1); bid != nil {
+		t.Fatalf("auction without bids still has current bid: %+v", bid)
+	}
+}
+
+func TestPromoteConfirmedConfirmsCurrentBid(t *testing.T) {
+	db := newTestDB(t)
+	s := NewAuctionService(db, nil)
+
+	saveBid(t, s, 1, alice, "100", 10, model.ChainStatePending)
+	if err := NewReorgService(db, 3).PromoteConfirmed(context.Background(), 20); err != nil {
+		t.Fatal(err)
+	}
+	bid := currentBid(t, db, 1)
+	if bid.Status != model.ChainStateConfirmed || bid.ConfirmedAt.IsZero() {
+		t.Fatalf("after promote: status=%s confirmed_at=%v", bid.Status, bid.ConfirmedAt)
+	}
+}
+
+// bidChainSnapshot 出价链快照：出价的 (前一出价, 是否最高)、退款、有效流水
+func bidChainSnapshot(t *testing.T, db *gorm.DB, auctionID uint64) string {
+	t.Helper()
+	var bids []model.BidHistory
+	var refunds []model.BidRefund
+	var entries []model.LedgerEntry
+	db.Where("auction_id = ?", auctionID).Order("id").Find(&bids)
+	db.Where("auction_id = ? AND chain_state <> ?", auctionID, model.ChainStateOrphaned).Order("bid_id").Find(&refunds)
+	db.Where("auction_id = ? AND chain_state <> ?", auctionID, model.ChainStateOrphaned).Order("kind, ref").Find(&entries)
+
+	var out string
+	for _, b := range bids {
+		out += fmt.Sprintf("bid %d prev=%d highest=%v; ", b.ID, b.PreviousBidID, b.IsHighest)
+	}
+	for _, r := range refunds {
+		out += fmt.Sprintf("refund bid=%d by=%d %s %s; ", r.BidID, r.OutbidByID, r.Amount, r.ChainState)
+	}
+	for _, e := range entries {
+		out += fmt.Sprintf("ledger %s %s %s %s; ", e.Kind, e.Ref, e.Amount, e.ChainState)
+	}
+	if bid := currentBid(t, db, auctionID); bid != nil {
+		out += fmt.Sprintf("current %d %s", bid.BidID, bid.Amount)
+	}
+	return out
+}
+
+// 按顺序到达的出价只追加，结果与整条重建一致
+func TestSaveBidHistoryAppendMatchesRebuild(t *testing.T) {
+	db := newTestDB(t)
+	s := NewAuctionService(db, nil)
+
+	saveBid(t, s, 1, alice, "100", 10, model.ChainStatePending)
+	saveBid(t, s, 1, bob, "200", 11, model.ChainStatePending)
+	saveBid(t, s, 1, carol, "300", 12, model.ChainStateConfirmed)
+
+	appended := bidChainSnapshot(t, db, 1)
+	if err := db.Transaction(func(tx *gorm.DB) error { return rebuildBidChain(tx, 1) }); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt := bidChainSnapshot(t, db, 1); rebuilt != appended {
+		t.Fatalf("appended:\n%s\nrebuilt:\n%s", appended, rebuilt)
+	}
+
+	var refunds int64
+	db.Model(&model.BidRefund{}).Where("auction_id = ? AND chain_state <> ?", 1, model.ChainStateOrphaned).Count(&refunds)
+	if refunds != 2 {
+		t.Fatalf("refunds = %d, want 2", refunds)
+	}
+}
+
+// 乱序到达（回填）的出价插在链中间：重建整条出价链
+func TestSaveBidHistoryOutOfOrderRebuilds(t *testing.T) {
+	db := newTestDB(t)
+	s := NewAuctionService(db, nil)
+
+	saveBid(t, s, 1, alice, "100", 10, model.ChainStatePending)
+	saveBid(t, s, 1, carol, "300", 12, model.ChainStatePending)
+	saveBid(t, s, 1, bob, "200", 11, model.ChainStatePending)
+
+	var bids []model.BidHistory
+	db.Where("auction_id = ?", 1).Order("block_number").Find(&bids)
+	if bids[1].PreviousBidID != bids[0].ID || bids[2].PreviousBidID != bids[1].ID || !bids[2].IsHighest || bids[1].IsHighest {
+		t.Fatalf("chain: %+v", bids)
+	}
+	if bid := currentBid(t, db, 1); bid.Bidder != carol {
+		t.Fatalf("current bid: %+v", bid)
+	}
+	var refund model.BidRefund
+	if err := db.Where("bid_id = ?", bids[0].ID).First(&refund).Error; err != nil || refund.OutbidByID != bids[1].ID {
+		t.Fatalf("refund of first bid: %+v %v", refund, err)
+	}
+}
@@ -5,16 +5,17 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"nft-auction-backend/internal/config"
 	"nft-auction-backend/internal/contract"
 	"nft-auction-backend/internal/model"
+	"nft-auction-backend/pkg/rpcpool"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // ────────────────────┐
@@ -58,14 +59,41 @@ import (
 
 // BlockchainListener 监听区块链事件
 type BlockchainListener struct {
-	rpcURL         string
-	ethClient      *ethclient.Client
-	nftService     *NFTService
-	auctionService *AuctionService
-
-	ctx       context.Context
-	cancel    context.CancelFunc
-	running   bool
+	ethClient         *rpcpool.Pool // 共享RPC连接池（订阅走 WebSocket 节点，查询自动故障转移）
+	nftService        *NFTService
+	auctionService    *AuctionService
+	collectionService *CollectionService
+	eventSync         *EventSyncService
+	reorgService      *ReorgService
+	journal           *EventJournalService // 原始事件日志
+
+	events         *EventRegistry      // 事件解码与处理函数
+	newCollections chan common.Address // 新注册的第三方合集（启动独立监听）
+
+	startBlock        uint64 // 无检查点时的起始区块
+	backfillBatchSize uint64 // 每次 FilterLogs 的区块范围
+
+	mode                 string        // 监听模式: auto, subscribe, poll
+	pollInterval         time.Duration // 轮询间隔
+	maxSubscribeFailures int32         // auto 模式下切换到轮询的连续失败次数
+	subscribeFailures    int32         // 订阅连续失败次数（原子操作）
+
+	reconcileInterval time.Duration // 过期拍卖对账间隔
+
+	headerCache *lru.Cache[common.Hash, *types.Header] // 区块头缓存（事件时间戳）
+
+	processMu sync.Mutex // 日志处理锁：实时日志、回填批次与重组回滚互斥（检查点与业务表不会交错写入）
+
+	ctx    context.Context // 当前运行的上下文（Start 时从 parent 派生，Stop 时取消）
+	cancel context.CancelFunc
+	parent context.Context // 应用上下文（Start 传入），回放结束后用它恢复监听
+	ctxMu  sync.RWMutex    // 保护 ctx / cancel / parent（监听协程之外读取时使用 contexts）
+
+	lifecycle sync.Mutex     // 保护 running / replaying，串行化启动、停止与回放
+	running   bool           // 监听协程是否在运行
+	replaying bool           // 正在回放事件日志（监听器停止，Start 等回放结束后再启动）
+	wg        sync.WaitGroup // 监听协程，Stop 等它们全部退出
+
 	stats     map[string]int
 	statsLock sync.RWMutex
 }
@@ -74,95 +102,159 @@ type BlockchainListener struct {
 func NewBlockchainListener(
 	nftSvc *NFTService,
 	auctionSvc *AuctionService,
-	rpcURL string,
+	collectionSvc *CollectionService,
+	eventSync *EventSyncService,
+	reorgSvc *ReorgService,
+	journal *EventJournalService,
+	pool *rpcpool.Pool,
+	cfg config.BlockchainConfig,
 	ctx context.Context,
 	cancel context.CancelFunc) *BlockchainListener {
 
-	return &BlockchainListener{
-		rpcURL:         rpcURL,
-		nftService:     nftSvc,
-		auctionService: auctionSvc,
-		ctx:            ctx,
-		cancel:         cancel,
-		stats:          map[string]int{"nft_transfers": 0, "auctions": 0, "bids": 0},
+	batchSize := cfg.BackfillBatchSize
+	if batchSize == 0 {
+		batchSize = 2000
+	}
+	mode := cfg.ListenerMode
+	if mode == "" {
+		mode = ListenerModeAuto
+	}
+	pollInterval := cfg.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+	maxFailures := cfg.MaxSubscribeFailures
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+	reconcileInterval := cfg.ReconcileInterval
+	if reconcileInterval <= 0 {
+		reconcileInterval = time.Minute
+	}
+
+	l := &BlockchainListener{
+		ethClient:            pool,
+		nftService:           nftSvc,
+		auctionService:       auctionSvc,
+		collectionService:    collectionSvc,
+		eventSync:            eventSync,
+		reorgService:         reorgSvc,
+		journal:              journal,
+		events:               NewEventRegistry(),
+		newCollections:       make(chan common.Address),
+		startBlock:           cfg.StartBlock,
+		backfillBatchSize:    batchSize,
+		mode:                 mode,
+		pollInterval:         pollInterval,
+		maxSubscribeFailures: int32(maxFailures),
+		reconcileInterval:    reconcileInterval,
+		headerCache:          lru.NewCache[common.Hash, *types.Header](headerCacheSize),
+		ctx:                  ctx,
+		cancel:               cancel,
+		parent:               ctx,
+		stats:                map[string]int{"nft_transfers": 0, "auctions": 0, "bids": 0},
+	}
+	if err := l.registerEventHandlers(); err != nil {
+		log.Fatalf("❌ 注册事件处理函数失败: %v", err)
+	}
+	for _, addr := range collectionSvc.IndexedAddresses() {
+		if err := l.registerCollectionEvents(addr); err != nil {
+			log.Fatalf("❌ 注册合集 %s 事件处理函数失败: %v", addr.Hex(), err)
+		}
 	}
+	collectionSvc.OnRegister(l.onCollectionRegistered)
+	return l
 }
 
-// Start 启动监听器
+// Start 启动监听器（回放进行中时不启动，回放结束后自动恢复）
 func (l *BlockchainListener) Start(ctx context.Context) {
+	l.lifecycle.Lock()
+	defer l.lifecycle.Unlock()
 	if l.running {
 		return
 	}
+	if l.replaying {
+		log.Println("⚠️ 事件日志回放进行中，监听器将在回放结束后启动")
+		return
+	}
 	l.running = true
+	l.ctxMu.Lock()
+	l.parent = ctx
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	l.ctxMu.Unlock()
 	log.Println("🔍 区块链事件监听器启动中...")
 
-	go func() {
-		// 无限循环，持续监听区块链事件
-		// 除非收到停止信号（ctx.Done()），否则会一直运行
-		for {
-			select {
-			case <-l.ctx.Done():
-				// 如果收到停止信号，输出日志并退出函数
-				log.Println("❌ 区块链监听器已停止")
-				return
-			default:
-				// 连接 WebSocket RPC
-				log.Println(" 区块链监听器开始同步...")
-
-				client, err := ethclient.Dial(l.rpcURL)
-				if err != nil {
-					log.Printf("❌ 连接 RPC 失败: %v, 3s后重试...", err)
-					time.Sleep(3 * time.Second)
-					continue
-				}
-				defer client.Close()
-				l.ethClient = client
-
-				// 先同步一遍链上的数据
-				l.syncAllNFTs()
-				l.syncAllAuctions()
-
-				// 创建 WaitGroup，用于等待两个监听goroutine完成
-				// 启动 NFT 和拍卖实时监听
-				var wg sync.WaitGroup
-
-				// 设置需要等待的 goroutine 数量为 2
-				wg.Add(2)
-				go func() {
-					// 监听NFT 的监听器
-					defer wg.Done() // 无论函数如何结束，defer都会执行
-					l.listenNFTTransfer()
-				}()
-				go func() {
-					// 监听NFT拍卖 的监听器
-					defer wg.Done()
-					l.listenAuctionEvents()
-				}()
-
-				// 等待两个监听任务完成
-				// wg.Wait() 会阻塞，直到两个任务都调用了 wg.Done()
-				// 这意味着只有当两个监听函数都退出时，才会继续执行后面的代码
-				wg.Wait()
-				log.Println("----2----🔄 区块链监听器重连中...")
-				time.Sleep(3 * time.Second)
-			}
+	// 确认深度与重组检测
+	l.goRun(l.runConfirmationLoop)
+
+	// 无人出价结束的拍卖不发事件，定期回读链上状态
+	l.goRun(l.runReconcileLoop)
+
+	// 第三方合集各自独立监听（新发现的合集随时加入）
+	l.goRun(l.runCollectionListeners)
+
+	l.goRun(func() {
+		// 连接由RPC池统一管理（WebSocket 使用订阅，HTTP 使用轮询）
+		log.Printf(" 区块链监听器开始同步... 模式: %s", l.GetMode())
+
+		// 首次启动（没有检查点）时先全量同步一遍链上的数据
+		// 之后的重启/重连只需要从检查点回填断档区间
+		if l.needsFullSync() {
+			l.syncAllNFTs()
+			l.syncAllAuctions()
 		}
+
+		// NFT 与拍卖合约各自独立监听：一个订阅断开只重连它自己，
+		// 并从它自己的检查点回填，不等待另一个退出
+		l.goRun(func() { l.keepListening(NFTEventsKind, l.nftService.GetContractAddress()) })
+		l.goRun(func() { l.keepListening(AuctionEventsKind, l.auctionService.GetContractAddress()) })
+
+		<-l.ctx.Done()
+		log.Println("❌ 区块链监听器已停止")
+	})
+}
+
+// IsReplaying 是否正在回放事件日志
+func (l *BlockchainListener) IsReplaying() bool {
+	l.lifecycle.Lock()
+	defer l.lifecycle.Unlock()
+	return l.replaying
+}
+
+// goRun 启动一个监听协程（Stop 会等待它退出）
+func (l *BlockchainListener) goRun(fn func()) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn()
 	}()
 }
 
-// Stop 停止监听器
+// Stop 停止监听器，等待正在处理的日志完成后返回
 func (l *BlockchainListener) Stop() {
+	l.lifecycle.Lock()
+	defer l.lifecycle.Unlock()
+	l.stopLocked()
+}
+
+// stopLocked 取消监听上下文并等待所有监听协程退出（调用方持有 lifecycle）
+func (l *BlockchainListener) stopLocked() {
 	if !l.running {
 		return
 	}
 	log.Println("🛑 停止区块链监听器...")
 	l.cancel()
-	if l.ethClient != nil {
-		l.ethClient.Close()
-	}
+	l.wg.Wait()
 	l.running = false
 }
 
+// contexts 监听协程之外读取当前监听上下文与应用上下文
+func (l *BlockchainListener) contexts() (run, parent context.Context) {
+	l.ctxMu.RLock()
+	defer l.ctxMu.RUnlock()
+	return l.ctx, l.parent
+}
+
 // ---------------- 拍卖同步 ----------------
 func (l *BlockchainListener) syncAllAuctions() {
 	log.Println("====1====⏳ 同步链上所有拍卖数据中...")
@@ -180,64 +272,266 @@ func (l *BlockchainListener) syncAllNFTs() {
 	}
 }
 
-// ---------------- NFT Transfer 监听 ----------------
-func (l *BlockchainListener) listenNFTTransfer() {
-	nftAddr := l.nftService.GetContractAddress()
-	log.Printf("🎯 监听合约: %s", nftAddr.Hex())
-	query := ethereum.FilterQuery{Addresses: []common.Address{nftAddr}}
-	logsChan := make(chan types.Log)
+// ---------------- 检查点与断档回填 ----------------
+
+// needsFullSync 是否需要全量同步：只有在未配置起始区块且任一合约没有检查点时才需要
+func (l *BlockchainListener) needsFullSync() bool {
+	if l.startBlock > 0 {
+		return false
+	}
+	contracts := map[string]common.Address{
+		NFTEventsKind:     l.nftService.GetContractAddress(),
+		AuctionEventsKind: l.auctionService.GetContractAddress(),
+	}
+	for kind, addr := range contracts {
+		_, found, err := l.eventSync.GetLastBlock(l.ctx, kind, addr)
+		if err != nil || !found {
+			return true
+		}
+	}
+	return false
+}
+
+// backfill 从检查点开始，按固定区块范围用 FilterLogs 补齐断档期间的事件
+// 返回值为已回填到的区块高度，实时订阅中小于等于该高度的日志会被跳过
+func (l *BlockchainListener) backfill(kind string, contractAddr common.Address, dispatch func(types.Log)) (uint64, error) {
+	head, err := l.ethClient.BlockNumber(l.ctx)
+	if err != nil {
+		return 0, fmt.Errorf("获取最新区块失败: %v", err)
+	}
+
+	last, found, err := l.eventSync.GetLastBlock(l.ctx, kind, contractAddr)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		if l.startBlock == 0 {
+			// 没有检查点也没有起始区块：历史数据已由全量同步覆盖，直接从最新区块开始
+			log.Printf("📍 %s 无检查点，从最新区块 %d 开始记录", kind, head)
+			return head, l.eventSync.SaveLastBlock(l.ctx, kind, contractAddr, head)
+		}
+		last = l.startBlock - 1
+	}
 
-	// 计算预期的签名
-	transferSig := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex()
-	mintSig := crypto.Keccak256Hash([]byte("NFTMinted(address,uint256,string)")).Hex()
-	approvalSig := crypto.Keccak256Hash([]byte("Approval(address,address,uint256)")).Hex()
+	if last >= head {
+		return head, nil
+	}
+	log.Printf("⏪ %s 回填区块 %d → %d", kind, last+1, head)
+
+	if err := l.filterRange(kind, contractAddr, last+1, head, dispatch); err != nil {
+		return 0, err
+	}
+	return head, nil
+}
+
+// Reindex 重新拉取并应用 [from, to] 区间的日志（离线重建，不启动实时订阅）
+// 区间与检查点相连时推进检查点，否则不动检查点，避免跳过中间未处理的区块
+func (l *BlockchainListener) Reindex(from, to uint64) error {
+	if to == 0 {
+		head, err := l.ethClient.BlockNumber(l.ctx)
+		if err != nil {
+			return fmt.Errorf("获取最新区块失败: %v", err)
+		}
+		to = head
+	}
+	if from > to {
+		return fmt.Errorf("起始区块 %d 大于结束区块 %d", from, to)
+	}
+
+	for _, c := range l.indexedContracts() {
+		kind := c.kind
+		last, found, err := l.eventSync.GetLastBlock(l.ctx, c.kind, c.addr)
+		if err != nil {
+			return err
+		}
+		if !found || from > last+1 {
+			kind = ""
+		}
+
+		log.Printf("⏪ %s 重建区块 %d → %d", c.kind, from, to)
+		if err := l.filterRange(kind, c.addr, from, to, l.applyLog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexedContract 需要索引日志的合约及其检查点类型
+type indexedContract struct {
+	kind string
+	addr common.Address
+}
+
+// indexedContracts 主NFT合约、拍卖合约与已注册的第三方合集
+func (l *BlockchainListener) indexedContracts() []indexedContract {
+	contracts := []indexedContract{
+		{NFTEventsKind, l.nftService.GetContractAddress()},
+		{AuctionEventsKind, l.auctionService.GetContractAddress()},
+	}
+	for _, addr := range l.collectionService.IndexedAddresses() {
+		contracts = append(contracts, indexedContract{CollectionEventsKind, addr})
+	}
+	return contracts
+}
+
+// filterRange 按固定区块范围用 FilterLogs 处理 [from, to] 内的日志，每批完成后推进检查点
+// kind 为空时不推进检查点；每批在日志处理锁内应用
+func (l *BlockchainListener) filterRange(kind string, contractAddr common.Address, from, to uint64, dispatch func(types.Log)) error {
+	return l.filterBatches(kind, contractAddr, from, to, dispatch, func(apply func() error) error {
+		l.processMu.Lock()
+		defer l.processMu.Unlock()
+		return apply()
+	})
+}
+
+// filterRangeLocked 同 filterRange，调用方已持有日志处理锁（重组处理）
+func (l *BlockchainListener) filterRangeLocked(kind string, contractAddr common.Address, from, to uint64, dispatch func(types.Log)) error {
+	return l.filterBatches(kind, contractAddr, from, to, dispatch, func(apply func() error) error {
+		return apply()
+	})
+}
+
+func (l *BlockchainListener) filterBatches(kind string, contractAddr common.Address, from, to uint64, dispatch func(types.Log), guard func(func() error) error) error {
+	for start := from; start <= to; start += l.backfillBatchSize {
+		end := start + l.backfillBatchSize - 1
+		if end > to {
+			end = to
+		}
+
+		query := ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{contractAddr},
+		}
+		logs, err := l.ethClient.FilterLogs(l.ctx, query)
+		if err != nil {
+			return fmt.Errorf("回填区块 %d-%d 失败: %v", start, end, err)
+		}
+
+		err = guard(func() error {
+			for _, vLog := range logs {
+				dispatch(vLog)
+			}
+
+			if kind == "" {
+				log.Printf("✅ 已处理区块 %d-%d（%d 条日志）", start, end, len(logs))
+				return nil
+			}
+			if err := l.eventSync.SaveLastBlock(l.ctx, kind, contractAddr, end); err != nil {
+				return err
+			}
+			l.recordCheckpoint(end)
+			log.Printf("✅ %s 已回填至区块 %d（%d 条日志）", kind, end, len(logs))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markProcessed 实时模式下收到区块N的日志，说明N之前的区块已经全部处理完毕
+func (l *BlockchainListener) markProcessed(kind string, contractAddr common.Address, vLog types.Log) {
+	if vLog.Removed || vLog.BlockNumber == 0 {
+		return
+	}
+	if err := l.eventSync.SaveLastBlock(l.ctx, kind, contractAddr, vLog.BlockNumber-1); err != nil {
+		log.Printf("❌ 保存检查点失败: %v", err)
+		return
+	}
+	// 检查点区块的哈希即日志所在区块的父哈希，区块头已在取事件时间时缓存
+	if header, ok := l.headerCache.Get(vLog.BlockHash); ok && header.ParentHash != (common.Hash{}) {
+		if err := l.reorgService.RecordBlock(l.ctx, vLog.BlockNumber-1, header.ParentHash.Hex()); err != nil {
+			log.Printf("❌ %v", err)
+		}
+	}
+}
 
-	log.Printf("  Transfer签名: %s", transferSig)
-	log.Printf("  Minted签名: %s", mintSig)
-	filterer, err := contract.NewKevinNFTFilterer(nftAddr, nil)
+// advanceCheckpoint 订阅模式下按最新区块推进检查点（托管对账等依赖检查点的功能不会停在旧区块）
+// 推进到上一个间隔看到的最新区块：它的日志有一个间隔的时间送达订阅通道，之后到达的日志由 markProcessed 处理
+// 返回本次看到的最新区块，作为下一次推进的目标
+func (l *BlockchainListener) advanceCheckpoint(kind string, contractAddr common.Address, seenHead uint64) uint64 {
+	head, err := l.ethClient.BlockNumber(l.ctx)
 	if err != nil {
-		log.Fatalf("❌ 创建Filterer失败: %v", err)
+		log.Printf("⚠️ %s 获取最新区块失败: %v", kind, err)
+		return seenHead
+	}
+	if seenHead == 0 || seenHead > head {
+		return head
+	}
+
+	l.processMu.Lock()
+	defer l.processMu.Unlock()
+	last, found, err := l.eventSync.GetLastBlock(l.ctx, kind, contractAddr)
+	if err != nil || !found || last >= seenHead {
+		return head
 	}
+	if err := l.eventSync.SaveLastBlock(l.ctx, kind, contractAddr, seenHead); err != nil {
+		log.Printf("❌ 保存检查点失败: %v", err)
+		return head
+	}
+	l.recordCheckpoint(seenHead)
+	return head
+}
+
+// ---------------- 合约日志监听 ----------------
 
-	// SubscribeFilterLogs默认从最新区块开始监听
+// listenContract 监听单个合约的全部日志：先订阅再回填，订阅失败或断开时返回由调用方重连
+func (l *BlockchainListener) listenContract(kind string, contractAddr common.Address) {
+	name := l.events.ContractName(contractAddr)
+	log.Printf("🎯 监听%s合约: %s", name, contractAddr.Hex())
+	query := ethereum.FilterQuery{Addresses: []common.Address{contractAddr}}
+
+	dispatch := l.applyLog
+
+	// HTTP RPC 或订阅屡次失败时改用 eth_getLogs 轮询
+	if l.usePolling() {
+		l.pollLogs(kind, contractAddr, dispatch)
+		return
+	}
+
+	// 先订阅再回填：回填期间到达的新日志由RPC客户端缓存，避免两者之间出现空档
+	logsChan := make(chan types.Log)
 	sub, err := l.ethClient.SubscribeFilterLogs(l.ctx, query, logsChan)
 	if err != nil {
-		log.Fatalf("❌ 订阅失败: %v", err)
+		l.recordSubscribeFailure(err)
+		return
 	}
-	log.Println("✅ 1 NFT 事件监听器订阅成功，等待事件...")
+	defer sub.Unsubscribe()
+
+	backfilledTo, err := l.backfill(kind, contractAddr, dispatch)
+	if err != nil {
+		log.Printf("❌ %s 事件回填失败: %v", name, err)
+		return
+	}
+	l.resetSubscribeFailures()
+	log.Printf("✅ %s 事件监听器订阅成功，等待事件...", name)
+
+	// 检查点只在收到日志时推进，合约长时间没有日志时按最新区块推进（见 advanceCheckpoint）
+	ticker := time.NewTicker(l.pollInterval)
+	defer ticker.Stop()
+	var seenHead uint64
 
 	for {
 		select {
 		case err := <-sub.Err():
-			log.Printf("❌ 订阅错误: %v", err)
+			l.recordSubscribeFailure(err)
 			return
 
 		case vLog := <-logsChan:
-			if len(vLog.Topics) == 0 {
-				continue
-			}
-			// 打印事件基本信息
-			log.Printf("📥 NFT  监听器收到事件日志:")
-			log.Printf("  区块: %d", vLog.BlockNumber)
-			log.Printf("  交易: %s", vLog.TxHash.Hex())
-			log.Printf("  主题数: %d", len(vLog.Topics))
-			// 监听到的事件签名
-			eventSig := vLog.Topics[0].Hex()
-			log.Printf("  事件签名: %s", eventSig)
-
-			// 根据事件签名分流处理
-			switch eventSig {
-			case mintSig:
-				l.handleNFTMinted(vLog, filterer)
-			case transferSig:
-				l.handleTransfer(vLog, filterer)
-			case approvalSig:
-				l.handleApproval(vLog, filterer)
-			default:
-				log.Printf("⚠️ 未知NFT事件签名: %s", eventSig)
+			if !vLog.Removed && vLog.BlockNumber <= backfilledTo {
+				continue // 回填时已处理
 			}
+			l.processMu.Lock()
+			dispatch(vLog)
+			l.markProcessed(kind, contractAddr, vLog)
+			l.processMu.Unlock()
+
+		case <-ticker.C:
+			seenHead = l.advanceCheckpoint(kind, contractAddr, seenHead)
 		case <-l.ctx.Done():
-			log.Println("🛑 监听器停止")
+			log.Printf("🛑 %s 监听器已停止", name)
 			return
 		}
 	}
@@ -245,39 +539,28 @@ func (l *BlockchainListener) listenNFTTransfer() {
 
 // ==================== 事件处理函数 ====================
 // handleNFTMinted 处理NFT铸造事件
-func (l *BlockchainListener) handleNFTMinted(vLog types.Log, filterer *contract.KevinNFTFilterer) {
-	event, err := filterer.ParseNFTMinted(vLog)
-	if err != nil {
-		log.Printf("❌ 解析Mint事件失败: %v", err)
-		return
-	}
-
+func (l *BlockchainListener) handleNFTMinted(ctx context.Context, event *contract.KevinNFTNFTMinted, vLog types.Log) {
 	log.Printf("✅ Mint事件: TokenID=%s, Owner=%s, URI=%s",
 		event.TokenId.String(), event.Owner.Hex(), event.Uri)
-	contractName, _ := l.nftService.client.GetName(l.ctx)
-	contractSymbol, _ := l.nftService.client.GetSymbol(l.ctx)
 
-	// 获取总供应量
-	var totalSupply string
-	if total, err := l.nftService.client.GetTotalSupply(l.ctx); err == nil {
-		totalSupply = total.String()
-	}
-	// 直接从事件数据创建NFT记录，不需要再查询区块链
+	// 直接从事件数据创建NFT记录（合约名称、供应量由 handleCollectionMint 更新到 collections），不需要再查询区块链
 	nft := &model.NFTInfo{
 		ContractAddress: l.nftService.GetContractAddress().Hex(),
 		TokenID:         event.TokenId.String(),
 		Owner:           event.Owner.Hex(),
 		Name:            fmt.Sprintf("NFT #%s", event.TokenId.String()),
 		Uri:             event.Uri,
-		TotalSupply:     totalSupply,
 		Blockchain:      "sepolia",
-		ContractName:    contractName,
-		ContractSymbol:  contractSymbol,
 		IsMinted:        true,
+		MintedBlock:     vLog.BlockNumber,
+		MintedAt:        time.Unix(int64(l.blockTime(ctx, vLog)), 0),
 		LastSyncTime:    time.Now(),
+		BlockNumber:     vLog.BlockNumber,
+		BlockHash:       vLog.BlockHash.Hex(),
+		ChainState:      model.ChainStatePending,
 	}
 
-	if err := l.nftService.SaveNFT(l.ctx, nft); err != nil {
+	if err := l.nftService.SaveNFT(ctx, nft); err != nil {
 		log.Printf("❌ 保存NFT失败: %v", err)
 	} else {
 		log.Printf("✅ NFT已保存: TokenID=%s", event.TokenId.String())
@@ -285,13 +568,7 @@ func (l *BlockchainListener) handleNFTMinted(vLog types.Log, filterer *contract.
 }
 
 // handleTransfer 处理NFT转移事件
-func (l *BlockchainListener) handleTransfer(vLog types.Log, filterer *contract.KevinNFTFilterer) {
-	event, err := filterer.ParseTransfer(vLog)
-	if err != nil {
-		log.Printf("❌ 解析Transfer事件失败: %v", err)
-		return
-	}
-
+func (l *BlockchainListener) handleTransfer(ctx context.Context, event *contract.KevinNFTTransfer, vLog types.Log) {
 	log.Printf("✅ Transfer事件: TokenID=%s, From=%s, To=%s",
 		event.TokenId.String(), event.From.Hex(), event.To.Hex())
 
@@ -299,54 +576,86 @@ func (l *BlockchainListener) handleTransfer(vLog types.Log, filterer *contract.K
 	contractAddr := l.nftService.GetContractAddress().Hex()
 	tokenID := event.TokenId.String()
 	newOwner := event.To.Hex()
+	l.recordTransfer(ctx, event.From, event.To, event.TokenId, vLog)
 
 	var existing model.NFTInfo
-	result := l.nftService.DB.WithContext(l.ctx).
+	result := l.nftService.DB.WithContext(ctx).
 		Model(&model.NFTInfo{}).
 		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID).First(&existing)
 	if result.Error != nil {
-		log.Printf("❌ 数据库更新失败: %v", err)
+		// 铸造时 Transfer 先于 NFTMinted 触发，记录还不存在
+		existing = model.NFTInfo{
+			ContractAddress: contractAddr,
+			TokenID:         tokenID,
+			Name:            fmt.Sprintf("NFT #%s", tokenID),
+			Blockchain:      "sepolia",
+			IsMinted:        true,
+		}
 	}
 	existing.Owner = newOwner
+	existing.BeneficialOwner = event.From.Hex() // 转入拍卖合约且拍卖还未入库时，实际所有者为转出方
+	if event.From == (common.Address{}) {
+		existing.MintedBlock = vLog.BlockNumber
+		existing.MintedAt = time.Unix(int64(l.blockTime(ctx, vLog)), 0)
+	}
+	existing.BlockNumber = vLog.BlockNumber
+	existing.BlockHash = vLog.BlockHash.Hex()
+	existing.ChainState = model.ChainStatePending
 
 	// 更新数据库中的NFT所有者
-	if err := l.nftService.SaveNFT(l.ctx, &existing); err != nil {
+	if err := l.nftService.SaveNFT(ctx, &existing); err != nil {
 		log.Printf("❌ 保存NFT失败: %v", err)
 	} else {
 		log.Printf("✅ NFT已保存: TokenID=%s", event.TokenId.String())
 	}
-}
 
-// handleApproval 处理单NFT授权事件
-func (l *BlockchainListener) handleApproval(vLog types.Log, filterer *contract.KevinNFTFilterer) {
-	event, err := filterer.ParseApproval(vLog)
-	if err != nil {
-		log.Printf("❌ 解析Approval事件失败: %v", err)
-		return
+	// 拍卖合约把NFT转回卖家：无人出价结束（合约不发 AuctionEnded）
+	if event.From == l.auctionService.GetContractAddress() {
+		l.reconcileReturnedNFT(ctx, vLog.Address, tokenID, event.To, vLog)
 	}
+}
 
+// handleApproval 处理单NFT授权事件
+func (l *BlockchainListener) handleApproval(ctx context.Context, event *contract.KevinNFTApproval, vLog types.Log) {
 	log.Printf("✅ Approval事件: TokenID=%s, Owner=%s, Approved=%s",
 		event.TokenId.String(), event.Owner.Hex(), event.Approved.Hex())
 
-	// 保存授权记录到数据库
-	approval := &model.NFTInfo{
-		TokenID:         event.TokenId.String(),
-		Owner:           event.Owner.Hex(),
-		ApprovedAddress: event.Approved.Hex(),
-		ApprovedAt:      time.Now(),
-		ApprovalTxHash:  vLog.TxHash.Hex(),
-		LastSyncTime:    time.Now(),
-	}
+	l.saveApproval(ctx, vLog.Address, event.TokenId, event.Approved, vLog)
+}
 
-	if err := l.nftService.SaveNFT(l.ctx, approval); err != nil {
+// saveApproval 保存授权记录到数据库
+func (l *BlockchainListener) saveApproval(ctx context.Context, contractAddr common.Address, tokenID *big.Int, approved common.Address, vLog types.Log) {
+	err := l.nftService.SetApproval(ctx, contractAddr, tokenID.String(), approved.Hex(),
+		vLog.TxHash.Hex(), time.Unix(int64(l.blockTime(ctx, vLog)), 0))
+	if err != nil {
 		log.Printf("❌ 保存授权记录失败: %v", err)
 	}
 }
 
 // ==================== 辅助函数 ====================
 
+// recordTransfer 保存转移记录（来源追溯），按转出/转入地址分类
+func (l *BlockchainListener) recordTransfer(ctx context.Context, from, to common.Address, tokenID *big.Int, vLog types.Log) {
+	transfer := &model.Transfer{
+		ContractAddress: vLog.Address.Hex(),
+		TokenID:         tokenID.String(),
+		From:            from.Hex(),
+		To:              to.Hex(),
+		Kind:            l.nftService.ClassifyTransfer(from, to),
+		TxHash:          vLog.TxHash.Hex(),
+		LogIndex:        vLog.Index,
+		BlockNumber:     vLog.BlockNumber,
+		BlockHash:       vLog.BlockHash.Hex(),
+		BlockTime:       l.blockTime(ctx, vLog),
+		ChainState:      model.ChainStatePending,
+	}
+	if err := l.nftService.SaveTransfer(ctx, transfer); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
 // createNFTFromTransfer 从转移事件创建NFT记录
-func (l *BlockchainListener) createNFTFromTransfer(contractAddr, tokenID, owner string) error {
+func (l *BlockchainListener) createNFTFromTransfer(ctx context.Context, contractAddr, tokenID, owner string) error {
 	// 这里可以添加一些默认值或从区块链获取基本信息
 	nft := &model.NFTInfo{
 		ContractAddress: contractAddr,
@@ -361,7 +670,7 @@ func (l *BlockchainListener) createNFTFromTransfer(contractAddr, tokenID, owner
 		UpdatedAt:       time.Now(),
 	}
 
-	if err := l.nftService.SaveNFT(l.ctx, nft); err != nil {
+	if err := l.nftService.SaveNFT(ctx, nft); err != nil {
 		return fmt.Errorf("创建NFT记录失败: %v", err)
 	}
 
@@ -369,157 +678,108 @@ func (l *BlockchainListener) createNFTFromTransfer(contractAddr, tokenID, owner
 	return nil
 }
 
-// ---------------- 拍卖事件监听 ----------------
-func (l *BlockchainListener) listenAuctionEvents() {
-	auctionAddr := l.auctionService.GetContractAddress()
-	query := ethereum.FilterQuery{Addresses: []common.Address{auctionAddr}}
-
-	// 提前计算事件签名（只计算一次，提高性能）
-	auctionCreatedID := crypto.Keccak256Hash([]byte("AuctionCreated(uint256,address,uint256,uint256)"))
-	bidPlacedID := crypto.Keccak256Hash([]byte("NewBid(uint256,address,uint256)"))
-	auctionEndedID := crypto.Keccak256Hash([]byte("AuctionEnded(uint256,address,uint256)"))
-
-	// 提前创建Filterer（避免每次循环都创建）
-	// 只需要解析，不需要查询, 第二个参数可以传eth client 与区块链交互
-	filterer, err := contract.NewNftAuctionFilterer(auctionAddr, nil)
-	if err != nil {
-		log.Fatalf("❌ 创建Filterer失败: %v", err)
-	}
-
-	log.Println("✅ NFT拍卖事件监听器订阅成功，等待事件...")
-	logsChan := make(chan types.Log)
-	sub, err := l.ethClient.SubscribeFilterLogs(l.ctx, query, logsChan)
-	if err != nil {
-		log.Fatalf("❌ 拍卖 SubscribeFilterLogs 失败: %v", err)
-	}
-
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Printf("❌ 拍卖监听错误: %v, 重连中...", err)
-			return
-		case vLog := <-logsChan:
-			if len(vLog.Topics) == 0 {
-				continue
-			}
-
-			eventID := vLog.Topics[0]
-
-			var auctionID *big.Int
-			var eventName string
-
-			// 根据事件ID分流处理
-			switch eventID {
-			case auctionCreatedID:
-				eventName = "AuctionCreated"
-				log.Printf("📥 NFT 拍卖事件收到事件，事件名：%s 签名: %s", eventName, eventID.Hex())
-
-				// 只解析AuctionCreated事件
-				event, err := filterer.ParseAuctionCreated(vLog)
-				if err != nil {
-					log.Printf("❌ 解析AuctionCreated失败: %v", err)
-					continue
-				}
-				auctionID = event.AuctionId
-				l.handleAuctionCreated(event, vLog)
-
-			case bidPlacedID:
-				eventName = "NewBid"
-				log.Printf("📥 NFT 拍卖事件收到事件，事件名：%s 签名: %s", eventName, eventID.Hex())
-
-				// 只解析NewBid事件
-				event, err := filterer.ParseNewBid(vLog)
-				if err != nil {
-					log.Printf("❌ 解析NewBid失败: %v", err)
-					continue
-				}
-				auctionID = event.AuctionId
-				l.handleNewBid(event, vLog)
-
-			case auctionEndedID:
-				eventName = "AuctionEnded"
-				log.Printf("📥 NFT 拍卖事件收到事件，事件名：%s 签名: %s", eventName, eventID.Hex())
-
-				// 只解析AuctionEnded事件
-				event, err := filterer.ParseAuctionEnded(vLog)
-				if err != nil {
-					log.Printf("❌ 解析AuctionEnded失败: %v", err)
-					continue
-				}
-				auctionID = event.AuctionId
-				l.handleAuctionEnded(event, vLog)
-
-			default:
-				log.Printf("⚠️ 未知拍卖事件: %s", eventID.Hex())
-				continue
-			}
-
-			// 统计计数
-			if auctionID != nil {
-				log.Printf("🏷️ 拍卖事件: %s, AuctionID=%s", eventName, auctionID.String())
-
-				l.statsLock.Lock()
-				l.stats["auctions"]++
-				if eventID == bidPlacedID {
-					l.stats["bids"]++
-				}
-				l.statsLock.Unlock()
-
-				// 注意：现在不需要调用 UpdateAuctionFromChain 了！
-				// 因为 handleXXX 方法已经用事件数据更新了数据库
-			}
-		case <-l.ctx.Done():
-			log.Println("❌ 拍卖监听器已停止")
-			return
-		}
-	}
-}
-
 // 处理拍卖创建事件 - 现在可以直接使用事件参数
-func (l *BlockchainListener) handleAuctionCreated(event *contract.NftAuctionAuctionCreated, vLog types.Log) {
+func (l *BlockchainListener) handleAuctionCreated(ctx context.Context, event *contract.NftAuctionAuctionCreated, vLog types.Log) {
 	// 直接从事件获取所有参数，不需要再查区块链
 	auction := &model.Auction{
 		AuctionID:     event.AuctionId.Uint64(),
-		NFTContract:   l.auctionService.GetContractAddress().Hex(), // 假设拍卖合约知道对应的NFT合约
 		TokenID:       event.TokenId.String(),
 		Seller:        event.Seller.Hex(),
 		StartingPrice: event.StartPrice.String(),
 		HighestBid:    "0",
 		HighestBidder: "0x0000000000000000000000000000000000000000",
-		StartTime:     uint64(time.Now().Unix()), // 可能需要从区块时间获取更准确
-		EndTime:       0,                         // 需要从duration计算，可能需要额外查询
+		StartTime:     l.blockTime(ctx, vLog), // 合约 startTime = block.timestamp
 		Ended:         false,
-		Status:        "active",
+		Status:        contract.AuctionStatusActive,
+		TxHash:        vLog.TxHash.Hex(),
+		BlockNumber:   vLog.BlockNumber,
+		BlockHash:     vLog.BlockHash.Hex(),
+		ChainState:    model.ChainStatePending,
+	}
+
+	// 事件不包含NFT合约、时长和支付币种，从链上补充并记入事件日志（回放时读回）
+	enrichment, ok := l.auctionCreatedEnrichment(ctx, auction.AuctionID, vLog)
+	if ok {
+		auction.NFTContract = enrichment.NFTContract
+		auction.EndTime = auction.StartTime + enrichment.Duration
+		if auction.EndTime <= uint64(time.Now().Unix()) {
+			// 延迟处理的事件（回填），拍卖可能已经到期
+			auction.Status = contract.AuctionStatusExpired
+		}
+		if enrichment.UseERC20 {
+			auction.UseERC20 = true
+			auction.PaymentToken = enrichment.PaymentToken
+		}
 	}
 
 	// 如果有问题，可以记录但不阻塞
-	if err := l.auctionService.SaveAuction(l.ctx, auction); err != nil {
+	if err := l.auctionService.SaveAuction(ctx, auction); err != nil {
 		log.Printf("❌ 保存拍卖失败: %v", err)
 	} else {
 		log.Printf("✅ 拍卖 #%d 已保存到数据库", auction.AuctionID)
+		// 托管转移可能已先处理：NFT的实际所有者改为卖家并关联拍卖
+		if err := l.nftService.LinkEscrow(ctx, auction); err != nil {
+			log.Printf("❌ %v", err)
+		}
+	}
+
+	// 第三方合集：注册后从拍卖所在区块开始索引（托管转移与拍卖创建在同一笔交易中）
+	// 回放时合集注册表保留，不需要（也不能离线）注册
+	if ok && !isReplay(ctx) {
+		l.discoverCollection(ctx, common.HexToAddress(enrichment.NFTContract), vLog.BlockNumber)
 	}
 }
 
+// auctionCreatedEnrichment 拍卖的NFT合约、时长与支付币种：回放时读事件日志，否则查询链上并记入事件日志
+func (l *BlockchainListener) auctionCreatedEnrichment(ctx context.Context, auctionID uint64, vLog types.Log) (*auctionCreatedEnrichment, bool) {
+	enrichment := &auctionCreatedEnrichment{}
+	if isReplay(ctx) {
+		if !l.replayed(ctx, enrichment) {
+			// EndTime 保持为0，由过期拍卖对账回读；NFT合约留空，启动时修正
+			log.Printf("⚠️ 拍卖 #%d 的事件日志没有补充数据，NFT合约与时长留空", auctionID)
+			return nil, false
+		}
+		return enrichment, true
+	}
+
+	info, err := l.auctionService.GetAuctionInfo(ctx, auctionID)
+	if err != nil {
+		// EndTime 保持为0，由过期拍卖对账回读；NFT合约留空，启动时修正
+		log.Printf("⚠️ 获取拍卖 #%d NFT合约、时长与支付币种失败: %v", auctionID, err)
+		return nil, false
+	}
+	enrichment.NFTContract = info.NFTContract.Hex()
+	enrichment.Duration = info.Duration.Uint64()
+	if info.UseERC20 {
+		enrichment.UseERC20 = true
+		enrichment.PaymentToken = info.PaymentToken.Hex()
+	}
+	l.enrich(ctx, vLog, enrichment)
+	return enrichment, true
+}
+
 // 处理新出价事件
-func (l *BlockchainListener) handleNewBid(event *contract.NftAuctionNewBid, vLog types.Log) {
+func (l *BlockchainListener) handleNewBid(ctx context.Context, event *contract.NftAuctionNewBid, vLog types.Log) {
 	// 1. 保存出价历史
 	bidHistory := &model.BidHistory{
 		AuctionID:   event.AuctionId.Uint64(),
 		Bidder:      event.Bidder.Hex(),
 		Amount:      event.Amount.String(),
 		TxHash:      vLog.TxHash.Hex(),
+		LogIndex:    vLog.Index,
 		BlockNumber: vLog.BlockNumber,
-		BlockTime:   uint64(time.Now().Unix()),
-		Status:      "success",
+		BlockHash:   vLog.BlockHash.Hex(),
+		BlockTime:   l.blockTime(ctx, vLog),
+		Status:      model.ChainStatePending,
 	}
 
-	if err := l.auctionService.SaveBidHistory(l.ctx, bidHistory); err != nil {
+	if err := l.auctionService.SaveBidHistory(ctx, bidHistory); err != nil {
 		log.Printf("❌ 保存出价历史失败: %v", err)
 	}
 
 	// 2. 更新拍卖最高出价
 	// 注意：这里最好从数据库获取当前拍卖信息来比较
-	auction, err := l.auctionService.GetAuctionByAuctionID(l.ctx, event.AuctionId.Uint64())
+	auction, err := l.auctionService.GetAuctionByAuctionID(ctx, event.AuctionId.Uint64())
 	if err != nil {
 		log.Printf("❌ 获取拍卖 #%d 信息失败: %v", event.AuctionId.Uint64(), err)
 		return
@@ -532,7 +792,7 @@ func (l *BlockchainListener) handleNewBid(event *contract.NftAuctionNewBid, vLog
 		auction.HighestBidder = event.Bidder.Hex()
 		auction.UpdatedAt = time.Now()
 
-		if err := l.auctionService.SaveAuction(l.ctx, auction); err != nil {
+		if err := l.auctionService.SaveAuction(ctx, auction); err != nil {
 			log.Printf("❌ 更新拍卖出价失败: %v", err)
 		} else {
 			log.Printf("✅ 拍卖 #%d 最高出价更新为 %s", auction.AuctionID, event.Amount.String())
@@ -541,9 +801,9 @@ func (l *BlockchainListener) handleNewBid(event *contract.NftAuctionNewBid, vLog
 }
 
 // 处理拍卖结束事件
-func (l *BlockchainListener) handleAuctionEnded(event *contract.NftAuctionAuctionEnded, vLog types.Log) {
+func (l *BlockchainListener) handleAuctionEnded(ctx context.Context, event *contract.NftAuctionAuctionEnded, vLog types.Log) {
 	// 更新拍卖状态为结束
-	auction, err := l.auctionService.GetAuctionByAuctionID(l.ctx, event.AuctionId.Uint64())
+	auction, err := l.auctionService.GetAuctionByAuctionID(ctx, event.AuctionId.Uint64())
 	if err != nil {
 		log.Printf("❌ 获取拍卖 #%d 信息失败: %v", event.AuctionId.Uint64(), err)
 		return
@@ -555,9 +815,14 @@ func (l *BlockchainListener) handleAuctionEnded(event *contract.NftAuctionAuctio
 	auction.HighestBidder = event.Winner.Hex()
 	auction.UpdatedAt = time.Now()
 
-	if err := l.auctionService.SaveAuction(l.ctx, auction); err != nil {
+	if err := l.auctionService.SaveAuction(ctx, auction); err != nil {
 		log.Printf("❌ 更新拍卖结束状态失败: %v", err)
 	} else {
 		log.Printf("✅ 拍卖 #%d 已结束，赢家: %s", auction.AuctionID, event.Winner.Hex())
 	}
+
+	// 最高出价从托管支付给卖家（AuctionEnded 只在有人出价时触发）
+	if err := l.auctionService.RecordSellerPayout(ctx, auction, event.FinalPrice.String(), l.blockTime(ctx, vLog), vLog); err != nil {
+		log.Printf("❌ %v", err)
+	}
 }
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+// ==================== NFT合约级事件 ====================
+
+// handleCollectionMint 铸造后更新供应量（TokenID 从1开始连续分配）
+func (l *BlockchainListener) handleCollectionMint(ctx context.Context, event *contract.KevinNFTNFTMinted, vLog types.Log) {
+	if err := l.collectionService.RecordMint(ctx, event.TokenId.Uint64(), vLog.BlockNumber); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// handlePriceUpdated 铸造价格变更
+func (l *BlockchainListener) handlePriceUpdated(ctx context.Context, event *contract.KevinNFTPriceUpdated, vLog types.Log) {
+	log.Printf("✅ PriceUpdated事件: 新价格=%s", event.NewPrice.String())
+	l.updateCollection(ctx, vLog, map[string]interface{}{"mint_price": event.NewPrice.String()})
+}
+
+// handleMintingToggled 铸造开关变更
+func (l *BlockchainListener) handleMintingToggled(ctx context.Context, event *contract.KevinNFTMintingToggled, vLog types.Log) {
+	log.Printf("✅ MintingToggled事件: 开放铸造=%v", event.Enabled)
+	l.updateCollection(ctx, vLog, map[string]interface{}{"minting_enabled": event.Enabled})
+}
+
+// handleOwnershipTransferred 合约所有者变更
+func (l *BlockchainListener) handleOwnershipTransferred(ctx context.Context, event *contract.KevinNFTOwnershipTransferred, vLog types.Log) {
+	log.Printf("✅ OwnershipTransferred事件: %s → %s", event.PreviousOwner.Hex(), event.NewOwner.Hex())
+	l.updateCollection(ctx, vLog, map[string]interface{}{"owner": event.NewOwner.Hex()})
+}
+
+func (l *BlockchainListener) updateCollection(ctx context.Context, vLog types.Log, updates map[string]interface{}) {
+	if err := l.collectionService.UpdateState(ctx, vLog.BlockNumber, updates); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// handleWithdrawn 合约所有者提取铸造收入
+func (l *BlockchainListener) handleWithdrawn(ctx context.Context, event *contract.KevinNFTWithdrawn, vLog types.Log) {
+	log.Printf("✅ Withdrawn事件: Owner=%s, Amount=%s", event.Owner.Hex(), event.Amount.String())
+
+	withdrawal := &model.Withdrawal{
+		ContractAddress: vLog.Address.Hex(),
+		Owner:           event.Owner.Hex(),
+		Amount:          event.Amount.String(),
+		TxHash:          vLog.TxHash.Hex(),
+		LogIndex:        vLog.Index,
+		BlockNumber:     vLog.BlockNumber,
+		BlockHash:       vLog.BlockHash.Hex(),
+		BlockTime:       l.blockTime(ctx, vLog),
+		ChainState:      model.ChainStatePending,
+	}
+	if err := l.collectionService.SaveWithdrawal(ctx, withdrawal); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// handleApprovalForAll 全量授权变更
+func (l *BlockchainListener) handleApprovalForAll(ctx context.Context, event *contract.KevinNFTApprovalForAll, vLog types.Log) {
+	log.Printf("✅ ApprovalForAll事件: Owner=%s, Operator=%s, Approved=%v",
+		event.Owner.Hex(), event.Operator.Hex(), event.Approved)
+
+	approval := &model.OperatorApproval{
+		ContractAddress: vLog.Address.Hex(),
+		Owner:           event.Owner.Hex(),
+		Operator:        event.Operator.Hex(),
+		Approved:        event.Approved,
+		TxHash:          vLog.TxHash.Hex(),
+		BlockNumber:     vLog.BlockNumber,
+		BlockHash:       vLog.BlockHash.Hex(),
+		ChainState:      model.ChainStatePending,
+	}
+	if err := l.collectionService.SaveOperatorApproval(ctx, approval); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// handleMetadataUpdate 单个NFT元数据变更：重新读取 tokenURI（读取结果记入事件日志，回放时直接写入）
+func (l *BlockchainListener) handleMetadataUpdate(ctx context.Context, event *contract.KevinNFTMetadataUpdate, vLog types.Log) {
+	if isReplay(ctx) {
+		l.replayTokenURIs(ctx)
+		return
+	}
+	uri, err := l.nftService.RefreshTokenURI(ctx, event.TokenId)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return
+	}
+	if uri != "" {
+		l.enrich(ctx, vLog, tokenURIEnrichment{URIs: map[string]string{event.TokenId.String(): uri}})
+	}
+}
+
+// handleBatchMetadataUpdate 批量元数据变更：重新读取区间内已入库NFT的 tokenURI
+func (l *BlockchainListener) handleBatchMetadataUpdate(ctx context.Context, event *contract.KevinNFTBatchMetadataUpdate, vLog types.Log) {
+	if isReplay(ctx) {
+		l.replayTokenURIs(ctx)
+		return
+	}
+	uris, err := l.nftService.RefreshTokenURIRange(ctx, event.FromTokenId, event.ToTokenId)
+	if len(uris) > 0 {
+		l.enrich(ctx, vLog, tokenURIEnrichment{URIs: uris})
+	}
+	if err != nil {
+		log.Printf("❌ 批量刷新 tokenURI 失败（已刷新 %d 个）: %v", len(uris), err)
+		return
+	}
+	log.Printf("✅ BatchMetadataUpdate: 已刷新 %d 个NFT的 tokenURI", len(uris))
+}
+
+// replayTokenURIs 回放元数据变更：写入事件日志中记录的 tokenURI
+func (l *BlockchainListener) replayTokenURIs(ctx context.Context) {
+	enrichment := tokenURIEnrichment{}
+	if !l.replayed(ctx, &enrichment) {
+		return
+	}
+	for id, uri := range enrichment.URIs {
+		tokenID, ok := new(big.Int).SetString(id, 10)
+		if !ok || uri == "" {
+			continue
+		}
+		if err := l.nftService.SetTokenURI(ctx, tokenID, uri); err != nil {
+			log.Printf("❌ %v", err)
+		}
+	}
+}
+
+// ---------------- 回滚 ----------------
+
+// rollbackCollectionState 合约状态事件被移除：从链上重新同步
+func (l *BlockchainListener) rollbackCollectionState(ctx context.Context) {
+	if _, err := l.collectionService.SyncCollection(ctx); err != nil {
+		log.Printf("❌ 回滚合约状态失败: %v", err)
+	}
+}
+
+// rollbackWithdrawn 提取记录被移除：标记 orphaned
+func (l *BlockchainListener) rollbackWithdrawn(ctx context.Context, event *contract.KevinNFTWithdrawn, vLog types.Log) {
+	if err := l.collectionService.OrphanWithdrawal(ctx, vLog.TxHash.Hex(), vLog.Index); err != nil {
+		log.Printf("❌ 回滚提取记录失败: %v", err)
+	}
+}
+
+// rollbackApprovalForAll 全量授权被移除：从链上读取当前状态
+func (l *BlockchainListener) rollbackApprovalForAll(ctx context.Context, event *contract.KevinNFTApprovalForAll, vLog types.Log) {
+	if err := l.collectionService.RefreshOperatorApproval(ctx, vLog.Address, event.Owner, event.Operator); err != nil {
+		log.Printf("❌ 回滚全量授权失败: %v", err)
+	}
+}
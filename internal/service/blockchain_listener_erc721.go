@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+// ==================== 第三方合集（通用 ERC-721） ====================
+
+// registerCollectionEvents 注册第三方合集的 Transfer / Approval / ApprovalForAll 处理函数
+func (l *BlockchainListener) registerCollectionEvents(addr common.Address) error {
+	erc721ABI, err := contract.ERC721ABI()
+	if err != nil {
+		return err
+	}
+	// 只用于解析日志
+	parser, err := contract.NewERC721Client(nil, addr)
+	if err != nil {
+		return err
+	}
+
+	r := l.events
+	r.RegisterContract(fmt.Sprintf("合集 %s", addr.Hex()), addr, erc721ABI)
+
+	steps := []error{
+		RegisterEvent(r, addr, "Transfer", parser.ParseTransfer),
+		Subscribe(r, addr, "Transfer", l.handleCollectionTransfer),
+		Subscribe(r, addr, "Transfer", func(context.Context, *contract.ERC721Transfer, types.Log) { l.countEvent("nft_transfers") }),
+		SubscribeRemoved(r, addr, "Transfer", l.rollbackCollectionTransfer),
+
+		RegisterEvent(r, addr, "Approval", parser.ParseApproval),
+		Subscribe(r, addr, "Approval", l.handleCollectionApproval),
+		SubscribeRemoved(r, addr, "Approval", func(ctx context.Context, _ *contract.ERC721Approval, vLog types.Log) {
+			if err := l.nftService.ClearApproval(ctx, vLog.TxHash.Hex()); err != nil {
+				log.Printf("❌ 回滚授权记录失败: %v", err)
+			}
+		}),
+
+		RegisterEvent(r, addr, "ApprovalForAll", parser.ParseApprovalForAll),
+		Subscribe(r, addr, "ApprovalForAll", l.handleCollectionApprovalForAll),
+		SubscribeRemoved(r, addr, "ApprovalForAll", l.rollbackCollectionApprovalForAll),
+	}
+	for _, err := range steps {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// onCollectionRegistered 新合集注册：注册事件处理函数，从发现区块开始记录检查点，通知监听协程
+func (l *BlockchainListener) onCollectionRegistered(collection model.Collection) {
+	addr := common.HexToAddress(collection.ContractAddress)
+	if err := l.registerCollectionEvents(addr); err != nil {
+		log.Printf("❌ 注册合集 %s 事件处理函数失败: %v", addr.Hex(), err)
+		return
+	}
+
+	// 可能在监听器停止期间（回放、重启）由 API 触发：数据库操作使用 parent，通知随当前监听上下文取消
+	runCtx, parent := l.contexts()
+	_, found, err := l.eventSync.GetLastBlock(parent, CollectionEventsKind, addr)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return
+	}
+	if !found && collection.DiscoveredBlock > 0 {
+		if err := l.eventSync.SaveLastBlock(parent, CollectionEventsKind, addr, collection.DiscoveredBlock-1); err != nil {
+			log.Printf("❌ %v", err)
+			return
+		}
+	}
+
+	// 监听协程未启动时（CLI 子命令、监听器已停止）随 ctx 取消退出，下次启动时从注册表加载
+	go func() {
+		select {
+		case l.newCollections <- addr:
+		case <-runCtx.Done():
+		}
+	}()
+}
+
+// discoverCollection 拍卖中出现的NFT合约加入注册表
+func (l *BlockchainListener) discoverCollection(ctx context.Context, addr common.Address, blockNumber uint64) {
+	if _, err := l.collectionService.Register(ctx, addr, model.CollectionSourceAuction, blockNumber); err != nil {
+		log.Printf("⚠️ 注册合集 %s 失败: %v", addr.Hex(), err)
+	}
+}
+
+// runCollectionListeners 为每个第三方合集启动独立的监听协程，新注册的合集随时加入
+func (l *BlockchainListener) runCollectionListeners() {
+	started := make(map[common.Address]bool)
+	start := func(addr common.Address) {
+		if started[addr] {
+			return
+		}
+		started[addr] = true
+		l.goRun(func() { l.keepListening(CollectionEventsKind, addr) })
+	}
+
+	for _, addr := range l.collectionService.IndexedAddresses() {
+		start(addr)
+	}
+
+	// 修正旧版本写错的拍卖NFT合约并补全托管关系，再从已有拍卖中发现合集
+	l.goRun(func() {
+		if _, err := l.auctionService.RepairNFTContracts(l.ctx); err != nil {
+			log.Printf("❌ %v", err)
+		}
+		if _, err := l.nftService.RepairCustody(l.ctx); err != nil {
+			log.Printf("❌ %v", err)
+		}
+		if count, err := l.collectionService.DiscoverFromAuctions(l.ctx); err != nil {
+			log.Printf("❌ %v", err)
+		} else if count > 0 {
+			log.Printf("✅ 从已有拍卖中发现 %d 个合集", count)
+		}
+	})
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case addr := <-l.newCollections:
+			start(addr)
+		}
+	}
+}
+
+// keepListening 单个合约的监听断开后自动重连
+func (l *BlockchainListener) keepListening(kind string, addr common.Address) {
+	for {
+		l.listenContract(kind, addr)
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-time.After(3 * time.Second):
+			log.Printf("🔄 %s 监听重连中...", l.events.ContractName(addr))
+		}
+	}
+}
+
+// ---------------- 事件处理 ----------------
+
+// handleCollectionTransfer 第三方合集的NFT转移：按 (合约, TokenID) 更新所有者
+func (l *BlockchainListener) handleCollectionTransfer(ctx context.Context, event *contract.ERC721Transfer, vLog types.Log) {
+	log.Printf("✅ Transfer事件: 合约=%s TokenID=%s, From=%s, To=%s",
+		vLog.Address.Hex(), event.TokenId.String(), event.From.Hex(), event.To.Hex())
+
+	tokenID := event.TokenId.String()
+	l.recordTransfer(ctx, event.From, event.To, event.TokenId, vLog)
+
+	nft, err := l.nftService.GetNFT(vLog.Address.Hex(), tokenID)
+	if err != nil {
+		// 第一次见到这个NFT（铸造或发现前已存在），tokenURI 尽量补充
+		nft = &model.NFTInfo{
+			ContractAddress: vLog.Address.Hex(),
+			TokenID:         tokenID,
+			Name:            fmt.Sprintf("NFT #%s", tokenID),
+			Blockchain:      "sepolia",
+			LastSyncTime:    time.Now(),
+		}
+		nft.Uri = l.collectionTokenURI(ctx, event.TokenId, vLog)
+	}
+	nft.Owner = event.To.Hex()
+	nft.BeneficialOwner = event.From.Hex() // 转入拍卖合约且拍卖还未入库时，实际所有者为转出方
+	nft.IsMinted = event.To != (common.Address{})
+	if event.From == (common.Address{}) {
+		nft.MintedBlock = vLog.BlockNumber
+		nft.MintedAt = time.Unix(int64(l.blockTime(ctx, vLog)), 0)
+	}
+	nft.BlockNumber = vLog.BlockNumber
+	nft.BlockHash = vLog.BlockHash.Hex()
+	nft.ChainState = model.ChainStatePending
+
+	if err := l.nftService.SaveNFT(ctx, nft); err != nil {
+		log.Printf("❌ 保存NFT失败: %v", err)
+	}
+
+	// 拍卖合约把NFT转回卖家：无人出价结束（合约不发 AuctionEnded）
+	if event.From == l.auctionService.GetContractAddress() {
+		l.reconcileReturnedNFT(ctx, vLog.Address, tokenID, event.To, vLog)
+	}
+}
+
+// collectionTokenURI 第一次见到的第三方NFT的 tokenURI：回放时读事件日志，否则查询链上并记入事件日志
+func (l *BlockchainListener) collectionTokenURI(ctx context.Context, tokenID *big.Int, vLog types.Log) string {
+	enrichment := tokenURIEnrichment{}
+	if isReplay(ctx) {
+		l.replayed(ctx, &enrichment)
+		return enrichment.URIs[tokenID.String()]
+	}
+
+	reader, ok := l.collectionService.Reader(vLog.Address)
+	if !ok {
+		return ""
+	}
+	uri, err := reader.GetTokenURI(ctx, tokenID)
+	if err != nil {
+		return ""
+	}
+	enrichment.URIs = map[string]string{tokenID.String(): uri}
+	l.enrich(ctx, vLog, enrichment)
+	return uri
+}
+
+// handleCollectionApproval 第三方合集的单NFT授权
+func (l *BlockchainListener) handleCollectionApproval(ctx context.Context, event *contract.ERC721Approval, vLog types.Log) {
+	l.saveApproval(ctx, vLog.Address, event.TokenId, event.Approved, vLog)
+}
+
+// handleCollectionApprovalForAll 第三方合集的全量授权
+func (l *BlockchainListener) handleCollectionApprovalForAll(ctx context.Context, event *contract.ERC721ApprovalForAll, vLog types.Log) {
+	approval := &model.OperatorApproval{
+		ContractAddress: vLog.Address.Hex(),
+		Owner:           event.Owner.Hex(),
+		Operator:        event.Operator.Hex(),
+		Approved:        event.Approved,
+		TxHash:          vLog.TxHash.Hex(),
+		BlockNumber:     vLog.BlockNumber,
+		BlockHash:       vLog.BlockHash.Hex(),
+		ChainState:      model.ChainStatePending,
+	}
+	if err := l.collectionService.SaveOperatorApproval(ctx, approval); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// ---------------- 回滚 ----------------
+
+// rollbackCollectionTransfer 转移被移除：转移记录标记 orphaned，从链上刷新所有者
+func (l *BlockchainListener) rollbackCollectionTransfer(ctx context.Context, event *contract.ERC721Transfer, vLog types.Log) {
+	l.orphanTransfer(ctx, vLog)
+	l.refreshNFT(ctx, vLog.Address, event.TokenId)
+}
+
+// rollbackCollectionApprovalForAll 全量授权被移除：从链上读取当前状态
+func (l *BlockchainListener) rollbackCollectionApprovalForAll(ctx context.Context, event *contract.ERC721ApprovalForAll, vLog types.Log) {
+	if err := l.collectionService.RefreshOperatorApproval(ctx, vLog.Address, event.Owner, event.Operator); err != nil {
+		log.Printf("❌ 回滚全量授权失败: %v", err)
+	}
+}
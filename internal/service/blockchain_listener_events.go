@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"nft-auction-backend/internal/contract"
+)
+
+// 合约在事件注册表中的名称
+const (
+	nftContractName     = "NFT"
+	auctionContractName = "拍卖"
+)
+
+// registerEventHandlers 注册两个合约的事件解码器与处理函数
+// 支持新事件：RegisterEvent 注册解码器，Subscribe / SubscribeRemoved 添加处理函数
+func (l *BlockchainListener) registerEventHandlers() error {
+	nftABI, err := contract.KevinNFTMetaData.GetAbi()
+	if err != nil {
+		return fmt.Errorf("解析NFT合约ABI失败: %v", err)
+	}
+	auctionABI, err := contract.NftAuctionMetaData.GetAbi()
+	if err != nil {
+		return fmt.Errorf("解析拍卖合约ABI失败: %v", err)
+	}
+
+	// Filterer 只需要解析日志，不需要查询，第二个参数传 nil
+	nftAddr := l.nftService.GetContractAddress()
+	nftFilterer, err := contract.NewKevinNFTFilterer(nftAddr, nil)
+	if err != nil {
+		return fmt.Errorf("创建NFT Filterer失败: %v", err)
+	}
+	auctionAddr := l.auctionService.GetContractAddress()
+	auctionFilterer, err := contract.NewNftAuctionFilterer(auctionAddr, nil)
+	if err != nil {
+		return fmt.Errorf("创建拍卖Filterer失败: %v", err)
+	}
+
+	r := l.events
+	r.RegisterContract(nftContractName, nftAddr, nftABI)
+	r.RegisterContract(auctionContractName, auctionAddr, auctionABI)
+
+	steps := []error{
+		// ---------------- NFT 合约 ----------------
+		RegisterEvent(r, nftAddr, "NFTMinted", nftFilterer.ParseNFTMinted),
+		Subscribe(r, nftAddr, "NFTMinted", l.handleNFTMinted),
+		Subscribe(r, nftAddr, "NFTMinted", l.handleCollectionMint),
+		SubscribeRemoved(r, nftAddr, "NFTMinted", l.rollbackNFTMinted),
+		SubscribeRemoved(r, nftAddr, "NFTMinted", func(ctx context.Context, _ *contract.KevinNFTNFTMinted, _ types.Log) { l.rollbackCollectionState(ctx) }),
+
+		RegisterEvent(r, nftAddr, "Transfer", nftFilterer.ParseTransfer),
+		Subscribe(r, nftAddr, "Transfer", l.handleTransfer),
+		Subscribe(r, nftAddr, "Transfer", func(context.Context, *contract.KevinNFTTransfer, types.Log) { l.countEvent("nft_transfers") }),
+		SubscribeRemoved(r, nftAddr, "Transfer", l.rollbackTransfer),
+
+		RegisterEvent(r, nftAddr, "Approval", nftFilterer.ParseApproval),
+		Subscribe(r, nftAddr, "Approval", l.handleApproval),
+		SubscribeRemoved(r, nftAddr, "Approval", l.rollbackApproval),
+
+		// 合约级状态（铸造价格、铸造开关、合约所有者）
+		RegisterEvent(r, nftAddr, "PriceUpdated", nftFilterer.ParsePriceUpdated),
+		Subscribe(r, nftAddr, "PriceUpdated", l.handlePriceUpdated),
+		SubscribeRemoved(r, nftAddr, "PriceUpdated", func(ctx context.Context, _ *contract.KevinNFTPriceUpdated, _ types.Log) {
+			l.rollbackCollectionState(ctx)
+		}),
+
+		RegisterEvent(r, nftAddr, "MintingToggled", nftFilterer.ParseMintingToggled),
+		Subscribe(r, nftAddr, "MintingToggled", l.handleMintingToggled),
+		SubscribeRemoved(r, nftAddr, "MintingToggled", func(ctx context.Context, _ *contract.KevinNFTMintingToggled, _ types.Log) {
+			l.rollbackCollectionState(ctx)
+		}),
+
+		RegisterEvent(r, nftAddr, "OwnershipTransferred", nftFilterer.ParseOwnershipTransferred),
+		Subscribe(r, nftAddr, "OwnershipTransferred", l.handleOwnershipTransferred),
+		SubscribeRemoved(r, nftAddr, "OwnershipTransferred", func(ctx context.Context, _ *contract.KevinNFTOwnershipTransferred, _ types.Log) {
+			l.rollbackCollectionState(ctx)
+		}),
+
+		RegisterEvent(r, nftAddr, "Withdrawn", nftFilterer.ParseWithdrawn),
+		Subscribe(r, nftAddr, "Withdrawn", l.handleWithdrawn),
+		SubscribeRemoved(r, nftAddr, "Withdrawn", l.rollbackWithdrawn),
+
+		RegisterEvent(r, nftAddr, "ApprovalForAll", nftFilterer.ParseApprovalForAll),
+		Subscribe(r, nftAddr, "ApprovalForAll", l.handleApprovalForAll),
+		SubscribeRemoved(r, nftAddr, "ApprovalForAll", l.rollbackApprovalForAll),
+
+		// 元数据变更（ERC-4906）：重新读取 tokenURI
+		RegisterEvent(r, nftAddr, "MetadataUpdate", nftFilterer.ParseMetadataUpdate),
+		Subscribe(r, nftAddr, "MetadataUpdate", l.handleMetadataUpdate),
+
+		RegisterEvent(r, nftAddr, "BatchMetadataUpdate", nftFilterer.ParseBatchMetadataUpdate),
+		Subscribe(r, nftAddr, "BatchMetadataUpdate", l.handleBatchMetadataUpdate),
+
+		// ---------------- 拍卖合约 ----------------
+		RegisterEvent(r, auctionAddr, "AuctionCreated", auctionFilterer.ParseAuctionCreated),
+		Subscribe(r, auctionAddr, "AuctionCreated", l.handleAuctionCreated),
+		Subscribe(r, auctionAddr, "AuctionCreated", func(context.Context, *contract.NftAuctionAuctionCreated, types.Log) { l.countEvent("auctions") }),
+		SubscribeRemoved(r, auctionAddr, "AuctionCreated", l.rollbackAuctionCreated),
+
+		RegisterEvent(r, auctionAddr, "NewBid", auctionFilterer.ParseNewBid),
+		Subscribe(r, auctionAddr, "NewBid", l.handleNewBid),
+		Subscribe(r, auctionAddr, "NewBid", func(context.Context, *contract.NftAuctionNewBid, types.Log) { l.countEvent("bids") }),
+		SubscribeRemoved(r, auctionAddr, "NewBid", l.rollbackNewBid),
+
+		RegisterEvent(r, auctionAddr, "AuctionEnded", auctionFilterer.ParseAuctionEnded),
+		Subscribe(r, auctionAddr, "AuctionEnded", l.handleAuctionEnded),
+		SubscribeRemoved(r, auctionAddr, "AuctionEnded", l.rollbackAuctionEnded),
+	}
+	for _, err := range steps {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Events 事件注册表（外部可追加订阅者）
+func (l *BlockchainListener) Events() *EventRegistry {
+	return l.events
+}
+
+// countEvent 事件统计计数
+func (l *BlockchainListener) countEvent(key string) {
+	l.statsLock.Lock()
+	l.stats[key]++
+	l.statsLock.Unlock()
+}
+
+// applyLog 处理一条合约日志：先写原始事件日志，再交给注册表分发
+// Removed=true 的日志由回滚订阅者撤销其影响
+func (l *BlockchainListener) applyLog(vLog types.Log) {
+	if vLog.Removed {
+		log.Printf("↩️ %s 日志被重组移除: 区块=%d 交易=%s",
+			l.events.ContractName(vLog.Address), vLog.BlockNumber, vLog.TxHash.Hex())
+		l.journalRemoved(vLog)
+		l.events.Dispatch(l.ctx, vLog)
+		return
+	}
+	l.recordBlock(vLog)
+	l.journalLog(vLog)
+	l.events.Dispatch(l.ctx, vLog)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 监听模式
+const (
+	ListenerModeAuto      = "auto"      // WebSocket 订阅，HTTP 或订阅屡次失败时自动轮询
+	ListenerModeSubscribe = "subscribe" // 只使用 eth_subscribe
+	ListenerModePoll      = "poll"      // 只使用 eth_getLogs 轮询
+)
+
+// usePolling 是否使用轮询模式
+func (l *BlockchainListener) usePolling() bool {
+	switch l.mode {
+	case ListenerModePoll:
+		return true
+	case ListenerModeSubscribe:
+		return false
+	}
+	// auto: 没有 WebSocket 节点时无法订阅；订阅连续失败达到上限也降级为轮询
+	return !l.ethClient.SupportsSubscription() || atomic.LoadInt32(&l.subscribeFailures) >= l.maxSubscribeFailures
+}
+
+// GetMode 当前实际使用的监听模式
+func (l *BlockchainListener) GetMode() string {
+	if l.usePolling() {
+		return ListenerModePoll
+	}
+	return ListenerModeSubscribe
+}
+
+// GetPollInterval 轮询间隔
+func (l *BlockchainListener) GetPollInterval() time.Duration {
+	return l.pollInterval
+}
+
+// recordSubscribeFailure 记录一次订阅失败
+func (l *BlockchainListener) recordSubscribeFailure(err error) {
+	failures := atomic.AddInt32(&l.subscribeFailures, 1)
+	log.Printf("❌ 订阅失败(%d/%d): %v", failures, l.maxSubscribeFailures, err)
+	if l.mode == ListenerModeAuto && failures == l.maxSubscribeFailures {
+		log.Printf("⚠️ 订阅连续失败 %d 次，切换到轮询模式（间隔 %s）", failures, l.pollInterval)
+	}
+}
+
+// resetSubscribeFailures 订阅成功后清零失败次数
+func (l *BlockchainListener) resetSubscribeFailures() {
+	atomic.StoreInt32(&l.subscribeFailures, 0)
+}
+
+// pollLogs 轮询模式：每个间隔从检查点开始，用 eth_getLogs 拉取到最新区块
+// 没有 Removed 日志，链重组由确认检查（区块哈希比对）负责发现
+func (l *BlockchainListener) pollLogs(kind string, contractAddr common.Address, dispatch func(types.Log)) {
+	log.Printf("✅ %s 轮询模式启动，间隔 %s", kind, l.pollInterval)
+
+	ticker := time.NewTicker(l.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := l.backfill(kind, contractAddr, dispatch); err != nil {
+			log.Printf("❌ %s 轮询失败: %v", kind, err)
+			return
+		}
+
+		select {
+		case <-l.ctx.Done():
+			log.Printf("🛑 %s 轮询已停止", kind)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// runReconcileLoop 定期对账过期拍卖
+// endAuction 在无人出价时只把NFT退回卖家、不发 AuctionEnded，需要回读 auctions(id).ended
+func (l *BlockchainListener) runReconcileLoop() {
+	l.reconcileExpiredAuctions()
+
+	ticker := time.NewTicker(l.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			l.reconcileExpiredAuctions()
+		}
+	}
+}
+
+func (l *BlockchainListener) reconcileExpiredAuctions() {
+	count, err := l.auctionService.ReconcileExpiredAuctions(l.ctx)
+	if err != nil {
+		log.Printf("❌ 过期拍卖对账失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("✅ 过期拍卖对账完成，%d 个拍卖已结束", count)
+	}
+}
+
+// reconcileReturnedNFT 拍卖合约把NFT转回卖家时，确认对应拍卖已无人出价结束
+// 回读到的结束状态记入事件日志，回放时直接应用
+func (l *BlockchainListener) reconcileReturnedNFT(ctx context.Context, nftContract common.Address, tokenID string, seller common.Address, vLog types.Log) {
+	auction, err := l.auctionService.FindEscrowedAuction(ctx, nftContract.Hex(), tokenID, seller.Hex())
+	if err != nil {
+		log.Printf("⚠️ NFT %s 退回卖家 %s，但未找到托管中的拍卖", tokenID, seller.Hex())
+		return
+	}
+
+	if isReplay(ctx) {
+		enrichment := returnedNFTEnrichment{}
+		if !l.replayed(ctx, &enrichment) || enrichment.AuctionID != auction.AuctionID {
+			log.Printf("⚠️ 拍卖 #%d 的退回事件没有补充数据，结束状态由过期拍卖对账回读", auction.AuctionID)
+			return
+		}
+		if err := l.auctionService.MarkAuctionEnded(ctx, auction.AuctionID, enrichment.Status); err != nil {
+			log.Printf("❌ %v", err)
+		}
+		return
+	}
+
+	// 以链上状态为准（同一笔交易里不会有其他拍卖转回同一个卖家）
+	ended, err := l.auctionService.ReconcileAuction(ctx, auction.AuctionID)
+	if err != nil {
+		log.Printf("❌ 回读拍卖 #%d 失败: %v", auction.AuctionID, err)
+		return
+	}
+	if !ended {
+		return
+	}
+	log.Printf("✅ 拍卖 #%d 无人出价结束，NFT %s 已退回卖家", auction.AuctionID, tokenID)
+	if reconciled, err := l.auctionService.GetAuctionByAuctionID(ctx, auction.AuctionID); err == nil {
+		l.enrich(ctx, vLog, returnedNFTEnrichment{AuctionID: reconciled.AuctionID, Status: reconciled.Status})
+	}
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+var reconcileNFTAddr = common.HexToAddress("0x4E47")
+
+// fakeChainAuctions 链上拍卖：ended 中的拍卖已结束，bids 中的有人出价
+type fakeChainAuctions struct {
+	contract.AuctionContract
+	ended map[uint64]bool
+	bids  map[uint64]bool
+	reads []uint64
+}
+
+func (f *fakeChainAuctions) GetAuctionInfo(_ context.Context, auctionID *big.Int) (*contract.AuctionInfo, error) {
+	id := auctionID.Uint64()
+	f.reads = append(f.reads, id)
+	info := &contract.AuctionInfo{
+		AuctionID:   auctionID,
+		Seller:      common.HexToAddress(alice),
+		StartPrice:  big.NewInt(1),
+		StartTime:   big.NewInt(1700000000),
+		EndTime:     big.NewInt(1700003600),
+		Ended:       f.ended[id],
+		HighestBid:  big.NewInt(0),
+		NFTContract: reconcileNFTAddr,
+		TokenID:     new(big.Int).SetUint64(id),
+		Status:      contract.AuctionStatusExpired,
+	}
+	if f.bids[id] {
+		info.HighestBidder = common.HexToAddress(bob)
+		info.HighestBid = big.NewInt(100)
+	}
+	switch {
+	case info.Ended && !info.HasBids():
+		info.Status = contract.AuctionStatusEndedUnsold
+	case info.Ended:
+		info.Status = contract.AuctionStatusEnded
+	}
+	return info, nil
+}
+
+func (f *fakeChainAuctions) GetContractAddress() common.Address {
+	return keeperAuctionAddr
+}
+
+// seedEscrowedAuction 托管中的拍卖（数据库中尚未结束）
+func seedEscrowedAuction(t *testing.T, s *AuctionService, auctionID, endTime uint64, chainState string) {
+	t.Helper()
+	err := s.DB.Create(&model.Auction{
+		AuctionID:   auctionID,
+		NFTContract: reconcileNFTAddr.Hex(),
+		TokenID:     new(big.Int).SetUint64(auctionID).String(),
+		Seller:      alice,
+		EndTime:     endTime,
+		Status:      contract.AuctionStatusActive,
+		ChainState:  chainState,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func auctionStatus(t *testing.T, s *AuctionService, auctionID uint64) string {
+	t.Helper()
+	auction, err := s.GetAuctionByAuctionID(context.Background(), auctionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !auction.Ended {
+		return "open:" + auction.Status
+	}
+	return auction.Status
+}
+
+// 过期拍卖回读链上状态：无人出价结束的拍卖记为 ended_unsold，未到期与已孤立的拍卖不回读
+func TestReconcileExpiredAuctionsMarksEndedUnsold(t *testing.T) {
+	chain := &fakeChainAuctions{ended: map[uint64]bool{1: true, 2: true}, bids: map[uint64]bool{2: true}}
+	s := &AuctionService{DB: newTestDB(t), AuctionContract: chain}
+	past := uint64(time.Now().Add(-time.Hour).Unix())
+	future := uint64(time.Now().Add(time.Hour).Unix())
+
+	seedEscrowedAuction(t, s, 1, past, model.ChainStateConfirmed) // 无人出价，已结束
+	seedEscrowedAuction(t, s, 2, past, model.ChainStateConfirmed) // 有人出价，已结束
+	seedEscrowedAuction(t, s, 3, past, model.ChainStateConfirmed) // 已过期，尚未调用 endAuction
+	seedEscrowedAuction(t, s, 4, future, model.ChainStateConfirmed)
+	seedEscrowedAuction(t, s, 5, past, model.ChainStateOrphaned)
+
+	count, err := s.ReconcileExpiredAuctions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("reconciled %d auctions, want 2", count)
+	}
+	if !reflect.DeepEqual(chain.reads, []uint64{1, 2, 3}) {
+		t.Fatalf("read auctions %v from chain, want [1 2 3]", chain.reads)
+	}
+	want := []string{
+		contract.AuctionStatusEndedUnsold,
+		contract.AuctionStatusEnded,
+		"open:" + contract.AuctionStatusActive,
+		"open:" + contract.AuctionStatusActive,
+	}
+	for i, status := range want {
+		if got := auctionStatus(t, s, uint64(i+1)); got != status {
+			t.Errorf("auction %d status = %s, want %s", i+1, got, status)
+		}
+	}
+}
+
+// NFT 从拍卖合约退回卖家：实时处理回读链上状态并记入事件日志，回放时直接使用日志中的状态
+func TestReconcileReturnedNFTEndsUnsoldAuction(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	chain := &fakeChainAuctions{ended: map[uint64]bool{7: true}}
+	s := &AuctionService{DB: db, AuctionContract: chain}
+	journal := NewEventJournalService(db, 11155111)
+	l := &BlockchainListener{ctx: ctx, auctionService: s, journal: journal}
+
+	seedEscrowedAuction(t, s, 6, 0, model.ChainStateConfirmed) // 同一卖家的另一件NFT
+	seedEscrowedAuction(t, s, 7, 0, model.ChainStateConfirmed)
+	vLog := types.Log{BlockHash: blockHash(120), TxHash: common.HexToHash("0x77"), Index: 2, BlockNumber: 120}
+	raw := model.RawEvent{
+		ChainID:     journal.ChainID(),
+		BlockHash:   vLog.BlockHash.Hex(),
+		TxHash:      vLog.TxHash.Hex(),
+		LogIndex:    vLog.Index,
+		BlockNumber: vLog.BlockNumber,
+	}
+	if err := db.Create(&raw).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	l.reconcileReturnedNFT(ctx, reconcileNFTAddr, "7", common.HexToAddress(alice), vLog)
+	if got := auctionStatus(t, s, 7); got != contract.AuctionStatusEndedUnsold {
+		t.Fatalf("auction 7 status = %s, want ended_unsold", got)
+	}
+	if got := auctionStatus(t, s, 6); got != "open:"+contract.AuctionStatusActive {
+		t.Fatalf("auction 6 status = %s, want untouched", got)
+	}
+	if err := db.First(&raw, raw.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if raw.Enrichment != `{"auction_id":7,"status":"ended_unsold"}` {
+		t.Fatalf("enrichment = %s", raw.Enrichment)
+	}
+
+	// 回放：重置结束状态，不查询链上
+	if err := db.Model(&model.Auction{}).Where("auction_id = ?", 7).
+		Updates(map[string]interface{}{"ended": false, "status": contract.AuctionStatusActive}).Error; err != nil {
+		t.Fatal(err)
+	}
+	chain.reads = nil
+	l.reconcileReturnedNFT(withReplayEvent(ctx, &raw), reconcileNFTAddr, "7", common.HexToAddress(alice), vLog)
+	if got := auctionStatus(t, s, 7); got != contract.AuctionStatusEndedUnsold {
+		t.Fatalf("replayed auction 7 status = %s, want ended_unsold", got)
+	}
+	if len(chain.reads) != 0 {
+		t.Fatalf("replay read auctions %v from chain", chain.reads)
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"nft-auction-backend/internal/contract"
+)
+
+// 确认检查间隔
+const confirmationCheckInterval = 15 * time.Second
+
+// ---------------- 日志入口（正常日志 / 被重组移除的日志） ----------------
+
+// recordBlock 记录日志所在区块的哈希，供确认检查时比对
+func (l *BlockchainListener) recordBlock(vLog types.Log) {
+	if err := l.reorgService.RecordBlock(l.ctx, vLog.BlockNumber, vLog.BlockHash.Hex()); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// recordCheckpoint 检查点推进到 number 时记录该区块的哈希
+// 没有日志的空区块被重组替换时也能在确认检查中发现
+func (l *BlockchainListener) recordCheckpoint(number uint64) {
+	header, err := l.ethClient.HeaderByNumber(l.ctx, new(big.Int).SetUint64(number))
+	if err != nil || header == nil {
+		log.Printf("⚠️ 获取检查点区块 %d 失败: %v", number, err)
+		return
+	}
+	l.headerCache.Add(header.Hash(), header)
+	if err := l.reorgService.RecordBlock(l.ctx, number, header.Hash().Hex()); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// journalLog 先写原始事件日志，再应用到业务表
+func (l *BlockchainListener) journalLog(vLog types.Log) {
+	if err := l.journal.Record(l.ctx, vLog, l.blockTime(l.ctx, vLog), l.events.ABI(vLog.Address)); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// journalRemoved 标记事件日志被重组移除
+func (l *BlockchainListener) journalRemoved(vLog types.Log) {
+	if err := l.journal.MarkRemoved(l.ctx, vLog); err != nil {
+		log.Printf("❌ 标记事件日志失败: %v", err)
+	}
+}
+
+// ---------------- 回滚被重组移除的日志 ----------------
+
+// rollbackNFTMinted 铸造被移除：从链上刷新所有者
+func (l *BlockchainListener) rollbackNFTMinted(ctx context.Context, event *contract.KevinNFTNFTMinted, vLog types.Log) {
+	l.refreshNFT(ctx, vLog.Address, event.TokenId)
+}
+
+// rollbackTransfer 转移被移除：转移记录标记 orphaned，从链上刷新所有者
+func (l *BlockchainListener) rollbackTransfer(ctx context.Context, event *contract.KevinNFTTransfer, vLog types.Log) {
+	l.orphanTransfer(ctx, vLog)
+	l.refreshNFT(ctx, vLog.Address, event.TokenId)
+}
+
+func (l *BlockchainListener) orphanTransfer(ctx context.Context, vLog types.Log) {
+	if err := l.nftService.OrphanTransfer(ctx, vLog.TxHash.Hex(), vLog.Index); err != nil {
+		log.Printf("❌ 回滚转移记录失败: %v", err)
+	}
+}
+
+// rollbackApproval 授权被移除：直接清除授权记录
+func (l *BlockchainListener) rollbackApproval(ctx context.Context, event *contract.KevinNFTApproval, vLog types.Log) {
+	if err := l.nftService.ClearApproval(ctx, vLog.TxHash.Hex()); err != nil {
+		log.Printf("❌ 回滚授权记录失败: %v", err)
+	}
+}
+
+func (l *BlockchainListener) refreshNFT(ctx context.Context, contractAddr common.Address, tokenID *big.Int) {
+	if err := l.nftService.RefreshNFTFromChain(ctx, contractAddr, tokenID.String()); err != nil {
+		log.Printf("❌ 回滚 NFT #%s 失败: %v", tokenID.String(), err)
+	}
+}
+
+// rollbackAuctionCreated 创建被移除：链上已不存在则标记 orphaned，否则从链上刷新
+func (l *BlockchainListener) rollbackAuctionCreated(ctx context.Context, event *contract.NftAuctionAuctionCreated, vLog types.Log) {
+	if err := l.auctionService.RefreshAuctionFromChain(ctx, event.AuctionId.Uint64()); err != nil {
+		log.Printf("❌ 回滚拍卖 #%d 失败: %v", event.AuctionId.Uint64(), err)
+	}
+}
+
+// rollbackNewBid 出价被移除：出价标记 orphaned，按剩余出价重新计算最高价
+func (l *BlockchainListener) rollbackNewBid(ctx context.Context, event *contract.NftAuctionNewBid, vLog types.Log) {
+	if err := l.auctionService.OrphanBid(ctx, vLog.TxHash.Hex(), vLog.Index); err != nil {
+		log.Printf("❌ %v", err)
+		return
+	}
+	if err := l.auctionService.RecalculateHighestBid(ctx, event.AuctionId.Uint64()); err != nil {
+		log.Printf("❌ 重新计算拍卖 #%d 最高出价失败: %v", event.AuctionId.Uint64(), err)
+	}
+}
+
+// rollbackAuctionEnded 结束被移除：成交流水标记 orphaned，从链上刷新拍卖状态
+func (l *BlockchainListener) rollbackAuctionEnded(ctx context.Context, event *contract.NftAuctionAuctionEnded, vLog types.Log) {
+	if err := l.auctionService.OrphanSellerPayout(ctx, event.AuctionId.Uint64()); err != nil {
+		log.Printf("❌ %v", err)
+	}
+	if err := l.auctionService.UpdateAuctionFromChain(ctx, event.AuctionId.Uint64()); err != nil {
+		log.Printf("❌ 回滚拍卖 #%d 结束状态失败: %v", event.AuctionId.Uint64(), err)
+	}
+}
+
+// ---------------- 确认深度与重组检测 ----------------
+
+// runConfirmationLoop 定期比对已处理区块的哈希，并晋升达到确认深度的实体
+func (l *BlockchainListener) runConfirmationLoop() {
+	ticker := time.NewTicker(confirmationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			l.checkConfirmations()
+		}
+	}
+}
+
+// checkConfirmations 检测重组（已处理区块的哈希不再是主链哈希）并更新确认状态
+func (l *BlockchainListener) checkConfirmations() {
+	client := l.ethClient
+
+	head, err := client.BlockNumber(l.ctx)
+	if err != nil {
+		log.Printf("❌ 确认检查获取最新区块失败: %v", err)
+		return
+	}
+
+	blocks, err := l.reorgService.UnconfirmedBlocks(l.ctx, head)
+	if err != nil {
+		log.Printf("❌ 查询未确认区块失败: %v", err)
+		return
+	}
+
+	for _, block := range blocks {
+		hash, err := l.canonicalHash(block.Number)
+		if err != nil {
+			log.Printf("❌ 获取区块 %d 失败: %v", block.Number, err)
+			return
+		}
+		if hash != block.Hash {
+			fork, err := l.reorgService.ForkHeight(l.ctx, block.Number, l.canonicalHash)
+			if err != nil {
+				log.Printf("❌ 查找分叉高度失败: %v", err)
+				return
+			}
+			l.handleReorg(fork, head)
+			break
+		}
+	}
+
+	if err := l.reorgService.PromoteConfirmed(l.ctx, head); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// canonicalHash 主链上该高度的区块哈希（区块头加入缓存）
+func (l *BlockchainListener) canonicalHash(number uint64) (string, error) {
+	header, err := l.ethClient.HeaderByNumber(l.ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return "", err
+	}
+	l.headerCache.Add(header.Hash(), header)
+	return header.Hash().Hex(), nil
+}
+
+// handleReorg 从分叉高度开始回滚并重新拉取日志
+// 持有日志处理锁：实时监听与回填在回滚、重新拉取完成前不会写入检查点和业务表
+func (l *BlockchainListener) handleReorg(height, head uint64) {
+	log.Printf("⚠️ 检测到链重组，分叉高度: %d，当前区块: %d", height, head)
+	if height == 0 {
+		height = 1 // 创世区块不会被重组
+	}
+
+	l.processMu.Lock()
+	defer l.processMu.Unlock()
+
+	// 1. 分叉高度之后的实体先全部标记为 orphaned
+	affected, err := l.reorgService.OrphanFrom(l.ctx, height)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return
+	}
+
+	// 2. 回退检查点并重新拉取新主链上的日志（重新包含的实体会回到 pending）
+	for _, c := range l.indexedContracts() {
+		if err := l.eventSync.RewindLastBlock(l.ctx, c.kind, c.addr, height-1); err != nil {
+			log.Printf("❌ %v", err)
+			return
+		}
+		if err := l.filterRangeLocked(c.kind, c.addr, height, head, l.applyLog); err != nil {
+			log.Printf("❌ 重组后重新拉取 %s 日志失败: %v", c.kind, err)
+			return
+		}
+	}
+
+	// 3. 受影响的拍卖按剩余出价重算最高价，受影响的NFT从链上刷新
+	for _, auctionID := range affected.AuctionIDs {
+		if err := l.auctionService.RecalculateHighestBid(l.ctx, auctionID); err != nil {
+			log.Printf("❌ 重新计算拍卖 #%d 最高出价失败: %v", auctionID, err)
+		}
+	}
+	// 结束事件被重组的拍卖：新链上可能还没有结束，以链上状态为准
+	for _, auctionID := range affected.EndedAuctionIDs {
+		if err := l.auctionService.UpdateAuctionFromChain(l.ctx, auctionID); err != nil {
+			log.Printf("❌ 刷新拍卖 #%d 结束状态失败: %v", auctionID, err)
+		}
+	}
+	for _, nft := range affected.NFTs {
+		if err := l.nftService.RefreshNFTFromChain(l.ctx, common.HexToAddress(nft.ContractAddress), nft.TokenID); err != nil {
+			log.Printf("❌ 刷新 NFT %s/%s 失败: %v", nft.ContractAddress, nft.TokenID, err)
+		}
+	}
+
+	// 合约状态与新主链上不再存在的全量授权从链上刷新
+	if _, err := l.collectionService.SyncCollection(l.ctx); err != nil {
+		log.Printf("❌ 刷新合约状态失败: %v", err)
+	}
+	if err := l.collectionService.RefreshOrphanedApprovals(l.ctx); err != nil {
+		log.Printf("❌ 刷新全量授权失败: %v", err)
+	}
+
+	log.Printf("✅ 链重组处理完成: 拍卖 %d 个, NFT %d 个", len(affected.AuctionIDs), len(affected.NFTs))
+}
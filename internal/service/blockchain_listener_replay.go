@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+// ErrReplayInProgress 已有回放在进行
+var ErrReplayInProgress = errors.New("事件日志回放进行中")
+
+// Replay 从原始事件日志重新应用事件，重建由事件派生的业务表
+// 完全离线：不调用 eth_getLogs，也不查询链上状态；处理时从链上补充的数据（拍卖时长、tokenURI 等）
+// 在首次处理日志时已写入事件日志的 enrichment，回放时读回，结果只取决于事件日志
+// 回放期间监听器停止（实时处理会写入正在重建的表），结束后恢复；回放状态随 Dispatch 的 ctx 传给处理函数
+// reset=true 时先清空业务表（只允许从0开始的全量回放）；to 为0表示回放到日志末尾
+func (l *BlockchainListener) Replay(ctx context.Context, from, to uint64, reset bool) (int, error) {
+	if reset && from != 0 {
+		return 0, fmt.Errorf("清空重建只能从区块0开始回放")
+	}
+
+	l.lifecycle.Lock()
+	if l.replaying {
+		l.lifecycle.Unlock()
+		return 0, ErrReplayInProgress
+	}
+	wasRunning := l.running
+	_, parent := l.contexts()
+	if wasRunning {
+		log.Println("⏸️ 回放前停止区块链监听器")
+		l.stopLocked()
+	}
+	l.replaying = true
+	l.lifecycle.Unlock()
+
+	defer func() {
+		l.lifecycle.Lock()
+		l.replaying = false
+		l.lifecycle.Unlock()
+		if wasRunning {
+			log.Println("▶️ 回放结束，恢复区块链监听器")
+			l.Start(parent)
+		}
+	}()
+
+	if reset {
+		if err := l.resetProjections(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	log.Printf("🔁 开始回放事件日志: 区块 %d - %d (清空重建: %v)", from, to, reset)
+	count, err := l.journal.Iterate(ctx, from, to, func(vLog types.Log, event *model.RawEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.events.Dispatch(withReplayEvent(ctx, event), vLog)
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("回放中断（已处理 %d 条）: %v", count, err)
+	}
+
+	log.Printf("✅ 事件日志回放完成，共 %d 条", count)
+	return count, nil
+}
+
+type replayEventKey struct{}
+
+// withReplayEvent 回放时把当前事件日志放入 ctx，处理函数据此只使用日志中的数据
+func withReplayEvent(ctx context.Context, event *model.RawEvent) context.Context {
+	return context.WithValue(ctx, replayEventKey{}, event)
+}
+
+// replayEventFrom 回放中的当前事件日志，实时处理时为 nil
+func replayEventFrom(ctx context.Context) *model.RawEvent {
+	event, _ := ctx.Value(replayEventKey{}).(*model.RawEvent)
+	return event
+}
+
+// isReplay 是否在回放事件日志（处理函数不查询链上，补充数据从事件日志读回）
+func isReplay(ctx context.Context) bool {
+	return replayEventFrom(ctx) != nil
+}
+
+// enrich 记录处理日志时从链上补充的数据，回放时由 replayed 读回（回放中不重复写入）
+func (l *BlockchainListener) enrich(ctx context.Context, vLog types.Log, v interface{}) {
+	if isReplay(ctx) {
+		return
+	}
+	if err := l.journal.Enrich(ctx, vLog, v); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// replayed 回放中读取当前日志的补充数据；没有补充数据（旧版本写入的日志）时返回 false
+func (l *BlockchainListener) replayed(ctx context.Context, v interface{}) bool {
+	event := replayEventFrom(ctx)
+	if event == nil || event.Enrichment == "" {
+		return false
+	}
+	if err := json.Unmarshal([]byte(event.Enrichment), v); err != nil {
+		log.Printf("⚠️ 解析事件补充数据失败 (区块 %d 交易 %s): %v", event.BlockNumber, event.TxHash, err)
+		return false
+	}
+	return true
+}
+
+// auctionCreatedEnrichment AuctionCreated 事件不包含、从 auctions(id) 补充的字段
+type auctionCreatedEnrichment struct {
+	NFTContract  string `json:"nft_contract"`
+	Duration     uint64 `json:"duration"`
+	UseERC20     bool   `json:"use_erc20,omitempty"`
+	PaymentToken string `json:"payment_token,omitempty"`
+}
+
+// returnedNFTEnrichment 拍卖合约退回NFT时回读到的拍卖结束状态
+type returnedNFTEnrichment struct {
+	AuctionID uint64 `json:"auction_id"`
+	Status    string `json:"status"`
+}
+
+// tokenURIEnrichment 处理时读取的 tokenURI（TokenID -> URI）
+type tokenURIEnrichment struct {
+	URIs map[string]string `json:"token_uris"`
+}
+
+// resetProjections 清空由事件派生的业务表（collections 是合集注册表，保留）
+func (l *BlockchainListener) resetProjections(ctx context.Context) error {
+	return l.auctionService.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{
+			&model.BidHistory{}, &model.Bid{}, &model.BidRefund{}, &model.Auction{}, &model.NFTInfo{},
+			&model.Withdrawal{}, &model.OperatorApproval{}, &model.Transfer{},
+		} {
+			if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(m).Error; err != nil {
+				return fmt.Errorf("清空业务表失败: %v", err)
+			}
+		}
+		// 管理员提取不来自事件日志，回放无法重建，保留
+		if err := tx.Where("kind <> ?", model.LedgerAdminWithdraw).Delete(&model.LedgerEntry{}).Error; err != nil {
+			return fmt.Errorf("清空资金流水失败: %v", err)
+		}
+		log.Println("🧹 已清空 auctions / nft_infos / bid_histories / bids / bid_refunds / withdrawals / operator_approvals / transfers / ledger_entries")
+		return nil
+	})
+}
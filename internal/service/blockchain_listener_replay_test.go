@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+func TestJournalEnrichRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	journal := NewEventJournalService(db, 1)
+
+	vLog := types.Log{
+		Address:     common.HexToAddress("0x1"),
+		Topics:      []common.Hash{common.HexToHash("0xabc")},
+		BlockNumber: 10,
+		BlockHash:   common.HexToHash("0xb10"),
+		TxHash:      common.HexToHash("0x7a"),
+		Index:       3,
+	}
+	if err := journal.Record(ctx, vLog, 1700000000, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Enrich(ctx, vLog, auctionCreatedEnrichment{NFTContract: "0xNFT", Duration: 3600}); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &BlockchainListener{ctx: ctx, journal: journal}
+	var got auctionCreatedEnrichment
+	if _, err := journal.Iterate(ctx, 0, 0, func(_ types.Log, event *model.RawEvent) error {
+		if !l.replayed(withReplayEvent(ctx, event), &got) {
+			t.Fatal("enrichment not found")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got.NFTContract != "0xNFT" || got.Duration != 3600 {
+		t.Fatalf("enrichment = %+v", got)
+	}
+}
+
+// 离线回放的链ID：事件日志只有一条链时使用它，为空或有多条链时报错
+func TestStoredChainID(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	if _, err := StoredChainID(ctx, db); err == nil {
+		t.Fatal("empty journal should not yield a chain id")
+	}
+
+	vLog := types.Log{BlockNumber: 1, BlockHash: common.HexToHash("0xb1"), TxHash: common.HexToHash("0x71")}
+	if err := NewEventJournalService(db, 11155111).Record(ctx, vLog, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := StoredChainID(ctx, db); err != nil || id != 11155111 {
+		t.Fatalf("chain id = %d, %v", id, err)
+	}
+
+	if err := NewEventJournalService(db, 1).Record(ctx, vLog, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StoredChainID(ctx, db); err == nil {
+		t.Fatal("journal with two chains should require blockchain.chain_id")
+	}
+}
+
+// 回放中 AuctionCreated 只使用事件日志：AuctionContract 为 nil，任何链上查询都会 panic
+func TestReplayAuctionCreatedIsOffline(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	l := &BlockchainListener{
+		ctx:            ctx,
+		auctionService: &AuctionService{DB: db},
+		nftService:     &NFTService{DB: db},
+		journal:        NewEventJournalService(db, 1),
+	}
+	replayCtx := withReplayEvent(ctx, &model.RawEvent{
+		BlockNumber: 100,
+		BlockTime:   1700000000,
+		Enrichment:  `{"nft_contract":"0x00000000000000000000000000000000000000aa","duration":86400,"use_erc20":true,"payment_token":"0x00000000000000000000000000000000000000bb"}`,
+	})
+	event := &contract.NftAuctionAuctionCreated{
+		AuctionId:  big.NewInt(7),
+		Seller:     common.HexToAddress("0x5e11e4"),
+		TokenId:    big.NewInt(42),
+		StartPrice: big.NewInt(1000),
+	}
+	l.handleAuctionCreated(replayCtx, event, types.Log{BlockNumber: 100, BlockHash: common.HexToHash("0xb100"), TxHash: common.HexToHash("0x7b")})
+
+	var auction model.Auction
+	if err := db.Where("auction_id = ?", 7).First(&auction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if auction.StartTime != 1700000000 || auction.EndTime != 1700000000+86400 {
+		t.Errorf("start/end = %d/%d", auction.StartTime, auction.EndTime)
+	}
+	if auction.NFTContract != "0x00000000000000000000000000000000000000aa" || !auction.UseERC20 ||
+		auction.PaymentToken != "0x00000000000000000000000000000000000000bb" {
+		t.Errorf("auction = %+v", auction)
+	}
+}
+
+// 没有补充数据的旧日志：拍卖照常入库，时长留给对账回读
+func TestReplayAuctionCreatedWithoutEnrichment(t *testing.T) {
+	db := newTestDB(t)
+	l := &BlockchainListener{
+		ctx:            context.Background(),
+		auctionService: &AuctionService{DB: db},
+		nftService:     &NFTService{DB: db},
+	}
+	replayCtx := withReplayEvent(context.Background(), &model.RawEvent{BlockTime: 1700000000})
+	event := &contract.NftAuctionAuctionCreated{
+		AuctionId: big.NewInt(8), Seller: common.HexToAddress("0x1"), TokenId: big.NewInt(1), StartPrice: big.NewInt(1),
+	}
+	l.handleAuctionCreated(replayCtx, event, types.Log{BlockHash: common.HexToHash("0xb1")})
+
+	var auction model.Auction
+	if err := db.Where("auction_id = ?", 8).First(&auction).Error; err != nil {
+		t.Fatal(err)
+	}
+	if auction.EndTime != 0 || auction.NFTContract != "" {
+		t.Errorf("auction = %+v", auction)
+	}
+}
+
+// 回放状态只随 Dispatch 的 ctx 传递：同时进行的实时处理看不到正在回放的日志
+func TestReplayStateIsPerDispatch(t *testing.T) {
+	ctx := context.Background()
+	event := &model.RawEvent{BlockTime: 1700000000, Enrichment: `{"duration":60}`}
+	replayCtx := withReplayEvent(ctx, event)
+
+	if !isReplay(replayCtx) || replayEventFrom(replayCtx) != event {
+		t.Fatal("replay ctx does not carry the journal row")
+	}
+	if isReplay(ctx) {
+		t.Fatal("live ctx reports replay")
+	}
+
+	l := &BlockchainListener{}
+	var enrichment auctionCreatedEnrichment
+	if l.replayed(ctx, &enrichment) {
+		t.Fatal("live handler read replay enrichment")
+	}
+	if !l.replayed(replayCtx, &enrichment) || enrichment.Duration != 60 {
+		t.Fatalf("replay enrichment = %+v", enrichment)
+	}
+}
+
+// 回放期间不能再次回放，也不会启动监听器
+func TestReplayExcludesListener(t *testing.T) {
+	l := &BlockchainListener{replaying: true}
+
+	if _, err := l.Replay(context.Background(), 0, 0, false); !errors.Is(err, ErrReplayInProgress) {
+		t.Fatalf("concurrent replay err = %v", err)
+	}
+	l.Start(context.Background())
+	if l.running {
+		t.Fatal("listener started during replay")
+	}
+	if !l.IsReplaying() {
+		t.Fatal("replay flag cleared by rejected calls")
+	}
+}
+
+// 回放结束后清除回放标记；监听器回放前未运行时不会被启动
+func TestReplayWithoutRunningListener(t *testing.T) {
+	db := newTestDB(t)
+	l := &BlockchainListener{
+		journal: NewEventJournalService(db, 1),
+		events:  NewEventRegistry(),
+	}
+	if _, err := l.Replay(context.Background(), 0, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if l.IsReplaying() || l.running {
+		t.Fatalf("replaying=%v running=%v after replay", l.IsReplaying(), l.running)
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// headerCacheSize 区块头缓存容量（同一区块的多个事件只查询一次）
+const headerCacheSize = 1024
+
+// blockTime 事件所在区块的时间戳
+// 按区块哈希缓存，重组后的新区块哈希不同，不会读到旧时间
+func (l *BlockchainListener) blockTime(ctx context.Context, vLog types.Log) uint64 {
+	if event := replayEventFrom(ctx); event != nil {
+		// 回放使用日志中记录的区块时间，不请求区块头
+		return event.BlockTime
+	}
+	if header, ok := l.headerCache.Get(vLog.BlockHash); ok {
+		return header.Time
+	}
+
+	header, err := l.ethClient.HeaderByHash(ctx, vLog.BlockHash)
+	if err != nil || header == nil {
+		// 区块已被重组移除或节点暂不可用：退回本地时间，确认循环会处理重组
+		log.Printf("⚠️ 获取区块 #%d (%s) 时间失败，使用本地时间: %v",
+			vLog.BlockNumber, vLog.BlockHash.Hex(), err)
+		return uint64(time.Now().Unix())
+	}
+
+	l.headerCache.Add(vLog.BlockHash, header)
+	return header.Time
+}
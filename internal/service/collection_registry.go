@@ -0,0 +1,213 @@
+// collection_registry.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+// ErrCollectionNotRegistered 合约不在合集注册表中（或未通过 ERC-165 检查）
+var ErrCollectionNotRegistered = errors.New("合约未注册")
+
+// ==================== 合集注册表 ====================
+//
+//	拍卖创建（_nftAddress） / 管理员注册
+//	    → ERC-165 supportsInterface(0x80ac58cd)
+//	        ├─ 支持   → 保存合集，创建通用 ERC-721 客户端，从发现区块开始索引 Transfer
+//	        └─ 不支持 → 保存为 supported=false，不索引
+
+// LoadRegistry 启动时为已注册的第三方合集创建客户端
+func (s *CollectionService) LoadRegistry(ctx context.Context) error {
+	var collections []model.Collection
+	if err := s.DB.WithContext(ctx).
+		Where("supported = ? AND contract_address <> ?", true, s.GetContractAddress().Hex()).
+		Find(&collections).Error; err != nil {
+		return fmt.Errorf("加载合集注册表失败: %v", err)
+	}
+
+	for _, collection := range collections {
+		addr := common.HexToAddress(collection.ContractAddress)
+		reader, err := contract.NewERC721Client(s.backend, addr)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.readers[addr] = reader
+		s.mu.Unlock()
+	}
+	if len(collections) > 0 {
+		log.Printf("✅ 已加载 %d 个第三方合集", len(collections))
+	}
+	return nil
+}
+
+// OnRegister 添加新合集注册后的回调
+func (s *CollectionService) OnRegister(fn func(model.Collection)) {
+	s.registerMu.Lock()
+	defer s.registerMu.Unlock()
+	s.onRegister = append(s.onRegister, fn)
+}
+
+// Register 注册合集：已知的合约直接返回，新合约先做 ERC-165 检查
+// fromBlock 为开始索引的区块，0 表示从当前最新区块开始
+func (s *CollectionService) Register(ctx context.Context, contractAddr common.Address, source string, fromBlock uint64) (*model.Collection, error) {
+	if contractAddr == s.GetContractAddress() {
+		return s.GetCollection(ctx)
+	}
+	if contractAddr == (common.Address{}) {
+		return nil, fmt.Errorf("无效的合约地址")
+	}
+
+	s.registerMu.Lock()
+	defer s.registerMu.Unlock()
+
+	var existing model.Collection
+	err := s.DB.WithContext(ctx).Where("contract_address = ?", contractAddr.Hex()).First(&existing).Error
+	if err == nil {
+		if !existing.Supported {
+			return &existing, fmt.Errorf("合约 %s 不支持 ERC-721", contractAddr.Hex())
+		}
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询合集失败: %v", err)
+	}
+
+	client, err := contract.NewERC721Client(s.backend, contractAddr)
+	if err != nil {
+		return nil, err
+	}
+	supported, err := client.SupportsERC721(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ERC-165 检查失败: %v", err)
+	}
+
+	if fromBlock == 0 {
+		header, err := s.backend.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("获取最新区块失败: %v", err)
+		}
+		fromBlock = header.Number.Uint64()
+	}
+
+	collection := &model.Collection{
+		ContractAddress: contractAddr.Hex(),
+		Source:          source,
+		Supported:       supported,
+		DiscoveredBlock: fromBlock,
+		LastSyncTime:    time.Now(),
+	}
+	if supported {
+		// IERC721Metadata 是可选扩展，读取失败时留空
+		collection.Name, _ = client.GetName(ctx)
+		collection.Symbol, _ = client.GetSymbol(ctx)
+	}
+	if err := s.DB.WithContext(ctx).Create(collection).Error; err != nil {
+		return nil, fmt.Errorf("保存合集失败: %v", err)
+	}
+
+	if !supported {
+		log.Printf("⚠️ 合约 %s 未声明支持 ERC-721，不建立索引", contractAddr.Hex())
+		return collection, fmt.Errorf("合约 %s 不支持 ERC-721", contractAddr.Hex())
+	}
+
+	s.mu.Lock()
+	s.readers[contractAddr] = client
+	s.mu.Unlock()
+	log.Printf("✅ 新合集已注册: %s (%s) 来源=%s 起始区块=%d",
+		collection.Name, contractAddr.Hex(), source, fromBlock)
+
+	for _, fn := range s.onRegister {
+		fn(*collection)
+	}
+	return collection, nil
+}
+
+// Reader 获取合约的只读客户端（主合约或已注册的第三方合集）
+func (s *CollectionService) Reader(contractAddr common.Address) (contract.ERC721Reader, bool) {
+	if contractAddr == s.GetContractAddress() {
+		return s.client, true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reader, ok := s.readers[contractAddr]
+	return reader, ok
+}
+
+// IndexedAddresses 已注册的第三方合集地址（不含主合约）
+func (s *CollectionService) IndexedAddresses() []common.Address {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	addrs := make([]common.Address, 0, len(s.readers))
+	for addr := range s.readers {
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+// GetCollectionByAddress 按地址获取已注册的合集
+func (s *CollectionService) GetCollectionByAddress(ctx context.Context, contractAddr common.Address) (*model.Collection, error) {
+	if contractAddr == s.GetContractAddress() {
+		return s.GetCollection(ctx)
+	}
+
+	var collection model.Collection
+	err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND supported = ?", contractAddr.Hex(), true).
+		First(&collection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCollectionNotRegistered
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询合集失败: %v", err)
+	}
+	return &collection, nil
+}
+
+// ListCollections 列出注册表中的合集（包括未通过 ERC-165 检查的）
+func (s *CollectionService) ListCollections(ctx context.Context) ([]model.Collection, error) {
+	var collections []model.Collection
+	err := s.DB.WithContext(ctx).Order("id ASC").Find(&collections).Error
+	return collections, err
+}
+
+// DiscoverFromAuctions 从已入库的拍卖中发现尚未注册的NFT合约（从该合约最早的拍卖区块开始索引）
+// 返回新注册的合集数量
+func (s *CollectionService) DiscoverFromAuctions(ctx context.Context) (int, error) {
+	var found []struct {
+		NFTContract string
+		FirstBlock  uint64
+	}
+	err := s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Select("nft_contract, MIN(block_number) AS first_block").
+		Where("nft_contract <> '' AND nft_contract NOT IN (?)",
+			s.DB.Model(&model.Collection{}).Select("contract_address")).
+		Group("nft_contract").
+		Scan(&found).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询拍卖中的NFT合约失败: %v", err)
+	}
+
+	registered := 0
+	for _, f := range found {
+		if !common.IsHexAddress(f.NFTContract) || common.HexToAddress(f.NFTContract) == s.GetContractAddress() {
+			continue
+		}
+		// 全量同步写入的拍卖没有区块号（FirstBlock=0），从最新区块开始索引
+		if _, err := s.Register(ctx, common.HexToAddress(f.NFTContract), model.CollectionSourceAuction, f.FirstBlock); err != nil {
+			log.Printf("⚠️ 注册合集 %s 失败: %v", f.NFTContract, err)
+			continue
+		}
+		registered++
+	}
+	return registered, nil
+}
@@ -0,0 +1,325 @@
+// collection_service.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+// CollectionService NFT合约级状态服务与合集注册表
+// 铸造价格、铸造开关、合约所有者由事件维护，页面不再需要直接调用RPC
+// 第三方合集从拍卖中发现或由管理员注册，每个合集一个通用 ERC-721 客户端
+type CollectionService struct {
+	DB      *gorm.DB
+	client  contract.NFTContract
+	backend contract.Backend // 创建第三方合集客户端使用（共享RPC连接池）
+
+	mu         sync.RWMutex
+	readers    map[common.Address]contract.ERC721Reader // 已注册的第三方合集
+	registerMu sync.Mutex                               // 同一合集只注册一次
+	onRegister []func(model.Collection)                 // 新合集注册后的回调（监听器开始索引）
+}
+
+// NewCollectionService 创建合约状态服务
+func NewCollectionService(db *gorm.DB, client contract.NFTContract, backend contract.Backend) *CollectionService {
+	return &CollectionService{
+		DB:      db,
+		client:  client,
+		backend: backend,
+		readers: make(map[common.Address]contract.ERC721Reader),
+	}
+}
+
+// GetContractAddress 获取NFT合约地址
+func (s *CollectionService) GetContractAddress() common.Address {
+	return s.client.GetContractAddress()
+}
+
+// GetCollection 获取合约状态；数据库中还没有时从链上同步一次
+func (s *CollectionService) GetCollection(ctx context.Context) (*model.Collection, error) {
+	var collection model.Collection
+	err := s.DB.WithContext(ctx).
+		Where("contract_address = ?", s.GetContractAddress().Hex()).
+		First(&collection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.SyncCollection(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询合约状态失败: %v", err)
+	}
+	return &collection, nil
+}
+
+// SyncCollection 从链上读取合约状态并保存
+func (s *CollectionService) SyncCollection(ctx context.Context) (*model.Collection, error) {
+	name, err := s.client.GetName(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取合约名称失败: %v", err)
+	}
+	symbol, err := s.client.GetSymbol(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取合约符号失败: %v", err)
+	}
+	owner, err := s.client.GetContractOwner(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取合约所有者失败: %v", err)
+	}
+	stats, err := s.client.GetStats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取合约统计失败: %v", err)
+	}
+	maxSupply, err := s.client.GetMaxSupply(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取最大供应量失败: %v", err)
+	}
+
+	collection := &model.Collection{
+		ContractAddress: s.GetContractAddress().Hex(),
+		Name:            name,
+		Symbol:          symbol,
+		Source:          model.CollectionSourceConfig,
+		Supported:       true,
+		Owner:           owner.Hex(),
+		MintPrice:       stats.MintPrice.String(),
+		MintingEnabled:  stats.MintingEnabled,
+		MaxSupply:       maxSupply.Uint64(),
+		TotalSupply:     stats.TotalSupply.Uint64(),
+		RemainingSupply: stats.Remaining.Uint64(),
+		NextTokenID:     stats.NextTokenID.Uint64(),
+		LastSyncTime:    time.Now(),
+	}
+	err = s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "contract_address"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"name", "symbol", "source", "supported", "owner", "mint_price", "minting_enabled",
+			"max_supply", "total_supply", "remaining_supply", "next_token_id",
+			"last_sync_time", "updated_at",
+		}),
+	}).Create(collection).Error
+	if err != nil {
+		return nil, fmt.Errorf("保存合约状态失败: %v", err)
+	}
+
+	log.Printf("✅ 合约状态已同步: 供应量=%d/%d 价格=%s 开放铸造=%v 所有者=%s",
+		collection.TotalSupply, collection.MaxSupply, collection.MintPrice, collection.MintingEnabled, collection.Owner)
+	return s.GetCollection(ctx)
+}
+
+// UpdateState 应用合约状态事件（字段名 -> 新值）
+func (s *CollectionService) UpdateState(ctx context.Context, blockNumber uint64, updates map[string]interface{}) error {
+	collection, err := s.GetCollection(ctx)
+	if err != nil {
+		return err
+	}
+
+	updates["block_number"] = blockNumber
+	if err := s.DB.WithContext(ctx).Model(collection).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新合约状态失败: %v", err)
+	}
+	return nil
+}
+
+// RecordMint 铸造事件更新供应量；回填或回放时旧事件不会让供应量倒退
+func (s *CollectionService) RecordMint(ctx context.Context, tokenID, blockNumber uint64) error {
+	collection, err := s.GetCollection(ctx)
+	if err != nil {
+		return err
+	}
+	if tokenID < collection.TotalSupply {
+		return nil
+	}
+
+	updates := map[string]interface{}{
+		"total_supply":  tokenID,
+		"next_token_id": tokenID + 1,
+	}
+	if collection.MaxSupply >= tokenID {
+		updates["remaining_supply"] = collection.MaxSupply - tokenID
+	}
+	return s.UpdateState(ctx, blockNumber, updates)
+}
+
+// ==================== 持有者统计 ====================
+
+// HolderCount 单个持有者的NFT数量
+type HolderCount struct {
+	Owner string `json:"owner"`
+	Count int64  `json:"count"`
+}
+
+// HolderBucket 持有数量分布区间（Max 为0表示不设上限）
+type HolderBucket struct {
+	Min     int64 `json:"min"`
+	Max     int64 `json:"max"`
+	Holders int64 `json:"holders"`
+}
+
+// CollectionStats 合约状态 + 持有者统计
+type CollectionStats struct {
+	Collection   *model.Collection `json:"collection"`
+	HolderCount  int64             `json:"holder_count"`
+	TopHolders   []HolderCount     `json:"top_holders"`
+	Distribution []HolderBucket    `json:"distribution"`
+}
+
+// holderBuckets 持有数量分布区间
+var holderBuckets = []HolderBucket{{Min: 1, Max: 1}, {Min: 2, Max: 5}, {Min: 6, Max: 10}, {Min: 11, Max: 50}, {Min: 51}}
+
+// GetCollectionStats 合约状态与持有者分布（持有者按 nft_infos 当前所有者统计）
+// 未注册的合约返回 ErrCollectionNotRegistered
+func (s *CollectionService) GetCollectionStats(ctx context.Context, contractAddr common.Address, top int) (*CollectionStats, error) {
+	collection, err := s.GetCollectionByAddress(ctx, contractAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	var holders []HolderCount
+	err = s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Select("owner, COUNT(*) AS count").
+		Where("contract_address = ? AND chain_state <> ? AND owner <> '' AND owner <> ?",
+			collection.ContractAddress, model.ChainStateOrphaned, common.Address{}.Hex()).
+		Group("owner").
+		Order("count DESC, owner ASC").
+		Scan(&holders).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计持有者失败: %v", err)
+	}
+
+	distribution := make([]HolderBucket, len(holderBuckets))
+	copy(distribution, holderBuckets)
+	for _, holder := range holders {
+		for i := range distribution {
+			if holder.Count >= distribution[i].Min && (distribution[i].Max == 0 || holder.Count <= distribution[i].Max) {
+				distribution[i].Holders++
+				break
+			}
+		}
+	}
+
+	stats := &CollectionStats{
+		Collection:   collection,
+		HolderCount:  int64(len(holders)),
+		TopHolders:   holders,
+		Distribution: distribution,
+	}
+	if top > 0 && len(holders) > top {
+		stats.TopHolders = holders[:top]
+	}
+	return stats, nil
+}
+
+// ==================== 提取记录 ====================
+
+// SaveWithdrawal 保存提取记录（同一条日志重复写入时恢复为 pending）
+func (s *CollectionService) SaveWithdrawal(ctx context.Context, withdrawal *model.Withdrawal) error {
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_hash"}, {Name: "log_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_number", "block_hash", "chain_state"}),
+	}).Create(withdrawal).Error
+	if err != nil {
+		return fmt.Errorf("保存提取记录失败: %v", err)
+	}
+	return nil
+}
+
+// OrphanWithdrawal 提取记录所在区块被重组移除
+func (s *CollectionService) OrphanWithdrawal(ctx context.Context, txHash string, logIndex uint) error {
+	return s.DB.WithContext(ctx).Model(&model.Withdrawal{}).
+		Where("tx_hash = ? AND log_index = ?", txHash, logIndex).
+		Update("chain_state", model.ChainStateOrphaned).Error
+}
+
+// GetWithdrawals 分页查询提取记录（不含被重组移除的）
+func (s *CollectionService) GetWithdrawals(ctx context.Context, page, pageSize int) ([]model.Withdrawal, int64, error) {
+	var withdrawals []model.Withdrawal
+	var total int64
+
+	query := s.DB.WithContext(ctx).Model(&model.Withdrawal{}).
+		Where("contract_address = ? AND chain_state <> ?", s.GetContractAddress().Hex(), model.ChainStateOrphaned)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("block_number DESC, log_index DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&withdrawals).Error
+	return withdrawals, total, err
+}
+
+// ==================== 全量授权 ====================
+
+// SaveOperatorApproval 保存 ApprovalForAll 的最新状态
+func (s *CollectionService) SaveOperatorApproval(ctx context.Context, approval *model.OperatorApproval) error {
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "contract_address"}, {Name: "owner"}, {Name: "operator"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"approved", "tx_hash", "block_number", "block_hash", "chain_state", "updated_at",
+		}),
+	}).Create(approval).Error
+	if err != nil {
+		return fmt.Errorf("保存全量授权失败: %v", err)
+	}
+	return nil
+}
+
+// RefreshOperatorApproval 从链上读取 isApprovedForAll（授权事件被重组移除时使用）
+func (s *CollectionService) RefreshOperatorApproval(ctx context.Context, contractAddr, owner, operator common.Address) error {
+	reader, ok := s.Reader(contractAddr)
+	if !ok {
+		return fmt.Errorf("合约 %s 未注册", contractAddr.Hex())
+	}
+	approved, err := reader.IsApprovedForAll(ctx, owner, operator)
+	if err != nil {
+		return fmt.Errorf("获取全量授权失败: %v", err)
+	}
+	return s.DB.WithContext(ctx).Model(&model.OperatorApproval{}).
+		Where("contract_address = ? AND owner = ? AND operator = ?",
+			contractAddr.Hex(), owner.Hex(), operator.Hex()).
+		Updates(map[string]interface{}{
+			"approved":    approved,
+			"chain_state": model.ChainStateConfirmed,
+		}).Error
+}
+
+// RefreshOrphanedApprovals 重组后仍为 orphaned 的授权（新主链上没有对应事件）从链上刷新
+func (s *CollectionService) RefreshOrphanedApprovals(ctx context.Context) error {
+	var approvals []model.OperatorApproval
+	if err := s.DB.WithContext(ctx).
+		Where("chain_state = ?", model.ChainStateOrphaned).
+		Find(&approvals).Error; err != nil {
+		return err
+	}
+	for _, approval := range approvals {
+		err := s.RefreshOperatorApproval(ctx, common.HexToAddress(approval.ContractAddress),
+			common.HexToAddress(approval.Owner), common.HexToAddress(approval.Operator))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOperatorApprovals 查询当前有效的全量授权；owner 为空时返回全部
+func (s *CollectionService) GetOperatorApprovals(ctx context.Context, owner string) ([]model.OperatorApproval, error) {
+	query := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND approved = ? AND chain_state <> ?",
+			s.GetContractAddress().Hex(), true, model.ChainStateOrphaned)
+	if owner != "" {
+		query = query.Where("owner = ?", common.HexToAddress(owner).Hex())
+	}
+
+	var approvals []model.OperatorApproval
+	err := query.Order("updated_at DESC").Find(&approvals).Error
+	return approvals, err
+}
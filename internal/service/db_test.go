@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"nft-auction-backend/internal/model"
+)
+
+// newTestDB 每个测试独立的内存数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Auction{}, &model.NFTInfo{}, &model.EventSync{}, &model.ProcessedBlock{},
+		&model.BidHistory{}, &model.Bid{}, &model.BidRefund{}, &model.RawEvent{}, &model.Transfer{},
+		&model.Snapshot{}, &model.SnapshotHolder{}, &model.LedgerEntry{}, &model.EscrowReconciliation{},
+		&model.TokenMetadata{}, &model.TokenAttribute{}, &model.Withdrawal{}, &model.OperatorApproval{},
+		&model.SettlementAttempt{}, &model.NFTImage{},
+	); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
@@ -0,0 +1,149 @@
+// escrow_ledger.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/model"
+)
+
+// 资金流水由已索引的事件推导：
+//
+//	bid_escrowed   每条 NewBid：出价者 → escrow（随出价链重建）
+//	refund_issued  每个被顶替的出价：escrow → 出价者（随出价链重建）
+//	seller_paid    AuctionEnded：escrow → 卖家
+//	admin_withdrawal  合约不发事件，按交易哈希登记（EscrowService.RecordWithdrawal）
+
+// bidLedgerRef / refundLedgerRef / auctionLedgerRef / txLedgerRef 流水来源
+func bidLedgerRef(bidID uint) string           { return "bid:" + strconv.FormatUint(uint64(bidID), 10) }
+func refundLedgerRef(bidID uint) string        { return "refund:" + strconv.FormatUint(uint64(bidID), 10) }
+func auctionLedgerRef(auctionID uint64) string { return "auction:" + strconv.FormatUint(auctionID, 10) }
+func txLedgerRef(txHash string) string         { return "tx:" + txHash }
+
+// saveLedgerEntry 按 (类型, 来源) 写入流水，已存在时覆盖
+func saveLedgerEntry(tx *gorm.DB, entry *model.LedgerEntry) error {
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "kind"}, {Name: "ref"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"auction_id", "currency", "debit_account", "credit_account", "amount",
+			"tx_hash", "log_index", "block_number", "block_time", "chain_state", "updated_at",
+		}),
+	}).Create(entry).Error
+	if err != nil {
+		return fmt.Errorf("保存资金流水失败: %v", err)
+	}
+	return nil
+}
+
+// auctionCurrency 拍卖的支付币种（ERC20代币地址，ETH为空）
+func auctionCurrency(tx *gorm.DB, auctionID uint64) (string, error) {
+	var auctions []model.Auction
+	if err := tx.Select("payment_token").Where("auction_id = ?", auctionID).Limit(1).Find(&auctions).Error; err != nil {
+		return "", fmt.Errorf("查询拍卖 #%d 支付币种失败: %v", auctionID, err)
+	}
+	if len(auctions) == 0 {
+		return "", nil
+	}
+	return auctions[0].PaymentToken, nil
+}
+
+// rebuildBidLedger 重建拍卖的出价托管与退款流水（bids 为按顺序排列、未被重组移除的出价）
+func rebuildBidLedger(tx *gorm.DB, auctionID uint64, bids []model.BidHistory) error {
+	if err := tx.Model(&model.LedgerEntry{}).
+		Where("auction_id = ? AND kind IN ?", auctionID, []string{model.LedgerBidEscrowed, model.LedgerRefundIssued}).
+		Update("chain_state", model.ChainStateOrphaned).Error; err != nil {
+		return fmt.Errorf("重置出价流水失败: %v", err)
+	}
+	if len(bids) == 0 {
+		return nil
+	}
+
+	currency, err := auctionCurrency(tx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	for i, bid := range bids {
+		if err := saveLedgerEntry(tx, bidLedgerEntry(currency, bid)); err != nil {
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		if err := saveLedgerEntry(tx, refundLedgerEntry(currency, bids[i-1], bid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bidLedgerEntry 出价托管流水：出价者 → escrow
+func bidLedgerEntry(currency string, bid model.BidHistory) *model.LedgerEntry {
+	return &model.LedgerEntry{
+		Kind:          model.LedgerBidEscrowed,
+		Ref:           bidLedgerRef(bid.ID),
+		AuctionID:     bid.AuctionID,
+		Currency:      currency,
+		DebitAccount:  model.LedgerEscrowAccount,
+		CreditAccount: bid.Bidder,
+		Amount:        bid.Amount,
+		TxHash:        bid.TxHash,
+		LogIndex:      bid.LogIndex,
+		BlockNumber:   bid.BlockNumber,
+		BlockTime:     bid.BlockTime,
+		ChainState:    bidChainState(bid.Status),
+	}
+}
+
+// refundLedgerEntry 被顶替出价的退款流水：escrow → 出价者（与顶替它的出价在同一笔交易中）
+func refundLedgerEntry(currency string, previous, outbid model.BidHistory) *model.LedgerEntry {
+	return &model.LedgerEntry{
+		Kind:          model.LedgerRefundIssued,
+		Ref:           refundLedgerRef(previous.ID),
+		AuctionID:     previous.AuctionID,
+		Currency:      currency,
+		DebitAccount:  previous.Bidder,
+		CreditAccount: model.LedgerEscrowAccount,
+		Amount:        previous.Amount,
+		TxHash:        outbid.TxHash,
+		LogIndex:      outbid.LogIndex,
+		BlockNumber:   outbid.BlockNumber,
+		BlockTime:     outbid.BlockTime,
+		ChainState:    bidChainState(outbid.Status),
+	}
+}
+
+// RecordSellerPayout 成交（AuctionEnded）：最高出价从托管支付给卖家
+func (s *AuctionService) RecordSellerPayout(ctx context.Context, auction *model.Auction, amount string, blockTime uint64, vLog types.Log) error {
+	return saveLedgerEntry(s.DB.WithContext(ctx), &model.LedgerEntry{
+		Kind:          model.LedgerSellerPaid,
+		Ref:           auctionLedgerRef(auction.AuctionID),
+		AuctionID:     auction.AuctionID,
+		Currency:      auction.PaymentToken,
+		DebitAccount:  auction.Seller,
+		CreditAccount: model.LedgerEscrowAccount,
+		Amount:        amount,
+		TxHash:        vLog.TxHash.Hex(),
+		LogIndex:      vLog.Index,
+		BlockNumber:   vLog.BlockNumber,
+		BlockTime:     blockTime,
+		ChainState:    model.ChainStatePending,
+	})
+}
+
+// OrphanSellerPayout 成交事件被重组移除
+func (s *AuctionService) OrphanSellerPayout(ctx context.Context, auctionID uint64) error {
+	err := s.DB.WithContext(ctx).Model(&model.LedgerEntry{}).
+		Where("kind = ? AND ref = ?", model.LedgerSellerPaid, auctionLedgerRef(auctionID)).
+		Update("chain_state", model.ChainStateOrphaned).Error
+	if err != nil {
+		return fmt.Errorf("回滚成交流水失败: %v", err)
+	}
+	return nil
+}
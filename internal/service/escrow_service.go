@@ -0,0 +1,417 @@
+// escrow_service.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+// ErrNotWithdrawal 交易不是成功执行的管理员提取
+var ErrNotWithdrawal = errors.New("不是拍卖合约的提取交易")
+
+// UnregisteredWithdrawalHint 实际余额少于流水余额时的提示：
+// withdrawETH / withdrawERC20 不发事件，只有通过 RecordWithdrawal（POST /api/escrow/withdrawals）登记后才进入流水
+const UnregisteredWithdrawalHint = "实际余额少于流水余额：可能有未登记的管理员提取，请按交易哈希登记（POST /api/escrow/withdrawals）"
+
+// EscrowBackend 托管对账需要的链上能力（RPC连接池已实现）
+type EscrowBackend interface {
+	contract.Backend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+}
+
+// EscrowService 拍卖合约资金视图：资金流水余额、托管对账、管理员提取登记
+//
+// 流水由事件推导（见 escrow_ledger.go），escrow 账户的借贷差就是合约应持有的资金；
+// 对账在拍卖事件检查点所在区块读取合约的 ETH 余额与各 ERC20 的 balanceOf 并与之比较；
+// 检查点就是最新区块时按 latest 查询，非归档节点已裁剪检查点区块状态时记为 unavailable（不算不一致）
+type EscrowService struct {
+	DB        *gorm.DB
+	backend   EscrowBackend
+	contract  common.Address
+	eventSync *EventSyncService
+	cfg       config.EscrowConfig
+}
+
+// NewEscrowService 创建托管服务
+func NewEscrowService(db *gorm.DB, backend EscrowBackend, auctionContract common.Address, eventSync *EventSyncService, cfg config.EscrowConfig) *EscrowService {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Minute
+	}
+	return &EscrowService{
+		DB:        db,
+		backend:   backend,
+		contract:  auctionContract,
+		eventSync: eventSync,
+		cfg:       cfg,
+	}
+}
+
+// AccountBalance 账户在某个币种下的流水汇总
+type AccountBalance struct {
+	Currency string `json:"currency"` // ERC20代币地址，ETH为空
+	Received string `json:"received"` // 借方合计（从其他账户收到）
+	Paid     string `json:"paid"`     // 贷方合计（支付给其他账户）
+	Balance  string `json:"balance"`  // Received - Paid；escrow 账户为合约应持有的资金，地址为负表示净支出
+}
+
+// GetBalances 账户（地址或 escrow）按币种的余额，不含被重组移除的流水
+func (s *EscrowService) GetBalances(ctx context.Context, account string) ([]AccountBalance, error) {
+	return s.balancesAt(ctx, account, 0)
+}
+
+// balancesAt 截止到区块 block（含）的余额，block 为0表示全部
+func (s *EscrowService) balancesAt(ctx context.Context, account string, block uint64) ([]AccountBalance, error) {
+	account = strings.ToLower(account)
+	query := s.DB.WithContext(ctx).
+		Where("(LOWER(debit_account) = ? OR LOWER(credit_account) = ?) AND chain_state <> ?", account, account, model.ChainStateOrphaned)
+	if block > 0 {
+		query = query.Where("block_number <= ?", block)
+	}
+
+	var entries []model.LedgerEntry
+	if err := query.Order("block_number ASC, log_index ASC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("查询资金流水失败: %v", err)
+	}
+
+	type totals struct{ received, paid *big.Int }
+	byCurrency := make(map[string]*totals)
+	order := make([]string, 0)
+	for _, entry := range entries {
+		amount, ok := new(big.Int).SetString(entry.Amount, 10)
+		if !ok {
+			log.Printf("⚠️ 流水 #%d 金额无效: %q", entry.ID, entry.Amount)
+			continue
+		}
+		t, ok := byCurrency[entry.Currency]
+		if !ok {
+			t = &totals{received: new(big.Int), paid: new(big.Int)}
+			byCurrency[entry.Currency] = t
+			order = append(order, entry.Currency)
+		}
+		if strings.EqualFold(entry.DebitAccount, account) {
+			t.received.Add(t.received, amount)
+		}
+		if strings.EqualFold(entry.CreditAccount, account) {
+			t.paid.Add(t.paid, amount)
+		}
+	}
+
+	balances := make([]AccountBalance, 0, len(order))
+	for _, currency := range order {
+		t := byCurrency[currency]
+		balances = append(balances, AccountBalance{
+			Currency: currency,
+			Received: t.received.String(),
+			Paid:     t.paid.String(),
+			Balance:  new(big.Int).Sub(t.received, t.paid).String(),
+		})
+	}
+	return balances, nil
+}
+
+// LedgerQuery 流水查询条件
+type LedgerQuery struct {
+	Account   string // 借方或贷方为该账户
+	AuctionID uint64
+	Kind      string
+	Page      int
+	PageSize  int
+}
+
+// GetLedger 分页查询流水（最新的在前，不含被重组移除的）
+func (s *EscrowService) GetLedger(ctx context.Context, q LedgerQuery) ([]model.LedgerEntry, int64, error) {
+	query := s.DB.WithContext(ctx).Model(&model.LedgerEntry{}).Where("chain_state <> ?", model.ChainStateOrphaned)
+	if q.Account != "" {
+		account := strings.ToLower(q.Account)
+		query = query.Where("LOWER(debit_account) = ? OR LOWER(credit_account) = ?", account, account)
+	}
+	if q.AuctionID > 0 {
+		query = query.Where("auction_id = ?", q.AuctionID)
+	}
+	if q.Kind != "" {
+		query = query.Where("kind = ?", q.Kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计资金流水失败: %v", err)
+	}
+
+	var entries []model.LedgerEntry
+	if err := query.Order("block_number DESC, log_index DESC, id DESC").
+		Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询资金流水失败: %v", err)
+	}
+	return entries, total, nil
+}
+
+// Start 启动定期对账（未启用时不做任何事）
+func (s *EscrowService) Start(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.Reconcile(ctx); err != nil {
+				log.Printf("❌ 托管对账失败: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Println("🛑 托管对账已停止")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Reconcile 对每个币种比较流水推导的 escrow 余额与合约实际余额，保存并返回对账结果
+func (s *EscrowService) Reconcile(ctx context.Context) ([]model.EscrowReconciliation, error) {
+	block, atHead, err := s.reconcileBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var blockNumber *big.Int // nil 表示 latest
+	if !atHead {
+		blockNumber = new(big.Int).SetUint64(block)
+	}
+
+	expected := make(map[string]*big.Int)
+	balances, err := s.balancesAt(ctx, model.LedgerEscrowAccount, block)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range balances {
+		expected[b.Currency], _ = new(big.Int).SetString(b.Balance, 10)
+	}
+
+	currencies, err := s.currencies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]model.EscrowReconciliation, 0, len(currencies))
+	for _, currency := range currencies {
+		want := expected[currency]
+		if want == nil {
+			want = new(big.Int)
+		}
+		result := model.EscrowReconciliation{
+			Currency:    currency,
+			BlockNumber: block,
+			Expected:    want.String(),
+		}
+
+		actual, err := s.contractBalance(ctx, currency, blockNumber)
+		if err != nil {
+			result.Status = model.ReconciliationError
+			result.Error = err.Error()
+			if isStateUnavailable(err) {
+				result.Status = model.ReconciliationUnavailable
+				log.Printf("⚠️ 节点已没有区块 %d 的状态，跳过 %s 对账（需要归档节点）: %v", block, currencyLabel(currency), err)
+			}
+		} else {
+			diff := new(big.Int).Sub(actual, want)
+			result.Actual = actual.String()
+			result.Difference = diff.String()
+			result.Status = model.ReconciliationOK
+			if diff.Sign() != 0 {
+				result.Status = model.ReconciliationMismatch
+				if diff.Sign() < 0 {
+					result.Error = UnregisteredWithdrawalHint
+				}
+				log.Printf("⚠️ 托管对账不一致: 币种=%s 区块=%d 应有=%s 实际=%s", currencyLabel(currency), block, want, actual)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if err := s.DB.WithContext(ctx).Create(&results).Error; err != nil {
+		return nil, fmt.Errorf("保存对账结果失败: %v", err)
+	}
+	return results, nil
+}
+
+// reconcileBlock 对账区块：拍卖合约事件已完整处理到的区块，没有检查点时取最新区块
+// atHead 表示对账区块就是最新区块，此时按 latest 查询余额（非归档节点总能提供）
+func (s *EscrowService) reconcileBlock(ctx context.Context) (block uint64, atHead bool, err error) {
+	last, found, err := s.eventSync.GetLastBlock(ctx, AuctionEventsKind, s.contract)
+	if err != nil {
+		return 0, false, err
+	}
+	head, err := s.backend.BlockNumber(ctx)
+	if err != nil {
+		return 0, false, fmt.Errorf("获取最新区块失败: %v", err)
+	}
+	if found && last > 0 && last != head {
+		return last, false, nil
+	}
+	return head, true, nil
+}
+
+// 节点不提供历史区块状态（非归档节点已裁剪）时各客户端/服务商返回的错误
+var stateUnavailableErrors = []string{
+	"missing trie node",
+	"header not found",
+	"historical state",
+	"state not available",
+	"state is not available",
+	"state unavailable",
+	"pruned",
+	"archive",
+}
+
+// isStateUnavailable 错误是否表示节点没有该区块的状态
+func isStateUnavailable(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range stateUnavailableErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// currencies 需要对账的币种：ETH、流水中出现过的币种、拍卖使用过的ERC20
+func (s *EscrowService) currencies(ctx context.Context) ([]string, error) {
+	var fromLedger, fromAuctions []string
+	if err := s.DB.WithContext(ctx).Model(&model.LedgerEntry{}).
+		Distinct().Pluck("currency", &fromLedger).Error; err != nil {
+		return nil, fmt.Errorf("查询流水币种失败: %v", err)
+	}
+	if err := s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Where("payment_token <> ''").
+		Distinct().Pluck("payment_token", &fromAuctions).Error; err != nil {
+		return nil, fmt.Errorf("查询拍卖币种失败: %v", err)
+	}
+
+	seen := map[string]bool{"": true}
+	currencies := []string{""}
+	for _, currency := range append(fromLedger, fromAuctions...) {
+		if !seen[currency] {
+			seen[currency] = true
+			currencies = append(currencies, currency)
+		}
+	}
+	return currencies, nil
+}
+
+// contractBalance 拍卖合约在区块 blockNumber 的余额（ETH 或 ERC20 balanceOf），nil 为最新区块
+func (s *EscrowService) contractBalance(ctx context.Context, currency string, blockNumber *big.Int) (*big.Int, error) {
+	if currency == "" {
+		balance, err := s.backend.BalanceAt(ctx, s.contract, blockNumber)
+		if err != nil {
+			return nil, fmt.Errorf("查询ETH余额失败: %v", err)
+		}
+		return balance, nil
+	}
+
+	token, err := contract.NewERC20Client(s.backend, common.HexToAddress(currency))
+	if err != nil {
+		return nil, err
+	}
+	balance, err := token.BalanceOfAt(ctx, s.contract, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("查询 %s balanceOf 失败: %v", currency, err)
+	}
+	return balance, nil
+}
+
+// GetReconciliations 最近的对账结果
+func (s *EscrowService) GetReconciliations(ctx context.Context, limit int) ([]model.EscrowReconciliation, error) {
+	var results []model.EscrowReconciliation
+	if err := s.DB.WithContext(ctx).Order("id DESC").Limit(limit).Find(&results).Error; err != nil {
+		return nil, fmt.Errorf("查询对账结果失败: %v", err)
+	}
+	return results, nil
+}
+
+// RecordWithdrawal 按交易哈希登记管理员提取（withdrawETH / withdrawERC20 不发事件）
+// 交易必须发往拍卖合约且执行成功；重复登记会覆盖，被重组移除后重新登记即可恢复
+func (s *EscrowService) RecordWithdrawal(ctx context.Context, txHash common.Hash) (*model.LedgerEntry, error) {
+	tx, pending, err := s.backend.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("获取交易失败: %v", err)
+	}
+	if pending {
+		return nil, fmt.Errorf("%w: 交易尚未上链", ErrNotWithdrawal)
+	}
+	if tx.To() == nil || *tx.To() != s.contract {
+		return nil, fmt.Errorf("%w: 交易不是发往拍卖合约", ErrNotWithdrawal)
+	}
+	withdrawal, err := contract.DecodeAuctionWithdrawal(tx.Data())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotWithdrawal, err)
+	}
+
+	receipt, err := s.backend.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("获取交易回执失败: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("%w: 交易执行失败", ErrNotWithdrawal)
+	}
+
+	// 只有管理员能提取，发送者即收款方
+	admin, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, fmt.Errorf("解析交易发送者失败: %v", err)
+	}
+
+	var blockTime uint64
+	if header, err := s.backend.HeaderByNumber(ctx, receipt.BlockNumber); err == nil {
+		blockTime = header.Time
+	} else {
+		log.Printf("⚠️ 获取区块 %d 时间失败: %v", receipt.BlockNumber, err)
+	}
+
+	entry := &model.LedgerEntry{
+		Kind:          model.LedgerAdminWithdraw,
+		Ref:           txLedgerRef(txHash.Hex()),
+		DebitAccount:  admin.Hex(),
+		CreditAccount: model.LedgerEscrowAccount,
+		Amount:        withdrawal.Amount.String(),
+		TxHash:        txHash.Hex(),
+		BlockNumber:   receipt.BlockNumber.Uint64(),
+		BlockTime:     blockTime,
+		ChainState:    model.ChainStatePending,
+	}
+	if withdrawal.IsERC20() {
+		entry.Currency = withdrawal.Token.Hex()
+	}
+	if err := saveLedgerEntry(s.DB.WithContext(ctx), entry); err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ 登记管理员提取: %s %s → %s", entry.Amount, currencyLabel(entry.Currency), entry.DebitAccount)
+	return entry, nil
+}
+
+// currencyLabel 日志中显示的币种
+func currencyLabel(currency string) string {
+	if currency == "" {
+		return "ETH"
+	}
+	return currency
+}
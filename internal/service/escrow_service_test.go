@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/model"
+)
+
+var escrowContract = common.HexToAddress("0xAC")
+
+// fakeEscrowBackend 模拟非归档节点：只有 pruneBelow 及以上区块（和 latest）的状态
+type fakeEscrowBackend struct {
+	EscrowBackend
+	head       uint64
+	pruneBelow uint64
+	balance    *big.Int
+	queried    []*big.Int
+}
+
+func (f *fakeEscrowBackend) BlockNumber(context.Context) (uint64, error) {
+	return f.head, nil
+}
+
+func (f *fakeEscrowBackend) BalanceAt(_ context.Context, _ common.Address, blockNumber *big.Int) (*big.Int, error) {
+	f.queried = append(f.queried, blockNumber)
+	if blockNumber != nil && blockNumber.Uint64() < f.pruneBelow {
+		return nil, errors.New("missing trie node 5f3a (path ) state 0x5f3a is not available")
+	}
+	return f.balance, nil
+}
+
+func newEscrowFixture(t *testing.T, checkpoint uint64, backend *fakeEscrowBackend) *EscrowService {
+	t.Helper()
+	db := newTestDB(t)
+	eventSync := NewEventSyncService(db)
+	if checkpoint > 0 {
+		if err := eventSync.SaveLastBlock(context.Background(), AuctionEventsKind, escrowContract, checkpoint); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewEscrowService(db, backend, escrowContract, eventSync, config.EscrowConfig{})
+}
+
+func TestReconcileAtHeadQueriesLatest(t *testing.T) {
+	backend := &fakeEscrowBackend{head: 1000, pruneBelow: 1001, balance: big.NewInt(0)}
+	s := newEscrowFixture(t, 1000, backend)
+
+	results, err := s.Reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Status != model.ReconciliationOK || results[0].BlockNumber != 1000 {
+		t.Fatalf("results: %+v", results)
+	}
+	if len(backend.queried) != 1 || backend.queried[0] != nil {
+		t.Fatalf("balance queried at %v, want latest", backend.queried)
+	}
+}
+
+func TestReconcileWithoutCheckpointQueriesLatest(t *testing.T) {
+	backend := &fakeEscrowBackend{head: 1000, pruneBelow: 1001, balance: big.NewInt(0)}
+	s := newEscrowFixture(t, 0, backend)
+
+	results, err := s.Reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != model.ReconciliationOK || results[0].BlockNumber != 1000 || backend.queried[0] != nil {
+		t.Fatalf("results: %+v queried: %v", results, backend.queried)
+	}
+}
+
+func TestReconcileBehindHeadQueriesCheckpoint(t *testing.T) {
+	backend := &fakeEscrowBackend{head: 1000, pruneBelow: 900, balance: big.NewInt(5)}
+	s := newEscrowFixture(t, 990, backend)
+
+	results, err := s.Reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.queried[0] == nil || backend.queried[0].Uint64() != 990 {
+		t.Fatalf("balance queried at %v, want 990", backend.queried)
+	}
+	if results[0].Status != model.ReconciliationMismatch || results[0].Difference != "5" {
+		t.Fatalf("results: %+v", results)
+	}
+}
+
+func TestReconcilePrunedStateIsUnavailable(t *testing.T) {
+	backend := &fakeEscrowBackend{head: 1000, pruneBelow: 900, balance: big.NewInt(0)}
+	s := newEscrowFixture(t, 100, backend)
+
+	results, err := s.Reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Status != model.ReconciliationUnavailable || results[0].Error == "" {
+		t.Fatalf("results: %+v", results)
+	}
+
+	saved, err := s.GetReconciliations(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 1 || saved[0].Status != model.ReconciliationUnavailable {
+		t.Fatalf("saved: %+v", saved)
+	}
+}
+
+func TestIsStateUnavailable(t *testing.T) {
+	cases := map[string]bool{
+		"missing trie node 1a2b (path ) <nil>":                    true,
+		"header not found":                                        true,
+		"historical state not available in path scheme yet":       true,
+		"project ID does not have access to archive state":        true,
+		"execution reverted":                                      false,
+		"Post \"https://rpc.example\": context deadline exceeded": false,
+	}
+	for msg, want := range cases {
+		if got := isStateUnavailable(errors.New(msg)); got != want {
+			t.Errorf("isStateUnavailable(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+// 实际余额少于流水余额：提示可能有未登记的管理员提取
+func TestReconcileShortfallHintsUnregisteredWithdrawal(t *testing.T) {
+	backend := &fakeEscrowBackend{head: 1000, pruneBelow: 900, balance: big.NewInt(4)}
+	s := newEscrowFixture(t, 990, backend)
+	entry := model.LedgerEntry{
+		Kind: model.LedgerBidEscrowed, Ref: "bid:1", AuctionID: 1,
+		DebitAccount: model.LedgerEscrowAccount, CreditAccount: alice,
+		Amount: "10", BlockNumber: 980, ChainState: model.ChainStateConfirmed,
+	}
+	if err := saveLedgerEntry(s.DB, &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.Reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != model.ReconciliationMismatch || results[0].Difference != "-6" || results[0].Error != UnregisteredWithdrawalHint {
+		t.Fatalf("results: %+v", results)
+	}
+}
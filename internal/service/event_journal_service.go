@@ -0,0 +1,231 @@
+// event_journal_service.go
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/model"
+)
+
+// journalReplayBatch 回放时每批读取的日志数
+const journalReplayBatch = 500
+
+// EventJournalService 原始事件日志服务
+// 键为 (链ID, 区块哈希, 交易哈希, 日志序号)，同一条日志重复写入是幂等的
+type EventJournalService struct {
+	DB      *gorm.DB
+	chainID uint64
+}
+
+// NewEventJournalService 创建事件日志服务
+func NewEventJournalService(db *gorm.DB, chainID uint64) *EventJournalService {
+	return &EventJournalService{
+		DB:      db,
+		chainID: chainID,
+	}
+}
+
+// StoredChainID 事件日志中记录的链ID（离线回放时使用）；日志为空或包含多条链时返回错误
+func StoredChainID(ctx context.Context, db *gorm.DB) (uint64, error) {
+	var chainIDs []uint64
+	if err := db.WithContext(ctx).Model(&model.RawEvent{}).
+		Distinct().Pluck("chain_id", &chainIDs).Error; err != nil {
+		return 0, fmt.Errorf("查询事件日志链ID失败: %v", err)
+	}
+	switch len(chainIDs) {
+	case 0:
+		return 0, fmt.Errorf("事件日志为空，无法确定链ID，请配置 blockchain.chain_id")
+	case 1:
+		return chainIDs[0], nil
+	default:
+		return 0, fmt.Errorf("事件日志包含多条链 %v，请配置 blockchain.chain_id", chainIDs)
+	}
+}
+
+// ChainID 日志所属链ID
+func (s *EventJournalService) ChainID() uint64 {
+	return s.chainID
+}
+
+// Record 写入一条日志；contractABI 不为空时同时保存解码结果
+// 已存在的日志（重组后被重新包含）只恢复 removed 标记
+func (s *EventJournalService) Record(ctx context.Context, vLog types.Log, blockTime uint64, contractABI *abi.ABI) error {
+	topics := make([]string, len(vLog.Topics))
+	for i, topic := range vLog.Topics {
+		topics[i] = topic.Hex()
+	}
+	topicsJSON, _ := json.Marshal(topics)
+
+	event := &model.RawEvent{
+		ChainID:         s.chainID,
+		BlockHash:       vLog.BlockHash.Hex(),
+		TxHash:          vLog.TxHash.Hex(),
+		LogIndex:        vLog.Index,
+		BlockNumber:     vLog.BlockNumber,
+		BlockTime:       blockTime,
+		TxIndex:         vLog.TxIndex,
+		ContractAddress: vLog.Address.Hex(),
+		Topics:          string(topicsJSON),
+		Data:            hexutil.Encode(vLog.Data),
+	}
+	if contractABI != nil {
+		if name, payload, err := decodeLog(contractABI, vLog); err == nil {
+			event.EventName = name
+			if decoded, err := json.Marshal(payload); err == nil {
+				event.Decoded = string(decoded)
+			}
+		}
+	}
+
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "chain_id"}, {Name: "block_hash"}, {Name: "tx_hash"}, {Name: "log_index"},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{"removed": false}),
+	}).Create(event).Error
+	if err != nil {
+		return fmt.Errorf("写入事件日志失败: %v", err)
+	}
+	return nil
+}
+
+// Enrich 保存处理日志时从链上补充的数据（JSON），回放时读回，不再查询链上
+func (s *EventJournalService) Enrich(ctx context.Context, vLog types.Log, v interface{}) error {
+	enrichment, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("编码事件补充数据失败: %v", err)
+	}
+	err = s.DB.WithContext(ctx).Model(&model.RawEvent{}).
+		Where("chain_id = ? AND block_hash = ? AND tx_hash = ? AND log_index = ?",
+			s.chainID, vLog.BlockHash.Hex(), vLog.TxHash.Hex(), vLog.Index).
+		Update("enrichment", string(enrichment)).Error
+	if err != nil {
+		return fmt.Errorf("保存事件补充数据失败: %v", err)
+	}
+	return nil
+}
+
+// MarkRemoved 标记被重组移除的日志
+func (s *EventJournalService) MarkRemoved(ctx context.Context, vLog types.Log) error {
+	return s.DB.WithContext(ctx).Model(&model.RawEvent{}).
+		Where("chain_id = ? AND block_hash = ? AND tx_hash = ? AND log_index = ?",
+			s.chainID, vLog.BlockHash.Hex(), vLog.TxHash.Hex(), vLog.Index).
+		Update("removed", true).Error
+}
+
+// Iterate 按 (区块, 日志序号) 顺序遍历未被移除的日志；to 为0表示不限
+func (s *EventJournalService) Iterate(ctx context.Context, from, to uint64, fn func(types.Log, *model.RawEvent) error) (int, error) {
+	count := 0
+	lastBlock, lastIndex, started := from, uint(0), false
+
+	for {
+		query := s.DB.WithContext(ctx).
+			Where("chain_id = ? AND removed = ?", s.chainID, false)
+		if started {
+			// 键集分页：从上一批最后一条日志之后继续
+			query = query.Where("(block_number > ? OR (block_number = ? AND log_index > ?))", lastBlock, lastBlock, lastIndex)
+		} else {
+			query = query.Where("block_number >= ?", from)
+		}
+		if to > 0 {
+			query = query.Where("block_number <= ?", to)
+		}
+
+		var events []model.RawEvent
+		if err := query.Order("block_number ASC, log_index ASC").
+			Limit(journalReplayBatch).
+			Find(&events).Error; err != nil {
+			return count, fmt.Errorf("读取事件日志失败: %v", err)
+		}
+		if len(events) == 0 {
+			return count, nil
+		}
+
+		for i := range events {
+			vLog, err := rawEventToLog(&events[i])
+			if err != nil {
+				return count, err
+			}
+			if err := fn(vLog, &events[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		last := events[len(events)-1]
+		lastBlock, lastIndex, started = last.BlockNumber, last.LogIndex, true
+	}
+}
+
+// decodeLog 按ABI解码日志（indexed 参数来自 topics，其余来自 data）
+func decodeLog(contractABI *abi.ABI, vLog types.Log) (string, map[string]interface{}, error) {
+	if len(vLog.Topics) == 0 {
+		return "", nil, fmt.Errorf("日志没有主题")
+	}
+	event, err := contractABI.EventByID(vLog.Topics[0])
+	if err != nil {
+		return "", nil, err
+	}
+
+	payload := make(map[string]interface{})
+	if len(vLog.Data) > 0 {
+		if err := contractABI.UnpackIntoMap(payload, event.Name, vLog.Data); err != nil {
+			return event.Name, nil, err
+		}
+	}
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(payload, indexed, vLog.Topics[1:]); err != nil {
+		return event.Name, nil, err
+	}
+
+	// 大整数转字符串，避免前端 JSON 精度丢失
+	for key, value := range payload {
+		if n, ok := value.(*big.Int); ok {
+			payload[key] = n.String()
+		}
+	}
+	return event.Name, payload, nil
+}
+
+// rawEventToLog 把日志记录还原为 types.Log
+func rawEventToLog(event *model.RawEvent) (types.Log, error) {
+	var hexTopics []string
+	if err := json.Unmarshal([]byte(event.Topics), &hexTopics); err != nil {
+		return types.Log{}, fmt.Errorf("解析日志 %s#%d 主题失败: %v", event.TxHash, event.LogIndex, err)
+	}
+	topics := make([]common.Hash, len(hexTopics))
+	for i, topic := range hexTopics {
+		topics[i] = common.HexToHash(topic)
+	}
+
+	data, err := hexutil.Decode(event.Data)
+	if err != nil {
+		return types.Log{}, fmt.Errorf("解析日志 %s#%d 数据失败: %v", event.TxHash, event.LogIndex, err)
+	}
+
+	return types.Log{
+		Address:     common.HexToAddress(event.ContractAddress),
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: event.BlockNumber,
+		TxHash:      common.HexToHash(event.TxHash),
+		TxIndex:     event.TxIndex,
+		BlockHash:   common.HexToHash(event.BlockHash),
+		Index:       event.LogIndex,
+	}, nil
+}
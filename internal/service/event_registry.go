@@ -0,0 +1,195 @@
+// event_registry.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// EventRegistry 事件处理注册表
+// 每个合约按 ABI 注册，事件ID取自 ABI（不再手写签名字符串）
+// 每个事件有一个类型化解码器（生成代码的 ParseXxx），可以有多个订阅者
+type EventRegistry struct {
+	mu        sync.RWMutex
+	contracts map[common.Address]*registeredContract
+}
+
+type registeredContract struct {
+	name   string
+	abi    *abi.ABI
+	events map[common.Hash]*registeredEvent
+}
+
+type registeredEvent struct {
+	name     string
+	typ      reflect.Type // 解码结果类型，订阅时校验
+	decode   func(types.Log) (interface{}, error)
+	handlers []func(context.Context, interface{}, types.Log) // 正常日志
+	removed  []func(context.Context, interface{}, types.Log) // 被重组移除的日志
+}
+
+// NewEventRegistry 创建事件注册表
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{
+		contracts: make(map[common.Address]*registeredContract),
+	}
+}
+
+// RegisterContract 注册合约及其 ABI（同一地址重复注册会覆盖）
+func (r *EventRegistry) RegisterContract(name string, addr common.Address, contractABI *abi.ABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contracts[addr] = &registeredContract{
+		name:   name,
+		abi:    contractABI,
+		events: make(map[common.Hash]*registeredEvent),
+	}
+}
+
+// RegisterEvent 为合约事件注册类型化解码器
+// eventName 必须存在于合约 ABI 中，事件ID从 ABI 计算
+func RegisterEvent[T any](r *EventRegistry, addr common.Address, eventName string, decode func(types.Log) (*T, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.contracts[addr]
+	if !ok {
+		return fmt.Errorf("合约 %s 未注册", addr.Hex())
+	}
+	event, ok := c.abi.Events[eventName]
+	if !ok {
+		return fmt.Errorf("合约 %s 的 ABI 中没有事件 %s", c.name, eventName)
+	}
+
+	c.events[event.ID] = &registeredEvent{
+		name: eventName,
+		typ:  reflect.TypeOf((*T)(nil)),
+		decode: func(vLog types.Log) (interface{}, error) {
+			return decode(vLog)
+		},
+	}
+	return nil
+}
+
+// Subscribe 订阅事件（按注册顺序调用）
+// 处理函数收到 Dispatch 传入的 ctx（回放时携带当前的事件日志，见 replayEventFrom）
+func Subscribe[T any](r *EventRegistry, addr common.Address, eventName string, handler func(context.Context, *T, types.Log)) error {
+	return subscribe(r, addr, eventName, handler, false)
+}
+
+// SubscribeRemoved 订阅被重组移除的事件，用于回滚其影响
+func SubscribeRemoved[T any](r *EventRegistry, addr common.Address, eventName string, handler func(context.Context, *T, types.Log)) error {
+	return subscribe(r, addr, eventName, handler, true)
+}
+
+func subscribe[T any](r *EventRegistry, addr common.Address, eventName string, handler func(context.Context, *T, types.Log), removed bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event, err := r.lookup(addr, eventName)
+	if err != nil {
+		return err
+	}
+	if typ := reflect.TypeOf((*T)(nil)); typ != event.typ {
+		return fmt.Errorf("事件 %s 的类型为 %v，订阅者需要 %v", eventName, event.typ, typ)
+	}
+
+	fn := func(ctx context.Context, decoded interface{}, vLog types.Log) {
+		handler(ctx, decoded.(*T), vLog)
+	}
+	if removed {
+		event.removed = append(event.removed, fn)
+	} else {
+		event.handlers = append(event.handlers, fn)
+	}
+	return nil
+}
+
+// lookup 按名称查找已注册的事件（调用方持有锁）
+func (r *EventRegistry) lookup(addr common.Address, eventName string) (*registeredEvent, error) {
+	c, ok := r.contracts[addr]
+	if !ok {
+		return nil, fmt.Errorf("合约 %s 未注册", addr.Hex())
+	}
+	for _, event := range c.events {
+		if event.name == eventName {
+			return event, nil
+		}
+	}
+	return nil, fmt.Errorf("合约 %s 的事件 %s 没有注册解码器", c.name, eventName)
+}
+
+// ABI 返回合约 ABI（未注册返回 nil）
+func (r *EventRegistry) ABI(addr common.Address) *abi.ABI {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.contracts[addr]; ok {
+		return c.abi
+	}
+	return nil
+}
+
+// ContractName 返回合约注册名（未注册返回地址）
+func (r *EventRegistry) ContractName(addr common.Address) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if c, ok := r.contracts[addr]; ok {
+		return c.name
+	}
+	return addr.Hex()
+}
+
+// Dispatch 解码日志并依次调用订阅者；Removed=true 的日志交给回滚订阅者
+// 返回 false 表示事件没有注册解码器
+func (r *EventRegistry) Dispatch(ctx context.Context, vLog types.Log) bool {
+	if len(vLog.Topics) == 0 {
+		return false
+	}
+
+	r.mu.RLock()
+	c, ok := r.contracts[vLog.Address]
+	if !ok {
+		r.mu.RUnlock()
+		log.Printf("⚠️ 未注册合约的日志: %s", vLog.Address.Hex())
+		return false
+	}
+	event, ok := c.events[vLog.Topics[0]]
+	if !ok {
+		r.mu.RUnlock()
+		if abiEvent, err := c.abi.EventByID(vLog.Topics[0]); err == nil {
+			log.Printf("ℹ️ %s 事件 %s 没有处理函数，已跳过", c.name, abiEvent.Name)
+		} else {
+			log.Printf("⚠️ 未知%s事件签名: %s", c.name, vLog.Topics[0].Hex())
+		}
+		return false
+	}
+	handlers := event.handlers
+	if vLog.Removed {
+		handlers = event.removed
+	}
+	contractName, eventName, decode := c.name, event.name, event.decode
+	r.mu.RUnlock()
+
+	if len(handlers) == 0 {
+		return true
+	}
+
+	decoded, err := decode(vLog)
+	if err != nil {
+		log.Printf("❌ 解析%s事件 %s 失败: %v", contractName, eventName, err)
+		return true
+	}
+
+	log.Printf("📥 %s 事件: %s 区块=%d 交易=%s", contractName, eventName, vLog.BlockNumber, vLog.TxHash.Hex())
+	for _, handler := range handlers {
+		handler(ctx, decoded, vLog)
+	}
+	return true
+}
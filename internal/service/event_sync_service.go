@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+// 检查点类型
+const (
+	NFTEventsKind     = "nft_events"     // NFT合约事件
+	AuctionEventsKind = "auction_events" // 拍卖合约事件
+
+	CollectionEventsKind = "collection_events" // 第三方合集事件（每个合约一个检查点）
+)
+
+// EventSyncService 管理每个合约的区块检查点（event_syncs 表）
+type EventSyncService struct {
+	DB *gorm.DB
+}
+
+// NewEventSyncService 创建检查点服务
+func NewEventSyncService(db *gorm.DB) *EventSyncService {
+	return &EventSyncService{DB: db}
+}
+
+// CheckpointKey 生成检查点键，例如 nft_events:0xabc...
+func CheckpointKey(kind string, contractAddr common.Address) string {
+	return kind + ":" + strings.ToLower(contractAddr.Hex())
+}
+
+// GetLastBlock 获取合约最后完整处理的区块
+// 返回值 found=false 表示该合约还没有检查点（首次启动）
+func (s *EventSyncService) GetLastBlock(ctx context.Context, kind string, contractAddr common.Address) (uint64, bool, error) {
+	var record model.EventSync
+	err := s.DB.WithContext(ctx).
+		Where("event_type = ?", CheckpointKey(kind, contractAddr)).
+		First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("查询检查点失败: %v", err)
+	}
+	return record.LastBlock, true, nil
+}
+
+// SaveLastBlock 保存检查点（只前进不后退）
+func (s *EventSyncService) SaveLastBlock(ctx context.Context, kind string, contractAddr common.Address, block uint64) error {
+	key := CheckpointKey(kind, contractAddr)
+
+	var record model.EventSync
+	err := s.DB.WithContext(ctx).Where("event_type = ?", key).First(&record).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("查询检查点失败: %v", err)
+		}
+		record = model.EventSync{
+			EventType:       key,
+			ContractAddress: contractAddr.Hex(),
+			LastBlock:       block,
+		}
+		if err := s.DB.WithContext(ctx).Create(&record).Error; err != nil {
+			return fmt.Errorf("创建检查点失败: %v", err)
+		}
+		return nil
+	}
+
+	if block <= record.LastBlock {
+		return nil
+	}
+	if err := s.DB.WithContext(ctx).Model(&record).Update("last_block", block).Error; err != nil {
+		return fmt.Errorf("更新检查点失败: %v", err)
+	}
+	return nil
+}
+
+// RewindLastBlock 回退检查点（链重组时使用），只在当前检查点高于 block 时生效
+func (s *EventSyncService) RewindLastBlock(ctx context.Context, kind string, contractAddr common.Address, block uint64) error {
+	err := s.DB.WithContext(ctx).Model(&model.EventSync{}).
+		Where("event_type = ? AND last_block > ?", CheckpointKey(kind, contractAddr), block).
+		Update("last_block", block).Error
+	if err != nil {
+		return fmt.Errorf("回退检查点失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/model"
+	"nft-auction-backend/pkg/rpcpool"
+)
+
+var syncContract = common.HexToAddress("0x5C")
+
+func lastBlock(t *testing.T, s *EventSyncService) (uint64, bool) {
+	t.Helper()
+	last, found, err := s.GetLastBlock(context.Background(), AuctionEventsKind, syncContract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return last, found
+}
+
+func TestSaveLastBlockOnlyAdvances(t *testing.T) {
+	s := NewEventSyncService(newTestDB(t))
+	ctx := context.Background()
+
+	if _, found := lastBlock(t, s); found {
+		t.Fatal("new contract should have no checkpoint")
+	}
+	for _, block := range []uint64{100, 120, 110} {
+		if err := s.SaveLastBlock(ctx, AuctionEventsKind, syncContract, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if last, _ := lastBlock(t, s); last != 120 {
+		t.Fatalf("checkpoint = %d, want 120 (never moves back)", last)
+	}
+
+	// 检查点按 (类型, 合约) 区分
+	if _, found, _ := s.GetLastBlock(ctx, NFTEventsKind, syncContract); found {
+		t.Fatal("nft checkpoint should be separate")
+	}
+}
+
+func TestRewindLastBlock(t *testing.T) {
+	s := NewEventSyncService(newTestDB(t))
+	ctx := context.Background()
+
+	// 没有检查点时不创建
+	if err := s.RewindLastBlock(ctx, AuctionEventsKind, syncContract, 50); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := lastBlock(t, s); found {
+		t.Fatal("rewind created a checkpoint")
+	}
+
+	if err := s.SaveLastBlock(ctx, AuctionEventsKind, syncContract, 120); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RewindLastBlock(ctx, AuctionEventsKind, syncContract, 100); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := lastBlock(t, s); last != 100 {
+		t.Fatalf("checkpoint = %d, want 100", last)
+	}
+	// 回退只降不升
+	if err := s.RewindLastBlock(ctx, AuctionEventsKind, syncContract, 110); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := lastBlock(t, s); last != 100 {
+		t.Fatalf("checkpoint = %d, want 100 after rewind above it", last)
+	}
+}
+
+// 收到区块N的日志：检查点推进到 N-1，并用缓存的区块头记录 N-1 的哈希；被移除的日志不推进
+func TestMarkProcessedAdvancesCheckpoint(t *testing.T) {
+	db := newTestDB(t)
+	l := &BlockchainListener{
+		ctx:          context.Background(),
+		eventSync:    NewEventSyncService(db),
+		reorgService: NewReorgService(db, 6),
+		headerCache:  lru.NewCache[common.Hash, *types.Header](headerCacheSize),
+	}
+	header := &types.Header{Number: big.NewInt(10), ParentHash: common.HexToHash("0xb9")}
+	l.headerCache.Add(header.Hash(), header)
+
+	l.markProcessed(AuctionEventsKind, syncContract, types.Log{BlockNumber: 10, BlockHash: header.Hash()})
+	l.markProcessed(AuctionEventsKind, syncContract, types.Log{BlockNumber: 30, Removed: true})
+	l.markProcessed(AuctionEventsKind, syncContract, types.Log{BlockNumber: 5})
+
+	if last, _ := lastBlock(t, l.eventSync); last != 9 {
+		t.Fatalf("checkpoint = %d, want 9", last)
+	}
+	var recorded []model.ProcessedBlock
+	db.Find(&recorded)
+	if len(recorded) != 1 || recorded[0].Number != 9 || recorded[0].Hash != header.ParentHash.Hex() {
+		t.Fatalf("recorded blocks: %+v", recorded)
+	}
+}
+
+// fakeLogNode JSON-RPC 节点：最新区块 head，eth_getLogs 按区间返回 logs，区块哈希由高度推导
+type fakeLogNode struct {
+	mu     sync.Mutex
+	head   uint64
+	logs   []types.Log
+	ranges [][2]uint64 // eth_getLogs 查询过的区间
+}
+
+func blockHash(number uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(0xb000 + number))
+}
+
+func (n *fakeLogNode) serve(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := n.handle(req.Method, req.Params)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32000,"message":%q}}`, req.ID, err.Error())
+			return
+		}
+		body, _ := json.Marshal(result)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func (n *fakeLogNode) handle(method string, params []json.RawMessage) (interface{}, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch method {
+	case "eth_blockNumber":
+		return fmt.Sprintf("0x%x", n.head), nil
+	case "eth_getBlockByNumber":
+		var tag string
+		if err := json.Unmarshal(params[0], &tag); err != nil {
+			return nil, err
+		}
+		number := n.head
+		if tag != "latest" {
+			parsed, err := strconv.ParseUint(strings.TrimPrefix(tag, "0x"), 16, 64)
+			if err != nil {
+				return nil, err
+			}
+			number = parsed
+		}
+		return &types.Header{
+			Number:     new(big.Int).SetUint64(number),
+			ParentHash: blockHash(number - 1),
+			Difficulty: big.NewInt(0),
+			Time:       1700000000 + number*12,
+		}, nil
+	case "eth_getLogs":
+		var query struct {
+			FromBlock string `json:"fromBlock"`
+			ToBlock   string `json:"toBlock"`
+		}
+		if err := json.Unmarshal(params[0], &query); err != nil {
+			return nil, err
+		}
+		from, _ := strconv.ParseUint(strings.TrimPrefix(query.FromBlock, "0x"), 16, 64)
+		to, _ := strconv.ParseUint(strings.TrimPrefix(query.ToBlock, "0x"), 16, 64)
+		n.ranges = append(n.ranges, [2]uint64{from, to})
+		logs := []types.Log{}
+		for _, vLog := range n.logs {
+			if vLog.BlockNumber >= from && vLog.BlockNumber <= to {
+				logs = append(logs, vLog)
+			}
+		}
+		return logs, nil
+	}
+	return nil, fmt.Errorf("method %s not supported", method)
+}
+
+func newBackfillListener(t *testing.T, node *fakeLogNode, startBlock uint64) *BlockchainListener {
+	t.Helper()
+	pool, err := rpcpool.NewPool(config.BlockchainConfig{RPCURL: node.serve(t), HealthCheckInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	db := newTestDB(t)
+	return &BlockchainListener{
+		ctx:               context.Background(),
+		ethClient:         pool,
+		eventSync:         NewEventSyncService(db),
+		reorgService:      NewReorgService(db, 6),
+		headerCache:       lru.NewCache[common.Hash, *types.Header](headerCacheSize),
+		startBlock:        startBlock,
+		backfillBatchSize: 10,
+	}
+}
+
+// 回填按批次拉取日志，每批处理完后推进检查点；下次从检查点之后继续
+func TestBackfillAdvancesCheckpointPerBatch(t *testing.T) {
+	node := &fakeLogNode{head: 125}
+	for _, block := range []uint64{101, 112, 125} {
+		node.logs = append(node.logs, types.Log{
+			Address:     syncContract,
+			Topics:      []common.Hash{common.HexToHash("0x01")},
+			BlockNumber: block,
+			BlockHash:   blockHash(block),
+			TxHash:      common.BigToHash(new(big.Int).SetUint64(block)),
+		})
+	}
+	l := newBackfillListener(t, node, 100)
+
+	var dispatched []uint64
+	var checkpoints []uint64
+	dispatch := func(vLog types.Log) {
+		dispatched = append(dispatched, vLog.BlockNumber)
+		last, _ := lastBlock(t, l.eventSync)
+		checkpoints = append(checkpoints, last)
+	}
+
+	head, err := l.backfill(AuctionEventsKind, syncContract, dispatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != 125 {
+		t.Fatalf("head = %d", head)
+	}
+	if want := [][2]uint64{{100, 109}, {110, 119}, {120, 125}}; !reflect.DeepEqual(node.ranges, want) {
+		t.Fatalf("queried %v, want %v", node.ranges, want)
+	}
+	if !reflect.DeepEqual(dispatched, []uint64{101, 112, 125}) {
+		t.Fatalf("dispatched %v", dispatched)
+	}
+	// 日志处理时检查点停在上一批末尾
+	if !reflect.DeepEqual(checkpoints, []uint64{0, 109, 119}) {
+		t.Fatalf("checkpoint while dispatching %v, want [0 109 119]", checkpoints)
+	}
+	if last, _ := lastBlock(t, l.eventSync); last != 125 {
+		t.Fatalf("checkpoint = %d, want 125", last)
+	}
+	var recorded model.ProcessedBlock
+	if err := l.reorgService.DB.Where("number = ?", 125).First(&recorded).Error; err != nil {
+		t.Fatalf("checkpoint block hash not recorded: %v", err)
+	}
+
+	// 新区块：只回填检查点之后的部分
+	node.mu.Lock()
+	node.head = 130
+	node.ranges = nil
+	node.mu.Unlock()
+	if _, err := l.backfill(AuctionEventsKind, syncContract, dispatch); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][2]uint64{{126, 130}}; !reflect.DeepEqual(node.ranges, want) {
+		t.Fatalf("second backfill queried %v, want %v", node.ranges, want)
+	}
+}
+
+// 没有检查点也没有起始区块：直接从最新区块开始记录，不回填历史
+func TestBackfillWithoutCheckpointStartsAtHead(t *testing.T) {
+	node := &fakeLogNode{head: 500}
+	l := newBackfillListener(t, node, 0)
+
+	if _, err := l.backfill(AuctionEventsKind, syncContract, func(types.Log) { t.Fatal("unexpected log") }); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.ranges) != 0 {
+		t.Fatalf("queried logs %v", node.ranges)
+	}
+	if last, _ := lastBlock(t, l.eventSync); last != 500 {
+		t.Fatalf("checkpoint = %d, want 500", last)
+	}
+}
+
+// 订阅模式没有日志时按最新区块推进检查点：推进到上一次看到的最新区块（给在途日志留一个间隔）
+func TestAdvanceCheckpointFollowsHead(t *testing.T) {
+	node := &fakeLogNode{head: 200}
+	l := newBackfillListener(t, node, 0)
+	ctx := context.Background()
+	if err := l.eventSync.SaveLastBlock(ctx, AuctionEventsKind, syncContract, 150); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次只记录最新区块
+	seen := l.advanceCheckpoint(AuctionEventsKind, syncContract, 0)
+	if last, _ := lastBlock(t, l.eventSync); seen != 200 || last != 150 {
+		t.Fatalf("seen=%d checkpoint=%d, want 200/150", seen, last)
+	}
+
+	node.mu.Lock()
+	node.head = 205
+	node.mu.Unlock()
+	seen = l.advanceCheckpoint(AuctionEventsKind, syncContract, seen)
+	if last, _ := lastBlock(t, l.eventSync); seen != 205 || last != 200 {
+		t.Fatalf("seen=%d checkpoint=%d, want 205/200", seen, last)
+	}
+	var recorded model.ProcessedBlock
+	if err := l.reorgService.DB.Where("number = ?", 200).First(&recorded).Error; err != nil || recorded.Hash == "" {
+		t.Fatalf("checkpoint block not recorded: %+v %v", recorded, err)
+	}
+
+	// 日志已把检查点推过 seenHead 时不回退
+	if err := l.eventSync.SaveLastBlock(ctx, AuctionEventsKind, syncContract, 210); err != nil {
+		t.Fatal(err)
+	}
+	l.advanceCheckpoint(AuctionEventsKind, syncContract, seen)
+	if last, _ := lastBlock(t, l.eventSync); last != 210 {
+		t.Fatalf("checkpoint = %d, want 210", last)
+	}
+}
@@ -0,0 +1,201 @@
+// expiry_scheduler.go
+package service
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"nft-auction-backend/internal/model"
+)
+
+// expiryRetryDelay 状态更新失败后的重试间隔（秒）
+const expiryRetryDelay = 30
+
+// expiryItem 待过期的拍卖
+type expiryItem struct {
+	auctionID uint64
+	endTime   uint64
+	index     int // 在堆中的位置
+}
+
+// expiryHeap 按结束时间排序的最小堆
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].endTime < h[j].endTime }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+// ExpiryScheduler 拍卖过期调度器
+// 跟踪未结束拍卖的 EndTime，到期时立即把状态切换为 expired_pending_settlement
+type ExpiryScheduler struct {
+	auctionService *AuctionService
+
+	mu    sync.Mutex
+	queue expiryHeap
+	items map[uint64]*expiryItem // auctionID -> 堆元素
+	wake  chan struct{}          // 堆顶变化时唤醒调度循环
+}
+
+// NewExpiryScheduler 创建过期调度器，并注册到拍卖服务（拍卖保存时自动更新调度）
+func NewExpiryScheduler(auctionSvc *AuctionService) *ExpiryScheduler {
+	s := &ExpiryScheduler{
+		auctionService: auctionSvc,
+		items:          make(map[uint64]*expiryItem),
+		wake:           make(chan struct{}, 1),
+	}
+	auctionSvc.SetExpiryScheduler(s)
+	return s
+}
+
+// Start 从数据库加载未结束的拍卖并启动调度循环
+func (s *ExpiryScheduler) Start(ctx context.Context) error {
+	var auctions []model.Auction
+	err := s.auctionService.DB.WithContext(ctx).
+		Where("ended = ? AND end_time > 0 AND chain_state <> ?", false, model.ChainStateOrphaned).
+		Find(&auctions).Error
+	if err != nil {
+		return err
+	}
+
+	for _, auction := range auctions {
+		s.Schedule(auction.AuctionID, auction.EndTime)
+	}
+	log.Printf("⏰ 过期调度器启动，跟踪 %d 个未结束拍卖", len(auctions))
+
+	go s.run(ctx)
+	return nil
+}
+
+// Schedule 添加或更新拍卖的结束时间
+func (s *ExpiryScheduler) Schedule(auctionID, endTime uint64) {
+	if endTime == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	if item, ok := s.items[auctionID]; ok {
+		item.endTime = endTime
+		heap.Fix(&s.queue, item.index)
+	} else {
+		item := &expiryItem{auctionID: auctionID, endTime: endTime}
+		heap.Push(&s.queue, item)
+		s.items[auctionID] = item
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Cancel 取消跟踪（拍卖已结束或被重组移除）
+func (s *ExpiryScheduler) Cancel(auctionID uint64) {
+	s.mu.Lock()
+	if item, ok := s.items[auctionID]; ok {
+		heap.Remove(&s.queue, item.index)
+		delete(s.items, auctionID)
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Pending 当前跟踪的拍卖数
+func (s *ExpiryScheduler) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// NextExpiry 最近一个到期时间（没有则返回0）
+func (s *ExpiryScheduler) NextExpiry() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return 0
+	}
+	return s.queue[0].endTime
+}
+
+func (s *ExpiryScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 调度循环：等待到堆顶拍卖的结束时间，然后处理所有到期拍卖
+func (s *ExpiryScheduler) run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.processDue(ctx)
+
+		// 计算下一次唤醒时间
+		wait := time.Hour
+		if next := s.NextExpiry(); next > 0 {
+			wait = time.Until(time.Unix(int64(next), 0))
+			if wait < 0 {
+				wait = 0
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			log.Println("⏰ 过期调度器已停止")
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// processDue 弹出所有已到期的拍卖并更新状态
+func (s *ExpiryScheduler) processDue(ctx context.Context) {
+	now := uint64(time.Now().Unix())
+
+	var due []uint64
+	s.mu.Lock()
+	for len(s.queue) > 0 && s.queue[0].endTime <= now {
+		item := heap.Pop(&s.queue).(*expiryItem)
+		delete(s.items, item.auctionID)
+		due = append(due, item.auctionID)
+	}
+	s.mu.Unlock()
+
+	for _, auctionID := range due {
+		if err := s.auctionService.MarkAuctionExpired(ctx, auctionID); err != nil {
+			log.Printf("❌ 拍卖 #%d 过期状态更新失败，稍后重试: %v", auctionID, err)
+			s.Schedule(auctionID, now+expiryRetryDelay)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// image_service.go
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器（只取第一帧）
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/model"
+)
+
+var (
+	// ErrImageNotCached 图片还未下载完成（pending）或下载失败（failed）
+	ErrImageNotCached = errors.New("图片尚未缓存")
+	// ErrInvalidImageSize ?size= 不是配置的缩略图尺寸
+	ErrInvalidImageSize = errors.New("invalid image size")
+
+	// errUnsupportedImage 类型不支持或超过像素限制，重试也不会成功
+	errUnsupportedImage = errors.New("不支持的图片")
+)
+
+// supportedImageTypes 允许缓存的图片类型（按文件内容识别，不信任响应头）
+var supportedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// ImageService NFT图片缓存
+//
+//	元数据抓取成功（image 非空） → nft_images 置为 pending
+//	    → 下载任务按 next_attempt_at 扫描 → 解析 image 地址（ipfs:// ar:// https:// data:）
+//	    → 校验大小与类型（PNG/JPEG/GIF） → 按 SHA-256 保存原图 → 生成各尺寸缩略图
+//	    └─ 失败：按退避时间重试；类型不支持直接标记为 failed
+type ImageService struct {
+	DB       *gorm.DB
+	resolver *MetadataResolver
+	store    *ImageStore
+	cfg      config.ImageConfig
+}
+
+// NewImageService 创建图片缓存服务（与元数据抓取共用网关配置）
+func NewImageService(db *gorm.DB, cfg config.ImageConfig, resolver *MetadataResolver) *ImageService {
+	if cfg.Dir == "" {
+		cfg.Dir = "./data/images"
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 20 << 20
+	}
+	if cfg.MaxPixels <= 0 {
+		cfg.MaxPixels = 50_000_000
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 6
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = 2 * time.Minute
+	}
+	if cfg.BackoffMax < cfg.BackoffBase {
+		cfg.BackoffMax = cfg.BackoffBase
+	}
+	sizes := make([]int, 0, len(cfg.Sizes))
+	for _, size := range cfg.Sizes {
+		if size > 0 {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Ints(sizes)
+	cfg.Sizes = sizes
+
+	return &ImageService{
+		DB:       db,
+		resolver: resolver,
+		store:    NewImageStore(cfg.Dir),
+		cfg:      cfg,
+	}
+}
+
+// Sizes 可用的缩略图尺寸
+func (s *ImageService) Sizes() []int {
+	return s.cfg.Sizes
+}
+
+// Enqueue 记录待下载的图片；地址未变化且不是 failed 的不重复下载
+func (s *ImageService) Enqueue(ctx context.Context, contractAddr, tokenID, uri string) error {
+	if uri == "" {
+		return nil
+	}
+
+	var existing model.NFTImage
+	err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID).
+		First(&existing).Error
+	if err == nil && existing.SourceURI == uri && existing.Status != model.ImageStatusFailed {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询图片缓存失败: %v", err)
+	}
+
+	img := &model.NFTImage{
+		ContractAddress: contractAddr,
+		TokenID:         tokenID,
+		SourceURI:       uri,
+		Status:          model.ImageStatusPending,
+		NextAttemptAt:   time.Now(),
+	}
+	err = s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "contract_address"}, {Name: "token_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"source_uri":      uri,
+			"status":          model.ImageStatusPending,
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": img.NextAttemptAt,
+			"updated_at":      time.Now(),
+		}),
+	}).Create(img).Error
+	if err != nil {
+		return fmt.Errorf("保存待下载图片失败: %v", err)
+	}
+	return nil
+}
+
+// EnqueueMissing 为已抓取元数据但还没有图片缓存记录（或图片地址已变化）的NFT补充下载任务
+func (s *ImageService) EnqueueMissing(ctx context.Context) (int, error) {
+	var metadata []model.TokenMetadata
+	err := s.DB.WithContext(ctx).
+		Select("contract_address, token_id, image").
+		Where("status = ? AND image <> ''", model.MetadataStatusFetched).
+		Where("NOT EXISTS (SELECT 1 FROM nft_images i WHERE i.contract_address = token_metadata.contract_address AND i.token_id = token_metadata.token_id AND i.source_uri = token_metadata.image)").
+		Find(&metadata).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待下载图片失败: %v", err)
+	}
+	for _, m := range metadata {
+		if err := s.Enqueue(ctx, m.ContractAddress, m.TokenID, m.Image); err != nil {
+			return 0, err
+		}
+	}
+	return len(metadata), nil
+}
+
+// Start 启动下载循环
+func (s *ImageService) Start(ctx context.Context) {
+	go func() {
+		if count, err := s.EnqueueMissing(ctx); err != nil {
+			log.Printf("❌ %v", err)
+		} else if count > 0 {
+			log.Printf("🖼️ %d 张图片加入下载队列", count)
+		}
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.ProcessDue(ctx); err != nil {
+				log.Printf("❌ 图片下载失败: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Println("🛑 图片缓存已停止")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ProcessDue 下载到期的 pending 图片，返回成功数量
+func (s *ImageService) ProcessDue(ctx context.Context) (int, error) {
+	var due []model.NFTImage
+	err := s.DB.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", model.ImageStatusPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(s.cfg.BatchSize).
+		Find(&due).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待下载图片失败: %v", err)
+	}
+
+	cached := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.Cache(ctx, &due[i]); err != nil {
+			log.Printf("⚠️ 缓存图片 %s/%s 失败（第 %d 次）: %v",
+				due[i].ContractAddress, due[i].TokenID, due[i].Attempts, err)
+			continue
+		}
+		cached++
+	}
+	return cached, nil
+}
+
+// Cache 下载并保存单张图片与缩略图；失败时按退避时间安排下一次重试
+func (s *ImageService) Cache(ctx context.Context, img *model.NFTImage) error {
+	err := s.download(ctx, img)
+	if err == nil {
+		result := s.DB.WithContext(ctx).Model(&model.NFTImage{}).
+			Where("id = ? AND source_uri = ?", img.ID, img.SourceURI).
+			Updates(map[string]interface{}{
+				"content_hash": img.ContentHash,
+				"content_type": img.ContentType,
+				"bytes":        img.Bytes,
+				"width":        img.Width,
+				"height":       img.Height,
+				"status":       model.ImageStatusCached,
+				"attempts":     0,
+				"last_error":   "",
+				"cached_at":    time.Now(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("保存图片记录失败: %v", result.Error)
+		}
+		log.Printf("🖼️ 图片已缓存: %s/%s %s %dx%d",
+			img.ContractAddress, img.TokenID, img.ContentType, img.Width, img.Height)
+		return nil
+	}
+
+	img.Attempts++
+	updates := map[string]interface{}{
+		"attempts":        img.Attempts,
+		"last_error":      err.Error(),
+		"next_attempt_at": time.Now().Add(retryBackoff(s.cfg.BackoffBase, s.cfg.BackoffMax, img.Attempts)),
+	}
+	if img.Attempts >= s.cfg.MaxAttempts || errors.Is(err, errUnsupportedImage) {
+		updates["status"] = model.ImageStatusFailed
+	}
+	// 下载期间图片地址已变化（重新入队）时不覆盖新任务的状态
+	if dbErr := s.DB.WithContext(ctx).Model(&model.NFTImage{}).
+		Where("id = ? AND source_uri = ?", img.ID, img.SourceURI).
+		Updates(updates).Error; dbErr != nil {
+		return fmt.Errorf("%v（记录失败次数出错: %v）", err, dbErr)
+	}
+	return err
+}
+
+// download 下载原图，校验后写入存储并生成缩略图（填充 img 的哈希、类型与尺寸）
+func (s *ImageService) download(ctx context.Context, img *model.NFTImage) error {
+	body, err := s.resolver.ResolveMedia(ctx, img.SourceURI, s.cfg.MaxBytes)
+	if err != nil {
+		return err
+	}
+
+	contentType := http.DetectContentType(body)
+	if !supportedImageTypes[contentType] {
+		return fmt.Errorf("%w: 类型 %s", errUnsupportedImage, contentType)
+	}
+	conf, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnsupportedImage, err)
+	}
+	if int64(conf.Width)*int64(conf.Height) > s.cfg.MaxPixels {
+		return fmt.Errorf("%w: %dx%d 超过 %d 像素", errUnsupportedImage, conf.Width, conf.Height, s.cfg.MaxPixels)
+	}
+
+	sum := sha256.Sum256(body)
+	img.ContentHash = hex.EncodeToString(sum[:])
+	img.ContentType = contentType
+	img.Bytes = int64(len(body))
+	img.Width, img.Height = conf.Width, conf.Height
+
+	if err := s.store.Write(s.store.Path(img.ContentHash, 0, imageExt(contentType)), func(w io.Writer) error {
+		_, err := w.Write(body)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnsupportedImage, err)
+	}
+	for _, size := range s.cfg.Sizes {
+		if err := s.writeThumbnail(img, decoded, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeThumbnail 生成单个尺寸的缩略图（已存在时跳过）
+func (s *ImageService) writeThumbnail(img *model.NFTImage, decoded image.Image, size int) error {
+	path := s.store.Path(img.ContentHash, size, thumbnailExt(img.ContentType))
+	return s.store.Write(path, func(w io.Writer) error {
+		if err := encodeThumbnail(w, resizeToFit(decoded, size), img.ContentType); err != nil {
+			return fmt.Errorf("生成 %d 缩略图失败: %v", size, err)
+		}
+		return nil
+	})
+}
+
+// ImageFile 获取已缓存图片的文件路径；size 为 0 表示原图
+// 缩略图文件缺失时（如新增了尺寸配置）从原图重新生成
+func (s *ImageService) ImageFile(ctx context.Context, contractAddr, tokenID string, size int) (string, *model.NFTImage, error) {
+	if size != 0 && !slices.Contains(s.cfg.Sizes, size) {
+		return "", nil, ErrInvalidImageSize
+	}
+
+	var img model.NFTImage
+	if err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID).
+		First(&img).Error; err != nil {
+		return "", nil, err
+	}
+	if img.Status != model.ImageStatusCached {
+		return "", &img, ErrImageNotCached
+	}
+
+	original := s.store.Path(img.ContentHash, 0, imageExt(img.ContentType))
+	if size == 0 {
+		return original, &img, nil
+	}
+
+	path := s.store.Path(img.ContentHash, size, thumbnailExt(img.ContentType))
+	if s.store.Exists(path) {
+		return path, &img, nil
+	}
+	f, err := os.Open(original)
+	if err != nil {
+		return "", &img, fmt.Errorf("读取原图失败: %v", err)
+	}
+	defer f.Close()
+	decoded, _, err := image.Decode(f)
+	if err != nil {
+		return "", &img, fmt.Errorf("解码原图失败: %v", err)
+	}
+	if err := s.writeThumbnail(&img, decoded, size); err != nil {
+		return "", &img, err
+	}
+	return path, &img, nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/model"
+)
+
+func pngBytes(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newImageFixture 本地图片服务器：/small.png 40x20，/huge.png 200x200，
+// /page.html HTML，/fake.png 响应头声称 PNG 实际是文本，/broken 返回 500
+func newImageFixture(t *testing.T) (*ImageService, string) {
+	t.Helper()
+	small, huge := pngBytes(t, 40, 20), pngBytes(t, 200, 200)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/small.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(small)
+		case "/huge.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(huge)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>not an image</body></html>"))
+		case "/fake.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("plain text pretending to be a png"))
+		default:
+			http.Error(w, "gateway error", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	s := NewImageService(newTestDB(t), config.ImageConfig{
+		Dir:         t.TempDir(),
+		Sizes:       []int{16},
+		MaxPixels:   10_000,
+		MaxAttempts: 3,
+	}, newTestResolver(nil, "", 0))
+	return s, srv.URL
+}
+
+// 类型按内容识别（不信任响应头），超过像素限制的图片不解码
+func TestImageDownloadValidatesTypeAndPixels(t *testing.T) {
+	s, base := newImageFixture(t)
+	ctx := context.Background()
+
+	for _, path := range []string{"/page.html", "/fake.png", "/huge.png"} {
+		img := &model.NFTImage{SourceURI: base + path}
+		if err := s.download(ctx, img); !errors.Is(err, errUnsupportedImage) {
+			t.Errorf("%s: err = %v, want unsupported image", path, err)
+		}
+		if img.ContentHash != "" {
+			t.Errorf("%s: rejected image was stored as %s", path, img.ContentHash)
+		}
+	}
+
+	img := &model.NFTImage{SourceURI: base + "/small.png"}
+	if err := s.download(ctx, img); err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Width != 40 || img.Height != 20 || img.Bytes == 0 {
+		t.Fatalf("downloaded image: %+v", img)
+	}
+	for _, size := range []int{0, 16} {
+		path := s.store.Path(img.ContentHash, size, thumbnailExt(img.ContentType))
+		if size == 0 {
+			path = s.store.Path(img.ContentHash, 0, imageExt(img.ContentType))
+		}
+		if !s.store.Exists(path) {
+			t.Errorf("size %d not written to %s", size, path)
+		}
+	}
+}
+
+func enqueueImage(t *testing.T, s *ImageService, uri string) *model.NFTImage {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.Enqueue(ctx, alice, "1", uri); err != nil {
+		t.Fatal(err)
+	}
+	var img model.NFTImage
+	if err := s.DB.Where("contract_address = ? AND token_id = ?", alice, "1").First(&img).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &img
+}
+
+func storedImage(t *testing.T, s *ImageService) model.NFTImage {
+	t.Helper()
+	var img model.NFTImage
+	if err := s.DB.Where("contract_address = ? AND token_id = ?", alice, "1").First(&img).Error; err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// 不支持的图片直接标记为 failed；网关错误按退避重试
+func TestCacheFailurePaths(t *testing.T) {
+	s, base := newImageFixture(t)
+	ctx := context.Background()
+
+	if err := s.Cache(ctx, enqueueImage(t, s, base+"/broken")); err == nil {
+		t.Fatal("broken gateway should fail")
+	}
+	if got := storedImage(t, s); got.Status != model.ImageStatusPending || got.Attempts != 1 || got.LastError == "" {
+		t.Fatalf("after gateway error: status=%s attempts=%d", got.Status, got.Attempts)
+	}
+
+	if err := s.Cache(ctx, enqueueImage(t, s, base+"/huge.png")); !errors.Is(err, errUnsupportedImage) {
+		t.Fatalf("err = %v, want unsupported image", err)
+	}
+	if got := storedImage(t, s); got.Status != model.ImageStatusFailed || got.Attempts != 1 {
+		t.Fatalf("after unsupported image: status=%s attempts=%d", got.Status, got.Attempts)
+	}
+}
+
+// 下载期间图片地址变化（重新入队）：旧任务的结果不覆盖新任务
+func TestCacheKeepsRequeuedImage(t *testing.T) {
+	s, base := newImageFixture(t)
+	ctx := context.Background()
+
+	for _, path := range []string{"/page.html", "/small.png"} {
+		stale := enqueueImage(t, s, base+path)
+		enqueueImage(t, s, base+"/new.png")
+
+		s.Cache(ctx, stale)
+		got := storedImage(t, s)
+		if got.SourceURI != base+"/new.png" || got.Status != model.ImageStatusPending || got.Attempts != 0 || got.ContentHash != "" {
+			t.Fatalf("%s: requeued image overwritten: %+v", path, got)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// image_store.go
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ImageStore 按内容哈希保存图片的本地目录
+//
+//	<dir>/ab/abcdef....png       原图
+//	<dir>/ab/abcdef..._128.png   缩略图（最长边128像素）
+type ImageStore struct {
+	dir string
+}
+
+// NewImageStore 创建图片存储
+func NewImageStore(dir string) *ImageStore {
+	return &ImageStore{dir: dir}
+}
+
+// Path 图片文件路径；size 为 0 表示原图
+func (s *ImageStore) Path(hash string, size int, ext string) string {
+	name := hash + ext
+	if size > 0 {
+		name = fmt.Sprintf("%s_%d%s", hash, size, ext)
+	}
+	return filepath.Join(s.dir, hash[:2], name)
+}
+
+// Exists 文件是否已存在
+func (s *ImageStore) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Write 写入文件（先写临时文件再重命名，已存在时跳过：内容由哈希决定）
+func (s *ImageStore) Write(path string, write func(io.Writer) error) error {
+	if s.Exists(path) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建图片目录失败: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入图片失败: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("保存图片失败: %v", err)
+	}
+	return nil
+}
+
+// ==================== 缩略图 ====================
+
+// imageExt 原图扩展名
+func imageExt(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	}
+	return ".png"
+}
+
+// thumbnailExt 缩略图扩展名：JPEG 保持 JPEG，PNG/GIF（可能有透明通道）输出 PNG
+func thumbnailExt(contentType string) string {
+	if contentType == "image/jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// encodeThumbnail 按缩略图格式编码
+func encodeThumbnail(w io.Writer, img image.Image, contentType string) error {
+	if contentType == "image/jpeg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(w, img)
+}
+
+// resizeToFit 等比缩小到最长边不超过 size（不放大），每个目标像素取对应源区域的平均值
+func resizeToFit(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+
+	dw, dh := size, size
+	if w >= h {
+		dh = max(1, h*size/w)
+	} else {
+		dw = max(1, w*size/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		sy1 = max(sy1, sy0+1)
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			sx1 = max(sx1, sx0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
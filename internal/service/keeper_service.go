@@ -0,0 +1,478 @@
+// keeper_service.go
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+// keeperReceiptPollInterval 查询交易回执的间隔
+const keeperReceiptPollInterval = 3 * time.Second
+
+// errReceiptTimeout 等待回执超时（需要提高gas重发）
+var errReceiptTimeout = errors.New("等待交易上链超时")
+
+// KeeperBackend 自动结算需要的链上能力（RPC连接池已实现）
+type KeeperBackend interface {
+	contract.Backend
+	ChainID(ctx context.Context) (*big.Int, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error)
+}
+
+// KeeperService 自动结算服务：为到期拍卖调用 endAuction
+// 单协程顺序处理，nonce 在本地维护，超时未上链时用同一 nonce 提高gas替换
+type KeeperService struct {
+	DB             *gorm.DB
+	auctionService *AuctionService
+	backend        KeeperBackend
+	transactor     *contract.NftAuctionTransactor
+	cfg            config.KeeperConfig
+
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	chainID *big.Int
+
+	nonce       uint64 // 下一个可用 nonce
+	nonceLoaded bool
+}
+
+// NewKeeperService 创建自动结算服务（加载签名账户）
+func NewKeeperService(db *gorm.DB, auctionSvc *AuctionService, backend KeeperBackend, cfg config.KeeperConfig) (*KeeperService, error) {
+	key, err := loadKeeperKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	chainID, err := backend.ChainID(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("获取链ID失败: %v", err)
+	}
+
+	transactor, err := contract.NewNftAuctionTransactor(auctionSvc.GetContractAddress(), backend)
+	if err != nil {
+		return nil, fmt.Errorf("初始化拍卖合约Transactor失败: %v", err)
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.ReceiptTimeout <= 0 {
+		cfg.ReceiptTimeout = 2 * time.Minute
+	}
+	if cfg.GasBumpPercent < 10 {
+		cfg.GasBumpPercent = 10
+	}
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	log.Printf("✅ 自动结算账户: %s (链ID %s)", from.Hex(), chainID)
+
+	return &KeeperService{
+		DB:             db,
+		auctionService: auctionSvc,
+		backend:        backend,
+		transactor:     transactor,
+		cfg:            cfg,
+		key:            key,
+		from:           from,
+		chainID:        chainID,
+	}, nil
+}
+
+// loadKeeperKey 从 keystore 文件或环境变量加载私钥
+func loadKeeperKey(cfg config.KeeperConfig) (*ecdsa.PrivateKey, error) {
+	if cfg.KeystorePath != "" {
+		data, err := os.ReadFile(cfg.KeystorePath)
+		if err != nil {
+			return nil, fmt.Errorf("读取keystore失败: %v", err)
+		}
+		key, err := keystore.DecryptKey(data, os.Getenv(cfg.PasswordEnv))
+		if err != nil {
+			return nil, fmt.Errorf("解密keystore失败: %v", err)
+		}
+		return key.PrivateKey, nil
+	}
+
+	hexKey := strings.TrimPrefix(strings.TrimSpace(os.Getenv(cfg.PrivateKeyEnv)), "0x")
+	if hexKey == "" {
+		return nil, fmt.Errorf("未配置签名账户：请设置 keeper.keystore_path 或环境变量 %s", cfg.PrivateKeyEnv)
+	}
+	key, err := crypto.HexToECDSA(hexKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %v", err)
+	}
+	return key, nil
+}
+
+// Address 签名账户地址
+func (k *KeeperService) Address() common.Address {
+	return k.from
+}
+
+// Start 启动结算循环
+func (k *KeeperService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(k.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			k.settleExpired(ctx)
+
+			select {
+			case <-ctx.Done():
+				log.Println("🛑 自动结算已停止")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// settleExpired 结算所有到期拍卖
+func (k *KeeperService) settleExpired(ctx context.Context) {
+	auctions, err := k.auctionService.GetExpiredAuctions(ctx)
+	if err != nil {
+		log.Printf("❌ 查询待结算拍卖失败: %v", err)
+		return
+	}
+
+	for _, auction := range auctions {
+		if ctx.Err() != nil {
+			return
+		}
+
+		failed, err := k.failedAttempts(ctx, auction.AuctionID)
+		if err != nil {
+			log.Printf("❌ 查询拍卖 #%d 结算记录失败: %v", auction.AuctionID, err)
+			continue
+		}
+		if failed >= int64(k.cfg.MaxAttempts) {
+			continue
+		}
+		if k.hasPendingAttempt(ctx, auction.AuctionID) {
+			continue
+		}
+
+		if err := k.settle(ctx, auction.AuctionID); err != nil {
+			log.Printf("❌ 结算拍卖 #%d 失败 (%d/%d): %v", auction.AuctionID, failed+1, k.cfg.MaxAttempts, err)
+		}
+	}
+}
+
+// settle 为单个拍卖发送 endAuction，超时未上链时提高gas替换
+func (k *KeeperService) settle(ctx context.Context, auctionID uint64) error {
+	// 以链上状态为准：可能已被他人结算，或本地时钟早于区块时间
+	info, err := k.auctionService.GetAuctionInfo(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+	if info.Ended {
+		_, err := k.auctionService.ReconcileAuction(ctx, auctionID)
+		return err
+	}
+	if info.Status != contract.AuctionStatusExpired {
+		return nil
+	}
+
+	nonce, err := k.nextNonce(ctx)
+	if err != nil {
+		return err
+	}
+	tip, feeCap, err := k.suggestFees(ctx)
+	if err != nil {
+		return err
+	}
+
+	var sent []*model.SettlementAttempt
+	for bump := 0; ; bump++ {
+		attempt, err := k.send(ctx, auctionID, nonce, tip, feeCap)
+		if err != nil {
+			if len(sent) == 0 {
+				// 首次发送失败，nonce 未被占用
+				if isNonceError(err) {
+					k.nonceLoaded = false
+				}
+				return err
+			}
+			// 替换失败（如原交易刚好上链），继续等待已发送的交易
+			log.Printf("⚠️ 拍卖 #%d 提高gas重发失败: %v", auctionID, err)
+		} else {
+			// 替换交易已被节点接受，之前的交易不会再上链
+			for _, a := range sent {
+				k.updateAttempt(ctx, a, model.SettlementReplaced, nil, "")
+			}
+			sent = append(sent, attempt)
+			log.Printf("📤 拍卖 #%d 结算交易已发送: %s (nonce %d, tip %s, feeCap %s)",
+				auctionID, attempt.TxHash, nonce, tip, feeCap)
+		}
+
+		receipt, attempt, err := k.waitMined(ctx, sent)
+		if errors.Is(err, errReceiptTimeout) && bump < k.cfg.MaxGasBumps {
+			nextTip, tipOK := k.bumpFee(tip)
+			nextFeeCap, feeCapOK := k.bumpFee(feeCap)
+			if tipOK && feeCapOK {
+				tip, feeCap = nextTip, nextFeeCap
+				continue
+			}
+			// 已到gas费上限：同样费用的替换会被节点以 underpriced 拒绝，保留原交易等待上链
+			// 交易仍在交易池中时 hasPendingAttempt 会跳过该拍卖，不会重复发送
+			for _, a := range sent {
+				k.updateAttempt(ctx, a, model.SettlementSubmitted, nil, "已达gas费上限，等待上链")
+			}
+			k.nonceLoaded = false
+			return fmt.Errorf("已达gas费上限 %d gwei，停止替换，等待交易上链", k.cfg.MaxGasPriceGwei)
+		}
+		if err != nil {
+			// nonce 仍被未上链的交易占用，下次从节点重新读取
+			k.nonceLoaded = false
+			return err
+		}
+
+		// 已上链：nonce 被消耗
+		k.nonce = nonce + 1
+		for _, a := range sent {
+			if a != attempt {
+				k.updateAttempt(ctx, a, model.SettlementReplaced, nil, "")
+			}
+		}
+
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			k.updateAttempt(ctx, attempt, model.SettlementReverted, receipt, "交易执行失败")
+			return fmt.Errorf("结算交易 %s 执行失败", attempt.TxHash)
+		}
+
+		k.updateAttempt(ctx, attempt, model.SettlementMined, receipt, "")
+		log.Printf("✅ 拍卖 #%d 已结算: %s (区块 %d)", auctionID, attempt.TxHash, receipt.BlockNumber.Uint64())
+
+		if _, err := k.auctionService.ReconcileAuction(ctx, auctionID); err != nil {
+			log.Printf("⚠️ 结算后回读拍卖 #%d 失败: %v", auctionID, err)
+		}
+		return nil
+	}
+}
+
+// send 签名并广播 endAuction，记录每次尝试
+func (k *KeeperService) send(ctx context.Context, auctionID, nonce uint64, tip, feeCap *big.Int) (*model.SettlementAttempt, error) {
+	attempt := &model.SettlementAttempt{
+		AuctionID: auctionID,
+		Keeper:    k.from.Hex(),
+		Nonce:     nonce,
+		GasTipCap: tip.String(),
+		GasFeeCap: feeCap.String(),
+	}
+
+	opts, err := bind.NewKeyedTransactorWithChainID(k.key, k.chainID)
+	if err != nil {
+		return nil, fmt.Errorf("创建签名器失败: %v", err)
+	}
+	opts.Context = ctx
+	opts.Nonce = new(big.Int).SetUint64(nonce)
+	opts.GasTipCap = tip
+	opts.GasFeeCap = feeCap
+
+	tx, err := k.transactor.EndAuction(opts, new(big.Int).SetUint64(auctionID))
+	if err != nil {
+		attempt.Status = model.SettlementFailed
+		attempt.ErrorMessage = err.Error()
+		// 失败记录计入 MaxAttempts，保存失败时该拍卖会被无限重试
+		if dbErr := k.DB.WithContext(ctx).Create(attempt).Error; dbErr != nil {
+			log.Printf("⚠️ 保存结算记录失败: %v", dbErr)
+		}
+		return nil, err
+	}
+
+	attempt.TxHash = tx.Hash().Hex()
+	attempt.Status = model.SettlementSubmitted
+	if err := k.DB.WithContext(ctx).Create(attempt).Error; err != nil {
+		log.Printf("⚠️ 保存结算记录失败: %v", err)
+	}
+	return attempt, nil
+}
+
+// waitMined 等待任意一笔已发送的交易上链（同一 nonce 只会有一笔上链）
+func (k *KeeperService) waitMined(ctx context.Context, sent []*model.SettlementAttempt) (*types.Receipt, *model.SettlementAttempt, error) {
+	if len(sent) == 0 {
+		return nil, nil, errReceiptTimeout
+	}
+
+	deadline := time.NewTimer(k.cfg.ReceiptTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(keeperReceiptPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, attempt := range sent {
+			receipt, err := k.backend.TransactionReceipt(ctx, common.HexToHash(attempt.TxHash))
+			if err == nil && receipt != nil {
+				return receipt, attempt, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-deadline.C:
+			return nil, nil, errReceiptTimeout
+		case <-ticker.C:
+		}
+	}
+}
+
+// hasPendingAttempt 上一轮放弃等待的交易是否仍在交易池中
+// 已上链的会顺便更新状态，避免同一拍卖重复发送
+func (k *KeeperService) hasPendingAttempt(ctx context.Context, auctionID uint64) bool {
+	var submitted []model.SettlementAttempt
+	k.DB.WithContext(ctx).
+		Where("auction_id = ? AND status = ?", auctionID, model.SettlementSubmitted).
+		Find(&submitted)
+
+	pending := false
+	for i := range submitted {
+		attempt := &submitted[i]
+		hash := common.HexToHash(attempt.TxHash)
+
+		if receipt, err := k.backend.TransactionReceipt(ctx, hash); err == nil && receipt != nil {
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				k.updateAttempt(ctx, attempt, model.SettlementMined, receipt, "")
+			} else {
+				k.updateAttempt(ctx, attempt, model.SettlementReverted, receipt, "交易执行失败")
+			}
+			continue
+		}
+
+		if _, isPending, err := k.backend.TransactionByHash(ctx, hash); err == nil && isPending {
+			pending = true
+			continue
+		}
+		// 节点已不认识该交易（被替换或被丢弃）
+		k.updateAttempt(ctx, attempt, model.SettlementReplaced, nil, "交易已不在交易池中")
+	}
+	return pending
+}
+
+// updateAttempt 更新尝试状态
+func (k *KeeperService) updateAttempt(ctx context.Context, attempt *model.SettlementAttempt, status string, receipt *types.Receipt, message string) {
+	attempt.Status = status
+	attempt.ErrorMessage = message
+	if receipt != nil {
+		attempt.BlockNumber = receipt.BlockNumber.Uint64()
+		attempt.GasUsed = receipt.GasUsed
+	}
+	if err := k.DB.WithContext(ctx).Save(attempt).Error; err != nil {
+		log.Printf("⚠️ 更新结算记录失败: %v", err)
+	}
+}
+
+// nextNonce 下一个可用 nonce：取本地计数与节点 pending nonce 的较大值
+func (k *KeeperService) nextNonce(ctx context.Context) (uint64, error) {
+	pending, err := k.backend.PendingNonceAt(ctx, k.from)
+	if err != nil {
+		return 0, fmt.Errorf("获取nonce失败: %v", err)
+	}
+	if !k.nonceLoaded || pending > k.nonce {
+		k.nonce = pending
+		k.nonceLoaded = true
+	}
+	return k.nonce, nil
+}
+
+// suggestFees EIP-1559 费用：tip 取节点建议值，feeCap = 2*baseFee + tip
+func (k *KeeperService) suggestFees(ctx context.Context) (*big.Int, *big.Int, error) {
+	tip, err := k.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取gas小费建议失败: %v", err)
+	}
+	head, err := k.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取最新区块失败: %v", err)
+	}
+
+	feeCap := new(big.Int).Set(tip)
+	if head.BaseFee != nil {
+		feeCap.Add(feeCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+	return k.capFee(tip), k.capFee(feeCap), nil
+}
+
+// bumpFee 按配置百分比提高费用（至少+1 wei）
+// 被gas费上限截断时返回 false：节点要求替换交易按比例提高费用，截断后的替换会被拒绝
+func (k *KeeperService) bumpFee(fee *big.Int) (*big.Int, bool) {
+	bumped := new(big.Int).Mul(fee, big.NewInt(int64(100+k.cfg.GasBumpPercent)))
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(fee) <= 0 {
+		bumped.Add(fee, big.NewInt(1))
+	}
+	capped := k.capFee(bumped)
+	return capped, capped.Cmp(bumped) == 0
+}
+
+// capFee 应用gas费上限
+func (k *KeeperService) capFee(fee *big.Int) *big.Int {
+	if k.cfg.MaxGasPriceGwei == 0 {
+		return fee
+	}
+	max := new(big.Int).Mul(new(big.Int).SetUint64(k.cfg.MaxGasPriceGwei), big.NewInt(1e9))
+	if fee.Cmp(max) > 0 {
+		return max
+	}
+	return fee
+}
+
+// failedAttempts 拍卖的失败次数（被替换的交易不计入）
+func (k *KeeperService) failedAttempts(ctx context.Context, auctionID uint64) (int64, error) {
+	var count int64
+	err := k.DB.WithContext(ctx).Model(&model.SettlementAttempt{}).
+		Where("auction_id = ? AND status IN ?", auctionID, []string{model.SettlementFailed, model.SettlementReverted}).
+		Count(&count).Error
+	return count, err
+}
+
+// GetAttempts 查询结算记录（auctionID 为 nil 时返回全部）
+func (k *KeeperService) GetAttempts(ctx context.Context, auctionID *uint64, page, pageSize int) ([]model.SettlementAttempt, int64, error) {
+	var attempts []model.SettlementAttempt
+	var total int64
+
+	query := k.DB.WithContext(ctx).Model(&model.SettlementAttempt{})
+	if auctionID != nil {
+		query = query.Where("auction_id = ?", *auctionID)
+	}
+	query.Count(&total)
+
+	err := query.Offset((page - 1) * pageSize).Limit(pageSize).
+		Order("created_at DESC").
+		Find(&attempts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return attempts, total, nil
+}
+
+// isNonceError 节点返回的 nonce 相关错误
+func isNonceError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low") ||
+		strings.Contains(msg, "nonce too high") ||
+		strings.Contains(msg, "already known") ||
+		strings.Contains(msg, "replacement transaction underpriced")
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9))
+}
+
+func TestBumpFee(t *testing.T) {
+	k := &KeeperService{cfg: config.KeeperConfig{GasBumpPercent: 20, MaxGasPriceGwei: 100}}
+
+	cases := []struct {
+		name string
+		fee  *big.Int
+		want *big.Int
+		ok   bool
+	}{
+		{"below cap", gwei(50), gwei(60), true},
+		{"clamped by cap", gwei(90), gwei(100), false},
+		{"already at cap", gwei(100), gwei(100), false},
+		{"tiny fee bumps at least 1 wei", big.NewInt(1), big.NewInt(2), true},
+	}
+	for _, tc := range cases {
+		got, ok := k.bumpFee(tc.fee)
+		if got.Cmp(tc.want) != 0 || ok != tc.ok {
+			t.Errorf("%s: bumpFee(%s) = %s, %v; want %s, %v", tc.name, tc.fee, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestBumpFeeUncapped(t *testing.T) {
+	k := &KeeperService{cfg: config.KeeperConfig{GasBumpPercent: 10}}
+	got, ok := k.bumpFee(gwei(1000))
+	if !ok || got.Cmp(gwei(1100)) != 0 {
+		t.Fatalf("bumpFee = %s, %v; want 1100 gwei, true", got, ok)
+	}
+}
+
+var keeperAuctionAddr = common.HexToAddress("0xA0C7")
+
+// fakeAuctionContract 链上拍卖：所有拍卖都已到期未结算
+type fakeAuctionContract struct {
+	contract.AuctionContract
+	reads []uint64 // 读取过的拍卖ID
+}
+
+func (f *fakeAuctionContract) GetAuctionInfo(_ context.Context, auctionID *big.Int) (*contract.AuctionInfo, error) {
+	f.reads = append(f.reads, auctionID.Uint64())
+	return &contract.AuctionInfo{
+		AuctionID:  auctionID,
+		Duration:   big.NewInt(3600),
+		StartPrice: big.NewInt(1),
+		StartTime:  big.NewInt(1700000000),
+		EndTime:    big.NewInt(1700003600),
+		HighestBid: big.NewInt(0),
+		TokenID:    big.NewInt(1),
+		Status:     contract.AuctionStatusExpired,
+	}, nil
+}
+
+func (f *fakeAuctionContract) GetContractAddress() common.Address {
+	return keeperAuctionAddr
+}
+
+// fakeKeeperBackend 节点：sendErrs 依次作为 SendTransaction 的结果，发送成功的交易立即上链
+type fakeKeeperBackend struct {
+	KeeperBackend
+	pending  uint64
+	sendErrs []error
+	sent     []uint64 // 发送的 nonce（含失败）
+	mined    map[common.Hash]bool
+	inPool   map[common.Hash]bool
+}
+
+func (f *fakeKeeperBackend) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return f.pending, nil
+}
+
+func (f *fakeKeeperBackend) SuggestGasTipCap(context.Context) (*big.Int, error) {
+	return gwei(1), nil
+}
+
+func (f *fakeKeeperBackend) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(100), BaseFee: gwei(10)}, nil
+}
+
+func (f *fakeKeeperBackend) PendingCodeAt(context.Context, common.Address) ([]byte, error) {
+	return []byte{1}, nil
+}
+
+func (f *fakeKeeperBackend) EstimateGas(context.Context, ethereum.CallMsg) (uint64, error) {
+	return 100000, nil
+}
+
+func (f *fakeKeeperBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	f.sent = append(f.sent, tx.Nonce())
+	if len(f.sendErrs) > 0 {
+		err := f.sendErrs[0]
+		f.sendErrs = f.sendErrs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	f.mined[tx.Hash()] = true
+	return nil
+}
+
+func (f *fakeKeeperBackend) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	if !f.mined[hash] {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(101), GasUsed: 50000}, nil
+}
+
+func (f *fakeKeeperBackend) TransactionByHash(_ context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	if !f.inPool[hash] {
+		return nil, false, ethereum.NotFound
+	}
+	return nil, true, nil
+}
+
+func newKeeperFixture(t *testing.T, backend *fakeKeeperBackend) (*KeeperService, *fakeAuctionContract) {
+	t.Helper()
+	db := newTestDB(t)
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transactor, err := contract.NewNftAuctionTransactor(keeperAuctionAddr, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.mined == nil {
+		backend.mined = map[common.Hash]bool{}
+	}
+	auctions := &fakeAuctionContract{}
+	return &KeeperService{
+		DB:             db,
+		auctionService: &AuctionService{DB: db, AuctionContract: auctions},
+		backend:        backend,
+		transactor:     transactor,
+		cfg:            config.KeeperConfig{MaxAttempts: 3, ReceiptTimeout: time.Second, GasBumpPercent: 10},
+		key:            key,
+		from:           crypto.PubkeyToAddress(key.PublicKey),
+		chainID:        big.NewInt(11155111),
+	}, auctions
+}
+
+func attemptStatuses(t *testing.T, k *KeeperService, auctionID uint64) []string {
+	t.Helper()
+	var attempts []model.SettlementAttempt
+	if err := k.DB.Where("auction_id = ?", auctionID).Order("id").Find(&attempts).Error; err != nil {
+		t.Fatal(err)
+	}
+	statuses := make([]string, len(attempts))
+	for i, a := range attempts {
+		statuses[i] = fmt.Sprintf("%s@%d", a.Status, a.Nonce)
+	}
+	return statuses
+}
+
+// 发送失败的交易没有占用 nonce：下一次结算复用同一个 nonce
+func TestSettleReusesNonceAfterFailedSend(t *testing.T) {
+	backend := &fakeKeeperBackend{pending: 5, sendErrs: []error{errors.New("connection reset")}}
+	k, _ := newKeeperFixture(t, backend)
+	ctx := context.Background()
+
+	if err := k.settle(ctx, 1); err == nil {
+		t.Fatal("first settle should fail")
+	}
+	if err := k.settle(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(backend.sent, []uint64{5, 5}) {
+		t.Fatalf("sent nonces %v, want [5 5]", backend.sent)
+	}
+	if got := attemptStatuses(t, k, 1); !reflect.DeepEqual(got, []string{"failed@5", "mined@5"}) {
+		t.Fatalf("attempts %v", got)
+	}
+	if k.nonce != 6 {
+		t.Fatalf("next nonce %d, want 6", k.nonce)
+	}
+}
+
+// nonce 错误：本地计数作废，下次从节点重新读取
+func TestSettleReloadsNonceAfterNonceError(t *testing.T) {
+	backend := &fakeKeeperBackend{pending: 5, sendErrs: []error{errors.New("nonce too high")}}
+	k, _ := newKeeperFixture(t, backend)
+	k.nonce, k.nonceLoaded = 9, true
+	ctx := context.Background()
+
+	if err := k.settle(ctx, 1); err == nil {
+		t.Fatal("first settle should fail")
+	}
+	if err := k.settle(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backend.sent, []uint64{9, 5}) {
+		t.Fatalf("sent nonces %v, want [9 5]", backend.sent)
+	}
+}
+
+func TestHasPendingAttempt(t *testing.T) {
+	minedHash := common.HexToHash("0x01")
+	poolHash := common.HexToHash("0x02")
+	droppedHash := common.HexToHash("0x03")
+	backend := &fakeKeeperBackend{
+		mined:  map[common.Hash]bool{minedHash: true},
+		inPool: map[common.Hash]bool{poolHash: true},
+	}
+	k, _ := newKeeperFixture(t, backend)
+	ctx := context.Background()
+
+	seed := func(auctionID uint64, hash common.Hash) {
+		if err := k.DB.Create(&model.SettlementAttempt{AuctionID: auctionID, TxHash: hash.Hex(), Status: model.SettlementSubmitted}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed(1, minedHash)
+	seed(1, droppedHash)
+	seed(2, poolHash)
+
+	if k.hasPendingAttempt(ctx, 1) {
+		t.Fatal("auction 1 has no transaction left in the pool")
+	}
+	if got := attemptStatuses(t, k, 1); !reflect.DeepEqual(got, []string{"mined@0", "replaced@0"}) {
+		t.Fatalf("auction 1 attempts %v", got)
+	}
+	if !k.hasPendingAttempt(ctx, 2) {
+		t.Fatal("auction 2 transaction is still in the pool")
+	}
+	if got := attemptStatuses(t, k, 2); !reflect.DeepEqual(got, []string{"submitted@0"}) {
+		t.Fatalf("auction 2 attempts %v", got)
+	}
+}
+
+// 失败次数达到 MaxAttempts 的拍卖不再尝试（被替换的交易不计入）
+func TestSettleExpiredStopsAtMaxAttempts(t *testing.T) {
+	backend := &fakeKeeperBackend{pending: 1}
+	k, auctions := newKeeperFixture(t, backend)
+	ctx := context.Background()
+
+	for _, id := range []uint64{1, 2} {
+		a := model.Auction{AuctionID: id, Status: contract.AuctionStatusExpired, ChainState: model.ChainStateConfirmed}
+		if err := k.DB.Create(&a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < k.cfg.MaxAttempts; i++ {
+		status := model.SettlementFailed
+		if i == 0 {
+			status = model.SettlementReverted
+		}
+		k.DB.Create(&model.SettlementAttempt{AuctionID: 1, Status: status})
+		if i < k.cfg.MaxAttempts-1 {
+			k.DB.Create(&model.SettlementAttempt{AuctionID: 2, Status: model.SettlementFailed})
+		}
+	}
+	k.DB.Create(&model.SettlementAttempt{AuctionID: 2, Status: model.SettlementReplaced})
+
+	k.settleExpired(ctx)
+
+	for _, id := range auctions.reads {
+		if id != 2 {
+			t.Fatalf("auction %d read from chain after reaching MaxAttempts", id)
+		}
+	}
+	if !reflect.DeepEqual(backend.sent, []uint64{1}) || !reflect.DeepEqual(attemptStatuses(t, k, 2)[3:], []string{"mined@1"}) {
+		t.Fatalf("sent %v, attempts %v; want only auction 2 settled", backend.sent, attemptStatuses(t, k, 2))
+	}
+	if failed, _ := k.failedAttempts(ctx, 1); failed != int64(k.cfg.MaxAttempts) {
+		t.Fatalf("auction 1 failed attempts %d", failed)
+	}
+}
@@ -0,0 +1,141 @@
+// metadata_client.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// metadataMaxRedirects 元数据/图片请求最多跟随的重定向次数
+const metadataMaxRedirects = 5
+
+// errBlockedAddress 目标地址是内网、回环、链路本地或组播地址
+var errBlockedAddress = errors.New("拒绝访问内网地址")
+
+// 标准库 net.IP 方法没有覆盖的保留网段
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // 本网络
+	"100.64.0.0/10", // 运营商级 NAT
+	"192.0.0.0/24",  // IETF 协议分配
+	"198.18.0.0/15", // 基准测试
+	"240.0.0.0/4",   // 保留
+	"64:ff9b::/96",  // NAT64（可映射到内网 IPv4）
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isPublicIP 是否为可以访问的公网地址
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range blockedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newMetadataHTTPClient 抓取 tokenURI 与图片的 HTTP 客户端
+//
+// tokenURI 由合约（任何人都能部署）控制，默认在 DNS 解析之后、建立连接之前检查目标 IP，
+// 拒绝内网、回环、链路本地（含云厂商元数据地址 169.254.169.254）与组播地址；
+// 每次重定向都重新检查协议与地址，且不使用环境变量中的代理（代理会绕过地址检查）。
+// allowPrivate=true 时不做地址检查（本地 IPFS 节点等开发环境）。
+func newMetadataHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = guardDial
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          50,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= metadataMaxRedirects {
+				return fmt.Errorf("重定向次数超过 %d", metadataMaxRedirects)
+			}
+			if allowPrivate {
+				return checkRedirectScheme(req)
+			}
+			return checkRedirectTarget(req)
+		},
+	}
+}
+
+// guardDial 在 DNS 解析之后检查实际连接的地址（防止 DNS 重绑定）
+func guardDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, host)
+	}
+	return nil
+}
+
+// checkRedirectScheme 只允许重定向到 http(s)
+func checkRedirectScheme(req *http.Request) error {
+	if scheme := strings.ToLower(req.URL.Scheme); scheme != "http" && scheme != "https" {
+		return fmt.Errorf("不允许重定向到 %s", req.URL.Redacted())
+	}
+	return nil
+}
+
+// checkRedirectTarget 重定向目标的协议与解析出的全部地址都必须是公网的
+func checkRedirectTarget(req *http.Request) error {
+	if err := checkRedirectScheme(req); err != nil {
+		return err
+	}
+	host := req.URL.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("%w: 重定向到 %s", errBlockedAddress, host)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("解析重定向地址 %s 失败: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%w: 重定向到 %s (%s)", errBlockedAddress, host, addr.IP)
+		}
+	}
+	return nil
+}
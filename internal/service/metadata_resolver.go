@@ -0,0 +1,234 @@
+// metadata_resolver.go
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MetadataResolver 把 tokenURI 解析为元数据内容
+//
+//	data:application/json;base64,... → 直接解码
+//	ipfs://<cid>/<path>               → 依次尝试 IPFS 网关
+//	ar://<txid>                       → Arweave 网关
+//	http(s)://...                     → 直接请求
+//
+// 图片缓存共用同一个解析器与 HTTP 客户端（newMetadataHTTPClient，拒绝内网地址）
+type MetadataResolver struct {
+	client         *http.Client
+	ipfsGateways   []string
+	arweaveGateway string
+	maxBodyBytes   int64
+}
+
+// NewMetadataResolver 创建解析器（网关地址以 / 结尾，如 https://ipfs.io/ipfs/）
+func NewMetadataResolver(client *http.Client, ipfsGateways []string, arweaveGateway string, maxBodyBytes int64) *MetadataResolver {
+	gateways := make([]string, 0, len(ipfsGateways))
+	for _, gw := range ipfsGateways {
+		if gw = strings.TrimSpace(gw); gw != "" {
+			gateways = append(gateways, strings.TrimSuffix(gw, "/")+"/")
+		}
+	}
+	if arweaveGateway != "" {
+		arweaveGateway = strings.TrimSuffix(arweaveGateway, "/") + "/"
+	}
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = 1 << 20
+	}
+	return &MetadataResolver{
+		client:         client,
+		ipfsGateways:   gateways,
+		arweaveGateway: arweaveGateway,
+		maxBodyBytes:   maxBodyBytes,
+	}
+}
+
+// Resolve 读取 tokenURI 指向的内容
+func (r *MetadataResolver) Resolve(ctx context.Context, uri string) ([]byte, error) {
+	return r.resolve(ctx, uri, "application/json", r.maxBodyBytes)
+}
+
+// ResolveMedia 读取元数据中 image 等字段指向的文件（大小限制由调用方指定）
+func (r *MetadataResolver) ResolveMedia(ctx context.Context, uri string, maxBytes int64) ([]byte, error) {
+	return r.resolve(ctx, uri, "image/*", maxBytes)
+}
+
+func (r *MetadataResolver) resolve(ctx context.Context, uri, accept string, maxBytes int64) ([]byte, error) {
+	uri = strings.TrimSpace(uri)
+	if strings.HasPrefix(uri, "data:") {
+		data, err := decodeDataURI(uri)
+		if err == nil && int64(len(data)) > maxBytes {
+			return nil, fmt.Errorf("内容超过 %d 字节", maxBytes)
+		}
+		return data, err
+	}
+
+	urls, err := r.GatewayURLs(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, u := range urls {
+		body, err := r.get(ctx, u, accept, maxBytes)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// GatewayURLs 把 ipfs:// ar:// http(s):// 转换为可直接请求的地址（多个网关按顺序）
+func (r *MetadataResolver) GatewayURLs(uri string) ([]string, error) {
+	lower := strings.ToLower(uri)
+	switch {
+	case strings.HasPrefix(lower, "ipfs://"):
+		if len(r.ipfsGateways) == 0 {
+			return nil, fmt.Errorf("未配置IPFS网关")
+		}
+		// 兼容 ipfs://ipfs/<cid> 的写法
+		path := strings.TrimPrefix(uri[len("ipfs://"):], "ipfs/")
+		if path == "" {
+			return nil, fmt.Errorf("无效的IPFS地址: %s", uri)
+		}
+		urls := make([]string, 0, len(r.ipfsGateways))
+		for _, gw := range r.ipfsGateways {
+			urls = append(urls, gw+path)
+		}
+		return urls, nil
+
+	case strings.HasPrefix(lower, "ar://"):
+		if r.arweaveGateway == "" {
+			return nil, fmt.Errorf("未配置Arweave网关")
+		}
+		id := uri[len("ar://"):]
+		if id == "" {
+			return nil, fmt.Errorf("无效的Arweave地址: %s", uri)
+		}
+		return []string{r.arweaveGateway + id}, nil
+
+	case strings.HasPrefix(lower, "https://"), strings.HasPrefix(lower, "http://"):
+		return []string{uri}, nil
+	}
+	return nil, fmt.Errorf("不支持的URI: %s", uri)
+}
+
+// get 请求单个地址，非2xx或超过大小限制视为失败
+func (r *MetadataResolver) get(ctx context.Context, u, accept string, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 %s 失败: %v", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("请求 %s 失败: HTTP %d", u, resp.StatusCode)
+	}
+
+	if resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("内容超过 %d 字节: %s", maxBytes, u)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", u, err)
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, fmt.Errorf("内容超过 %d 字节: %s", maxBytes, u)
+	}
+	return body, nil
+}
+
+// decodeDataURI 解码 data:[<mediatype>][;base64],<data>
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 {
+		return nil, fmt.Errorf("无效的data URI")
+	}
+	header, data := uri[len("data:"):comma], uri[comma+1:]
+
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			// 部分合约使用不带填充的 base64
+			if decoded, err = base64.RawStdEncoding.DecodeString(data); err != nil {
+				return nil, fmt.Errorf("base64 解码失败: %v", err)
+			}
+		}
+		return decoded, nil
+	}
+
+	// data:application/json;utf8,{...} 常见未转义的原文，解码失败时按原文处理
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return []byte(data), nil
+	}
+	return []byte(decoded), nil
+}
+
+// ==================== ERC-721 元数据 JSON ====================
+
+// ERC721Metadata ERC-721 元数据 JSON（OpenSea 约定的扩展字段一并解析）
+type ERC721Metadata struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Image        string            `json:"image"`
+	ImageURL     string            `json:"image_url"`
+	ImageData    string            `json:"image_data"`
+	AnimationURL string            `json:"animation_url"`
+	ExternalURL  string            `json:"external_url"`
+	Attributes   []ERC721Attribute `json:"attributes"`
+}
+
+// ERC721Attribute 单个属性；value 可能是字符串、数字或布尔值
+type ERC721Attribute struct {
+	TraitType   string `json:"trait_type"`
+	Value       any    `json:"value"`
+	DisplayType string `json:"display_type"`
+}
+
+// ParseERC721Metadata 解析元数据 JSON
+func ParseERC721Metadata(body []byte) (*ERC721Metadata, error) {
+	var meta ERC721Metadata
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, fmt.Errorf("解析元数据JSON失败: %v", err)
+	}
+	if meta.Image == "" {
+		meta.Image = meta.ImageURL
+	}
+	if meta.Image == "" && meta.ImageData != "" {
+		// 链上 SVG：转为 data URI 保存
+		meta.Image = "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(meta.ImageData))
+	}
+	return &meta, nil
+}
+
+// ValueString 属性值转为字符串（数字保留原始精度）
+func (a ERC721Attribute) ValueString() string {
+	switch v := a.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/model"
+)
+
+const testMetadataJSON = `{"name":"Kevin #1","image":"ipfs://QmImage","attributes":[{"trait_type":"Eyes","value":"Blue"}]}`
+
+// newTestResolver 测试服务器在 127.0.0.1 上，使用不做地址检查的客户端
+func newTestResolver(ipfsGateways []string, arweaveGateway string, maxBodyBytes int64) *MetadataResolver {
+	return NewMetadataResolver(newMetadataHTTPClient(5*time.Second, true), ipfsGateways, arweaveGateway, maxBodyBytes)
+}
+
+func TestResolveDataURI(t *testing.T) {
+	r := newTestResolver(nil, "", 0)
+	cases := map[string]string{
+		"data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(testMetadataJSON)):    testMetadataJSON,
+		"data:application/json;base64," + base64.RawStdEncoding.EncodeToString([]byte(testMetadataJSON)): testMetadataJSON,
+		"data:application/json;utf8," + url.PathEscape(testMetadataJSON):                                 testMetadataJSON,
+		`data:application/json,{"name":"100%"}`:                                                          `{"name":"100%"}`,
+	}
+	for uri, want := range cases {
+		got, err := r.Resolve(context.Background(), uri)
+		if err != nil {
+			t.Errorf("Resolve(%.40s...): %v", uri, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("Resolve(%.40s...) = %s, want %s", uri, got, want)
+		}
+	}
+
+	if _, err := r.Resolve(context.Background(), "data:application/json;base64"); err == nil {
+		t.Error("data URI without comma should fail")
+	}
+}
+
+func TestResolveDataURIRespectsLimit(t *testing.T) {
+	r := newTestResolver(nil, "", 8)
+	uri := "data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(testMetadataJSON))
+	if _, err := r.Resolve(context.Background(), uri); err == nil {
+		t.Fatal("expected size limit error")
+	}
+}
+
+func TestResolveIPFSGatewayFallback(t *testing.T) {
+	var downHits int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&downHits, 1)
+		http.Error(w, "gateway timeout", http.StatusGatewayTimeout)
+	}))
+	defer down.Close()
+
+	var gotPath, gotAccept string
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotPath, gotAccept = req.URL.Path, req.Header.Get("Accept")
+		w.Write([]byte(testMetadataJSON))
+	}))
+	defer up.Close()
+
+	r := newTestResolver([]string{down.URL + "/ipfs", up.URL + "/ipfs/"}, "", 0)
+	for _, uri := range []string{"ipfs://QmCid/1.json", "ipfs://ipfs/QmCid/1.json"} {
+		body, err := r.Resolve(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("Resolve(%s): %v", uri, err)
+		}
+		if string(body) != testMetadataJSON {
+			t.Errorf("body = %s", body)
+		}
+		if gotPath != "/ipfs/QmCid/1.json" {
+			t.Errorf("gateway path = %s", gotPath)
+		}
+		if gotAccept != "application/json" {
+			t.Errorf("Accept = %s", gotAccept)
+		}
+	}
+	if atomic.LoadInt32(&downHits) != 2 {
+		t.Errorf("first gateway hits = %d, want 2", downHits)
+	}
+}
+
+func TestResolveAllGatewaysFail(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.NotFound(w, req)
+	}))
+	defer down.Close()
+
+	r := newTestResolver([]string{down.URL + "/a/", down.URL + "/b/"}, "", 0)
+	_, err := r.Resolve(context.Background(), "ipfs://QmMissing")
+	if err == nil || !strings.Contains(err.Error(), "/b/QmMissing") || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("err = %v, want last gateway 404", err)
+	}
+}
+
+func TestResolveArweaveAndHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/ar/TxID":
+			w.Write([]byte(`{"name":"arweave"}`))
+		case "/meta/1":
+			w.Write([]byte(`{"name":"http"}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestResolver(nil, srv.URL+"/ar", 0)
+	for uri, want := range map[string]string{
+		"ar://TxID":                `{"name":"arweave"}`,
+		srv.URL + "/meta/1":        `{"name":"http"}`,
+		" " + srv.URL + "/meta/1 ": `{"name":"http"}`,
+	} {
+		body, err := r.Resolve(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("Resolve(%s): %v", uri, err)
+		}
+		if string(body) != want {
+			t.Errorf("Resolve(%s) = %s", uri, body)
+		}
+	}
+}
+
+func TestGatewayURLsErrors(t *testing.T) {
+	r := newTestResolver(nil, "", 0)
+	for _, uri := range []string{"ipfs://QmCid", "ar://TxID", "ftp://example.com/x", "file:///etc/passwd", "gopher://x"} {
+		if _, err := r.GatewayURLs(uri); err == nil {
+			t.Errorf("GatewayURLs(%s) should fail", uri)
+		}
+	}
+	r = newTestResolver([]string{"https://ipfs.io/ipfs/"}, "https://arweave.net/", 0)
+	for _, uri := range []string{"ipfs://", "ipfs://ipfs/", "ar://"} {
+		if _, err := r.GatewayURLs(uri); err == nil {
+			t.Errorf("GatewayURLs(%s) should fail", uri)
+		}
+	}
+}
+
+func TestGetMaxBodyBytes(t *testing.T) {
+	big := strings.Repeat("x", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/sized":
+			// 声明了 Content-Length：读取前拒绝
+			w.Write([]byte(big))
+		case "/chunked":
+			// 没有 Content-Length：读取时截断检查
+			w.Header().Set("Transfer-Encoding", "chunked")
+			w.(http.Flusher).Flush()
+			w.Write([]byte(big))
+		case "/exact":
+			w.Write([]byte(big[:64]))
+		}
+	}))
+	defer srv.Close()
+
+	r := newTestResolver(nil, "", 64)
+	for _, path := range []string{"/sized", "/chunked"} {
+		if _, err := r.Resolve(context.Background(), srv.URL+path); err == nil || !strings.Contains(err.Error(), "超过 64 字节") {
+			t.Errorf("%s: err = %v, want size limit", path, err)
+		}
+	}
+	body, err := r.Resolve(context.Background(), srv.URL+"/exact")
+	if err != nil || len(body) != 64 {
+		t.Errorf("/exact: len=%d err=%v", len(body), err)
+	}
+
+	// ResolveMedia 使用调用方的限制
+	if _, err := r.ResolveMedia(context.Background(), srv.URL+"/sized", 200); err != nil {
+		t.Errorf("ResolveMedia with larger limit: %v", err)
+	}
+}
+
+func TestFetchRetryBackoff(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	var fail int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testMetadataJSON))
+	}))
+	defer srv.Close()
+
+	cfg := config.MetadataConfig{MaxAttempts: 3, BackoffBase: time.Minute, BackoffMax: 3 * time.Minute}
+	s := &MetadataService{DB: db, resolver: newTestResolver(nil, "", 0), cfg: cfg}
+	if err := s.Refresh(ctx, "0xC", "1", srv.URL+"/1.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func() model.TokenMetadata {
+		var m model.TokenMetadata
+		if err := db.Where("contract_address = ? AND token_id = ?", "0xC", "1").First(&m).Error; err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	// 第1、2次失败：退避 1m、2m，仍为 pending
+	for attempt, wait := range []time.Duration{time.Minute, 2 * time.Minute} {
+		m := load()
+		before := time.Now()
+		if err := s.Fetch(ctx, &m); err == nil {
+			t.Fatal("expected fetch error")
+		}
+		m = load()
+		if m.Attempts != attempt+1 || m.Status != model.MetadataStatusPending || !strings.Contains(m.LastError, "503") {
+			t.Fatalf("after attempt %d: %+v", attempt+1, m)
+		}
+		if delay := m.NextAttemptAt.Sub(before); delay < wait-time.Second || delay > wait+time.Second {
+			t.Errorf("attempt %d backoff = %s, want %s", attempt+1, delay, wait)
+		}
+	}
+
+	// 恢复后成功：清零失败次数
+	atomic.StoreInt32(&fail, 0)
+	m := load()
+	if err := s.Fetch(ctx, &m); err != nil {
+		t.Fatal(err)
+	}
+	m = load()
+	if m.Status != model.MetadataStatusFetched || m.Attempts != 0 || m.Name != "Kevin #1" {
+		t.Fatalf("after success: %+v", m)
+	}
+
+	// 连续失败达到 MaxAttempts 标记为 failed
+	atomic.StoreInt32(&fail, 1)
+	if err := s.Refresh(ctx, "0xC", "1", srv.URL+"/2.json"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < cfg.MaxAttempts; i++ {
+		m = load()
+		s.Fetch(ctx, &m)
+	}
+	if m = load(); m.Status != model.MetadataStatusFailed || m.Attempts != cfg.MaxAttempts {
+		t.Fatalf("after max attempts: %+v", m)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute}, {2, 2 * time.Minute}, {3, 4 * time.Minute}, {4, 5 * time.Minute}, {50, 5 * time.Minute},
+	}
+	for _, tc := range cases {
+		if got := retryBackoff(time.Minute, 5*time.Minute, tc.attempts); got != tc.want {
+			t.Errorf("retryBackoff(%d) = %s, want %s", tc.attempts, got, tc.want)
+		}
+	}
+}
+
+// ==================== SSRF ====================
+
+func TestMetadataClientBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(testMetadataJSON))
+	}))
+	defer srv.Close()
+
+	r := NewMetadataResolver(newMetadataHTTPClient(5*time.Second, false), nil, "", 0)
+	_, err := r.Resolve(context.Background(), srv.URL+"/1.json")
+	if !errors.Is(err, errBlockedAddress) && (err == nil || !strings.Contains(err.Error(), errBlockedAddress.Error())) {
+		t.Fatalf("err = %v, want blocked address", err)
+	}
+
+	// localhost 解析后同样被拒绝
+	localhost := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	if _, err := r.Resolve(context.Background(), localhost+"/1.json"); err == nil || !strings.Contains(err.Error(), errBlockedAddress.Error()) {
+		t.Fatalf("localhost err = %v, want blocked address", err)
+	}
+}
+
+func TestMetadataClientChecksRedirects(t *testing.T) {
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/",
+		"http://[::1]/",
+		"http://localhost/",
+		"file:///etc/passwd",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		if err := checkRedirectTarget(req); err == nil {
+			t.Errorf("redirect to %s should be rejected", target)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://93.184.216.34/", nil)
+	if err := checkRedirectTarget(req); err != nil {
+		t.Errorf("redirect to public IP rejected: %v", err)
+	}
+}
+
+func TestMetadataClientRejectsNonHTTPRedirect(t *testing.T) {
+	// 允许访问内网时（测试服务器本身在 127.0.0.1）仍然拒绝非 http(s) 重定向
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "file:///etc/passwd", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	r := newTestResolver(nil, "", 0)
+	if _, err := r.Resolve(context.Background(), srv.URL); err == nil {
+		t.Fatal("redirect to file:// should fail")
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for ip, want := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"224.0.0.1":       false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		if got := isPublicIP(net.ParseIP(ip)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestTokenMetadataRawNotSerialized(t *testing.T) {
+	out, err := json.Marshal(model.TokenMetadata{Name: "n", Raw: `{"secret":"internal"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "secret") {
+		t.Fatalf("raw metadata leaked: %s", out)
+	}
+}
@@ -0,0 +1,339 @@
+// metadata_service.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/config"
+	"nft-auction-backend/internal/model"
+)
+
+// MetadataService tokenURI 元数据抓取
+//
+//	NFT入库 / tokenURI 变更 → token_metadata 置为 pending
+//	    → 抓取任务按 next_attempt_at 扫描 → 解析 URI → 保存元数据与属性
+//	    └─ 失败：attempts+1，等待 backoff_base * 2^(attempts-1)（不超过 backoff_max），超过 max_attempts 标记为 failed
+type MetadataService struct {
+	DB       *gorm.DB
+	resolver *MetadataResolver
+	cfg      config.MetadataConfig
+	images   *ImageService // 图片缓存（未启用时为 nil）
+}
+
+// NewMetadataService 创建元数据抓取服务
+func NewMetadataService(db *gorm.DB, cfg config.MetadataConfig) *MetadataService {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 20
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 15 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = time.Minute
+	}
+	if cfg.BackoffMax < cfg.BackoffBase {
+		cfg.BackoffMax = cfg.BackoffBase
+	}
+
+	client := newMetadataHTTPClient(cfg.Timeout, cfg.AllowPrivate)
+	return &MetadataService{
+		DB:       db,
+		resolver: NewMetadataResolver(client, cfg.IPFSGateways, cfg.ArweaveGateway, cfg.MaxBodyBytes),
+		cfg:      cfg,
+	}
+}
+
+// Resolver 元数据地址解析器（图片缓存共用网关配置）
+func (s *MetadataService) Resolver() *MetadataResolver {
+	return s.resolver
+}
+
+// SetImages 设置图片缓存服务（元数据抓取成功后下载 image）
+func (s *MetadataService) SetImages(images *ImageService) {
+	s.images = images
+}
+
+// Enqueue 记录待抓取的 tokenURI；tokenURI 未变化的不重复抓取
+func (s *MetadataService) Enqueue(ctx context.Context, contractAddr, tokenID, uri string) error {
+	if uri == "" {
+		return nil
+	}
+
+	var existing model.TokenMetadata
+	err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID).
+		First(&existing).Error
+	if err == nil && existing.TokenURI == uri {
+		return nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询元数据失败: %v", err)
+	}
+	return s.Refresh(ctx, contractAddr, tokenID, uri)
+}
+
+// Refresh 重新抓取元数据（MetadataUpdate 事件：tokenURI 可能不变但内容已更新）
+func (s *MetadataService) Refresh(ctx context.Context, contractAddr, tokenID, uri string) error {
+	if uri == "" {
+		return nil
+	}
+
+	metadata := &model.TokenMetadata{
+		ContractAddress: contractAddr,
+		TokenID:         tokenID,
+		TokenURI:        uri,
+		Status:          model.MetadataStatusPending,
+		NextAttemptAt:   time.Now(),
+	}
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "contract_address"}, {Name: "token_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"token_uri":       uri,
+			"status":          model.MetadataStatusPending,
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": metadata.NextAttemptAt,
+			"updated_at":      time.Now(),
+		}),
+	}).Create(metadata).Error
+	if err != nil {
+		return fmt.Errorf("保存待抓取元数据失败: %v", err)
+	}
+	return nil
+}
+
+// EnqueueMissing 为已有 tokenURI 但还没有元数据记录的NFT补充抓取任务（升级后首次启动）
+func (s *MetadataService) EnqueueMissing(ctx context.Context) (int, error) {
+	var nfts []model.NFTInfo
+	err := s.DB.WithContext(ctx).
+		Where("uri <> '' AND NOT EXISTS (SELECT 1 FROM token_metadata m WHERE m.contract_address = nft_infos.contract_address AND m.token_id = nft_infos.token_id)").
+		Find(&nfts).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待抓取NFT失败: %v", err)
+	}
+	for _, nft := range nfts {
+		if err := s.Enqueue(ctx, nft.ContractAddress, nft.TokenID, nft.Uri); err != nil {
+			return 0, err
+		}
+	}
+	return len(nfts), nil
+}
+
+// Retry 手动重新抓取（包括已 failed 的）
+func (s *MetadataService) Retry(ctx context.Context, contractAddr, tokenID string) error {
+	result := s.DB.WithContext(ctx).Model(&model.TokenMetadata{}).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID).
+		Updates(map[string]interface{}{
+			"status":          model.MetadataStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Start 启动抓取循环
+func (s *MetadataService) Start(ctx context.Context) {
+	go func() {
+		if count, err := s.EnqueueMissing(ctx); err != nil {
+			log.Printf("❌ %v", err)
+		} else if count > 0 {
+			log.Printf("📝 %d 个NFT加入元数据抓取队列", count)
+		}
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.ProcessDue(ctx); err != nil {
+				log.Printf("❌ 元数据抓取失败: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				log.Println("🛑 元数据抓取已停止")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ProcessDue 抓取到期的 pending 元数据，返回成功数量
+func (s *MetadataService) ProcessDue(ctx context.Context) (int, error) {
+	var due []model.TokenMetadata
+	err := s.DB.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", model.MetadataStatusPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(s.cfg.BatchSize).
+		Find(&due).Error
+	if err != nil {
+		return 0, fmt.Errorf("查询待抓取元数据失败: %v", err)
+	}
+
+	fetched := 0
+	changed := make(map[string]bool)
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.Fetch(ctx, &due[i]); err != nil {
+			log.Printf("⚠️ 抓取元数据 %s/%s 失败（第 %d 次）: %v",
+				due[i].ContractAddress, due[i].TokenID, due[i].Attempts, err)
+			continue
+		}
+		fetched++
+		changed[due[i].ContractAddress] = true
+	}
+
+	// 属性变化后合集内所有NFT的稀有度都要重新计算
+	for contractAddr := range changed {
+		if err := s.RecomputeRarity(ctx, contractAddr); err != nil {
+			log.Printf("❌ %v", err)
+		}
+	}
+	return fetched, nil
+}
+
+// Fetch 抓取并保存单个NFT的元数据；失败时按退避时间安排下一次重试
+func (s *MetadataService) Fetch(ctx context.Context, metadata *model.TokenMetadata) error {
+	body, err := s.resolver.Resolve(ctx, metadata.TokenURI)
+	if err == nil {
+		var meta *ERC721Metadata
+		if meta, err = ParseERC721Metadata(body); err == nil {
+			if err := s.save(ctx, metadata, meta, body); err != nil {
+				return err
+			}
+			if s.images != nil {
+				if err := s.images.Enqueue(ctx, metadata.ContractAddress, metadata.TokenID, meta.Image); err != nil {
+					log.Printf("❌ %v", err)
+				}
+			}
+			return nil
+		}
+	}
+
+	metadata.Attempts++
+	updates := map[string]interface{}{
+		"attempts":        metadata.Attempts,
+		"last_error":      err.Error(),
+		"next_attempt_at": time.Now().Add(s.backoff(metadata.Attempts)),
+	}
+	if metadata.Attempts >= s.cfg.MaxAttempts {
+		updates["status"] = model.MetadataStatusFailed
+	}
+	if dbErr := s.DB.WithContext(ctx).Model(metadata).Updates(updates).Error; dbErr != nil {
+		return fmt.Errorf("%v（记录失败次数出错: %v）", err, dbErr)
+	}
+	return err
+}
+
+// backoff 第 n 次失败后的等待时间
+func (s *MetadataService) backoff(attempts int) time.Duration {
+	return retryBackoff(s.cfg.BackoffBase, s.cfg.BackoffMax, attempts)
+}
+
+// retryBackoff 指数退避：base * 2^(attempts-1)，不超过 max
+func retryBackoff(base, max time.Duration, attempts int) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait
+}
+
+// save 保存元数据并替换属性；抓取期间 tokenURI 又变化时放弃本次结果
+func (s *MetadataService) save(ctx context.Context, metadata *model.TokenMetadata, meta *ERC721Metadata, raw []byte) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.TokenMetadata{}).
+			Where("id = ? AND token_uri = ?", metadata.ID, metadata.TokenURI).
+			Updates(map[string]interface{}{
+				"name":          meta.Name,
+				"description":   meta.Description,
+				"image":         meta.Image,
+				"animation_url": meta.AnimationURL,
+				"external_url":  meta.ExternalURL,
+				"raw":           string(raw),
+				"status":        model.MetadataStatusFetched,
+				"attempts":      0,
+				"last_error":    "",
+				"fetched_at":    time.Now(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("保存元数据失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Where("contract_address = ? AND token_id = ?", metadata.ContractAddress, metadata.TokenID).
+			Delete(&model.TokenAttribute{}).Error; err != nil {
+			return fmt.Errorf("清除旧属性失败: %v", err)
+		}
+		attributes := make([]model.TokenAttribute, 0, len(meta.Attributes))
+		for _, attr := range meta.Attributes {
+			attributes = append(attributes, model.TokenAttribute{
+				ContractAddress: metadata.ContractAddress,
+				TokenID:         metadata.TokenID,
+				TraitType:       attr.TraitType,
+				Value:           attr.ValueString(),
+				DisplayType:     attr.DisplayType,
+			})
+		}
+		if len(attributes) > 0 {
+			if err := tx.Create(&attributes).Error; err != nil {
+				return fmt.Errorf("保存属性失败: %v", err)
+			}
+		}
+
+		log.Printf("✅ 元数据已抓取: %s/%s %s（%d 个属性）",
+			metadata.ContractAddress, metadata.TokenID, meta.Name, len(attributes))
+		return nil
+	})
+}
+
+// TokenMetadataView 元数据与属性（接口返回）
+type TokenMetadataView struct {
+	*model.TokenMetadata
+	Attributes []model.TokenAttribute `json:"attributes"`
+}
+
+// GetMetadata 获取NFT的元数据与属性
+func (s *MetadataService) GetMetadata(ctx context.Context, contractAddr, tokenID string) (*TokenMetadataView, error) {
+	var metadata model.TokenMetadata
+	if err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID).
+		First(&metadata).Error; err != nil {
+		return nil, err
+	}
+
+	var attributes []model.TokenAttribute
+	if err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID).
+		Order("id ASC").
+		Find(&attributes).Error; err != nil {
+		return nil, err
+	}
+	return &TokenMetadataView{TokenMetadata: &metadata, Attributes: attributes}, nil
+}
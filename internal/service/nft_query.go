@@ -0,0 +1,124 @@
+// nft_query.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"nft-auction-backend/internal/model"
+)
+
+// NFT列表排序方式
+const (
+	NFTSortTokenID = "token_id" // TokenID 数值排序（默认升序）
+	NFTSortRarity  = "rarity"   // 稀有度排名（默认升序，未计算的始终排在最后）
+	NFTSortRecent  = "recent"   // 最近变更（默认降序）
+	NFTSortMinted  = "minted"   // 铸造区块（默认降序）
+)
+
+// NFTQuery NFT列表查询条件（零值表示不过滤）
+type NFTQuery struct {
+	ContractAddress string // 为空时查询所有合约
+	Owner           string // 实际所有者（托管在拍卖中的NFT算作卖家的）
+	Holder          string // 链上持有者
+	MintedFrom      uint64 // 铸造区块范围 [MintedFrom, MintedTo]
+	MintedTo        uint64
+	InAuction       *bool // 是否在进行中的拍卖里
+	Traits          TraitFilter
+	Sort            string
+	Desc            *bool // 为 nil 时使用排序方式的默认方向
+	Page            int
+	PageSize        int
+}
+
+// NFTListItem NFT列表项（附带元数据名称、图片与稀有度）
+type NFTListItem struct {
+	model.NFTInfo
+	MetadataName      string  `json:"metadata_name"`
+	Image             string  `json:"image"`
+	RarityScore       float64 `json:"rarity_score"`
+	StatisticalRarity float64 `json:"statistical_rarity"`
+	RarityRank        int     `json:"rarity_rank"`
+}
+
+// nftSort 排序方式对应的 ORDER BY（%[1]s 替换为 ASC/DESC）
+type nftSort struct {
+	order string
+	desc  bool
+}
+
+var nftSorts = map[string]nftSort{
+	NFTSortTokenID: {order: "LENGTH(nft_infos.token_id) %[1]s, nft_infos.token_id %[1]s"},
+	NFTSortRarity:  {order: "CASE WHEN m.rarity_rank IS NULL OR m.rarity_rank = 0 THEN 1 ELSE 0 END ASC, m.rarity_rank %[1]s, LENGTH(nft_infos.token_id) ASC, nft_infos.token_id ASC"},
+	NFTSortRecent:  {order: "nft_infos.block_number %[1]s, nft_infos.id %[1]s", desc: true},
+	NFTSortMinted:  {order: "nft_infos.minted_block %[1]s, LENGTH(nft_infos.token_id) %[1]s, nft_infos.token_id %[1]s", desc: true},
+}
+
+// inAuctionCondition NFT在未结束的拍卖中（托管在拍卖合约）
+const inAuctionCondition = "EXISTS (SELECT 1 FROM auctions a WHERE LOWER(a.nft_contract) = LOWER(nft_infos.contract_address) " +
+	"AND a.token_id = nft_infos.token_id AND a.ended = ? AND a.chain_state <> ?)"
+
+// ListNFTs 分页查询NFT（所有者、铸造区块、是否在拍卖中、属性过滤，多种排序），返回当前页和总数
+func (s *NFTService) ListNFTs(ctx context.Context, q NFTQuery) ([]NFTListItem, int64, error) {
+	if q.Sort == "" {
+		q.Sort = NFTSortTokenID
+	}
+	sortBy, ok := nftSorts[q.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid sort: %s", q.Sort)
+	}
+	desc := sortBy.desc
+	if q.Desc != nil {
+		desc = *q.Desc
+	}
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	query := s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Joins("LEFT JOIN token_metadata m ON m.contract_address = nft_infos.contract_address AND m.token_id = nft_infos.token_id").
+		Where("nft_infos.chain_state <> ?", model.ChainStateOrphaned)
+	if q.ContractAddress != "" {
+		query = query.Where("nft_infos.contract_address = ?", q.ContractAddress)
+	}
+	if q.Owner != "" {
+		query = query.Where("LOWER(nft_infos.beneficial_owner) = ?", strings.ToLower(q.Owner))
+	}
+	if q.Holder != "" {
+		query = query.Where("LOWER(nft_infos.owner) = ?", strings.ToLower(q.Holder))
+	}
+	if q.MintedFrom > 0 {
+		query = query.Where("nft_infos.minted_block >= ?", q.MintedFrom)
+	}
+	if q.MintedTo > 0 {
+		query = query.Where("nft_infos.minted_block > 0 AND nft_infos.minted_block <= ?", q.MintedTo)
+	}
+	if q.InAuction != nil {
+		if *q.InAuction {
+			query = query.Where(inAuctionCondition, false, model.ChainStateOrphaned)
+		} else {
+			query = query.Where("NOT "+inAuctionCondition, false, model.ChainStateOrphaned)
+		}
+	}
+	query = ApplyTraitFilter(query, "nft_infos.contract_address", "nft_infos.token_id", q.Traits)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计NFT数量失败: %v", err)
+	}
+
+	var items []NFTListItem
+	err := query.
+		Select("nft_infos.*, COALESCE(m.name, '') AS metadata_name, COALESCE(m.image, '') AS image, " +
+			"COALESCE(m.rarity_score, 0) AS rarity_score, COALESCE(m.statistical_rarity, 0) AS statistical_rarity, COALESCE(m.rarity_rank, 0) AS rarity_rank").
+		Order(fmt.Sprintf(sortBy.order, direction)).
+		Offset((q.Page - 1) * q.PageSize).
+		Limit(q.PageSize).
+		Scan(&items).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询NFT列表失败: %v", err)
+	}
+	return items, total, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"strings"
 	"time"
 
 	"nft-auction-backend/internal/contract"
@@ -15,8 +16,12 @@ import (
 )
 
 type NFTService struct {
-	DB     *gorm.DB
-	client contract.NFTContract
+	DB       *gorm.DB
+	client   contract.NFTContract
+	registry *CollectionService // 第三方合集的客户端
+	metadata *MetadataService   // tokenURI 元数据抓取（未启用时为 nil）
+
+	escrowContract common.Address // 拍卖合约（托管NFT的持有者）
 }
 
 func NewNFTService(db *gorm.DB, client contract.NFTContract) *NFTService {
@@ -31,6 +36,44 @@ func (s *NFTService) GetContractAddress() common.Address {
 	return s.client.GetContractAddress()
 }
 
+// SetRegistry 设置合集注册表（第三方合集的NFT从对应合约读取）
+func (s *NFTService) SetRegistry(registry *CollectionService) {
+	s.registry = registry
+}
+
+// SetMetadata 设置元数据抓取服务（tokenURI 变化时加入抓取队列）
+func (s *NFTService) SetMetadata(metadata *MetadataService) {
+	s.metadata = metadata
+}
+
+// SetEscrowContract 设置拍卖合约地址：持有者为该地址的NFT视为托管中，实际所有者为拍卖卖家
+func (s *NFTService) SetEscrowContract(auctionContract common.Address) {
+	s.escrowContract = auctionContract
+}
+
+// enqueueMetadata tokenURI 加入元数据抓取队列
+func (s *NFTService) enqueueMetadata(ctx context.Context, contractAddr, tokenID, uri string) {
+	if s.metadata == nil {
+		return
+	}
+	if err := s.metadata.Enqueue(ctx, contractAddr, tokenID, uri); err != nil {
+		log.Printf("❌ %v", err)
+	}
+}
+
+// reader 获取NFT所在合约的只读客户端
+func (s *NFTService) reader(contractAddr common.Address) (contract.ERC721Reader, error) {
+	if contractAddr == s.GetContractAddress() {
+		return s.client, nil
+	}
+	if s.registry != nil {
+		if reader, ok := s.registry.Reader(contractAddr); ok {
+			return reader, nil
+		}
+	}
+	return nil, fmt.Errorf("合约 %s 未注册", contractAddr.Hex())
+}
+
 // GetNFT 从数据库获取 NFT
 func (s *NFTService) GetNFT(contractAddr, tokenID string) (*model.NFTInfo, error) {
 	var nft model.NFTInfo
@@ -54,6 +97,7 @@ func (s *NFTService) SaveNFT(ctx context.Context, nft *model.NFTInfo) error {
 	now := time.Now()
 
 	if result.Error != nil {
+		s.applyCustody(ctx, nft, nft.BeneficialOwner)
 		nft.CreatedAt = now
 		nft.UpdatedAt = now
 		if err := s.DB.WithContext(ctx).Create(nft).Error; err != nil {
@@ -61,17 +105,33 @@ func (s *NFTService) SaveNFT(ctx context.Context, nft *model.NFTInfo) error {
 			return err
 		}
 		log.Printf("新增 NFT %s", nft.TokenID)
+		s.enqueueMetadata(ctx, nft.ContractAddress, nft.TokenID, nft.Uri)
 		return nil
 	}
 
 	// 更新现有记录
+	fallback := existing.BeneficialOwner
+	if nft.BeneficialOwner != "" {
+		fallback = nft.BeneficialOwner
+	}
 	existing.Owner = nft.Owner
-	existing.TotalSupply = nft.TotalSupply
+	s.applyCustody(ctx, &existing, fallback)
+	if nft.Uri != "" {
+		existing.Uri = nft.Uri
+	}
 	existing.Blockchain = nft.Blockchain
 	existing.LastSyncTime = now
-	existing.ContractName = nft.ContractName
-	existing.ContractSymbol = nft.ContractSymbol
 	existing.IsMinted = nft.IsMinted
+	if nft.MintedBlock != 0 {
+		existing.MintedBlock = nft.MintedBlock
+		existing.MintedAt = nft.MintedAt
+	}
+	if nft.BlockHash != "" {
+		// 只有来自事件的更新才携带区块信息
+		existing.BlockNumber = nft.BlockNumber
+		existing.BlockHash = nft.BlockHash
+		existing.ChainState = nft.ChainState
+	}
 	existing.UpdatedAt = now
 
 	if err := s.DB.WithContext(ctx).Save(&existing).Error; err != nil {
@@ -79,9 +139,95 @@ func (s *NFTService) SaveNFT(ctx context.Context, nft *model.NFTInfo) error {
 		return err
 	}
 	log.Printf(" 更新 NFT token id = %s", existing.TokenID)
+	s.enqueueMetadata(ctx, existing.ContractAddress, existing.TokenID, existing.Uri)
 	return nil
 }
 
+// applyCustody 根据链上持有者推导实际所有者与托管拍卖
+// 持有者是拍卖合约时实际所有者为托管中拍卖的卖家；拍卖还未入库时（AuctionCreated 与 Transfer 由不同协程处理）使用 fallback（转出方）
+func (s *NFTService) applyCustody(ctx context.Context, nft *model.NFTInfo, fallback string) {
+	if s.escrowContract == (common.Address{}) || !strings.EqualFold(nft.Owner, s.escrowContract.Hex()) {
+		nft.BeneficialOwner = nft.Owner
+		nft.EscrowedInAuction = 0
+		return
+	}
+
+	var auction model.Auction
+	err := s.DB.WithContext(ctx).
+		Where("LOWER(nft_contract) = ? AND token_id = ? AND ended = ? AND chain_state <> ?",
+			strings.ToLower(nft.ContractAddress), nft.TokenID, false, model.ChainStateOrphaned).
+		Order("auction_id DESC").
+		First(&auction).Error
+	if err == nil {
+		nft.BeneficialOwner = auction.Seller
+		nft.EscrowedInAuction = auction.AuctionID
+		return
+	}
+	nft.BeneficialOwner = fallback
+	nft.EscrowedInAuction = 0
+}
+
+// LinkEscrow 拍卖入库后关联已转入拍卖合约的NFT（Transfer 先于 AuctionCreated 处理时补上拍卖ID与卖家）
+// 只更新在拍卖创建区块之前（含）转入的NFT，避免回填时把后来的托管关联到旧拍卖
+func (s *NFTService) LinkEscrow(ctx context.Context, auction *model.Auction) error {
+	if s.escrowContract == (common.Address{}) {
+		return nil
+	}
+	query := s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("LOWER(contract_address) = ? AND token_id = ? AND LOWER(owner) = ?",
+			strings.ToLower(auction.NFTContract), auction.TokenID, strings.ToLower(s.escrowContract.Hex()))
+	if auction.BlockNumber > 0 {
+		query = query.Where("block_number <= ?", auction.BlockNumber)
+	}
+	if err := query.Updates(map[string]interface{}{
+		"beneficial_owner":    auction.Seller,
+		"escrowed_in_auction": auction.AuctionID,
+	}).Error; err != nil {
+		return fmt.Errorf("关联托管NFT失败: %v", err)
+	}
+	return nil
+}
+
+// RepairCustody 补全旧数据的实际所有者：未托管的等于持有者，托管在拍卖合约的关联到进行中的拍卖
+func (s *NFTService) RepairCustody(ctx context.Context) (int64, error) {
+	result := s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("(beneficial_owner IS NULL OR beneficial_owner = '') AND owner <> ''").
+		Update("beneficial_owner", gorm.Expr("owner"))
+	if result.Error != nil {
+		return 0, fmt.Errorf("补全实际所有者失败: %v", result.Error)
+	}
+	repaired := result.RowsAffected
+
+	if s.escrowContract == (common.Address{}) {
+		return repaired, nil
+	}
+	var auctions []model.Auction
+	if err := s.DB.WithContext(ctx).
+		Where("ended = ? AND chain_state <> ?", false, model.ChainStateOrphaned).
+		Order("auction_id ASC").
+		Find(&auctions).Error; err != nil {
+		return repaired, fmt.Errorf("查询进行中的拍卖失败: %v", err)
+	}
+	for i := range auctions {
+		result := s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+			Where("LOWER(contract_address) = ? AND token_id = ? AND LOWER(owner) = ? AND escrowed_in_auction <> ?",
+				strings.ToLower(auctions[i].NFTContract), auctions[i].TokenID,
+				strings.ToLower(s.escrowContract.Hex()), auctions[i].AuctionID).
+			Updates(map[string]interface{}{
+				"beneficial_owner":    auctions[i].Seller,
+				"escrowed_in_auction": auctions[i].AuctionID,
+			})
+		if result.Error != nil {
+			return repaired, fmt.Errorf("关联托管NFT失败: %v", result.Error)
+		}
+		repaired += result.RowsAffected
+	}
+	if repaired > 0 {
+		log.Printf("✅ 已补全 %d 个NFT的实际所有者", repaired)
+	}
+	return repaired, nil
+}
+
 // GetOwner 从区块链获取NFT拥有者
 func (s *NFTService) GetOwner(ctx context.Context, tokenID string) (string, error) {
 	tokenIDBig, ok := new(big.Int).SetString(tokenID, 10)
@@ -97,14 +243,62 @@ func (s *NFTService) GetOwner(ctx context.Context, tokenID string) (string, erro
 	return ownerAddr.Hex(), nil
 }
 
-// ValidateOwnership 验证指定地址是否是NFT所有者
-// 优化为从数据库判断
-func (s *NFTService) ValidateOwnership(ctx context.Context, tokenID, address string) (bool, error) {
-	owner, err := s.GetOwner(ctx, tokenID)
+// 所有权验证方式
+const (
+	OwnershipModeHolder     = "holder"     // 链上持有者（ownerOf）
+	OwnershipModeBeneficial = "beneficial" // 实际所有者：托管在拍卖中的NFT算作卖家的
+)
+
+// Custody NFT的链上持有者与实际所有者
+type Custody struct {
+	Holder            string `json:"holder"`
+	BeneficialOwner   string `json:"beneficial_owner"`
+	EscrowedInAuction uint64 `json:"escrowed_in_auction"` // 0 表示未托管
+}
+
+// GetCustody 从链上读取持有者，并按拍卖记录推导实际所有者
+func (s *NFTService) GetCustody(ctx context.Context, tokenID string) (*Custody, error) {
+	holder, err := s.GetOwner(ctx, tokenID)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	nft := &model.NFTInfo{
+		ContractAddress: s.GetContractAddress().Hex(),
+		TokenID:         tokenID,
+		Owner:           holder,
+	}
+	fallback := ""
+	if existing, err := s.GetNFT(nft.ContractAddress, tokenID); err == nil {
+		fallback = existing.BeneficialOwner
 	}
-	return owner == address, nil
+	s.applyCustody(ctx, nft, fallback)
+
+	return &Custody{
+		Holder:            nft.Owner,
+		BeneficialOwner:   nft.BeneficialOwner,
+		EscrowedInAuction: nft.EscrowedInAuction,
+	}, nil
+}
+
+// ValidateOwnership 验证指定地址是否是NFT所有者
+// mode 为 holder（默认）时比较链上持有者，为 beneficial 时托管在拍卖中的NFT按卖家判断
+func (s *NFTService) ValidateOwnership(ctx context.Context, tokenID, address, mode string) (bool, error) {
+	switch mode {
+	case "", OwnershipModeHolder:
+		owner, err := s.GetOwner(ctx, tokenID)
+		if err != nil {
+			return false, err
+		}
+		return strings.EqualFold(owner, address), nil
+	case OwnershipModeBeneficial:
+		custody, err := s.GetCustody(ctx, tokenID)
+		if err != nil {
+			return false, err
+		}
+		return strings.EqualFold(custody.BeneficialOwner, address), nil
+	}
+	return false, fmt.Errorf("invalid ownership mode: %s", mode)
 }
 
 // SyncAllNFTs 同步所有NFT
@@ -114,10 +308,7 @@ func (s *NFTService) SyncAllNFTs(ctx context.Context) error {
 		return fmt.Errorf("获取总供应量失败: %v", err)
 	}
 
-	// 获取合约信息
 	contractAddr := s.client.GetContractAddress().Hex()
-	contractName, _ := s.client.GetName(ctx)
-	contractSymbol, _ := s.client.GetSymbol(ctx)
 
 	successCount := 0
 	for i := int64(1); i <= total.Int64(); i++ {
@@ -143,10 +334,7 @@ func (s *NFTService) SyncAllNFTs(ctx context.Context) error {
 			TokenID:         tokenID,
 			Owner:           ownerAddr.Hex(),
 			Name:            fmt.Sprintf("NFT #%s", tokenID),
-			TotalSupply:     total.String(),
 			Blockchain:      "sepolia",
-			ContractName:    contractName,
-			ContractSymbol:  contractSymbol,
 			IsMinted:        true,
 			LastSyncTime:    time.Now(),
 		}
@@ -162,7 +350,7 @@ func (s *NFTService) SyncAllNFTs(ctx context.Context) error {
 }
 
 // UpdateNFTFromChain 从链上更新单个NFT信息（事件监听器调用）
-func (s *NFTService) UpdateNFTFromChain(ctx context.Context, tokenID string) error {
+func (s *NFTService) UpdateNFTFromChain(ctx context.Context, contractAddress common.Address, tokenID string) error {
 	// 注意：不再创建新的context，使用传入的ctx
 
 	// 将 tokenID 转换为 big.Int
@@ -171,23 +359,19 @@ func (s *NFTService) UpdateNFTFromChain(ctx context.Context, tokenID string) err
 		return fmt.Errorf("invalid token ID format: %s", tokenID)
 	}
 
+	reader, err := s.reader(contractAddress)
+	if err != nil {
+		return err
+	}
+
 	// 从区块链获取所有者
-	ownerAddr, err := s.client.GetOwner(ctx, tokenIDBig)
+	ownerAddr, err := reader.GetOwner(ctx, tokenIDBig)
 	if err != nil {
 		return fmt.Errorf("failed to get NFT owner from blockchain: %v", err)
 	}
 
-	// 获取合约信息
-	contractAddr := s.client.GetContractAddress().Hex()
-	contractName, _ := s.client.GetName(ctx)
-	contractSymbol, _ := s.client.GetSymbol(ctx)
-	contractUrl, _ := s.client.GetTokenURI(ctx, tokenIDBig)
-
-	// 获取总供应量
-	var totalSupply string
-	if total, err := s.client.GetTotalSupply(ctx); err == nil {
-		totalSupply = total.String()
-	}
+	contractAddr := contractAddress.Hex()
+	contractUrl, _ := reader.GetTokenURI(ctx, tokenIDBig)
 
 	// 构建NFT信息
 	nft := &model.NFTInfo{
@@ -196,10 +380,7 @@ func (s *NFTService) UpdateNFTFromChain(ctx context.Context, tokenID string) err
 		Owner:           ownerAddr.Hex(),
 		Name:            fmt.Sprintf("NFT #%s", tokenID),
 		Uri:             contractUrl, // 修正：直接使用获取到的URI
-		TotalSupply:     totalSupply,
 		Blockchain:      "sepolia",
-		ContractName:    contractName,
-		ContractSymbol:  contractSymbol,
 		IsMinted:        true,
 		LastSyncTime:    time.Now(),
 	}
@@ -213,3 +394,123 @@ func (s *NFTService) UpdateNFTFromChain(ctx context.Context, tokenID string) err
 	log.Printf(" NFT已更新: %s/%s, Owner=%s", contractAddr, tokenID, ownerAddr.Hex())
 	return nil
 }
+
+// RefreshNFTFromChain 重组后从链上刷新NFT；铸造被回滚（链上不存在）的NFT标记为 orphaned
+func (s *NFTService) RefreshNFTFromChain(ctx context.Context, contractAddr common.Address, tokenID string) error {
+	tokenIDBig, ok := new(big.Int).SetString(tokenID, 10)
+	if !ok {
+		return fmt.Errorf("invalid token ID format: %s", tokenID)
+	}
+
+	reader, err := s.reader(contractAddr)
+	if err != nil {
+		return err
+	}
+	minted, err := reader.CheckIfMinted(ctx, tokenIDBig)
+	if err != nil {
+		return fmt.Errorf("检查NFT是否存在失败: %v", err)
+	}
+	if !minted {
+		return s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+			Where("contract_address = ? AND token_id = ?", contractAddr.Hex(), tokenID).
+			Update("chain_state", model.ChainStateOrphaned).Error
+	}
+
+	if err := s.UpdateNFTFromChain(ctx, contractAddr, tokenID); err != nil {
+		return err
+	}
+	// 链上最新状态重新进入确认流程
+	return s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("contract_address = ? AND token_id = ? AND chain_state = ?",
+			contractAddr.Hex(), tokenID, model.ChainStateOrphaned).
+		Update("chain_state", model.ChainStatePending).Error
+}
+
+// SetApproval 记录单个NFT的授权（Approval 事件）；数据库中还没有的NFT跳过，由 Transfer 事件写入
+func (s *NFTService) SetApproval(ctx context.Context, contractAddr common.Address, tokenID, approved, txHash string, approvedAt time.Time) error {
+	return s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("contract_address = ? AND token_id = ?", contractAddr.Hex(), tokenID).
+		Updates(map[string]interface{}{
+			"approved_address": approved,
+			"approved_at":      approvedAt,
+			"approval_tx_hash": txHash,
+			"last_sync_time":   time.Now(),
+		}).Error
+}
+
+// ClearApproval 回滚被重组移除的授权记录
+func (s *NFTService) ClearApproval(ctx context.Context, approvalTxHash string) error {
+	return s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("approval_tx_hash = ?", approvalTxHash).
+		Updates(map[string]interface{}{
+			"approved_address": "",
+			"approval_tx_hash": "",
+		}).Error
+}
+
+// RefreshTokenURI 重新读取 tokenURI（MetadataUpdate 事件）；数据库中还没有的NFT跳过，由铸造事件写入
+// 返回读取到的 tokenURI（跳过时为空）
+func (s *NFTService) RefreshTokenURI(ctx context.Context, tokenID *big.Int) (string, error) {
+	contractAddr := s.GetContractAddress().Hex()
+
+	var count int64
+	if err := s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID.String()).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "", nil
+	}
+
+	uri, err := s.client.GetTokenURI(ctx, tokenID)
+	if err != nil {
+		return "", fmt.Errorf("获取 NFT #%s tokenURI 失败: %v", tokenID.String(), err)
+	}
+	if err := s.SetTokenURI(ctx, tokenID, uri); err != nil {
+		return "", err
+	}
+	return uri, nil
+}
+
+// SetTokenURI 写入已知的 tokenURI 并重新抓取元数据（回放 MetadataUpdate 时直接使用事件日志中记录的值）
+func (s *NFTService) SetTokenURI(ctx context.Context, tokenID *big.Int, uri string) error {
+	contractAddr := s.GetContractAddress().Hex()
+	if err := s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("contract_address = ? AND token_id = ?", contractAddr, tokenID.String()).
+		Updates(map[string]interface{}{"uri": uri, "last_sync_time": time.Now()}).Error; err != nil {
+		return fmt.Errorf("更新 NFT #%s tokenURI 失败: %v", tokenID.String(), err)
+	}
+	log.Printf("🔄 NFT #%s tokenURI 已刷新: %s", tokenID.String(), uri)
+	if s.metadata != nil {
+		if err := s.metadata.Refresh(ctx, contractAddr, tokenID.String(), uri); err != nil {
+			log.Printf("❌ %v", err)
+		}
+	}
+	return nil
+}
+
+// RefreshTokenURIRange 刷新 [from, to] 内已入库NFT的 tokenURI（BatchMetadataUpdate 事件）
+// 区间可能是 0 到 2^256-1，只遍历数据库中已有的 TokenID；返回已刷新的 TokenID -> tokenURI
+func (s *NFTService) RefreshTokenURIRange(ctx context.Context, from, to *big.Int) (map[string]string, error) {
+	var tokenIDs []string
+	if err := s.DB.WithContext(ctx).Model(&model.NFTInfo{}).
+		Where("contract_address = ?", s.GetContractAddress().Hex()).
+		Pluck("token_id", &tokenIDs).Error; err != nil {
+		return nil, err
+	}
+
+	refreshed := make(map[string]string)
+	for _, id := range tokenIDs {
+		tokenID, ok := new(big.Int).SetString(id, 10)
+		if !ok || tokenID.Cmp(from) < 0 || tokenID.Cmp(to) > 0 {
+			continue
+		}
+		uri, err := s.RefreshTokenURI(ctx, tokenID)
+		if err != nil {
+			return refreshed, err
+		}
+		refreshed[id] = uri
+	}
+	return refreshed, nil
+}
@@ -0,0 +1,152 @@
+// portfolio_service.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+// PortfolioService 钱包资产视图（全部来自已索引的数据库，不调用合约）
+//
+//	owned       钱包中持有的NFT（链上持有者，不含托管中的）
+//	escrowed    作为卖家托管在进行中拍卖里的NFT
+//	active_bids 在进行中拍卖里的出价（每个拍卖一条，取自己的最高出价）
+//	won         已结束且自己是最高出价者的拍卖
+type PortfolioService struct {
+	DB   *gorm.DB
+	nfts *NFTService
+}
+
+// NewPortfolioService 创建钱包资产服务
+func NewPortfolioService(db *gorm.DB, nftService *NFTService) *PortfolioService {
+	return &PortfolioService{
+		DB:   db,
+		nfts: nftService,
+	}
+}
+
+// Portfolio 钱包资产
+type Portfolio struct {
+	Address    string          `json:"address"`
+	Owned      []NFTListItem   `json:"owned"`
+	OwnedTotal int64           `json:"owned_total"`
+	Escrowed   []EscrowedToken `json:"escrowed"`
+	ActiveBids []ActiveBid     `json:"active_bids"`
+	Won        []model.Auction `json:"won_auctions"`
+}
+
+// EscrowedToken 托管在拍卖合约中的NFT
+type EscrowedToken struct {
+	Auction model.Auction  `json:"auction"`
+	NFT     *model.NFTInfo `json:"nft"` // 未索引到NFT记录时为 nil
+}
+
+// ActiveBid 在进行中拍卖里的出价
+type ActiveBid struct {
+	Auction   model.Auction `json:"auction"`
+	MyBid     string        `json:"my_bid"`     // 自己在该拍卖中的最高出价
+	BidCount  int           `json:"bid_count"`  // 自己的出价次数
+	IsHighest bool          `json:"is_highest"` // 当前是否为最高出价者
+	LastBidAt uint64        `json:"last_bid_at"`
+}
+
+// GetPortfolio 获取地址的资产；ownedLimit 为持有NFT最多返回的数量（完整列表使用 /api/nfts?holder=）
+func (s *PortfolioService) GetPortfolio(ctx context.Context, address string, ownedLimit int) (*Portfolio, error) {
+	portfolio := &Portfolio{Address: address}
+	lower := strings.ToLower(address)
+
+	owned, total, err := s.nfts.ListNFTs(ctx, NFTQuery{
+		Holder:   address,
+		Sort:     NFTSortRecent,
+		Page:     1,
+		PageSize: ownedLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	portfolio.Owned, portfolio.OwnedTotal = owned, total
+
+	// 托管中：自己是卖家、尚未结束的拍卖
+	var escrowed []model.Auction
+	if err := s.DB.WithContext(ctx).
+		Where("LOWER(seller) = ? AND ended = ? AND chain_state <> ?", lower, false, model.ChainStateOrphaned).
+		Order("end_time ASC").
+		Find(&escrowed).Error; err != nil {
+		return nil, fmt.Errorf("查询托管中的拍卖失败: %v", err)
+	}
+	portfolio.Escrowed = make([]EscrowedToken, 0, len(escrowed))
+	for _, auction := range escrowed {
+		token := EscrowedToken{Auction: auction}
+		var nft model.NFTInfo
+		if err := s.DB.WithContext(ctx).
+			Where("LOWER(contract_address) = ? AND token_id = ?", strings.ToLower(auction.NFTContract), auction.TokenID).
+			First(&nft).Error; err == nil {
+			token.NFT = &nft
+		}
+		portfolio.Escrowed = append(portfolio.Escrowed, token)
+	}
+
+	if portfolio.ActiveBids, err = s.activeBids(ctx, lower); err != nil {
+		return nil, err
+	}
+
+	// 已赢得：拍卖已结束且自己是最高出价者（无人出价结束的拍卖 highest_bid 为 0）
+	if err := s.DB.WithContext(ctx).
+		Where("LOWER(highest_bidder) = ? AND ended = ? AND chain_state <> ? AND highest_bid NOT IN ('', '0')",
+			lower, true, model.ChainStateOrphaned).
+		Order("end_time DESC").
+		Find(&portfolio.Won).Error; err != nil {
+		return nil, fmt.Errorf("查询赢得的拍卖失败: %v", err)
+	}
+	return portfolio, nil
+}
+
+// activeBids 在未结束拍卖中的出价，按拍卖汇总
+func (s *PortfolioService) activeBids(ctx context.Context, bidder string) ([]ActiveBid, error) {
+	var bids []model.BidHistory
+	if err := s.DB.WithContext(ctx).
+		Where("LOWER(bidder) = ? AND status <> ?", bidder, model.ChainStateOrphaned).
+		Where("auction_id IN (?)", s.DB.Model(&model.Auction{}).Select("auction_id").
+			Where("ended = ? AND chain_state <> ?", false, model.ChainStateOrphaned)).
+		Order("auction_id ASC, block_number ASC, log_index ASC").
+		Find(&bids).Error; err != nil {
+		return nil, fmt.Errorf("查询出价失败: %v", err)
+	}
+
+	byAuction := make(map[uint64]*ActiveBid)
+	order := make([]uint64, 0)
+	for _, bid := range bids {
+		active, ok := byAuction[bid.AuctionID]
+		if !ok {
+			active = &ActiveBid{MyBid: "0"}
+			byAuction[bid.AuctionID] = active
+			order = append(order, bid.AuctionID)
+		}
+		active.BidCount++
+		if bid.BlockTime > active.LastBidAt {
+			active.LastBidAt = bid.BlockTime
+		}
+		if amount, ok := new(big.Int).SetString(bid.Amount, 10); ok {
+			if current, _ := new(big.Int).SetString(active.MyBid, 10); amount.Cmp(current) > 0 {
+				active.MyBid = amount.String()
+			}
+		}
+	}
+
+	result := make([]ActiveBid, 0, len(order))
+	for _, auctionID := range order {
+		active := byAuction[auctionID]
+		if err := s.DB.WithContext(ctx).Where("auction_id = ?", auctionID).First(&active.Auction).Error; err != nil {
+			return nil, fmt.Errorf("查询拍卖 %d 失败: %v", auctionID, err)
+		}
+		active.IsHighest = strings.EqualFold(active.Auction.HighestBidder, bidder)
+		result = append(result, *active)
+	}
+	return result, nil
+}
@@ -0,0 +1,135 @@
+// provenance.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/model"
+)
+
+// ClassifyTransfer 按转出/转入地址判断转移类型（拍卖合约未设置时不区分托管与结算）
+func (s *NFTService) ClassifyTransfer(from, to common.Address) string {
+	escrow := s.escrowContract != (common.Address{})
+	switch {
+	case from == (common.Address{}):
+		return model.TransferKindMint
+	case to == (common.Address{}):
+		return model.TransferKindBurn
+	case escrow && to == s.escrowContract:
+		return model.TransferKindEscrow
+	case escrow && from == s.escrowContract:
+		return model.TransferKindSettlement
+	default:
+		return model.TransferKindTransfer
+	}
+}
+
+// SaveTransfer 保存转移记录（同一条日志重复写入时恢复为 pending）
+func (s *NFTService) SaveTransfer(ctx context.Context, transfer *model.Transfer) error {
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tx_hash"}, {Name: "log_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_number", "block_hash", "block_time", "chain_state"}),
+	}).Create(transfer).Error
+	if err != nil {
+		return fmt.Errorf("保存转移记录失败: %v", err)
+	}
+	return nil
+}
+
+// OrphanTransfer 转移记录所在区块被重组移除
+func (s *NFTService) OrphanTransfer(ctx context.Context, txHash string, logIndex uint) error {
+	return s.DB.WithContext(ctx).Model(&model.Transfer{}).
+		Where("tx_hash = ? AND log_index = ?", txHash, logIndex).
+		Update("chain_state", model.ChainStateOrphaned).Error
+}
+
+// ProvenanceEntry 来源记录：一次转移，托管与结算附带对应的拍卖和成交价
+type ProvenanceEntry struct {
+	model.Transfer
+	AuctionID    uint64 `json:"auction_id,omitempty"`    // 托管/结算对应的拍卖（未索引到时为0）
+	Sale         bool   `json:"sale"`                    // 拍卖成交（结算给最高出价者）
+	Price        string `json:"price,omitempty"`         // 成交价（最小单位）
+	PaymentToken string `json:"payment_token,omitempty"` // ERC20支付币种，ETH为空
+
+	// 展示字段（由 TokenService 按支付币种精度填充）
+	PaymentSymbol  string `json:"payment_symbol,omitempty"`
+	PriceFormatted string `json:"price_formatted,omitempty"`
+}
+
+// GetProvenance 按时间顺序返回NFT的全部转移记录（不含被重组移除的），并关联拍卖成交价
+//
+//	escrow     与创建拍卖在同一笔交易中，按交易哈希关联拍卖
+//	settlement 关联转出前最近创建的拍卖；拍卖已结束、有出价且转给最高出价者时为成交
+func (s *NFTService) GetProvenance(ctx context.Context, contractAddr, tokenID string) ([]ProvenanceEntry, error) {
+	var transfers []model.Transfer
+	if err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND token_id = ? AND chain_state <> ?", contractAddr, tokenID, model.ChainStateOrphaned).
+		Order("block_number ASC, log_index ASC").
+		Find(&transfers).Error; err != nil {
+		return nil, fmt.Errorf("查询转移记录失败: %v", err)
+	}
+
+	var auctions []model.Auction
+	if err := s.DB.WithContext(ctx).
+		Where("LOWER(nft_contract) = ? AND token_id = ? AND chain_state <> ?", strings.ToLower(contractAddr), tokenID, model.ChainStateOrphaned).
+		Order("block_number ASC, auction_id ASC").
+		Find(&auctions).Error; err != nil {
+		return nil, fmt.Errorf("查询拍卖记录失败: %v", err)
+	}
+
+	entries := make([]ProvenanceEntry, 0, len(transfers))
+	for _, transfer := range transfers {
+		entry := ProvenanceEntry{Transfer: transfer}
+		var auction *model.Auction
+		switch transfer.Kind {
+		case model.TransferKindEscrow:
+			auction = escrowAuction(auctions, transfer)
+		case model.TransferKindSettlement:
+			auction = settledAuction(auctions, transfer)
+		}
+		if auction != nil {
+			entry.AuctionID = auction.AuctionID
+			entry.PaymentToken = auction.PaymentToken
+			if transfer.Kind == model.TransferKindSettlement && auction.Ended &&
+				strings.EqualFold(auction.HighestBidder, transfer.To) {
+				if price, ok := new(big.Int).SetString(auction.HighestBid, 10); ok && price.Sign() > 0 {
+					entry.Sale = true
+					entry.Price = price.String()
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// escrowAuction 托管转移对应的拍卖：同一交易创建的拍卖，找不到时取该区块之后卖家最早创建的拍卖
+func escrowAuction(auctions []model.Auction, transfer model.Transfer) *model.Auction {
+	for i := range auctions {
+		if strings.EqualFold(auctions[i].TxHash, transfer.TxHash) {
+			return &auctions[i]
+		}
+	}
+	for i := range auctions {
+		if auctions[i].BlockNumber >= transfer.BlockNumber && strings.EqualFold(auctions[i].Seller, transfer.From) {
+			return &auctions[i]
+		}
+	}
+	return nil
+}
+
+// settledAuction 结算转移对应的拍卖：转出前（含同一区块）最近创建的拍卖
+func settledAuction(auctions []model.Auction, transfer model.Transfer) *model.Auction {
+	for i := len(auctions) - 1; i >= 0; i-- {
+		if auctions[i].BlockNumber <= transfer.BlockNumber {
+			return &auctions[i]
+		}
+	}
+	return nil
+}
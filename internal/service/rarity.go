@@ -0,0 +1,178 @@
+// rarity.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+// ==================== 属性统计与稀有度 ====================
+//
+//	合集内已抓取元数据的NFT数量 = N，某属性值 (trait_type, value) 出现次数 = n
+//	    属性稀有度得分 rarity_score       = Σ N/n   （越高越稀有，用于排名）
+//	    统计稀有度     statistical_rarity = Π n/N   （越低越稀有）
+
+// TraitFilter 属性过滤：trait_type → 可选值（同一属性内为 OR，不同属性之间为 AND）
+type TraitFilter map[string][]string
+
+// ApplyTraitFilter 为查询追加属性过滤条件；contractCol / tokenCol 为主表中合约地址与 TokenID 的列
+func ApplyTraitFilter(query *gorm.DB, contractCol, tokenCol string, filter TraitFilter) *gorm.DB {
+	for traitType, values := range filter {
+		if len(values) == 0 {
+			continue
+		}
+		query = query.Where(fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM token_attributes ta WHERE ta.contract_address = %s AND ta.token_id = %s AND ta.trait_type = ? AND ta.value IN ?)",
+			contractCol, tokenCol), traitType, values)
+	}
+	return query
+}
+
+// TraitCount 单个属性值的数量与占比
+type TraitCount struct {
+	TraitType string  `json:"trait_type"`
+	Value     string  `json:"value"`
+	Count     int64   `json:"count"`
+	Frequency float64 `json:"frequency"` // count / 已抓取元数据的NFT数量
+}
+
+// TraitCounts 合集的属性统计，返回各属性值数量和已抓取元数据的NFT总数
+func (s *MetadataService) TraitCounts(ctx context.Context, contractAddr string) ([]TraitCount, int64, error) {
+	var total int64
+	if err := s.DB.WithContext(ctx).Model(&model.TokenMetadata{}).
+		Where("contract_address = ? AND status = ?", contractAddr, model.MetadataStatusFetched).
+		Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计NFT数量失败: %v", err)
+	}
+
+	var counts []TraitCount
+	if err := s.DB.WithContext(ctx).Model(&model.TokenAttribute{}).
+		Select("trait_type, value, COUNT(DISTINCT token_id) AS count").
+		Where("contract_address = ?", contractAddr).
+		Where("token_id IN (?)", s.DB.Model(&model.TokenMetadata{}).Select("token_id").
+			Where("contract_address = ? AND status = ?", contractAddr, model.MetadataStatusFetched)).
+		Group("trait_type, value").
+		Order("trait_type ASC, count DESC, value ASC").
+		Scan(&counts).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计属性失败: %v", err)
+	}
+
+	for i := range counts {
+		if total > 0 {
+			counts[i].Frequency = float64(counts[i].Count) / float64(total)
+		}
+	}
+	return counts, total, nil
+}
+
+// RecomputeRarity 重新计算合集内所有已抓取元数据NFT的稀有度与排名
+func (s *MetadataService) RecomputeRarity(ctx context.Context, contractAddr string) error {
+	var tokens []model.TokenMetadata
+	if err := s.DB.WithContext(ctx).
+		Select("id, token_id").
+		Where("contract_address = ? AND status = ?", contractAddr, model.MetadataStatusFetched).
+		Find(&tokens).Error; err != nil {
+		return fmt.Errorf("查询元数据失败: %v", err)
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	counts, total, err := s.TraitCounts(ctx, contractAddr)
+	if err != nil {
+		return err
+	}
+	frequency := make(map[[2]string]int64, len(counts))
+	for _, c := range counts {
+		frequency[[2]string{c.TraitType, c.Value}] = c.Count
+	}
+
+	var attributes []model.TokenAttribute
+	if err := s.DB.WithContext(ctx).
+		Where("contract_address = ?", contractAddr).
+		Find(&attributes).Error; err != nil {
+		return fmt.Errorf("查询属性失败: %v", err)
+	}
+	traits := make(map[string]map[[2]string]bool)
+	for _, attr := range attributes {
+		if traits[attr.TokenID] == nil {
+			traits[attr.TokenID] = make(map[[2]string]bool)
+		}
+		// 同一属性值重复声明只计一次
+		traits[attr.TokenID][[2]string{attr.TraitType, attr.Value}] = true
+	}
+
+	for i := range tokens {
+		score, statistical := 0.0, 1.0
+		for key := range traits[tokens[i].TokenID] {
+			n := frequency[key]
+			if n == 0 {
+				continue
+			}
+			score += float64(total) / float64(n)
+			statistical *= float64(n) / float64(total)
+		}
+		tokens[i].RarityScore = score
+		tokens[i].StatisticalRarity = statistical
+	}
+
+	// 得分高的排前面，同分同名次；同分按 TokenID 数值排序保证结果稳定
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].RarityScore != tokens[j].RarityScore {
+			return tokens[i].RarityScore > tokens[j].RarityScore
+		}
+		return compareTokenID(tokens[i].TokenID, tokens[j].TokenID) < 0
+	})
+	for i := range tokens {
+		if i > 0 && tokens[i].RarityScore == tokens[i-1].RarityScore {
+			tokens[i].RarityRank = tokens[i-1].RarityRank
+		} else {
+			tokens[i].RarityRank = i + 1
+		}
+	}
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 重新抓取中（pending/failed）的NFT不参与排名
+		if err := tx.Model(&model.TokenMetadata{}).
+			Where("contract_address = ? AND status <> ?", contractAddr, model.MetadataStatusFetched).
+			Updates(map[string]interface{}{"rarity_score": 0, "statistical_rarity": 0, "rarity_rank": 0}).Error; err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			if err := tx.Model(&model.TokenMetadata{}).Where("id = ?", token.ID).
+				Updates(map[string]interface{}{
+					"rarity_score":       token.RarityScore,
+					"statistical_rarity": token.StatisticalRarity,
+					"rarity_rank":        token.RarityRank,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("保存稀有度失败: %v", err)
+	}
+
+	log.Printf("📊 稀有度已更新: %s（%d 个NFT，%d 个属性值）", contractAddr, len(tokens), len(counts))
+	return nil
+}
+
+// compareTokenID 按数值比较十进制 TokenID（不限长度）
+func compareTokenID(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+// seedRarityCollection 合集 alice：
+//
+//	1: gold + crown   2: blue（重复声明）   3: blue + crown   4: blue   10: gold + crown
+//	5: gold（元数据重新抓取中，不参与统计）
+//
+// 另一个合集 bob 的 1 号NFT同样是 gold
+func seedRarityCollection(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	tokens := []struct {
+		contract, tokenID, status string
+		traits                    [][2]string
+	}{
+		{alice, "1", model.MetadataStatusFetched, [][2]string{{"background", "gold"}, {"hat", "crown"}}},
+		{alice, "2", model.MetadataStatusFetched, [][2]string{{"background", "blue"}, {"background", "blue"}}},
+		{alice, "3", model.MetadataStatusFetched, [][2]string{{"background", "blue"}, {"hat", "crown"}}},
+		{alice, "4", model.MetadataStatusFetched, [][2]string{{"background", "blue"}}},
+		{alice, "10", model.MetadataStatusFetched, [][2]string{{"background", "gold"}, {"hat", "crown"}}},
+		{alice, "5", model.MetadataStatusPending, [][2]string{{"background", "gold"}}},
+		{bob, "1", model.MetadataStatusFetched, [][2]string{{"background", "gold"}}},
+	}
+	for _, token := range tokens {
+		metadata := model.TokenMetadata{ContractAddress: token.contract, TokenID: token.tokenID, Status: token.status}
+		if token.status != model.MetadataStatusFetched {
+			// 上一次计算留下的排名
+			metadata.RarityScore, metadata.StatisticalRarity, metadata.RarityRank = 9, 0.5, 1
+		}
+		if err := db.Create(&metadata).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, trait := range token.traits {
+			attr := model.TokenAttribute{ContractAddress: token.contract, TokenID: token.tokenID, TraitType: trait[0], Value: trait[1]}
+			if err := db.Create(&attr).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// 已抓取 5 个：gold 2、blue 3、crown 3；得分 Σ N/n，同分同名次，下一名次跳过
+func TestRecomputeRarityRanksWithTies(t *testing.T) {
+	db := newTestDB(t)
+	seedRarityCollection(t, db)
+	s := &MetadataService{DB: db}
+
+	if err := s.RecomputeRarity(context.Background(), alice); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		score, statistical float64
+		rank               int
+	}{
+		"1":  {5.0/2 + 5.0/3, 2.0 / 5 * 3.0 / 5, 1},
+		"10": {5.0/2 + 5.0/3, 2.0 / 5 * 3.0 / 5, 1},
+		"3":  {5.0/3 + 5.0/3, 3.0 / 5 * 3.0 / 5, 3},
+		"2":  {5.0 / 3, 3.0 / 5, 4},
+		"4":  {5.0 / 3, 3.0 / 5, 4},
+		"5":  {0, 0, 0},
+	}
+	var tokens []model.TokenMetadata
+	if err := db.Where("contract_address = ?", alice).Find(&tokens).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(want))
+	}
+	for _, token := range tokens {
+		w := want[token.TokenID]
+		if math.Abs(token.RarityScore-w.score) > 1e-9 || math.Abs(token.StatisticalRarity-w.statistical) > 1e-9 || token.RarityRank != w.rank {
+			t.Errorf("token %s: score=%v statistical=%v rank=%d; want %v %v %d",
+				token.TokenID, token.RarityScore, token.StatisticalRarity, token.RarityRank, w.score, w.statistical, w.rank)
+		}
+	}
+
+	// 其他合集不受影响
+	var other model.TokenMetadata
+	if err := db.Where("contract_address = ?", bob).First(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+	if other.RarityRank != 0 {
+		t.Fatalf("other collection rank = %d, want 0", other.RarityRank)
+	}
+}
+
+func TestCompareTokenID(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"2", "10", -1},
+		{"10", "9", 1},
+		{"007", "7", 0},
+		{"115792089237316195423570985008687907853269984665640564039457584007913129639935", "1", 1},
+	}
+	for _, tc := range cases {
+		if got := compareTokenID(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareTokenID(%s, %s) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+// 同一属性内的值为 OR，不同属性之间为 AND；没有可选值的属性忽略
+func TestApplyTraitFilter(t *testing.T) {
+	db := newTestDB(t)
+	seedRarityCollection(t, db)
+
+	cases := []struct {
+		name   string
+		filter TraitFilter
+		want   []string
+	}{
+		{"none", nil, []string{"1", "10", "2", "3", "4", "5"}},
+		{"single value", TraitFilter{"background": {"gold"}}, []string{"1", "10", "5"}},
+		{"values are OR", TraitFilter{"background": {"gold", "blue"}}, []string{"1", "10", "2", "3", "4", "5"}},
+		{"traits are AND", TraitFilter{"background": {"blue"}, "hat": {"crown"}}, []string{"3"}},
+		{"empty values ignored", TraitFilter{"background": {"gold"}, "hat": {}}, []string{"1", "10", "5"}},
+		{"no match", TraitFilter{"hat": {"cap"}}, []string{}},
+	}
+	for _, tc := range cases {
+		query := db.Model(&model.TokenMetadata{}).Where("contract_address = ?", alice)
+		query = ApplyTraitFilter(query, "token_metadata.contract_address", "token_metadata.token_id", tc.filter)
+		var tokenIDs []string
+		if err := query.Order("token_id").Pluck("token_id", &tokenIDs).Error; err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(tokenIDs, tc.want) {
+			t.Errorf("%s: tokens %v, want %v", tc.name, tokenIDs, tc.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/model"
+)
+
+// ReorgService 负责确认深度与链重组相关的数据库操作
+//
+//	区块N被处理 → 记录 (N, hash) → 等待 confirmations 个区块
+//	    ├─ hash 仍在主链上 → pending 晋升为 confirmed
+//	    └─ hash 不在主链上 → 高度≥N 的实体标记为 orphaned，重新拉取日志
+type ReorgService struct {
+	DB            *gorm.DB
+	confirmations uint64
+}
+
+// ReorgAffected 重组影响到的实体
+type ReorgAffected struct {
+	AuctionIDs      []uint64 // 创建区块、出价或结束被重组的拍卖
+	EndedAuctionIDs []uint64 // 结束（成交）被重组的拍卖，需要从链上刷新结束状态
+	NFTs            []NFTRef // 最近变更被重组的NFT
+}
+
+// NFTRef 合约地址 + TokenID
+type NFTRef struct {
+	ContractAddress string
+	TokenID         string
+}
+
+// NewReorgService 创建重组服务
+func NewReorgService(db *gorm.DB, confirmations uint64) *ReorgService {
+	return &ReorgService{DB: db, confirmations: confirmations}
+}
+
+// Confirmations 返回确认深度
+func (s *ReorgService) Confirmations() uint64 {
+	return s.confirmations
+}
+
+// RecordBlock 记录已处理区块的哈希（同一高度出现新哈希时覆盖）
+func (s *ReorgService) RecordBlock(ctx context.Context, number uint64, hash string) error {
+	block := &model.ProcessedBlock{Number: number, Hash: hash}
+	err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hash"}),
+	}).Create(block).Error
+	if err != nil {
+		return fmt.Errorf("记录区块 %d 失败: %v", number, err)
+	}
+	return nil
+}
+
+// UnconfirmedBlocks 获取仍处于确认窗口内的已处理区块（按高度升序）
+func (s *ReorgService) UnconfirmedBlocks(ctx context.Context, head uint64) ([]model.ProcessedBlock, error) {
+	var blocks []model.ProcessedBlock
+	err := s.DB.WithContext(ctx).
+		Where("number > ?", s.threshold(head)).
+		Order("number ASC").
+		Find(&blocks).Error
+	return blocks, err
+}
+
+// ForkHeight 从第一个哈希不一致的已记录区块 mismatched 向下查找分叉高度
+// 只有含日志的区块、其父区块与检查点区块有记录，真正的分叉点可能更低：
+// 取低于 mismatched 且哈希仍在主链上的最高已记录区块，从它的下一个区块开始重新拉取
+// canonical 返回主链上该高度的区块哈希；返回值不小于1（创世区块不会被重组）
+func (s *ReorgService) ForkHeight(ctx context.Context, mismatched uint64, canonical func(number uint64) (string, error)) (uint64, error) {
+	var blocks []model.ProcessedBlock
+	if err := s.DB.WithContext(ctx).
+		Where("number < ?", mismatched).
+		Order("number DESC").
+		Find(&blocks).Error; err != nil {
+		return 0, fmt.Errorf("查询已处理区块失败: %v", err)
+	}
+
+	fork := mismatched
+	for _, block := range blocks {
+		hash, err := canonical(block.Number)
+		if err != nil {
+			return 0, err
+		}
+		if hash == block.Hash {
+			fork = block.Number + 1
+			break
+		}
+		// 这个区块也被替换了，分叉点至少在它这里
+		fork = block.Number
+	}
+	if fork == 0 {
+		fork = 1
+	}
+	return fork, nil
+}
+
+// OrphanFrom 将高度≥height 的实体标记为 orphaned，并删除这些区块的哈希记录
+func (s *ReorgService) OrphanFrom(ctx context.Context, height uint64) (*ReorgAffected, error) {
+	affected := &ReorgAffected{}
+
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var bidAuctionIDs []uint64
+		if err := tx.Model(&model.BidHistory{}).
+			Where("block_number >= ? AND status <> ?", height, model.ChainStateOrphaned).
+			Distinct().Pluck("auction_id", &bidAuctionIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.BidHistory{}).
+			Where("block_number >= ?", height).
+			Update("status", model.ChainStateOrphaned).Error; err != nil {
+			return err
+		}
+
+		var createdAuctionIDs []uint64
+		if err := tx.Model(&model.Auction{}).
+			Where("block_number >= ?", height).
+			Pluck("auction_id", &createdAuctionIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Auction{}).
+			Where("block_number >= ?", height).
+			Update("chain_state", model.ChainStateOrphaned).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.NFTInfo{}).
+			Select("contract_address, token_id").
+			Where("block_number >= ?", height).
+			Scan(&affected.NFTs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.NFTInfo{}).
+			Where("block_number >= ?", height).
+			Update("chain_state", model.ChainStateOrphaned).Error; err != nil {
+			return err
+		}
+
+		// AuctionEnded 被移除而新链上没有重新包含时，拍卖仍是已结束状态，需要从链上刷新
+		if err := tx.Model(&model.LedgerEntry{}).
+			Where("kind = ? AND block_number >= ? AND chain_state <> ?", model.LedgerSellerPaid, height, model.ChainStateOrphaned).
+			Distinct().Pluck("auction_id", &affected.EndedAuctionIDs).Error; err != nil {
+			return err
+		}
+
+		for _, m := range []interface{}{&model.Withdrawal{}, &model.OperatorApproval{}, &model.Transfer{}, &model.BidRefund{}, &model.LedgerEntry{}} {
+			if err := tx.Model(m).
+				Where("block_number >= ?", height).
+				Update("chain_state", model.ChainStateOrphaned).Error; err != nil {
+				return err
+			}
+		}
+
+		// 原始事件日志同样标记移除，重新拉取时新主链上的日志会恢复
+		if err := tx.Model(&model.RawEvent{}).
+			Where("block_number >= ?", height).
+			Update("removed", true).Error; err != nil {
+			return err
+		}
+
+		affected.AuctionIDs = mergeUint64(mergeUint64(bidAuctionIDs, createdAuctionIDs), affected.EndedAuctionIDs)
+		return tx.Where("number >= ?", height).Delete(&model.ProcessedBlock{}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("标记重组实体失败: %v", err)
+	}
+	return affected, nil
+}
+
+// PromoteConfirmed 更新确认数，并把达到确认深度的 pending 实体晋升为 confirmed
+func (s *ReorgService) PromoteConfirmed(ctx context.Context, head uint64) error {
+	db := s.DB.WithContext(ctx)
+
+	if err := db.Model(&model.BidHistory{}).
+		Where("status = ? AND block_number <= ?", model.ChainStatePending, head).
+		Update("confirmations", gorm.Expr("? - block_number + 1", head)).Error; err != nil {
+		return fmt.Errorf("更新确认数失败: %v", err)
+	}
+
+	if head < s.confirmations {
+		return nil
+	}
+	threshold := s.threshold(head)
+
+	if err := db.Model(&model.BidHistory{}).
+		Where("status = ? AND block_number <= ?", model.ChainStatePending, threshold).
+		Update("status", model.ChainStateConfirmed).Error; err != nil {
+		return fmt.Errorf("确认出价失败: %v", err)
+	}
+	if err := db.Model(&model.Bid{}).
+		Where("status = ? AND block_number <= ?", model.ChainStatePending, threshold).
+		Updates(map[string]interface{}{"status": model.ChainStateConfirmed, "confirmed_at": time.Now()}).Error; err != nil {
+		return fmt.Errorf("确认当前出价失败: %v", err)
+	}
+	if err := db.Model(&model.Auction{}).
+		Where("chain_state = ? AND block_number <= ?", model.ChainStatePending, threshold).
+		Update("chain_state", model.ChainStateConfirmed).Error; err != nil {
+		return fmt.Errorf("确认拍卖失败: %v", err)
+	}
+	if err := db.Model(&model.NFTInfo{}).
+		Where("chain_state = ? AND block_number <= ?", model.ChainStatePending, threshold).
+		Update("chain_state", model.ChainStateConfirmed).Error; err != nil {
+		return fmt.Errorf("确认NFT失败: %v", err)
+	}
+
+	for _, m := range []interface{}{&model.Withdrawal{}, &model.OperatorApproval{}, &model.Transfer{}, &model.BidRefund{}, &model.LedgerEntry{}} {
+		if err := db.Model(m).
+			Where("chain_state = ? AND block_number <= ?", model.ChainStatePending, threshold).
+			Update("chain_state", model.ChainStateConfirmed).Error; err != nil {
+			return fmt.Errorf("确认合约事件失败: %v", err)
+		}
+	}
+
+	// 已确认区块的哈希只保留一个确认窗口作为缓冲
+	if threshold > s.confirmations {
+		if err := db.Where("number < ?", threshold-s.confirmations).
+			Delete(&model.ProcessedBlock{}).Error; err != nil {
+			return fmt.Errorf("清理区块记录失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// threshold 小于等于该高度的区块视为已确认
+func (s *ReorgService) threshold(head uint64) uint64 {
+	if head < s.confirmations {
+		return 0
+	}
+	return head - s.confirmations
+}
+
+// mergeUint64 合并去重
+func mergeUint64(a, b []uint64) []uint64 {
+	seen := make(map[uint64]bool, len(a)+len(b))
+	var result []uint64
+	for _, list := range [][]uint64{a, b} {
+		for _, v := range list {
+			if !seen[v] {
+				seen[v] = true
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+// fakeChain 主链区块哈希：高度 ≥ forkAt 的区块被替换
+type fakeChain struct {
+	forkAt uint64
+	calls  []uint64
+}
+
+func (c *fakeChain) hash(number uint64) (string, error) {
+	c.calls = append(c.calls, number)
+	if number >= c.forkAt {
+		return fmt.Sprintf("0xnew%d", number), nil
+	}
+	return fmt.Sprintf("0xold%d", number), nil
+}
+
+func recordBlocks(t *testing.T, s *ReorgService, numbers ...uint64) {
+	t.Helper()
+	for _, n := range numbers {
+		if err := s.RecordBlock(context.Background(), n, fmt.Sprintf("0xold%d", n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestForkHeightWalksBackToMatchingBlock(t *testing.T) {
+	s := NewReorgService(newTestDB(t), 12)
+	// 只有含日志的区块与检查点区块有记录，真正的分叉点 95 落在两条记录之间
+	recordBlocks(t, s, 80, 90, 97, 100)
+	chain := &fakeChain{forkAt: 95}
+
+	fork, err := s.ForkHeight(context.Background(), 100, chain.hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != 91 {
+		t.Fatalf("fork = %d, want 91 (after highest matching record 90)", fork)
+	}
+	if !reflect.DeepEqual(chain.calls, []uint64{97, 90}) {
+		t.Fatalf("checked blocks %v, want [97 90]", chain.calls)
+	}
+}
+
+func TestForkHeightWithoutMatchingRecord(t *testing.T) {
+	s := NewReorgService(newTestDB(t), 12)
+	recordBlocks(t, s, 50, 60)
+	chain := &fakeChain{forkAt: 10}
+
+	fork, err := s.ForkHeight(context.Background(), 60, chain.hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != 50 {
+		t.Fatalf("fork = %d, want lowest replaced record 50", fork)
+	}
+}
+
+func TestForkHeightNeverGenesis(t *testing.T) {
+	s := NewReorgService(newTestDB(t), 12)
+	recordBlocks(t, s, 0, 1)
+	chain := &fakeChain{forkAt: 0}
+
+	fork, err := s.ForkHeight(context.Background(), 1, chain.hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork != 1 {
+		t.Fatalf("fork = %d, want 1", fork)
+	}
+}
+
+// AuctionEnded 在分叉之后：拍卖需要从链上刷新结束状态，成交流水标记 orphaned
+func TestOrphanFromIncludesEndedAuctions(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewReorgService(db, 12)
+
+	// 拍卖1在分叉前创建，分叉后结束；拍卖2在分叉前结束
+	for _, a := range []model.Auction{
+		{AuctionID: 1, BlockNumber: 50, Ended: true, Status: "ended", ChainState: model.ChainStatePending},
+		{AuctionID: 2, BlockNumber: 40, Ended: true, Status: "ended", ChainState: model.ChainStatePending},
+	} {
+		if err := db.Create(&a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []model.LedgerEntry{
+		{Kind: model.LedgerSellerPaid, Ref: auctionLedgerRef(1), AuctionID: 1, BlockNumber: 105, ChainState: model.ChainStatePending},
+		{Kind: model.LedgerSellerPaid, Ref: auctionLedgerRef(2), AuctionID: 2, BlockNumber: 60, ChainState: model.ChainStatePending},
+	} {
+		if err := saveLedgerEntry(db, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	affected, err := s.OrphanFrom(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(affected.EndedAuctionIDs, []uint64{1}) {
+		t.Fatalf("ended auctions = %v, want [1]", affected.EndedAuctionIDs)
+	}
+	if !reflect.DeepEqual(affected.AuctionIDs, []uint64{1}) {
+		t.Fatalf("affected auctions = %v, want [1]", affected.AuctionIDs)
+	}
+
+	var entry model.LedgerEntry
+	if err := db.Where("ref = ?", auctionLedgerRef(1)).First(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	if entry.ChainState != model.ChainStateOrphaned {
+		t.Fatalf("seller_paid chain_state = %s", entry.ChainState)
+	}
+}
+
+// createAll 逐条插入测试数据
+func createAll(t *testing.T, db *gorm.DB, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// columnByBlock 按区块高度升序读取某一列
+func columnByBlock(t *testing.T, db *gorm.DB, m interface{}, column string) []string {
+	t.Helper()
+	var values []string
+	if err := db.Model(m).Order("block_number").Pluck(column, &values).Error; err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+// 分叉高度及以上的实体标记为 orphaned，以下的保持不变
+func TestOrphanFromMarksEntitiesFromHeight(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewReorgService(db, 12)
+	pending := model.ChainStatePending
+
+	createAll(t, db,
+		&model.BidHistory{AuctionID: 1, TxHash: "0x01", BlockNumber: 99, Status: pending},
+		&model.BidHistory{AuctionID: 1, TxHash: "0x02", BlockNumber: 100, Status: pending},
+		&model.BidHistory{AuctionID: 2, TxHash: "0x03", BlockNumber: 105, Status: pending},
+		&model.Auction{AuctionID: 1, BlockNumber: 90, ChainState: pending},
+		&model.Auction{AuctionID: 3, BlockNumber: 101, ChainState: pending},
+		&model.NFTInfo{ContractAddress: alice, TokenID: "1", BlockNumber: 99, ChainState: pending},
+		&model.NFTInfo{ContractAddress: alice, TokenID: "2", BlockNumber: 102, ChainState: pending},
+		&model.Transfer{TxHash: "0x04", BlockNumber: 98, ChainState: pending},
+		&model.Transfer{TxHash: "0x05", BlockNumber: 103, ChainState: pending},
+		&model.BidRefund{BidID: 1, BlockNumber: 97, ChainState: pending},
+		&model.BidRefund{BidID: 2, BlockNumber: 105, ChainState: pending},
+		&model.RawEvent{TxHash: "0x06", BlockNumber: 99},
+		&model.RawEvent{TxHash: "0x07", BlockNumber: 100},
+	)
+	for _, e := range []model.LedgerEntry{
+		{Kind: model.LedgerBidEscrowed, Ref: "bid:1", AuctionID: 1, BlockNumber: 99, ChainState: pending},
+		{Kind: model.LedgerBidEscrowed, Ref: "bid:2", AuctionID: 1, BlockNumber: 100, ChainState: pending},
+	} {
+		if err := saveLedgerEntry(db, &e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recordBlocks(t, s, 98, 99, 100, 105)
+
+	affected, err := s.OrphanFrom(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(affected.AuctionIDs, []uint64{1, 2, 3}) {
+		t.Fatalf("affected auctions = %v, want [1 2 3]", affected.AuctionIDs)
+	}
+	if !reflect.DeepEqual(affected.NFTs, []NFTRef{{ContractAddress: alice, TokenID: "2"}}) {
+		t.Fatalf("affected NFTs = %v", affected.NFTs)
+	}
+	if len(affected.EndedAuctionIDs) != 0 {
+		t.Fatalf("ended auctions = %v, want none", affected.EndedAuctionIDs)
+	}
+
+	orphaned := model.ChainStateOrphaned
+	for _, tc := range []struct {
+		model  interface{}
+		column string
+		want   []string
+	}{
+		{&model.BidHistory{}, "status", []string{pending, orphaned, orphaned}},
+		{&model.Auction{}, "chain_state", []string{pending, orphaned}},
+		{&model.NFTInfo{}, "chain_state", []string{pending, orphaned}},
+		{&model.Transfer{}, "chain_state", []string{pending, orphaned}},
+		{&model.BidRefund{}, "chain_state", []string{pending, orphaned}},
+		{&model.LedgerEntry{}, "chain_state", []string{pending, orphaned}},
+		{&model.RawEvent{}, "removed", []string{"0", "1"}},
+	} {
+		if got := columnByBlock(t, db, tc.model, tc.column); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%T %s = %v, want %v", tc.model, tc.column, got, tc.want)
+		}
+	}
+
+	var blocks []uint64
+	if err := db.Model(&model.ProcessedBlock{}).Order("number").Pluck("number", &blocks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(blocks, []uint64{98, 99}) {
+		t.Fatalf("processed blocks = %v, want [98 99]", blocks)
+	}
+}
+
+// 确认数随区块头更新，达到确认深度的 pending 实体晋升，orphaned 的不受影响
+func TestPromoteConfirmed(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewReorgService(db, 6)
+	pending, confirmed, orphaned := model.ChainStatePending, model.ChainStateConfirmed, model.ChainStateOrphaned
+
+	createAll(t, db,
+		&model.BidHistory{AuctionID: 1, TxHash: "0x01", BlockNumber: 94, Status: pending},
+		&model.BidHistory{AuctionID: 1, TxHash: "0x02", BlockNumber: 95, Status: pending},
+		&model.BidHistory{AuctionID: 1, TxHash: "0x03", BlockNumber: 96, Status: orphaned},
+		&model.Auction{AuctionID: 1, BlockNumber: 94, ChainState: pending},
+		&model.Auction{AuctionID: 2, BlockNumber: 95, ChainState: pending},
+		&model.Auction{AuctionID: 3, BlockNumber: 90, ChainState: orphaned},
+		&model.Transfer{TxHash: "0x04", BlockNumber: 94, ChainState: pending},
+		&model.Transfer{TxHash: "0x05", BlockNumber: 95, ChainState: pending},
+	)
+	recordBlocks(t, s, 80, 85, 86, 94, 95)
+
+	// 区块头低于确认深度：只更新确认数
+	if err := s.PromoteConfirmed(ctx, 5); err != nil {
+		t.Fatal(err)
+	}
+	if got := columnByBlock(t, db, &model.BidHistory{}, "status"); !reflect.DeepEqual(got, []string{pending, pending, orphaned}) {
+		t.Fatalf("bid statuses at head 5 = %v", got)
+	}
+
+	// head 100，确认深度 6：区块 ≤94 晋升
+	if err := s.PromoteConfirmed(ctx, 100); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		model  interface{}
+		column string
+		want   []string
+	}{
+		{&model.BidHistory{}, "status", []string{confirmed, pending, orphaned}},
+		{&model.BidHistory{}, "confirmations", []string{"7", "6", "0"}},
+		{&model.Auction{}, "chain_state", []string{orphaned, confirmed, pending}},
+		{&model.Transfer{}, "chain_state", []string{confirmed, pending}},
+	} {
+		if got := columnByBlock(t, db, tc.model, tc.column); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%T %s = %v, want %v", tc.model, tc.column, got, tc.want)
+		}
+	}
+
+	// 区块记录保留一个确认窗口：number < 94-6 的被清理
+	var blocks []uint64
+	if err := db.Model(&model.ProcessedBlock{}).Order("number").Pluck("number", &blocks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(blocks, []uint64{94, 95}) {
+		t.Fatalf("processed blocks = %v, want [94 95]", blocks)
+	}
+}
@@ -0,0 +1,444 @@
+// snapshot_service.go
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+var (
+	// ErrSnapshotExists 快照名称已被使用
+	ErrSnapshotExists = errors.New("快照名称已存在")
+	// ErrSnapshotNotIndexed 快照区块超出已索引范围，结果会不完整
+	ErrSnapshotNotIndexed = errors.New("快照区块尚未索引")
+)
+
+// SnapshotService 时间点持有者快照（空投、白名单）
+//
+// 持有者由 transfers 表推导，被重组移除的转移不参与计算：
+//   - 快照区块（含）之前有转移：最后一次转移的接收方，转入零地址的视为已销毁
+//   - 只在快照区块之后有转移：之后第一次转移的转出方（转出方为零地址说明当时还未铸造）
+//   - 从未索引到转移（索引起始区块之前铸造且之后没有转移过）：nft_infos 中的当前所有者
+//
+// 快照区块不能超过已索引的检查点。
+type SnapshotService struct {
+	DB        *gorm.DB
+	eventSync *EventSyncService
+	headers   HeaderSource // 按时间戳取快照时查询区块头（未连接区块链时为 nil）
+}
+
+// HeaderSource 按高度读取区块头（RPC 池）
+type HeaderSource interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// NewSnapshotService 创建快照服务
+func NewSnapshotService(db *gorm.DB, eventSync *EventSyncService) *SnapshotService {
+	return &SnapshotService{
+		DB:        db,
+		eventSync: eventSync,
+	}
+}
+
+// SetHeaderSource 设置区块头来源（按时间戳取快照需要）
+func (s *SnapshotService) SetHeaderSource(headers HeaderSource) {
+	s.headers = headers
+}
+
+// SnapshotRequest 快照参数；Block 与 Timestamp 二选一，都为0时取已索引的最新区块
+type SnapshotRequest struct {
+	Name            string `json:"name"`
+	ContractAddress string `json:"contract"`
+	TokenID         string `json:"token_id"` // 为空表示整个合集
+	Block           uint64 `json:"block"`
+	Timestamp       uint64 `json:"timestamp"` // 按区块时间戳（秒）取快照
+	Mode            string `json:"mode"`      // holder（默认）| beneficial
+}
+
+// SnapshotHolderView 导出的持有者
+type SnapshotHolderView struct {
+	Address  string   `json:"address"`
+	Quantity int      `json:"quantity"`
+	TokenIDs []string `json:"token_ids"`
+}
+
+// SnapshotExport 快照及其持有者列表
+type SnapshotExport struct {
+	Snapshot model.Snapshot       `json:"snapshot"`
+	Holders  []SnapshotHolderView `json:"holders"`
+}
+
+// CreateSnapshot 计算快照并按名称保存
+func (s *SnapshotService) CreateSnapshot(ctx context.Context, req SnapshotRequest) (*model.Snapshot, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, fmt.Errorf("invalid snapshot: 名称不能为空")
+	}
+	if !common.IsHexAddress(req.ContractAddress) {
+		return nil, fmt.Errorf("invalid snapshot: 无效的合约地址 %q", req.ContractAddress)
+	}
+	contractAddr := common.HexToAddress(req.ContractAddress)
+	if req.TokenID != "" {
+		if _, ok := new(big.Int).SetString(req.TokenID, 10); !ok {
+			return nil, fmt.Errorf("invalid snapshot: 无效的 token_id %q", req.TokenID)
+		}
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = OwnershipModeHolder
+	case OwnershipModeHolder, OwnershipModeBeneficial:
+	default:
+		return nil, fmt.Errorf("invalid snapshot: mode 只能是 %s 或 %s", OwnershipModeHolder, OwnershipModeBeneficial)
+	}
+	if req.Block != 0 && req.Timestamp != 0 {
+		return nil, fmt.Errorf("invalid snapshot: block 与 timestamp 只能指定一个")
+	}
+
+	var existing int64
+	if err := s.DB.WithContext(ctx).Model(&model.Snapshot{}).Where("name = ?", req.Name).Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("查询快照失败: %v", err)
+	}
+	if existing > 0 {
+		return nil, ErrSnapshotExists
+	}
+
+	indexed, err := s.indexedThrough(ctx, contractAddr)
+	if err != nil {
+		return nil, err
+	}
+	block := req.Block
+	switch {
+	case req.Timestamp != 0:
+		if block, err = s.blockAtTime(ctx, req.Timestamp, indexed); err != nil {
+			return nil, err
+		}
+	case block == 0:
+		block = indexed
+	case block > indexed:
+		return nil, fmt.Errorf("%w: 请求区块 %d，已索引到 %d", ErrSnapshotNotIndexed, block, indexed)
+	}
+	if block == 0 {
+		return nil, fmt.Errorf("invalid snapshot: 不能在区块0取快照")
+	}
+
+	holders, err := s.HoldersAt(ctx, contractAddr.Hex(), req.TokenID, block, req.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &model.Snapshot{
+		Name:            req.Name,
+		ContractAddress: contractAddr.Hex(),
+		TokenID:         req.TokenID,
+		BlockNumber:     block,
+		Timestamp:       req.Timestamp,
+		Mode:            req.Mode,
+		HolderCount:     len(holders),
+	}
+	for _, h := range holders {
+		snapshot.TokenCount += h.Quantity
+	}
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+		rows := make([]model.SnapshotHolder, 0, len(holders))
+		for _, h := range holders {
+			rows = append(rows, model.SnapshotHolder{
+				SnapshotID: snapshot.ID,
+				Address:    h.Address,
+				Quantity:   h.Quantity,
+				TokenIDs:   strings.Join(h.TokenIDs, ","),
+			})
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存快照失败: %v", err)
+	}
+	return snapshot, nil
+}
+
+// HoldersAt 计算区块 block（含）时的持有者，按持有数量降序、地址升序
+func (s *SnapshotService) HoldersAt(ctx context.Context, contractAddr, tokenID string, block uint64, mode string) ([]SnapshotHolderView, error) {
+	query := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND chain_state <> ?", contractAddr, model.ChainStateOrphaned)
+	if tokenID != "" {
+		query = query.Where("token_id = ?", tokenID)
+	}
+
+	var transfers []model.Transfer
+	if err := query.Order("block_number ASC, log_index ASC").Find(&transfers).Error; err != nil {
+		return nil, fmt.Errorf("查询转移记录失败: %v", err)
+	}
+
+	// 每个 token 在快照区块（含）之前的最后一次转移与之后的第一次转移
+	last := make(map[string]model.Transfer)
+	next := make(map[string]model.Transfer)
+	for _, t := range transfers {
+		if t.BlockNumber <= block {
+			last[t.TokenID] = t
+		} else if _, ok := next[t.TokenID]; !ok {
+			next[t.TokenID] = t
+		}
+	}
+
+	byHolder := make(map[string][]string)
+	for id, t := range last {
+		if t.Kind == model.TransferKindBurn {
+			continue
+		}
+		holder := t.To
+		if mode == OwnershipModeBeneficial && t.Kind == model.TransferKindEscrow {
+			holder = t.From
+		}
+		byHolder[holder] = append(byHolder[holder], id)
+	}
+
+	// 快照区块之前没有转移：持有者是之后第一次转移的转出方
+	for id, t := range next {
+		if _, ok := last[id]; ok || t.Kind == model.TransferKindMint {
+			continue
+		}
+		holder := t.From
+		if mode == OwnershipModeBeneficial && t.Kind == model.TransferKindSettlement {
+			// 快照时托管在拍卖合约中：实际所有者是快照区块之前创建的拍卖的卖家
+			if seller, err := s.escrowSellerAt(ctx, contractAddr, id, block); err != nil {
+				return nil, err
+			} else if seller != "" {
+				holder = seller
+			}
+		}
+		byHolder[holder] = append(byHolder[holder], id)
+	}
+
+	// 从未索引到转移：索引开始前铸造、之后没有转移过，当前所有者就是快照时的所有者
+	seeded, err := s.untransferredHolders(ctx, contractAddr, tokenID, block, mode, last, next)
+	if err != nil {
+		return nil, err
+	}
+	for holder, ids := range seeded {
+		byHolder[holder] = append(byHolder[holder], ids...)
+	}
+
+	holders := make([]SnapshotHolderView, 0, len(byHolder))
+	for address, ids := range byHolder {
+		sortTokenIDs(ids)
+		holders = append(holders, SnapshotHolderView{Address: address, Quantity: len(ids), TokenIDs: ids})
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		if holders[i].Quantity != holders[j].Quantity {
+			return holders[i].Quantity > holders[j].Quantity
+		}
+		return holders[i].Address < holders[j].Address
+	})
+	return holders, nil
+}
+
+// untransferredHolders 没有任何转移记录的 token 按 nft_infos 当前所有者归属（已知铸造区块晚于快照的跳过）
+func (s *SnapshotService) untransferredHolders(ctx context.Context, contractAddr, tokenID string, block uint64, mode string, last, next map[string]model.Transfer) (map[string][]string, error) {
+	query := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND is_minted = ? AND owner <> '' AND owner <> ? AND chain_state <> ?",
+			contractAddr, true, common.Address{}.Hex(), model.ChainStateOrphaned).
+		Where("minted_block = 0 OR minted_block <= ?", block)
+	if tokenID != "" {
+		query = query.Where("token_id = ?", tokenID)
+	}
+
+	var nfts []model.NFTInfo
+	if err := query.Find(&nfts).Error; err != nil {
+		return nil, fmt.Errorf("查询NFT失败: %v", err)
+	}
+
+	holders := make(map[string][]string)
+	for _, nft := range nfts {
+		if _, ok := last[nft.TokenID]; ok {
+			continue
+		}
+		if _, ok := next[nft.TokenID]; ok {
+			continue
+		}
+		holder := nft.Owner
+		if mode == OwnershipModeBeneficial && nft.BeneficialOwner != "" {
+			holder = nft.BeneficialOwner
+		}
+		holders[holder] = append(holders[holder], nft.TokenID)
+	}
+	return holders, nil
+}
+
+// escrowSellerAt 区块 block 时托管该 token 的拍卖卖家（快照区块之前最近创建的拍卖）
+func (s *SnapshotService) escrowSellerAt(ctx context.Context, contractAddr, tokenID string, block uint64) (string, error) {
+	var auction model.Auction
+	err := s.DB.WithContext(ctx).
+		Where("LOWER(nft_contract) = ? AND token_id = ? AND block_number <= ? AND chain_state <> ?",
+			strings.ToLower(contractAddr), tokenID, block, model.ChainStateOrphaned).
+		Order("block_number DESC, auction_id DESC").
+		First(&auction).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询托管拍卖失败: %v", err)
+	}
+	return auction.Seller, nil
+}
+
+// indexedThrough 合约已完整索引到的区块（主合约与第三方合集使用不同的检查点）
+func (s *SnapshotService) indexedThrough(ctx context.Context, contractAddr common.Address) (uint64, error) {
+	for _, kind := range []string{NFTEventsKind, CollectionEventsKind} {
+		last, found, err := s.eventSync.GetLastBlock(ctx, kind, contractAddr)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			return last, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: 合约 %s 没有检查点", ErrSnapshotNotIndexed, contractAddr.Hex())
+}
+
+// blockAtTime 时间戳对应的快照区块：区块时间不晚于 ts 的最后一个区块（按区块头二分查找）
+// ts 晚于已索引区块的时间时，之后的区块可能还有未索引的转移，返回 ErrSnapshotNotIndexed
+func (s *SnapshotService) blockAtTime(ctx context.Context, ts, indexed uint64) (uint64, error) {
+	if s.headers == nil {
+		return 0, fmt.Errorf("invalid snapshot: 按时间戳取快照需要连接区块链")
+	}
+	headerTime := func(number uint64) (uint64, error) {
+		header, err := s.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return 0, fmt.Errorf("获取区块 %d 失败: %v", number, err)
+		}
+		return header.Time, nil
+	}
+
+	indexedTime, err := headerTime(indexed)
+	if err != nil {
+		return 0, err
+	}
+	if ts > indexedTime {
+		return 0, fmt.Errorf("%w: 时间戳 %d 晚于已索引区块 %d 的时间 %d", ErrSnapshotNotIndexed, ts, indexed, indexedTime)
+	}
+
+	if ts == indexedTime {
+		return indexed, nil
+	}
+
+	// 不变量：lo 的时间 <= ts（或 lo 为0），hi 的时间 > ts
+	lo, hi := uint64(0), indexed
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		t, err := headerTime(mid)
+		if err != nil {
+			return 0, err
+		}
+		if t <= ts {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	if lo == 0 {
+		return 0, fmt.Errorf("invalid snapshot: 时间戳 %d 早于链上第一个区块", ts)
+	}
+	return lo, nil
+}
+
+// ListSnapshots 已保存的快照，最新的在前
+func (s *SnapshotService) ListSnapshots(ctx context.Context) ([]model.Snapshot, error) {
+	var snapshots []model.Snapshot
+	if err := s.DB.WithContext(ctx).Order("id DESC").Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("查询快照失败: %v", err)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshot 按名称读取快照及持有者
+func (s *SnapshotService) GetSnapshot(ctx context.Context, name string) (*SnapshotExport, error) {
+	var snapshot model.Snapshot
+	if err := s.DB.WithContext(ctx).Where("name = ?", name).First(&snapshot).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []model.SnapshotHolder
+	if err := s.DB.WithContext(ctx).
+		Where("snapshot_id = ?", snapshot.ID).
+		Order("quantity DESC, address ASC").
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询快照持有者失败: %v", err)
+	}
+
+	export := &SnapshotExport{Snapshot: snapshot, Holders: make([]SnapshotHolderView, 0, len(rows))}
+	for _, row := range rows {
+		view := SnapshotHolderView{Address: row.Address, Quantity: row.Quantity, TokenIDs: []string{}}
+		if row.TokenIDs != "" {
+			view.TokenIDs = strings.Split(row.TokenIDs, ",")
+		}
+		export.Holders = append(export.Holders, view)
+	}
+	return export, nil
+}
+
+// DeleteSnapshot 删除快照及持有者
+func (s *SnapshotService) DeleteSnapshot(ctx context.Context, name string) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var snapshot model.Snapshot
+		if err := tx.Where("name = ?", name).First(&snapshot).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("snapshot_id = ?", snapshot.ID).Delete(&model.SnapshotHolder{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&snapshot).Error
+	})
+}
+
+// WriteCSV 导出 CSV：address,quantity,token_ids（token_ids 以空格分隔）
+func (e *SnapshotExport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"address", "quantity", "token_ids"}); err != nil {
+		return err
+	}
+	for _, h := range e.Holders {
+		if err := cw.Write([]string{h.Address, strconv.Itoa(h.Quantity), strings.Join(h.TokenIDs, " ")}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON 导出 JSON（快照信息与持有者列表）
+func (e *SnapshotExport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// sortTokenIDs 按数值排序 token ID
+func sortTokenIDs(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+}
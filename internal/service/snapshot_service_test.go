@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/model"
+)
+
+var (
+	snapContract = common.HexToAddress("0xC0")
+	snapEscrow   = common.HexToAddress("0xE5").Hex()
+	alice        = common.HexToAddress("0xA1").Hex()
+	bob          = common.HexToAddress("0xB0").Hex()
+	carol        = common.HexToAddress("0xCA").Hex()
+	zeroAddr     = common.Address{}.Hex()
+)
+
+// fakeHeaders 区块 n 的时间为 1000 + 12n
+type fakeHeaders struct{ calls int }
+
+func (f *fakeHeaders) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	f.calls++
+	return &types.Header{Number: number, Time: 1000 + 12*number.Uint64()}, nil
+}
+
+func addTransfer(t *testing.T, db *gorm.DB, tokenID string, block uint64, from, to, kind string) {
+	t.Helper()
+	err := db.Create(&model.Transfer{
+		ContractAddress: snapContract.Hex(),
+		TokenID:         tokenID,
+		From:            from,
+		To:              to,
+		Kind:            kind,
+		TxHash:          fmt.Sprintf("0x%d%s", block, tokenID),
+		BlockNumber:     block,
+		BlockTime:       1000 + 12*block,
+		ChainState:      model.ChainStatePending,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newSnapshotFixture(t *testing.T, indexed uint64) (*SnapshotService, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t)
+	eventSync := NewEventSyncService(db)
+	if err := eventSync.SaveLastBlock(context.Background(), NFTEventsKind, snapContract, indexed); err != nil {
+		t.Fatal(err)
+	}
+	return NewSnapshotService(db, eventSync), db
+}
+
+func holderMap(holders []SnapshotHolderView) map[string][]string {
+	m := make(map[string][]string)
+	for _, h := range holders {
+		m[h.Address] = h.TokenIDs
+	}
+	return m
+}
+
+func TestHoldersAt(t *testing.T) {
+	s, db := newSnapshotFixture(t, 100)
+	ctx := context.Background()
+
+	addTransfer(t, db, "1", 10, zeroAddr, alice, model.TransferKindMint)
+	addTransfer(t, db, "1", 50, alice, bob, model.TransferKindTransfer)
+	addTransfer(t, db, "2", 20, zeroAddr, alice, model.TransferKindMint)
+	addTransfer(t, db, "2", 30, alice, zeroAddr, model.TransferKindBurn)
+	addTransfer(t, db, "3", 40, zeroAddr, carol, model.TransferKindMint)
+	addTransfer(t, db, "3", 45, carol, snapEscrow, model.TransferKindEscrow)
+
+	cases := []struct {
+		block uint64
+		mode  string
+		want  map[string][]string
+	}{
+		{25, OwnershipModeHolder, map[string][]string{alice: {"1", "2"}}},
+		{49, OwnershipModeHolder, map[string][]string{alice: {"1"}, snapEscrow: {"3"}}},
+		{49, OwnershipModeBeneficial, map[string][]string{alice: {"1"}, carol: {"3"}}},
+		{100, OwnershipModeHolder, map[string][]string{bob: {"1"}, snapEscrow: {"3"}}},
+	}
+	for _, tc := range cases {
+		holders, err := s.HoldersAt(ctx, snapContract.Hex(), "", tc.block, tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := holderMap(holders); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("block %d %s: got %v, want %v", tc.block, tc.mode, got, tc.want)
+		}
+	}
+}
+
+// 索引开始前铸造的 token：之后有转移的取第一次转移的转出方，从未转移的取 nft_infos 当前所有者
+func TestHoldersAtSeedsPreIndexTokens(t *testing.T) {
+	s, db := newSnapshotFixture(t, 100)
+	ctx := context.Background()
+
+	// token 7：索引前铸造给 alice，区块 60 转给 bob
+	addTransfer(t, db, "7", 60, alice, bob, model.TransferKindTransfer)
+	// token 8：索引前铸造给 carol，从未转移
+	// token 9：区块 80 才铸造（转移记录晚于快照区块，转出方为零地址）
+	addTransfer(t, db, "9", 80, zeroAddr, alice, model.TransferKindMint)
+	// token 10：索引前被 carol 托管到拍卖（拍卖在区块 5 创建），区块 70 退回
+	addTransfer(t, db, "10", 70, snapEscrow, carol, model.TransferKindSettlement)
+	db.Create(&model.Auction{AuctionID: 1, NFTContract: snapContract.Hex(), TokenID: "10", Seller: carol, BlockNumber: 5, ChainState: model.ChainStateConfirmed})
+
+	for _, nft := range []model.NFTInfo{
+		{ContractAddress: snapContract.Hex(), TokenID: "7", Owner: bob, BeneficialOwner: bob, IsMinted: true},
+		{ContractAddress: snapContract.Hex(), TokenID: "8", Owner: carol, BeneficialOwner: carol, IsMinted: true},
+		{ContractAddress: snapContract.Hex(), TokenID: "9", Owner: alice, BeneficialOwner: alice, IsMinted: true, MintedBlock: 80},
+		{ContractAddress: snapContract.Hex(), TokenID: "10", Owner: carol, BeneficialOwner: carol, IsMinted: true},
+		{ContractAddress: snapContract.Hex(), TokenID: "11", Owner: zeroAddr, IsMinted: false},
+	} {
+		if err := db.Create(&nft).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	holders, err := s.HoldersAt(ctx, snapContract.Hex(), "", 50, OwnershipModeHolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{alice: {"7"}, carol: {"8"}, snapEscrow: {"10"}}
+	if got := holderMap(holders); !reflect.DeepEqual(got, want) {
+		t.Errorf("holder mode: got %v, want %v", got, want)
+	}
+
+	holders, err = s.HoldersAt(ctx, snapContract.Hex(), "", 50, OwnershipModeBeneficial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]string{alice: {"7"}, carol: {"8", "10"}}
+	if got := holderMap(holders); !reflect.DeepEqual(got, want) {
+		t.Errorf("beneficial mode: got %v, want %v", got, want)
+	}
+}
+
+func TestCreateSnapshotByTimestamp(t *testing.T) {
+	s, db := newSnapshotFixture(t, 100)
+	ctx := context.Background()
+	addTransfer(t, db, "1", 10, zeroAddr, alice, model.TransferKindMint)
+	addTransfer(t, db, "1", 50, alice, bob, model.TransferKindTransfer)
+
+	headers := &fakeHeaders{}
+	s.SetHeaderSource(headers)
+
+	// 区块 49 的时间 1588，区块 50 的时间 1600
+	snapshot, err := s.CreateSnapshot(ctx, SnapshotRequest{Name: "t1", ContractAddress: snapContract.Hex(), Timestamp: 1599})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.BlockNumber != 49 || snapshot.Timestamp != 1599 || snapshot.HolderCount != 1 {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+	if headers.calls > 10 {
+		t.Errorf("binary search used %d header lookups", headers.calls)
+	}
+
+	snapshot, err = s.CreateSnapshot(ctx, SnapshotRequest{Name: "t2", ContractAddress: snapContract.Hex(), Timestamp: 1600})
+	if err != nil || snapshot.BlockNumber != 50 {
+		t.Fatalf("exact timestamp: %+v %v", snapshot, err)
+	}
+
+	// 已索引区块 100 的时间是 2200，之后的时间戳可能还有未索引的转移
+	_, err = s.CreateSnapshot(ctx, SnapshotRequest{Name: "t3", ContractAddress: snapContract.Hex(), Timestamp: 2201})
+	if !errors.Is(err, ErrSnapshotNotIndexed) {
+		t.Errorf("timestamp after indexed head: err = %v", err)
+	}
+
+	// 早于第一个区块：不能落到区块 0
+	_, err = s.CreateSnapshot(ctx, SnapshotRequest{Name: "t4", ContractAddress: snapContract.Hex(), Timestamp: 1005})
+	if err == nil {
+		t.Error("timestamp before block 1 should fail")
+	}
+}
+
+func TestCreateSnapshotRejectsUnindexedBlocks(t *testing.T) {
+	s, _ := newSnapshotFixture(t, 0)
+	ctx := context.Background()
+
+	if _, err := s.CreateSnapshot(ctx, SnapshotRequest{Name: "b0", ContractAddress: snapContract.Hex()}); err == nil {
+		t.Error("snapshot at block 0 should fail")
+	}
+	if _, err := s.CreateSnapshot(ctx, SnapshotRequest{Name: "b5", ContractAddress: snapContract.Hex(), Block: 5}); !errors.Is(err, ErrSnapshotNotIndexed) {
+		t.Errorf("block after checkpoint: err = %v", err)
+	}
+	if _, err := s.CreateSnapshot(ctx, SnapshotRequest{Name: "ts", ContractAddress: snapContract.Hex(), Timestamp: 5000}); err == nil {
+		t.Error("timestamp snapshot without header source should fail")
+	}
+	other := common.HexToAddress("0xDD").Hex()
+	if _, err := s.CreateSnapshot(ctx, SnapshotRequest{Name: "x", ContractAddress: other}); !errors.Is(err, ErrSnapshotNotIndexed) {
+		t.Errorf("contract without checkpoint: err = %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+// token_service.go
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+// nativeToken ETH 拍卖使用的币种信息
+var nativeToken = model.Token{
+	Name:     "Ether",
+	Symbol:   "ETH",
+	Decimals: 18,
+}
+
+// tokenCacheSize 代币元数据内存缓存容量
+const tokenCacheSize = 256
+
+// ErrTokenNotFound 代币未被任何已索引的拍卖使用（不从链上读取，避免任意地址写入数据库）
+var ErrTokenNotFound = errors.New("代币不存在")
+
+// TokenService ERC20代币元数据服务（内存缓存 + 数据库持久化）
+type TokenService struct {
+	DB     *gorm.DB
+	client contract.Backend
+
+	cache *lru.Cache[string, *model.Token] // key: 小写地址
+}
+
+// NewTokenService 创建代币服务
+func NewTokenService(db *gorm.DB, client contract.Backend) *TokenService {
+	return &TokenService{
+		DB:     db,
+		client: client,
+		cache:  lru.NewCache[string, *model.Token](tokenCacheSize),
+	}
+}
+
+// GetToken 获取代币元数据，空地址或零地址返回 ETH
+// 查询顺序：内存缓存 -> 数据库 -> 链上（只读取已索引拍卖使用的支付币种，其余返回 ErrTokenNotFound）
+func (s *TokenService) GetToken(ctx context.Context, address string) (*model.Token, error) {
+	if address == "" || common.HexToAddress(address) == (common.Address{}) {
+		token := nativeToken
+		return &token, nil
+	}
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("无效的代币地址: %s", address)
+	}
+	key := strings.ToLower(common.HexToAddress(address).Hex())
+
+	if cached, ok := s.cache.Get(key); ok {
+		return cached, nil
+	}
+
+	var token model.Token
+	err := s.DB.WithContext(ctx).Where("address = ?", key).First(&token).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("查询代币失败: %v", err)
+		}
+
+		used, err := s.usedByAuction(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if !used {
+			return nil, ErrTokenNotFound
+		}
+
+		fetched, err := s.fetchToken(ctx, common.HexToAddress(key))
+		if err != nil {
+			return nil, err
+		}
+		// 并发的首次请求可能同时写入：已存在时忽略，统一读回数据库中的记录
+		result := s.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(fetched)
+		if result.Error != nil {
+			return nil, fmt.Errorf("保存代币失败: %v", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("✅ 缓存代币元数据: %s (%s, decimals=%d)", fetched.Symbol, key, fetched.Decimals)
+		}
+		if err := s.DB.WithContext(ctx).Where("address = ?", key).First(&token).Error; err != nil {
+			return nil, fmt.Errorf("查询代币失败: %v", err)
+		}
+	}
+
+	s.cache.Add(key, &token)
+	return &token, nil
+}
+
+// usedByAuction 是否有已索引的拍卖使用该代币支付（拍卖中保存的是校验和地址）
+func (s *TokenService) usedByAuction(ctx context.Context, key string) (bool, error) {
+	var count int64
+	if err := s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Where("LOWER(payment_token) = ?", key).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("查询拍卖支付币种失败: %v", err)
+	}
+	return count > 0, nil
+}
+
+// fetchToken 从链上读取 symbol/decimals/name
+func (s *TokenService) fetchToken(ctx context.Context, address common.Address) (*model.Token, error) {
+	client, err := contract.NewERC20Client(s.client, address)
+	if err != nil {
+		return nil, err
+	}
+
+	// decimals 是金额换算的必要条件
+	decimals, err := client.Decimals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取代币 %s 精度失败: %v", address.Hex(), err)
+	}
+
+	// symbol/name 是可选的（部分老代币返回 bytes32）
+	symbol, err := client.Symbol(ctx)
+	if err != nil {
+		log.Printf("⚠️ 获取代币 %s 符号失败: %v", address.Hex(), err)
+	}
+	name, err := client.Name(ctx)
+	if err != nil {
+		log.Printf("⚠️ 获取代币 %s 名称失败: %v", address.Hex(), err)
+	}
+
+	return &model.Token{
+		Address:  strings.ToLower(address.Hex()),
+		Name:     name,
+		Symbol:   symbol,
+		Decimals: decimals,
+	}, nil
+}
+
+// ListTokens 列出已缓存的代币
+func (s *TokenService) ListTokens(ctx context.Context) ([]model.Token, error) {
+	var tokens []model.Token
+	if err := s.DB.WithContext(ctx).Order("symbol ASC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// ==================== 金额展示 ====================
+
+// DecorateAuction 按拍卖的支付币种填充可读金额
+func (s *TokenService) DecorateAuction(ctx context.Context, auction *model.Auction) {
+	token, err := s.GetToken(ctx, auction.PaymentToken)
+	if err != nil {
+		log.Printf("⚠️ 拍卖 #%d 支付币种解析失败: %v", auction.AuctionID, err)
+		return
+	}
+
+	auction.PaymentSymbol = token.Symbol
+	auction.PaymentDecimals = token.Decimals
+	auction.StartingPriceFormatted = contract.FormatUnitsString(auction.StartingPrice, token.Decimals)
+	auction.HighestBidFormatted = contract.FormatUnitsString(auction.HighestBid, token.Decimals)
+}
+
+// DecorateAuctions 批量填充可读金额
+func (s *TokenService) DecorateAuctions(ctx context.Context, auctions []model.Auction) {
+	for i := range auctions {
+		s.DecorateAuction(ctx, &auctions[i])
+	}
+}
+
+// DecorateBids 按支付币种填充出价的可读金额
+func (s *TokenService) DecorateBids(ctx context.Context, paymentToken string, bids []model.BidHistory) {
+	token, err := s.GetToken(ctx, paymentToken)
+	if err != nil {
+		log.Printf("⚠️ 出价币种解析失败: %v", err)
+		return
+	}
+
+	for i := range bids {
+		bids[i].Symbol = token.Symbol
+		bids[i].AmountFormatted = contract.FormatUnitsString(bids[i].Amount, token.Decimals)
+	}
+}
+
+// DecorateRefunds 按支付币种填充退款的可读金额
+func (s *TokenService) DecorateRefunds(ctx context.Context, paymentToken string, refunds []model.BidRefund) {
+	token, err := s.GetToken(ctx, paymentToken)
+	if err != nil {
+		log.Printf("⚠️ 退款币种解析失败: %v", err)
+		return
+	}
+
+	for i := range refunds {
+		refunds[i].Symbol = token.Symbol
+		refunds[i].AmountFormatted = contract.FormatUnitsString(refunds[i].Amount, token.Decimals)
+	}
+}
+
+// DecorateProvenance 按支付币种填充成交价的可读金额
+func (s *TokenService) DecorateProvenance(ctx context.Context, entries []ProvenanceEntry) {
+	for i := range entries {
+		if !entries[i].Sale {
+			continue
+		}
+		token, err := s.GetToken(ctx, entries[i].PaymentToken)
+		if err != nil {
+			log.Printf("⚠️ 拍卖 #%d 支付币种解析失败: %v", entries[i].AuctionID, err)
+			continue
+		}
+		entries[i].PaymentSymbol = token.Symbol
+		entries[i].PriceFormatted = contract.FormatUnitsString(entries[i].Price, token.Decimals)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+
+	"nft-auction-backend/internal/contract"
+	"nft-auction-backend/internal/model"
+)
+
+const testERC20ABI = `[
+	{"type":"function","name":"name","inputs":[],"outputs":[{"type":"string"}]},
+	{"type":"function","name":"symbol","inputs":[],"outputs":[{"type":"string"}]},
+	{"type":"function","name":"decimals","inputs":[],"outputs":[{"type":"uint8"}]}
+]`
+
+var usdc = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+
+// fakeERC20Backend 按方法选择器返回 name/symbol/decimals，onCall 在每次调用前执行
+type fakeERC20Backend struct {
+	contract.Backend
+	calls  int
+	onCall func()
+}
+
+func (f *fakeERC20Backend) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.calls++
+	if f.onCall != nil {
+		f.onCall()
+	}
+	parsed, err := abi.JSON(strings.NewReader(testERC20ABI))
+	if err != nil {
+		return nil, err
+	}
+	method, err := parsed.MethodById(msg.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	switch method.Name {
+	case "name":
+		return method.Outputs.Pack("USD Coin")
+	case "symbol":
+		return method.Outputs.Pack("USDC")
+	default:
+		return method.Outputs.Pack(uint8(6))
+	}
+}
+
+func newTokenFixture(t *testing.T) (*gorm.DB, *fakeERC20Backend, *TokenService) {
+	t.Helper()
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&model.Token{}); err != nil {
+		t.Fatal(err)
+	}
+	backend := &fakeERC20Backend{}
+	return db, backend, NewTokenService(db, backend)
+}
+
+// 没有拍卖使用的地址：不查询链上、不写入数据库
+func TestGetTokenUnusedAddressNotFound(t *testing.T) {
+	db, backend, s := newTokenFixture(t)
+
+	if _, err := s.GetToken(context.Background(), usdc.Hex()); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("err = %v, want ErrTokenNotFound", err)
+	}
+	var count int64
+	db.Model(&model.Token{}).Count(&count)
+	if backend.calls != 0 || count != 0 {
+		t.Fatalf("chain calls = %d, stored tokens = %d", backend.calls, count)
+	}
+}
+
+func TestGetTokenFetchesAuctionPaymentToken(t *testing.T) {
+	db, backend, s := newTokenFixture(t)
+	if err := db.Create(&model.Auction{AuctionID: 1, PaymentToken: usdc.Hex()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := s.GetToken(context.Background(), strings.ToLower(usdc.Hex()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Symbol != "USDC" || token.Decimals != 6 || token.Address != strings.ToLower(usdc.Hex()) {
+		t.Fatalf("token = %+v", token)
+	}
+
+	// 第二次命中缓存
+	calls := backend.calls
+	if _, err := s.GetToken(context.Background(), usdc.Hex()); err != nil || backend.calls != calls {
+		t.Fatalf("cached lookup: err=%v calls %d -> %d", err, calls, backend.calls)
+	}
+}
+
+// 并发的首次请求先写入了记录：不报唯一约束错误，返回数据库中的记录
+func TestGetTokenConcurrentCreate(t *testing.T) {
+	db, backend, s := newTokenFixture(t)
+	if err := db.Create(&model.Auction{AuctionID: 1, PaymentToken: usdc.Hex()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	backend.onCall = func() {
+		backend.onCall = nil
+		if err := db.Create(&model.Token{Address: strings.ToLower(usdc.Hex()), Symbol: "FIRST", Decimals: 6}).Error; err != nil {
+			t.Error(err)
+		}
+	}
+
+	token, err := s.GetToken(context.Background(), usdc.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Symbol != "FIRST" || token.ID == 0 {
+		t.Fatalf("token = %+v, want the row written first", token)
+	}
+}
@@ -0,0 +1,108 @@
+// verify.go
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"nft-auction-backend/internal/model"
+)
+
+// Mismatch 数据库与链上状态不一致的一项
+type Mismatch struct {
+	Kind  string `json:"kind"`  // auction / nft
+	ID    string `json:"id"`    // 拍卖ID 或 TokenID
+	Field string `json:"field"` // 不一致的字段
+	DB    string `json:"db"`
+	Chain string `json:"chain"`
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s #%s %s: 数据库=%q 链上=%q", m.Kind, m.ID, m.Field, m.DB, m.Chain)
+}
+
+// VerifyAuctions 逐个比对数据库中的拍卖与链上状态（只读，不修改数据库）
+func (s *AuctionService) VerifyAuctions(ctx context.Context) ([]Mismatch, error) {
+	count, err := s.AuctionContract.GetAuctionCount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取拍卖数量失败: %v", err)
+	}
+
+	var dbCount int64
+	if err := s.DB.WithContext(ctx).Model(&model.Auction{}).
+		Where("chain_state <> ?", model.ChainStateOrphaned).
+		Count(&dbCount).Error; err != nil {
+		return nil, fmt.Errorf("统计数据库拍卖失败: %v", err)
+	}
+
+	var mismatches []Mismatch
+	if uint64(dbCount) != count.Uint64() {
+		mismatches = append(mismatches, Mismatch{
+			Kind: "auction", ID: "*", Field: "count",
+			DB: fmt.Sprint(dbCount), Chain: count.String(),
+		})
+	}
+
+	for i := uint64(0); i < count.Uint64(); i++ {
+		if err := ctx.Err(); err != nil {
+			return mismatches, err
+		}
+
+		id := fmt.Sprint(i)
+		var auction model.Auction
+		if err := s.DB.WithContext(ctx).Where("auction_id = ?", i).First(&auction).Error; err != nil {
+			mismatches = append(mismatches, Mismatch{Kind: "auction", ID: id, Field: "exists", DB: "false", Chain: "true"})
+			continue
+		}
+
+		info, err := s.GetAuctionInfo(ctx, i)
+		if err != nil {
+			log.Printf("❌ 获取拍卖 #%d 信息失败: %v", i, err)
+			continue
+		}
+
+		compare := func(field, dbValue, chainValue string) {
+			if dbValue != chainValue {
+				mismatches = append(mismatches, Mismatch{Kind: "auction", ID: id, Field: field, DB: dbValue, Chain: chainValue})
+			}
+		}
+		compare("ended", fmt.Sprint(auction.Ended), fmt.Sprint(info.Ended))
+		compare("highest_bid", auction.HighestBid, info.HighestBid.String())
+		compare("highest_bidder", strings.ToLower(auction.HighestBidder), strings.ToLower(info.HighestBidder.Hex()))
+		compare("end_time", fmt.Sprint(auction.EndTime), info.EndTime.String())
+	}
+
+	return mismatches, nil
+}
+
+// VerifyNFTs 比对数据库中每个NFT的所有者与链上 ownerOf（只读，不修改数据库）
+func (s *NFTService) VerifyNFTs(ctx context.Context) ([]Mismatch, error) {
+	var nfts []model.NFTInfo
+	if err := s.DB.WithContext(ctx).
+		Where("contract_address = ? AND chain_state <> ?", s.GetContractAddress().Hex(), model.ChainStateOrphaned).
+		Find(&nfts).Error; err != nil {
+		return nil, fmt.Errorf("查询数据库NFT失败: %v", err)
+	}
+
+	var mismatches []Mismatch
+	for _, nft := range nfts {
+		if err := ctx.Err(); err != nil {
+			return mismatches, err
+		}
+
+		owner, err := s.GetOwner(ctx, nft.TokenID)
+		if err != nil {
+			log.Printf("❌ 获取 NFT %s 所有者失败: %v", nft.TokenID, err)
+			continue
+		}
+		if !strings.EqualFold(owner, nft.Owner) {
+			mismatches = append(mismatches, Mismatch{
+				Kind: "nft", ID: nft.TokenID, Field: "owner", DB: nft.Owner, Chain: owner,
+			})
+		}
+	}
+
+	return mismatches, nil
+}
@@ -6,17 +6,16 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
-	"nft-auction-backend/api"               // API处理器层
-	"nft-auction-backend/internal/config"   // 配置管理
-	"nft-auction-backend/internal/contract" // 区块链交互层
-	"nft-auction-backend/internal/service"  // 业务逻辑层
-	"nft-auction-backend/pkg/database"      // 数据库层
+	"nft-auction-backend/api"              // API处理器层
+	"nft-auction-backend/internal/service" // 业务逻辑层
+	"nft-auction-backend/pkg/rpcpool"
 )
 
 // 全局token存储（添加互斥锁保证并发安全）
@@ -36,89 +35,65 @@ var (
 //	        ↑
 //	   区块链节点
 func main() {
-	// ==================== 1. 配置加载阶段 ====================
-	cfg := config.LoadConfig()
-	log.SetPrefix("[NFT_BACK_END] ")
-
-	// ==================== 2. 数据库初始化阶段 ====================
-	db, err := database.InitDB(cfg.Database)
-	if err != nil {
-		log.Fatal("数据库初始化失败:", err)
+	if err := runCLI(os.Args[1:]); err != nil {
+		log.Fatalf("❌ %v", err)
 	}
-	defer func() {
-		sqlDB, _ := db.DB()
-		if sqlDB != nil {
-			sqlDB.Close()
-		}
-	}()
+}
 
-	// ==================== 3. NFT客户端初始化 ====================
-	// ┌─────────────┐    调用    ┌─────────────┐    调用    ┌─────────────┐
-	// │   API层     │───────────▶│ Service层  │───────────▶│ Contract层  │
-	// │  Handlers   │            │  Services  │            │    Client   │
-	// └─────────────┘            └─────────────┘            └─────────────┘
-	//        │                         │                            │
-	//        │ 返回JSON                │ 业务逻辑                   │ 区块链交互
-	//        ▼                         ▼                            ▼
-	//    前端/客户端               数据库操作                    以太坊网络
-
-	var nftHandler *api.NFTHandler
-
-	// 检查必要的配置
-	if cfg.Blockchain.RPCURL == "" {
-		log.Fatal("❌ 请在 config.yaml 中配置 blockchain.rpc_url")
-	}
+// runServe 启动HTTP服务与区块链监听器（默认子命令）
+func runServe(app *App) error {
+	cfg := app.Cfg
+	rpcPool := app.RPCPool
+	nftService := app.NFTService
+	auctionService := app.AuctionService
+	blockchainListener := app.Listener
+	ctx, cancel := app.Ctx, app.Cancel
+
+	userHandler := api.NewUserHandler(app.UserService)
+	nftHandler := api.NewNFTHandler(nftService)
+	tokenHandler := api.NewTokenHandler(app.TokenService)
+	auctionHandler := api.NewAuctionHandler(auctionService, app.TokenService)
+	collectionHandler := api.NewCollectionHandler(app.Collections)
+	metadataHandler := api.NewMetadataHandler(app.Metadata, nftService)
+	imageHandler := api.NewImageHandler(app.Images, nftService)
+	accountHandler := api.NewAccountHandler(app.Portfolio, app.TokenService)
+	provenanceHandler := api.NewProvenanceHandler(nftService, app.TokenService)
+	snapshotHandler := api.NewSnapshotHandler(app.Snapshots, nftService)
+	escrowHandler := api.NewEscrowHandler(app.Escrow)
 
-	if cfg.Blockchain.NFTContractAddress == "" {
-		log.Fatal("❌ 请在 config.yaml 中配置 blockchain.nft_contract_address")
-	}
+	log.SetPrefix("[NFT_LISTENER] ")
 
-	// 初始化NFT客户端
-	nftClient, err := contract.NewNFTClient(cfg.Blockchain.RPCURL, cfg.Blockchain.NFTContractAddress)
-	if err != nil {
-		log.Fatalf("❌ NFT客户端初始化失败: %v", err)
+	// 拍卖过期调度器（到期即切换为 expired_pending_settlement）
+	expiryScheduler := service.NewExpiryScheduler(auctionService)
+	if err := expiryScheduler.Start(ctx); err != nil {
+		log.Printf("⚠️ 过期调度器启动失败: %v", err)
 	}
 
-	// 初始化拍卖客户端
-	auctionClient, err := contract.NewAuctionClient(cfg.Blockchain.RPCURL, cfg.Blockchain.AuctionContractAddress)
-	if err != nil {
-		log.Fatalf("❌ 拍卖客户端初始化失败: %v", err)
+	// 自动结算（可选，需要本地签名账户）
+	var keeperService *service.KeeperService
+	if cfg.Keeper.Enabled {
+		var err error
+		keeperService, err = service.NewKeeperService(app.DB, auctionService, rpcPool, cfg.Keeper)
+		if err != nil {
+			log.Printf("⚠️ 自动结算初始化失败，已跳过: %v", err)
+		} else {
+			keeperService.Start(ctx)
+		}
 	}
+	keeperHandler := api.NewKeeperHandler(keeperService)
 
-	// ==================== 4. 服务层初始化 ====================
-	// user 服务
-	userService := service.NewUserService(db)
-	userHandler := api.NewUserHandler(userService)
-
-	// NFT 服务
-	nftService := service.NewNFTService(db, nftClient)
-	nftHandler = api.NewNFTHandler(nftService)
-
-	// NFT拍卖 服务（传入两个客户端）
-	auctionService := service.NewAuctionService(db, auctionClient)
-	auctionHandler := api.NewAuctionHandler(auctionService)
-
-	// ==================== 5.区块链监听器初始化 ====================
-	// 启动监听器（使用后台context）
-	// context.WithCancel 是 Go 语言中用于创建 可取消的上下文（Context） 的函数
-	//         case <-ctx.Done():  // 在监听器中 监听取消信号
-
-	// 它在函数调用之间显式传递
-	// 它携带本次调用的相关信息（取消信号、超时、请求ID等）
-	// 每个请求/任务有自己独立的Context链条
-	// 它让函数知道自己为什么运行、何时应该停止
-	// 在你的区块链监听器中，ctx 让监听器知道："当主程序要退出时，请优雅地停止监听，清理资源"。
-	log.SetPrefix("[NFT_LISTENER] ")
+	// 托管对账（定期比较流水余额与合约实际余额）
+	app.Escrow.Start(ctx)
+
+	// tokenURI 元数据抓取（失败按退避时间重试）
+	if cfg.Metadata.Enabled {
+		app.Metadata.Start(ctx)
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	blockchainListener := service.NewBlockchainListener(
-		nftService,            // NFT Service
-		auctionService,        // Auction Service
-		cfg.Blockchain.RPCURL, // RPC URL
-		ctx,
-		cancel,
-	)
-	defer cancel()
+	// 图片缓存与缩略图生成
+	if cfg.Images.Enabled {
+		app.Images.Start(ctx)
+	}
 
 	blockchainListener.Start(ctx)
 	// ==================== 6. Web服务器路由设置 ====================
@@ -180,14 +155,17 @@ func main() {
 			"features": gin.H{
 				"blockchain_listener": true,
 				"real_time_sync":      true,
-				// "polling_interval":    blockchainListener.GetPollInterval().String(),
+				"listener_mode":       blockchainListener.GetMode(),
+				"polling_interval":    blockchainListener.GetPollInterval().String(),
+				"auto_settlement":     keeperService != nil,
 			},
 			// "listener": listenerStatus,
 			// "stats":    eventStats,
 			"config": gin.H{
 				"port":             cfg.Server.Port,
 				"database":         cfg.Database.Path,
-				"rpc_url":          cfg.Blockchain.RPCURL,
+				"rpc_url":          rpcpool.RedactURL(cfg.Blockchain.RPCURL),
+				"rpc_endpoints":    rpcPool.Status(),
 				"nft_contract":     cfg.Blockchain.NFTContractAddress,
 				"auction_contract": cfg.Blockchain.AuctionContractAddress,
 			},
@@ -215,16 +193,36 @@ func main() {
 	// 拍卖列表和详情（公开）
 	router.GET("/api/auctions", auctionHandler.GetAuctions)
 	router.GET("/api/auctions/active", auctionHandler.GetActiveAuctions)
+	router.GET("/api/auctions/expired", auctionHandler.GetExpiredAuctions)
 	router.GET("/api/auctions/count", auctionHandler.GetAuctionCount)
 	router.GET("/api/auctions/:id", auctionHandler.GetAuction)
 	router.GET("/api/auctions/:id/bids", auctionHandler.GetAuctionBids)
 	router.GET("/api/auctions/:id/validate", auctionHandler.ValidateAuction)
 
+	// ERC20代币元数据（公开）
+	router.GET("/api/tokens", tokenHandler.ListTokens)
+	router.GET("/api/tokens/:address", tokenHandler.GetToken)
+
+	// NFT合约状态（公开，由事件维护）
+	router.GET("/api/collection", collectionHandler.GetCollection)
+	router.GET("/api/collections", collectionHandler.ListCollections)
+	router.GET("/api/collections/:address", collectionHandler.GetCollectionByAddress)
+	router.GET("/api/collections/:address/traits", metadataHandler.GetTraits)
+	router.GET("/api/collection/withdrawals", collectionHandler.GetWithdrawals)
+	router.GET("/api/collection/operators", collectionHandler.GetOperatorApprovals)
+
 	// NFT相关API（公开）
+	router.GET("/api/nfts", nftHandler.ListNFTs)
 	router.GET("/api/nfts/:id", nftHandler.GetNFTInfo)
 	router.GET("/api/nfts/:id/owner", nftHandler.GetNFTOwner)
+	router.GET("/api/nfts/:id/metadata", metadataHandler.GetMetadata)
+	router.GET("/api/nfts/:id/image", imageHandler.GetImage)
+	router.GET("/api/nfts/:id/history", provenanceHandler.GetHistory)
 	router.GET("/api/nfts/:id/validate/:address", nftHandler.ValidateOwnership)
 
+	// 钱包资产（公开）
+	router.GET("/api/accounts/:address/portfolio", accountHandler.GetPortfolio)
+
 	// ==================== 需要认证的API ====================
 	auth.Use(authCheck) // 检查是否登录
 	{
@@ -234,6 +232,22 @@ func main() {
 		// 管理API
 		auth.POST("/auctions/sync", auctionHandler.SyncAuctions)
 		auth.POST("/nft/sync", nftHandler.SyncNFTInfo)
+		auth.GET("/keeper/attempts", keeperHandler.GetAttempts)
+		auth.POST("/collections", collectionHandler.RegisterCollection)
+		auth.POST("/nfts/:id/metadata/refresh", metadataHandler.RetryMetadata)
+
+		// 持有者快照（空投、白名单）
+		auth.POST("/snapshots", snapshotHandler.CreateSnapshot)
+		auth.GET("/snapshots", snapshotHandler.ListSnapshots)
+		auth.GET("/snapshots/:name", snapshotHandler.ExportSnapshot)
+		auth.DELETE("/snapshots/:name", snapshotHandler.DeleteSnapshot)
+
+		// 托管资金流水与对账
+		auth.GET("/escrow/ledger", escrowHandler.GetLedger)
+		auth.GET("/escrow/balances/:account", escrowHandler.GetBalances)
+		auth.GET("/escrow/reconciliations", escrowHandler.GetReconciliations)
+		auth.POST("/escrow/reconcile", escrowHandler.Reconcile)
+		auth.POST("/escrow/withdrawals", escrowHandler.RecordWithdrawal)
 
 		// 监听器控制API（需要认证）
 		auth.POST("/listener/restart", func(c *gin.Context) {
@@ -265,6 +279,41 @@ func main() {
 				"timestamp": time.Now().Unix(),
 			})
 		})
+
+		// 从原始事件日志回放（不请求 eth_getLogs）
+		auth.POST("/listener/replay", func(c *gin.Context) {
+			from, _ := strconv.ParseUint(c.DefaultQuery("from", "0"), 10, 64)
+			to, _ := strconv.ParseUint(c.DefaultQuery("to", "0"), 10, 64)
+			reset := c.Query("reset") == "true"
+			if reset && from != 0 {
+				c.JSON(400, gin.H{
+					"success": false,
+					"error":   "清空重建只能从区块0开始回放",
+				})
+				return
+			}
+
+			if blockchainListener.IsReplaying() {
+				c.JSON(409, gin.H{
+					"success": false,
+					"error":   service.ErrReplayInProgress.Error(),
+				})
+				return
+			}
+
+			// 回放期间监听器暂停，结束后自动恢复
+			go func() {
+				if _, err := blockchainListener.Replay(ctx, from, to, reset); err != nil {
+					log.Printf("❌ 回放失败: %v", err)
+				}
+			}()
+
+			c.JSON(200, gin.H{
+				"success":   true,
+				"message":   "已触发事件日志回放（期间监听器暂停），请稍后查看结果",
+				"timestamp": time.Now().Unix(),
+			})
+		})
 	}
 
 	// ==================== 7. 服务器启动 ====================
@@ -276,7 +325,7 @@ func main() {
 	log.Println("🎉 NFT拍卖后端系统启动成功!")
 	log.Printf("📡 服务地址: http://localhost:%d", port)
 	log.Printf("💾 数据库文件: %s", cfg.Database.Path)
-	log.Printf("🔗 区块链节点: %s", cfg.Blockchain.RPCURL)
+	log.Printf("🔗 区块链节点: %s", rpcpool.RedactURL(cfg.Blockchain.RPCURL))
 	log.Printf("📄 NFT合约地址: %s", cfg.Blockchain.NFTContractAddress)
 	log.Println("========================================")
 	log.Println("🌐 可用API接口:")
@@ -290,8 +339,15 @@ func main() {
 	log.Println("  POST /api/auctions/:id/end          - 结束拍卖")   // ?
 	log.Println("  GET  /api/nfts/:id                  - NFT信息")  // ?
 	log.Println("  GET  /api/nfts/:id/owner            - NFT所有者") // ?
+	log.Println("  GET  /api/nfts/:id/metadata         - NFT元数据与属性")
+	log.Println("  GET  /api/nfts/:id/history          - NFT所有权与来源记录")
 	log.Println("  GET  /api/nfts/:id/validate/:addr   - 验证所有权")  // ?
 	log.Println("  GET  /api/nfts/contract/info        - 获取合约信息") //?
+	log.Println("  GET  /api/auctions/expired          - 已到期待结算拍卖")
+	log.Println("  GET  /api/tokens                    - 已缓存的ERC20代币")
+	log.Println("  GET  /api/collection                - NFT合约状态（铸造价格/开关）")
+	log.Println("  GET  /api/collections               - 合集注册表")
+	log.Println("  GET  /api/collections/:address      - 合约供应量与持有者分布")
 	log.Println("========================================")
 
 	// 优雅关闭处理
@@ -299,8 +355,9 @@ func main() {
 
 	// 启动HTTP服务器
 	if err := router.Run(addr); err != nil {
-		log.Fatal("服务启动失败:", err)
+		return fmt.Errorf("服务启动失败: %v", err)
 	}
+	return nil
 }
 
 func setupGracefulShutdown(cancel context.CancelFunc) {
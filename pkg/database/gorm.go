@@ -67,11 +67,27 @@ func autoMigrateTables(db *gorm.DB) error {
 	// 使用interface{}类型切片，可以存放任意类型的模型指针
 	models := []interface{}{
 		&model.User{},
-		&model.Auction{}, // 拍卖表模型
-		&model.NFTInfo{}, // NFT信息表模型
-		// &model.Bid{},        //
-		// &model.EventSync{},  // 新增
-		// &model.BidHistory{}, // 新增
+		&model.Auction{},              // 拍卖表模型
+		&model.NFTInfo{},              // NFT信息表模型
+		&model.EventSync{},            // 区块检查点表
+		&model.ProcessedBlock{},       // 已处理区块哈希（重组检测）
+		&model.BidHistory{},           // 出价历史
+		&model.Bid{},                  // 当前最高出价
+		&model.BidRefund{},            // 被顶替出价的退款
+		&model.Token{},                // ERC20代币元数据
+		&model.SettlementAttempt{},    // 自动结算尝试记录
+		&model.RawEvent{},             // 原始事件日志（回放用）
+		&model.Collection{},           // NFT合约级状态
+		&model.Withdrawal{},           // 铸造收入提取记录
+		&model.OperatorApproval{},     // 全量授权
+		&model.TokenMetadata{},        // tokenURI 元数据
+		&model.TokenAttribute{},       // 元数据属性
+		&model.NFTImage{},             // 图片缓存
+		&model.Transfer{},             // NFT转移记录（来源追溯）
+		&model.Snapshot{},             // 持有者快照
+		&model.SnapshotHolder{},       // 快照持有者
+		&model.LedgerEntry{},          // 拍卖合约资金流水
+		&model.EscrowReconciliation{}, // 托管对账记录
 
 		// 可以添加更多表模型...
 	}
@@ -97,6 +113,51 @@ func autoMigrateTables(db *gorm.DB) error {
 		log.Printf("✓ 表 '%s' 已就绪", stmt.Schema.Table)
 	}
 
+	// 合约名称、符号、供应量移到 collections 表，删除 nft_infos 上的冗余列
+	for _, column := range []string{"total_supply", "contract_name", "contract_symbol"} {
+		if db.Migrator().HasColumn(&model.NFTInfo{}, column) {
+			if err := db.Migrator().DropColumn(&model.NFTInfo{}, column); err != nil {
+				return fmt.Errorf("删除 nft_infos.%s 失败: %v", column, err)
+			}
+			log.Printf("✓ 已删除冗余列 nft_infos.%s", column)
+		}
+	}
+
+	// 合集注册表之前的 collections 只有主合约一行
+	if err := db.Model(&model.Collection{}).Where("source = ? OR source IS NULL", "").
+		Updates(map[string]interface{}{"source": model.CollectionSourceConfig, "supported": true}).Error; err != nil {
+		return fmt.Errorf("补全 collections.source 失败: %v", err)
+	}
+
+	// 出价去重从 tx_hash 改为 (tx_hash, log_index)，删除旧的唯一索引
+	if db.Migrator().HasIndex(&model.BidHistory{}, "idx_bid_histories_tx_hash") {
+		if err := db.Migrator().DropIndex(&model.BidHistory{}, "idx_bid_histories_tx_hash"); err != nil {
+			return fmt.Errorf("删除旧出价索引失败: %v", err)
+		}
+		log.Printf("✓ 已删除旧索引 idx_bid_histories_tx_hash")
+	}
+
+	// bids 表恢复前的数据库：从出价链回填每个拍卖的当前最高出价
+	var currentBids int64
+	if err := db.Model(&model.Bid{}).Count(&currentBids).Error; err != nil {
+		return fmt.Errorf("统计当前出价失败: %v", err)
+	}
+	if currentBids == 0 {
+		result := db.Exec(`INSERT INTO bids (auction_id, bid_id, bidder, amount, tx_hash, log_index, status, is_highest, block_number, confirmed_at, created_at, updated_at)
+			SELECT auction_id, id, bidder, amount, tx_hash, log_index,
+				CASE WHEN status = ? THEN ? ELSE ? END, true, block_number,
+				CASE WHEN status = ? THEN updated_at ELSE NULL END, created_at, updated_at
+			FROM bid_histories WHERE is_highest = true AND status <> ?`,
+			model.ChainStateConfirmed, model.ChainStateConfirmed, model.ChainStatePending,
+			model.ChainStateConfirmed, model.ChainStateOrphaned)
+		if result.Error != nil {
+			return fmt.Errorf("回填当前出价失败: %v", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("✓ 已回填 %d 条当前出价", result.RowsAffected)
+		}
+	}
+
 	// 所有表创建成功，返回nil
 	return nil
 }
@@ -0,0 +1,116 @@
+package rpcpool
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/time/rate"
+)
+
+// 错误率按指数加权移动平均计算（最近的请求权重更大）
+const errorRateAlpha = 0.1
+
+// Endpoint 单个RPC节点
+type Endpoint struct {
+	Name string
+	URL  string
+
+	client  *ethclient.Client
+	limiter *rate.Limiter // 每个节点独立限流，nil 表示不限流
+
+	mu        sync.RWMutex
+	healthy   bool
+	probeOK   bool          // 最近一次探测是否成功
+	head      uint64        // 最近一次探测到的最新区块
+	latency   time.Duration // 最近一次探测延迟
+	errorRate float64       // 请求错误率（EWMA）
+	requests  uint64
+	failures  uint64
+	lastError string
+	lastCheck time.Time
+}
+
+// EndpointStatus 节点状态快照（用于 /api/info 展示）
+type EndpointStatus struct {
+	Name      string  `json:"name"`
+	URL       string  `json:"url"` // 只保留 scheme://host，路径和参数中可能带有 API Key
+	Healthy   bool    `json:"healthy"`
+	Head      uint64  `json:"head"`
+	LatencyMs int64   `json:"latency_ms"`
+	ErrorRate float64 `json:"error_rate"`
+	Requests  uint64  `json:"requests"`
+	Failures  uint64  `json:"failures"`
+	LastError string  `json:"last_error,omitempty"`
+	LastCheck int64   `json:"last_check"`
+}
+
+// IsWebSocket 是否支持订阅（ws:// 或 wss://）
+func (e *Endpoint) IsWebSocket() bool {
+	u := strings.ToLower(e.URL)
+	return strings.HasPrefix(u, "ws://") || strings.HasPrefix(u, "wss://")
+}
+
+// record 记录一次请求结果
+func (e *Endpoint) record(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	sample := 0.0
+	if err != nil {
+		e.failures++
+		e.lastError = err.Error()
+		sample = 1.0
+	}
+	e.errorRate = e.errorRate*(1-errorRateAlpha) + sample*errorRateAlpha
+}
+
+// probed 记录一次健康探测结果
+func (e *Endpoint) probed(head uint64, latency time.Duration, err error) {
+	e.record(err)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastCheck = time.Now()
+	e.probeOK = err == nil
+	if err != nil {
+		e.healthy = false
+		return
+	}
+	e.head = head
+	e.latency = latency
+}
+
+// Status 返回状态快照
+func (e *Endpoint) Status() EndpointStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return EndpointStatus{
+		Name:      e.Name,
+		URL:       RedactURL(e.URL),
+		Healthy:   e.healthy,
+		Head:      e.head,
+		LatencyMs: e.latency.Milliseconds(),
+		ErrorRate: e.errorRate,
+		Requests:  e.requests,
+		Failures:  e.failures,
+		LastError: strings.ReplaceAll(e.lastError, e.URL, RedactURL(e.URL)),
+		LastCheck: e.lastCheck.Unix(),
+	}
+}
+
+// RedactURL 去掉节点地址中的用户信息、路径和查询参数（Infura/Alchemy 等把 API Key 放在路径里）
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<invalid>"
+	}
+	return u.Scheme + "://" + u.Host
+}
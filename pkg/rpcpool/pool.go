@@ -0,0 +1,474 @@
+// rpcpool 包提供多节点RPC连接池：健康探测、自动故障转移、按节点限流
+//
+//	NFTClient ─┐
+//	AuctionClient ─┼──▶ Pool ──▶ [节点A(wss)] [节点B(https)] [节点C(https)]
+//	Listener ─┘        │
+//	                  └─ 定期探测：最新区块落后数、延迟、错误率
+package rpcpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/time/rate"
+
+	"nft-auction-backend/internal/config"
+)
+
+var (
+	// ErrNoEndpoint 没有可用的节点
+	ErrNoEndpoint = errors.New("没有可用的RPC节点")
+	// ErrNoSubscriptionEndpoint 没有支持订阅的 WebSocket 节点
+	ErrNoSubscriptionEndpoint = errors.New("没有可用的WebSocket节点，无法订阅")
+)
+
+// 单次健康探测 / 重连的超时时间
+const probeTimeout = 5 * time.Second
+
+// Pool 多节点RPC连接池，实现 bind.ContractBackend，可直接注入合约绑定
+type Pool struct {
+	endpoints []*Endpoint
+
+	interval     time.Duration // 健康探测间隔
+	maxBlockLag  uint64        // 落后最高节点超过该区块数视为不健康
+	maxErrorRate float64       // 错误率超过该值视为不健康
+	maxLatency   time.Duration // 探测延迟超过该值视为不健康（0表示不限制）
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPool 根据配置创建连接池并启动健康探测
+// 未配置 rpc_endpoints 时退化为只有 rpc_url 一个节点
+func NewPool(cfg config.BlockchainConfig) (*Pool, error) {
+	endpointCfgs := cfg.RPCEndpoints
+	if len(endpointCfgs) == 0 && cfg.RPCURL != "" {
+		endpointCfgs = []config.RPCEndpointConfig{{Name: "default", URL: cfg.RPCURL}}
+	}
+	if len(endpointCfgs) == 0 {
+		return nil, fmt.Errorf("未配置任何RPC节点")
+	}
+
+	p := &Pool{
+		interval:     cfg.HealthCheckInterval,
+		maxBlockLag:  cfg.MaxBlockLag,
+		maxErrorRate: cfg.MaxErrorRate,
+		maxLatency:   cfg.MaxLatency,
+		stop:         make(chan struct{}),
+	}
+	if p.interval <= 0 {
+		p.interval = 15 * time.Second
+	}
+	if p.maxErrorRate <= 0 {
+		p.maxErrorRate = 0.5
+	}
+
+	connected := 0
+	for i, ec := range endpointCfgs {
+		name := ec.Name
+		if name == "" {
+			name = fmt.Sprintf("rpc-%d", i)
+		}
+		ep := &Endpoint{Name: name, URL: ec.URL}
+		if ec.RateLimit > 0 {
+			burst := ec.Burst
+			if burst <= 0 {
+				burst = int(ec.RateLimit) + 1
+			}
+			ep.limiter = rate.NewLimiter(rate.Limit(ec.RateLimit), burst)
+		}
+
+		if err := p.dial(ep); err != nil {
+			log.Printf("⚠️ RPC节点 %s 连接失败，稍后重试: %v", name, err)
+		} else {
+			connected++
+			log.Printf("✅ RPC节点 %s 已连接: %s", name, RedactURL(ec.URL))
+		}
+		p.endpoints = append(p.endpoints, ep)
+	}
+	if connected == 0 {
+		return nil, fmt.Errorf("所有RPC节点均连接失败")
+	}
+
+	p.checkAll()
+	go p.healthLoop()
+	return p, nil
+}
+
+// NewOfflinePool 没有任何节点的连接池：所有调用返回 ErrNoEndpoint
+// 供只处理本地数据的子命令（事件日志回放）初始化依赖连接池的服务，不建立任何连接
+func NewOfflinePool() *Pool {
+	return &Pool{stop: make(chan struct{})}
+}
+
+// Close 停止健康探测并关闭所有连接
+func (p *Pool) Close() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		for _, ep := range p.endpoints {
+			if client := ep.getClient(); client != nil {
+				client.Close()
+			}
+		}
+	})
+}
+
+// Status 所有节点的状态快照
+func (p *Pool) Status() []EndpointStatus {
+	statuses := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		statuses = append(statuses, ep.Status())
+	}
+	return statuses
+}
+
+// SupportsSubscription 是否存在已连接的 WebSocket 节点
+func (p *Pool) SupportsSubscription() bool {
+	for _, ep := range p.endpoints {
+		if ep.IsWebSocket() && ep.getClient() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== 健康探测 ====================
+
+func (p *Pool) healthLoop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.checkAll()
+		}
+	}
+}
+
+// checkAll 并发探测所有节点，再根据最高区块计算每个节点是否健康
+func (p *Pool) checkAll() {
+	var wg sync.WaitGroup
+	for _, ep := range p.endpoints {
+		wg.Add(1)
+		go func(ep *Endpoint) {
+			defer wg.Done()
+			p.probe(ep)
+		}(ep)
+	}
+	wg.Wait()
+
+	var bestHead uint64
+	for _, ep := range p.endpoints {
+		if status := ep.Status(); status.Head > bestHead {
+			bestHead = status.Head
+		}
+	}
+
+	for _, ep := range p.endpoints {
+		ep.mu.Lock()
+		wasHealthy := ep.healthy
+		probeOK := ep.client != nil && ep.probeOK
+		lagOK := p.maxBlockLag == 0 || bestHead-ep.head <= p.maxBlockLag
+		latencyOK := p.maxLatency == 0 || ep.latency <= p.maxLatency
+		ep.healthy = probeOK && lagOK && latencyOK && ep.errorRate <= p.maxErrorRate
+		healthy, head, name := ep.healthy, ep.head, ep.Name
+		ep.mu.Unlock()
+
+		if wasHealthy != healthy {
+			if healthy {
+				log.Printf("✅ RPC节点 %s 恢复健康（区块 %d）", name, head)
+			} else {
+				log.Printf("⚠️ RPC节点 %s 不健康（区块 %d，最高 %d）", name, head, bestHead)
+			}
+		}
+	}
+}
+
+// probe 探测单个节点：未连接的先重连，然后查询最新区块
+func (p *Pool) probe(ep *Endpoint) {
+	if ep.getClient() == nil {
+		if err := p.dial(ep); err != nil {
+			ep.probed(0, 0, err)
+			return
+		}
+		log.Printf("✅ RPC节点 %s 重新连接成功", ep.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	head, err := ep.getClient().BlockNumber(ctx)
+	ep.probed(head, time.Since(start), err)
+}
+
+// dial 连接节点
+func (p *Pool) dial(ep *Endpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	client, err := ethclient.DialContext(ctx, ep.URL)
+	if err != nil {
+		return err
+	}
+	ep.mu.Lock()
+	ep.client = client
+	ep.mu.Unlock()
+	return nil
+}
+
+// ==================== 节点选择与故障转移 ====================
+
+// candidates 按优先级返回候选节点：健康节点按错误率、延迟排序；没有健康节点时退化为全部已连接节点
+func (p *Pool) candidates(wsOnly bool) []*Endpoint {
+	var healthy, connected []*Endpoint
+	for _, ep := range p.endpoints {
+		if wsOnly && !ep.IsWebSocket() {
+			continue
+		}
+		if ep.getClient() == nil {
+			continue
+		}
+		connected = append(connected, ep)
+		if ep.Status().Healthy {
+			healthy = append(healthy, ep)
+		}
+	}
+
+	list := healthy
+	if len(list) == 0 {
+		list = connected
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].Status(), list[j].Status()
+		if a.ErrorRate != b.ErrorRate {
+			return a.ErrorRate < b.ErrorRate
+		}
+		return a.LatencyMs < b.LatencyMs
+	})
+	return list
+}
+
+// call 依次在候选节点上执行请求，节点故障时自动切换到下一个节点
+func call[T any](ctx context.Context, p *Pool, method string, fn func(*ethclient.Client) (T, error)) (T, error) {
+	var zero T
+	lastErr := ErrNoEndpoint
+
+	for _, ep := range p.candidates(false) {
+		client := ep.getClient()
+		if client == nil {
+			continue
+		}
+		if ep.limiter != nil {
+			if err := ep.limiter.Wait(ctx); err != nil {
+				return zero, err
+			}
+		}
+
+		result, err := fn(client)
+		if !isEndpointError(err) {
+			ep.record(nil)
+			return result, err
+		}
+
+		ep.record(err)
+		lastErr = err
+		log.Printf("⚠️ RPC节点 %s 调用 %s 失败，切换节点: %v", ep.Name, method, err)
+	}
+	return zero, lastErr
+}
+
+// isEndpointError 判断错误是否由节点本身引起（需要故障转移）
+// 合约 revert、记录不存在等是节点正常返回的业务错误，不计入节点错误率
+func isEndpointError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return true // 429 限流、5xx 等
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode() == -32005 // limit exceeded
+	}
+	return true // 网络错误、超时等
+}
+
+func (e *Endpoint) getClient() *ethclient.Client {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.client
+}
+
+// ==================== bind.ContractBackend 实现 ====================
+
+// CodeAt 获取合约代码
+func (p *Pool) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, p, "eth_getCode", func(c *ethclient.Client) ([]byte, error) {
+		return c.CodeAt(ctx, account, blockNumber)
+	})
+}
+
+// CallContract 执行只读合约调用
+func (p *Pool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, p, "eth_call", func(c *ethclient.Client) ([]byte, error) {
+		return c.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+// HeaderByNumber 获取区块头（number 为 nil 时返回最新区块）
+func (p *Pool) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return call(ctx, p, "eth_getBlockByNumber", func(c *ethclient.Client) (*types.Header, error) {
+		return c.HeaderByNumber(ctx, number)
+	})
+}
+
+// HeaderByHash 根据哈希获取区块头
+func (p *Pool) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return call(ctx, p, "eth_getBlockByHash", func(c *ethclient.Client) (*types.Header, error) {
+		return c.HeaderByHash(ctx, hash)
+	})
+}
+
+// PendingCodeAt 获取 pending 状态下的合约代码
+func (p *Pool) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return call(ctx, p, "eth_getCode", func(c *ethclient.Client) ([]byte, error) {
+		return c.PendingCodeAt(ctx, account)
+	})
+}
+
+// PendingNonceAt 获取 pending nonce
+func (p *Pool) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return call(ctx, p, "eth_getTransactionCount", func(c *ethclient.Client) (uint64, error) {
+		return c.PendingNonceAt(ctx, account)
+	})
+}
+
+// NonceAt 获取指定区块的 nonce（blockNumber 为 nil 时为最新区块）
+func (p *Pool) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return call(ctx, p, "eth_getTransactionCount", func(c *ethclient.Client) (uint64, error) {
+		return c.NonceAt(ctx, account, blockNumber)
+	})
+}
+
+// SuggestGasPrice 建议 gas 价格
+func (p *Pool) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, "eth_gasPrice", func(c *ethclient.Client) (*big.Int, error) {
+		return c.SuggestGasPrice(ctx)
+	})
+}
+
+// SuggestGasTipCap 建议 EIP-1559 小费
+func (p *Pool) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, "eth_maxPriorityFeePerGas", func(c *ethclient.Client) (*big.Int, error) {
+		return c.SuggestGasTipCap(ctx)
+	})
+}
+
+// EstimateGas 估算 gas
+func (p *Pool) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return call(ctx, p, "eth_estimateGas", func(c *ethclient.Client) (uint64, error) {
+		return c.EstimateGas(ctx, msg)
+	})
+}
+
+// SendTransaction 广播交易
+func (p *Pool) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	_, err := call(ctx, p, "eth_sendRawTransaction", func(c *ethclient.Client) (struct{}, error) {
+		return struct{}{}, c.SendTransaction(ctx, tx)
+	})
+	return err
+}
+
+// FilterLogs 查询日志
+func (p *Pool) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return call(ctx, p, "eth_getLogs", func(c *ethclient.Client) ([]types.Log, error) {
+		return c.FilterLogs(ctx, query)
+	})
+}
+
+// SubscribeFilterLogs 订阅日志（只在 WebSocket 节点上进行）
+func (p *Pool) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	var lastErr error = ErrNoSubscriptionEndpoint
+	for _, ep := range p.candidates(true) {
+		sub, err := ep.getClient().SubscribeFilterLogs(ctx, query, ch)
+		if err == nil {
+			ep.record(nil)
+			log.Printf("✅ 使用RPC节点 %s 订阅日志", ep.Name)
+			return sub, nil
+		}
+		ep.record(err)
+		lastErr = err
+		log.Printf("⚠️ RPC节点 %s 订阅失败，切换节点: %v", ep.Name, err)
+	}
+	return nil, lastErr
+}
+
+// ==================== 其他常用方法 ====================
+
+// BlockNumber 最新区块号
+func (p *Pool) BlockNumber(ctx context.Context) (uint64, error) {
+	return call(ctx, p, "eth_blockNumber", func(c *ethclient.Client) (uint64, error) {
+		return c.BlockNumber(ctx)
+	})
+}
+
+// NetworkID 网络ID
+func (p *Pool) NetworkID(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, "net_version", func(c *ethclient.Client) (*big.Int, error) {
+		return c.NetworkID(ctx)
+	})
+}
+
+// ChainID 链ID
+func (p *Pool) ChainID(ctx context.Context) (*big.Int, error) {
+	return call(ctx, p, "eth_chainId", func(c *ethclient.Client) (*big.Int, error) {
+		return c.ChainID(ctx)
+	})
+}
+
+// BalanceAt 账户ETH余额
+func (p *Pool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return call(ctx, p, "eth_getBalance", func(c *ethclient.Client) (*big.Int, error) {
+		return c.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+// TransactionReceipt 交易回执
+func (p *Pool) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return call(ctx, p, "eth_getTransactionReceipt", func(c *ethclient.Client) (*types.Receipt, error) {
+		return c.TransactionReceipt(ctx, txHash)
+	})
+}
+
+// TransactionByHash 根据哈希获取交易
+func (p *Pool) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	type result struct {
+		tx      *types.Transaction
+		pending bool
+	}
+	r, err := call(ctx, p, "eth_getTransactionByHash", func(c *ethclient.Client) (result, error) {
+		tx, pending, err := c.TransactionByHash(ctx, txHash)
+		return result{tx, pending}, err
+	})
+	return r.tx, r.pending, err
+}
@@ -0,0 +1,250 @@
+package rpcpool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"nft-auction-backend/internal/config"
+)
+
+// fakeNode 只实现 eth_blockNumber 的 JSON-RPC 节点，failing 时返回 503
+type fakeNode struct {
+	head     atomic.Uint64
+	delay    time.Duration
+	failing  atomic.Bool
+	requests atomic.Int64
+	server   *httptest.Server
+}
+
+func newFakeNode(t *testing.T, head uint64, delay time.Duration) *fakeNode {
+	t.Helper()
+	n := &fakeNode{delay: delay}
+	n.head.Store(head)
+	n.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.requests.Add(1)
+		time.Sleep(n.delay)
+		if n.failing.Load() {
+			http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method != "eth_blockNumber" {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"method not found"}}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":"0x%x"}`, req.ID, n.head.Load())
+	}))
+	t.Cleanup(n.server.Close)
+	return n
+}
+
+func newTestPool(t *testing.T, endpoints ...config.RPCEndpointConfig) *Pool {
+	t.Helper()
+	p, err := NewPool(config.BlockchainConfig{
+		RPCEndpoints:        endpoints,
+		HealthCheckInterval: time.Hour, // 测试中手动调用 checkAll
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func endpointByName(p *Pool, name string) *Endpoint {
+	for _, ep := range p.endpoints {
+		if ep.Name == name {
+			return ep
+		}
+	}
+	return nil
+}
+
+// 优先节点故障时请求切换到下一个节点，故障计入优先节点
+func TestCallFailsOverToNextEndpoint(t *testing.T) {
+	primary := newFakeNode(t, 100, 0)
+	backup := newFakeNode(t, 100, 20*time.Millisecond) // 延迟更高，排在后面
+	p := newTestPool(t,
+		config.RPCEndpointConfig{Name: "primary", URL: primary.server.URL},
+		config.RPCEndpointConfig{Name: "backup", URL: backup.server.URL},
+	)
+	if got := p.candidates(false); len(got) != 2 || got[0].Name != "primary" {
+		t.Fatalf("candidates before failure: %v", got)
+	}
+
+	primary.failing.Store(true)
+	backup.head.Store(101)
+	head, err := p.BlockNumber(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != 101 {
+		t.Fatalf("head = %d, want backup's 101", head)
+	}
+	if status := endpointByName(p, "primary").Status(); status.Failures != 1 || status.LastError == "" {
+		t.Fatalf("primary status after failover: %+v", status)
+	}
+}
+
+// 所有节点都故障时返回最后一个节点的错误
+func TestCallAllEndpointsFail(t *testing.T) {
+	node := newFakeNode(t, 100, 0)
+	p := newTestPool(t, config.RPCEndpointConfig{Name: "only", URL: node.server.URL})
+
+	node.failing.Store(true)
+	_, err := p.BlockNumber(context.Background())
+	var httpErr rpc.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want HTTP 503", err)
+	}
+}
+
+// 探测失败的节点标记为不健康并退出候选，恢复后重新加入
+func TestEndpointUnhealthyAndRecovers(t *testing.T) {
+	flaky := newFakeNode(t, 100, 0)
+	stable := newFakeNode(t, 100, 0)
+	p := newTestPool(t,
+		config.RPCEndpointConfig{Name: "flaky", URL: flaky.server.URL},
+		config.RPCEndpointConfig{Name: "stable", URL: stable.server.URL},
+	)
+	ep := endpointByName(p, "flaky")
+	if !ep.Status().Healthy {
+		t.Fatal("flaky should start healthy")
+	}
+
+	flaky.failing.Store(true)
+	p.checkAll()
+	if ep.Status().Healthy {
+		t.Fatal("flaky should be unhealthy after a failed probe")
+	}
+	for _, c := range p.candidates(false) {
+		if c == ep {
+			t.Fatal("unhealthy endpoint still a candidate")
+		}
+	}
+
+	flaky.failing.Store(false)
+	p.checkAll()
+	if !ep.Status().Healthy {
+		t.Fatalf("flaky should recover: %+v", ep.Status())
+	}
+	if got := p.candidates(false); len(got) != 2 {
+		t.Fatalf("candidates after recovery: %d, want 2", len(got))
+	}
+}
+
+// 落后最高节点太多的节点不健康
+func TestEndpointUnhealthyWhenLagging(t *testing.T) {
+	ahead := newFakeNode(t, 200, 0)
+	behind := newFakeNode(t, 100, 0)
+	p, err := NewPool(config.BlockchainConfig{
+		RPCEndpoints: []config.RPCEndpointConfig{
+			{Name: "ahead", URL: ahead.server.URL},
+			{Name: "behind", URL: behind.server.URL},
+		},
+		HealthCheckInterval: time.Hour,
+		MaxBlockLag:         10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if endpointByName(p, "behind").Status().Healthy || !endpointByName(p, "ahead").Status().Healthy {
+		t.Fatal("lagging endpoint should be unhealthy")
+	}
+}
+
+// 没有健康节点时退化为全部已连接节点
+func TestCandidatesFallBackToConnected(t *testing.T) {
+	node := newFakeNode(t, 100, 0)
+	p := newTestPool(t, config.RPCEndpointConfig{Name: "only", URL: node.server.URL})
+
+	node.failing.Store(true)
+	p.checkAll()
+	if got := p.candidates(false); len(got) != 1 {
+		t.Fatalf("candidates = %d, want the connected endpoint", len(got))
+	}
+}
+
+// 每个节点独立限流：超出速率的请求等待，ctx 取消时不再发出请求
+func TestEndpointRateLimit(t *testing.T) {
+	node := newFakeNode(t, 100, 0)
+	p := newTestPool(t, config.RPCEndpointConfig{Name: "limited", URL: node.server.URL, RateLimit: 20, Burst: 1})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := p.BlockNumber(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 突发1个，之后每 50ms 一个
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("5 calls took %s, limiter not applied", elapsed)
+	}
+
+	slow := newFakeNode(t, 100, 0)
+	p = newTestPool(t, config.RPCEndpointConfig{Name: "slow", URL: slow.server.URL, RateLimit: 0.1, Burst: 1})
+	if _, err := p.BlockNumber(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before := slow.requests.Load()
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.BlockNumber(timeout); err == nil {
+		t.Fatal("rate-limited call should fail when ctx expires")
+	}
+	if slow.requests.Load() != before {
+		t.Fatal("rate-limited call reached the node")
+	}
+}
+
+// rpcError 节点返回的 JSON-RPC 错误
+type rpcError struct{ code int }
+
+func (e rpcError) Error() string  { return fmt.Sprintf("rpc error %d", e.code) }
+func (e rpcError) ErrorCode() int { return e.code }
+
+func TestIsEndpointError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"canceled", context.Canceled, false},
+		{"not found", ethereum.NotFound, false},
+		{"wrapped not found", fmt.Errorf("receipt: %w", ethereum.NotFound), false},
+		{"http 429", rpc.HTTPError{StatusCode: 429, Status: "429 Too Many Requests"}, true},
+		{"http 503", rpc.HTTPError{StatusCode: 503, Status: "503 Service Unavailable"}, true},
+		{"limit exceeded", rpcError{-32005}, true},
+		{"execution reverted", rpcError{3}, false},
+		{"invalid params", rpcError{-32602}, false},
+		{"network", errors.New("dial tcp 127.0.0.1:8545: connect: connection refused"), true},
+		{"deadline", context.DeadlineExceeded, true},
+	}
+	for _, tc := range cases {
+		if got := isEndpointError(tc.err); got != tc.want {
+			t.Errorf("%s: isEndpointError(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}